@@ -21,14 +21,166 @@ import (
 )
 
 var (
-	TypeInstalled = "Installed"
+	TypeInstalled           = "Installed"
+	TypeSubscriptionAdopted = "SubscriptionAdopted"
+	TypeDependencies        = "DependenciesResolved"
+	TypeResolved            = "Resolved"
+	TypeCandidatesFiltered  = "CandidatesFiltered"
+	TypeDeprecated          = "Deprecated"
+	TypeUpdatesAvailable    = "UpdatesAvailable"
+	TypeImagePinned         = "ImagePinned"
+	TypeSignatureVerified   = "SignatureVerified"
+	TypePreflightPassed     = "PreflightPassed"
+	TypeUpgradeable         = "Upgradeable"
+	TypeAvailable           = "Available"
+	TypeDegraded            = "Degraded"
+	TypeRecreated           = "Recreated"
 
 	ReasonSourceFailed  = "SourceFailed"
 	ReasonUnpackPending = "UnpackPending"
+	ReasonUnpackTimeout = "UnpackTimeout"
 
 	ReasonInstallFailed     = "InstallFailed"
 	ReasonInstallSuccessful = "InstallSuccessful"
 	ReasonInstallPending    = "InstallPending"
+	ReasonOwnershipConflict = "OwnershipConflict"
+
+	ReasonAdoptionSuccessful       = "AdoptionSuccessful"
+	ReasonAdoptionPending          = "AdoptionPending"
+	ReasonSubscriptionNotFound     = "SubscriptionNotFound"
+	ReasonInstalledVersionNotFound = "InstalledVersionNotFound"
+	ReasonRetireFailed             = "RetireFailed"
+
+	ReasonDependenciesSatisfied     = "DependenciesSatisfied"
+	ReasonDependenciesPending       = "DependenciesPending"
+	ReasonDependenciesNotInstalled  = "DependenciesNotInstalled"
+	ReasonDependenciesUnsatisfiable = "DependenciesUnsatisfiable"
+
+	ReasonResolutionSuccessful = "ResolutionSuccessful"
+	ReasonResolutionFailed     = "ResolutionFailed"
+
+	ReasonCandidatesRejected = "CandidatesRejected"
+	ReasonCandidatesAccepted = "CandidatesAccepted"
+
+	ReasonDeprecated    = "Deprecated"
+	ReasonNotDeprecated = "NotDeprecated"
+
+	ReasonUpdatesAvailable   = "UpdatesAvailable"
+	ReasonNoUpdatesAvailable = "NoUpdatesAvailable"
+	ReasonUpdatesUnknown     = "UpdatesUnknown"
+
+	ReasonImagePinned    = "ImagePinned"
+	ReasonImagePinFailed = "ImagePinFailed"
+
+	ReasonSignatureVerified    = "SignatureVerified"
+	ReasonSignatureNotVerified = "SignatureNotVerified"
+
+	ReasonPreflightPassed = "PreflightPassed"
+	ReasonPreflightFailed = "PreflightFailed"
+
+	ReasonUpgradeApplied         = "UpgradeApplied"
+	ReasonUpgradeBlocked         = "UpgradeBlocked"
+	ReasonUpgradeApprovalPending = "UpgradeApprovalPending"
+
+	ReasonUnpackDeadlineExceeded  = "UnpackDeadlineExceeded"
+	ReasonInstallDeadlineExceeded = "InstallDeadlineExceeded"
+	ReasonStuckInstallRecreated   = "StuckInstallRecreated"
+
+	ReasonWorkloadsAvailable   = "WorkloadsAvailable"
+	ReasonWorkloadsProgressing = "WorkloadsProgressing"
+	ReasonCrashLoopBackOff     = "CrashLoopBackOff"
+	ReasonRolloutFailed        = "RolloutFailed"
+	ReasonReplicasUnavailable  = "ReplicasUnavailable"
+)
+
+const (
+	// AnnotationCatalog selects which of the configured catalogs a PlatformOperator
+	// is sourced from. The default CatalogSource is used when it's unset.
+	AnnotationCatalog = "platform.openshift.io/catalog"
+
+	// AnnotationAdoptSubscription opts a PlatformOperator into adopting the
+	// OLM Subscription that's already installing its package, when set to "true".
+	AnnotationAdoptSubscription = "platform.openshift.io/adopt-subscription"
+
+	// AnnotationVersionRange restricts the bundles a PlatformOperator can be
+	// sourced from to the versions within a semver range, e.g. ">=1.2.0 <2.0.0".
+	AnnotationVersionRange = "platform.openshift.io/version-range"
+
+	// AnnotationChannel restricts the bundles a PlatformOperator can be
+	// sourced from to the bundles in a single channel of its package.
+	AnnotationChannel = "platform.openshift.io/channel"
+
+	// AnnotationAllowPrereleases opts a PlatformOperator, and the
+	// dependencies resolved for it, into bundles with prerelease versions,
+	// e.g. 1.2.0-rc.1, when set to "true".
+	AnnotationAllowPrereleases = "platform.openshift.io/allow-prereleases"
+
+	// AnnotationPullSecret references the <namespace>/<name> of a
+	// kubernetes.io/dockerconfigjson Secret holding the credentials for a
+	// PlatformOperator's bundle images, in addition to the cluster's global
+	// pull secret and the catalog's secrets. The Secret must be in the
+	// namespace the platform operators manager runs in.
+	AnnotationPullSecret = "platform.openshift.io/pull-secret"
+
+	// AnnotationDependencyPolicy controls how a PlatformOperator's missing
+	// dependencies are handled. Set to DependencyPolicyInstall to create
+	// managed PlatformOperators for them, otherwise the installation is refused.
+	AnnotationDependencyPolicy = "platform.openshift.io/dependency-policy"
+
+	// AnnotationUpgradePolicy controls whether a PlatformOperator's installed
+	// bundle is upgraded. Set to UpgradePolicyManual to upgrade it to the most
+	// preferred update once that update is approved through
+	// AnnotationApprovedBundle and passes the preflight checks. Otherwise the
+	// installed bundle is kept.
+	AnnotationUpgradePolicy = "platform.openshift.io/upgrade-policy"
+
+	// AnnotationApprovedBundle approves the upgrade of a PlatformOperator with
+	// the UpgradePolicyManual policy to the bundle it names.
+	AnnotationApprovedBundle = "platform.openshift.io/approved-bundle"
+
+	// AnnotationRequiredBy lists the PlatformOperators a managed dependency
+	// PlatformOperator was created for.
+	AnnotationRequiredBy = "platform.openshift.io/required-by"
+
+	// AnnotationBundleName, AnnotationBundleVersion, AnnotationBundleChannel
+	// and AnnotationBundleImage record the bundle an installation object was
+	// built from.
+	AnnotationBundleName    = "platform.openshift.io/bundle-name"
+	AnnotationBundleVersion = "platform.openshift.io/bundle-version"
+	AnnotationBundleChannel = "platform.openshift.io/bundle-channel"
+	AnnotationBundleImage   = "platform.openshift.io/bundle-image"
+
+	// AnnotationRecoveryPolicy controls what happens to a PlatformOperator's
+	// installation object once it's stuck unpacking or installing its bundle
+	// past the deadline the manager is configured with. Set to
+	// RecoveryPolicyRecreate to delete it, so it's recreated, with the
+	// deadline doubling with each attempt. Otherwise the PlatformOperator is
+	// only reported as Degraded.
+	AnnotationRecoveryPolicy = "platform.openshift.io/recovery-policy"
+
+	// LabelManagedDependency marks PlatformOperators that were created to
+	// satisfy the dependencies of another PlatformOperator.
+	LabelManagedDependency = "platform.openshift.io/managed-dependency"
+
+	// LabelAggregateToCustomResourceReader adds the rules of the ClusterRole it's
+	// set to "true" on to those of the platform operators manager, which lists
+	// the custom resources of a PlatformOperator's CustomResourceDefinitions
+	// to check that an upgrade doesn't break them.
+	LabelAggregateToCustomResourceReader = "platform.openshift.io/aggregate-to-custom-resource-reader"
+)
+
+const (
+	DependencyPolicyInstall = "Install"
+	DependencyPolicyRefuse  = "Refuse"
+)
+
+const (
+	UpgradePolicyManual = "Manual"
+)
+
+const (
+	RecoveryPolicyRecreate = "Recreate"
+	RecoveryPolicyNone     = "None"
 )
 
 // SetActiveBundleDeployment is responsible for populating the status.ActiveBundleDeployment
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/openshift/platform-operators/internal/bundlediff"
+	"github.com/openshift/platform-operators/internal/images"
+	"github.com/openshift/platform-operators/internal/preflight"
+)
+
+// runDiff implements the diff subcommand, which prints the changes between
+// the manifests of an installed bundle image and a candidate bundle image,
+// so an upgrade can be reviewed before it's approved. It returns the exit
+// code.
+func runDiff(args []string) int {
+	fs := flag.NewFlagSet("diff", flag.ContinueOnError)
+	dockerConfig := fs.String("docker-config", "", "The path to a docker config.json file with the credentials for the bundle images.")
+	output := fs.String("output", "text", `The format the diff is printed in. One of "text" or "json".`)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s diff [flags] <installed-bundle-image> <candidate-bundle-image>\n\n", os.Args[0])
+		fmt.Fprintf(fs.Output(), "Prints the changes to RBAC, CRDs, Deployments, webhooks and other manifests between two bundle images.\n\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 2 || (*output != "text" && *output != "json") {
+		fs.Usage()
+		return 2
+	}
+
+	if err := diffBundleImages(context.Background(), fs.Arg(0), fs.Arg(1), *dockerConfig, *output); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+func diffBundleImages(ctx context.Context, from, to, dockerConfig, output string) error {
+	client, err := newRegistryHTTPClient(nil)
+	if err != nil {
+		return err
+	}
+	registry := images.NewRegistry(client)
+	if dockerConfig != "" {
+		data, err := os.ReadFile(dockerConfig)
+		if err != nil {
+			return err
+		}
+		keychain, err := images.ParseDockerConfig(data)
+		if err != nil {
+			return fmt.Errorf("failed to parse %s: %w", dockerConfig, err)
+		}
+		registry = registry.WithKeychain(keychain)
+	}
+
+	var bundles []*preflight.Bundle
+	for _, image := range []string{from, to} {
+		files, err := registry.Unpack(ctx, image, "manifests")
+		if err != nil {
+			return fmt.Errorf("failed to unpack the manifests of %s: %w", image, err)
+		}
+		b, err := preflight.ParseBundle(image, files)
+		if err != nil {
+			return err
+		}
+		bundles = append(bundles, b)
+	}
+	diff, err := bundlediff.Compute(bundles[0], bundles[1])
+	if err != nil {
+		return err
+	}
+
+	if output == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(diff)
+	}
+	_, err = fmt.Fprint(os.Stdout, diff.String())
+	return err
+}
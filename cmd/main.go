@@ -17,26 +17,48 @@ limitations under the License.
 package main
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"flag"
+	"fmt"
+	"net/http"
 	"os"
+	"strings"
+	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
 	configv1 "github.com/openshift/api/config/v1"
+	operatorv1alpha1 "github.com/openshift/api/operator/v1alpha1"
 	operatorsv1alpha1 "github.com/operator-framework/api/pkg/operators/v1alpha1"
 	rukpakv1alpha2 "github.com/operator-framework/rukpak/api/v1alpha2"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/selection"
+	"k8s.io/apimachinery/pkg/types"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/kubernetes"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 
 	platformv1alpha1 "github.com/openshift/api/platform/v1alpha1"
+	"github.com/openshift/platform-operators/internal/applier"
+	"github.com/openshift/platform-operators/internal/clusterfacts"
 	"github.com/openshift/platform-operators/internal/clusteroperator"
 	"github.com/openshift/platform-operators/internal/controllers"
+	"github.com/openshift/platform-operators/internal/images"
+	"github.com/openshift/platform-operators/internal/preflight"
+	"github.com/openshift/platform-operators/internal/resolution"
 	"github.com/openshift/platform-operators/internal/sourcer"
 	"github.com/openshift/platform-operators/internal/util"
 	//+kubebuilder:scaffold:imports
@@ -53,15 +75,42 @@ func init() {
 	utilruntime.Must(rukpakv1alpha2.AddToScheme(scheme))
 	utilruntime.Must(platformv1alpha1.Install(scheme))
 	utilruntime.Must(configv1.AddToScheme(scheme))
+	utilruntime.Must(operatorv1alpha1.AddToScheme(scheme))
+	utilruntime.Must(apiextensionsv1.AddToScheme(scheme))
 	//+kubebuilder:scaffold:scheme
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "diff" {
+		os.Exit(runDiff(os.Args[2:]))
+	}
+
 	var (
 		metricsAddr          string
 		enableLeaderElection bool
 		probeAddr            string
 		systemNamespace      string
+		catalogOpts          = sourcer.DefaultConnectionOptions()
+		catalogCache         bool
+		fileBasedCatalogs    = catalogFlag{}
+		clusterCatalogs      bool
+		clusterCatalogCAFile string
+		applierBackend       string
+		clusterExtensionOpts applier.ClusterExtensionOptions
+		requireFeatures      bool
+		pinBundleImages      bool
+		rewriteToMirrors     bool
+		signatureKeys        = stringsFlag{}
+		signatureMode        string
+		pullSecretNamespace  string
+		unpackNamespace      string
+		globalPullSecret     string
+		runPreflight         bool
+		permissionPolicy     string
+		workloadGracePeriod  time.Duration
+		unpackTimeout        time.Duration
+		unpackDeadline       time.Duration
+		installDeadline      time.Duration
 	)
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
@@ -69,6 +118,30 @@ func main() {
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
 	flag.StringVar(&systemNamespace, "system-namespace", "openshift-platform-operators", "Configures the namespace that gets used to deploy system resources.")
+	flag.DurationVar(&catalogOpts.DialTimeout, "catalog-dial-timeout", catalogOpts.DialTimeout, "The maximum amount of time spent establishing a connection to a catalog registry server.")
+	flag.DurationVar(&catalogOpts.CallTimeout, "catalog-call-timeout", catalogOpts.CallTimeout, "The maximum amount of time a single call to a catalog registry server may take. Calls that time out aren't retried until the platform operator is reconciled again.")
+	flag.IntVar(&catalogOpts.Backoff.Steps, "catalog-call-retries", catalogOpts.Backoff.Steps, "The number of attempts made when dialing or calling a catalog registry server fails.")
+	flag.BoolVar(&catalogCache, "catalog-cache", true, "Cache an index of each catalog's contents instead of querying the catalog registry server for every platform operator.")
+	flag.Var(fileBasedCatalogs, "file-based-catalog", "A <name>=<directory> pair that serves the file-based catalog stored in directory to platform operators selecting the name catalog. May be specified multiple times.")
+	flag.BoolVar(&clusterCatalogs, "cluster-catalogs", false, "Source platform operators that select a ClusterCatalog by name from the content served by catalogd.")
+	flag.StringVar(&clusterCatalogCAFile, "cluster-catalog-ca-file", "", "The path to a PEM encoded CA bundle used to verify catalogd's serving certificate.")
+	flag.StringVar(&applierBackend, "applier-backend", applier.BackendBundleDeployment, fmt.Sprintf("The API used to install platform operators. One of %q or %q. The %q backend sources platform operators from ClusterCatalogs only, and leaves pulling the bundle image to OLM v1.", applier.BackendBundleDeployment, applier.BackendClusterExtension, applier.BackendClusterExtension))
+	flag.StringVar(&clusterExtensionOpts.Namespace, "cluster-extension-namespace", "openshift-platform-operators", "The namespace ClusterExtensions install platform operators into.")
+	flag.StringVar(&clusterExtensionOpts.ServiceAccount, "cluster-extension-service-account", "platform-operators-installer", "The ServiceAccount ClusterExtensions use to install platform operators.")
+	flag.BoolVar(&requireFeatures, "require-infrastructure-features", false, "Only install bundles that declare support for the infrastructure features the cluster uses, i.e. FIPS mode, a cluster-wide proxy and image mirroring, through their features.operators.openshift.io annotations.")
+	flag.BoolVar(&pinBundleImages, "pin-bundle-images", false, "Resolve bundle images to digests before installing them, so moving tags don't change what's installed. The manager contacts the bundle images' registries itself to do so. Only applies to the BundleDeployment applier backend.")
+	flag.BoolVar(&rewriteToMirrors, "rewrite-bundle-images-to-mirrors", false, "Install pinned bundle images from the first mirror that serves them, according to the cluster's image mirroring policies. Requires --pin-bundle-images. Only applies to the BundleDeployment applier backend.")
+	flag.Var(&signatureKeys, "signature-public-key", "The path to a PEM encoded public key that bundle image signatures are verified against. May be specified multiple times. Signatures aren't verified when unset. Only applies to the BundleDeployment applier backend.")
+	flag.StringVar(&signatureMode, "signature-verification-mode", images.ModeEnforce, fmt.Sprintf("How bundle images whose signatures can't be verified are handled. %q refuses to install them and %q only reports them.", images.ModeEnforce, images.ModeWarn))
+	flag.StringVar(&pullSecretNamespace, "pull-secret-namespace", "openshift-rukpak", "The namespace platform operators' pull secrets are propagated to, which rukpak unpacks bundle images in. Only applies to the BundleDeployment applier backend. Set to \"\" to not propagate pull secrets.")
+	flag.StringVar(&unpackNamespace, "unpack-namespace", "openshift-rukpak", "The namespace rukpak runs the pods that unpack bundle images in, whose status and logs are reported when unpacking fails. Reading and deleting pods is only granted in openshift-rukpak, so other namespaces need an equivalent Role. Only applies to the BundleDeployment applier backend. Set to \"\" to disable.")
+	flag.BoolVar(&runPreflight, "preflight", false, "Check the manifests of bundle images for conflicts with the cluster before installing or upgrading them, and summarize the changes of upgrades waiting for approval. The manager pulls and unpacks every bundle image itself to do so. Only applies to the BundleDeployment applier backend.")
+	flag.StringVar(&permissionPolicy, "preflight-permission-policy", "", "The path to a YAML file listing the cluster permissions bundles may not request. Defaults to denying cluster-admin equivalent and privilege escalating permissions.")
+	flag.StringVar(&globalPullSecret, "global-pull-secret", "openshift-config/pull-secret", "The <namespace>/<name> of the cluster's global pull secret, whose credentials are used for every platform operator's bundle images. Set to an empty string to ignore it.")
+	flag.DurationVar(&unpackTimeout, "unpack-timeout", 0, "How long unpacking a bundle image may take before it's retried, by deleting the pod unpacking it. Only applies to the BundleDeployment applier backend. Unpacks are never retried when unset.")
+	flag.DurationVar(&unpackDeadline, "unpack-deadline", 0, "How long a BundleDeployment may take to unpack its bundle, including retries, before its platform operator is reported as degraded and recovered according to its recovery policy, which may delete and recreate the BundleDeployment. Only applies to the BundleDeployment applier backend. Stuck unpacks aren't reported or recovered when unset.")
+	flag.DurationVar(&installDeadline, "install-deadline", 0, "How long a BundleDeployment may take to install its unpacked bundle before its platform operator is reported as degraded and recovered according to its recovery policy, which may delete and recreate the BundleDeployment. Only applies to the BundleDeployment applier backend. Stuck installs aren't reported or recovered when unset.")
+	flag.DurationVar(&workloadGracePeriod, "workload-grace-period", 5*time.Minute, "How long the workloads of an installed platform operator may be unavailable after their rollout last made progress, before the platform operator reports that it's unavailable.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -77,20 +150,158 @@ func main() {
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
+	var globalPullSecretKey types.NamespacedName
+	if globalPullSecret != "" {
+		namespace, name, ok := strings.Cut(globalPullSecret, "/")
+		if !ok {
+			setupLog.Error(fmt.Errorf("expected <namespace>/<name>, got %q", globalPullSecret), "invalid global pull secret")
+			os.Exit(1)
+		}
+		globalPullSecretKey = types.NamespacedName{Namespace: namespace, Name: name}
+	}
+	if applierBackend != applier.BackendBundleDeployment {
+		pullSecretNamespace = ""
+		unpackNamespace = ""
+	}
+
+	// PlatformOperators may only reference pull secrets from the namespace
+	// the manager runs in.
+	referencedSecretNamespace := util.PodNamespace(systemNamespace)
+	// only the Secrets in the namespaces pull secrets are read from and
+	// propagated to are watched.
+	secretNamespaces := map[string]cache.Config{
+		referencedSecretNamespace:                   {},
+		sourcer.DefaultCatalogSourceKey().Namespace: {},
+	}
+	if globalPullSecretKey.Namespace != "" {
+		secretNamespaces[globalPullSecretKey.Namespace] = cache.Config{}
+	}
+	if pullSecretNamespace != "" {
+		secretNamespaces[pullSecretNamespace] = cache.Config{}
+	}
+
+	// only the Deployments installed by platform operators are cached, which
+	// are labeled with the name of the installation object that manages them.
+	installed, err := labels.NewRequirement(applier.OwnerLabel(applierBackend), selection.Exists, nil)
+	if err != nil {
+		setupLog.Error(err, "unable to configure the deployment cache")
+		os.Exit(1)
+	}
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme:                 scheme,
 		HealthProbeBindAddress: probeAddr,
 		LeaderElection:         enableLeaderElection,
 		LeaderElectionID:       "ffdf93bc.openshift.io",
+		Cache: cache.Options{
+			ByObject: map[client.Object]cache.ByObject{
+				&corev1.Secret{}:     {Namespaces: secretNamespaces},
+				&appsv1.Deployment{}: {Label: labels.NewSelector().Add(*installed)},
+			},
+		},
 	})
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
 		os.Exit(1)
 	}
 
+	catalogs := make(map[string]sourcer.Sourcer)
+	for name, dir := range fileBasedCatalogs {
+		catalogs[name] = sourcer.NewFileBasedCatalogHandler(dir)
+	}
+
+	var clusterCatalogSourcer sourcer.CatalogSet
+	if clusterCatalogs {
+		httpClient, err := newCatalogdHTTPClient(clusterCatalogCAFile)
+		if err != nil {
+			setupLog.Error(err, "unable to configure the catalogd client")
+			os.Exit(1)
+		}
+		clusterCatalogSourcer = sourcer.NewClusterCatalogHandler(mgr.GetClient(), httpClient)
+	}
+
+	poApplier, err := applier.New(mgr.GetClient(), applierBackend, clusterExtensionOpts)
+	if err != nil {
+		setupLog.Error(err, "unable to configure the applier")
+		os.Exit(1)
+	}
+
+	registryClient, err := newRegistryHTTPClient(mgr.GetAPIReader())
+	if err != nil {
+		setupLog.Error(err, "unable to configure the registry client")
+		os.Exit(1)
+	}
+	// bundle images are unpacked and verified through the same mirrors the
+	// cluster pulls them from.
+	registry := images.NewRegistry(registryClient).WithMirrors(mgr.GetClient())
+	var pinner *images.Pinner
+	if pinBundleImages && applierBackend == applier.BackendBundleDeployment {
+		pinner = images.NewPinner(mgr.GetClient(), registry, rewriteToMirrors)
+	}
+	var verifier *images.Verifier
+	if len(signatureKeys) != 0 {
+		if applierBackend != applier.BackendBundleDeployment {
+			setupLog.Error(fmt.Errorf("the %s applier backend doesn't install bundle images", applierBackend), "unable to verify bundle signatures")
+			os.Exit(1)
+		}
+		keys, err := images.LoadPublicKeys(signatureKeys...)
+		if err != nil {
+			setupLog.Error(err, "unable to load the signature public keys")
+			os.Exit(1)
+		}
+		if verifier, err = images.NewVerifier(registry, keys, signatureMode); err != nil {
+			setupLog.Error(err, "unable to configure signature verification")
+			os.Exit(1)
+		}
+	}
+
+	var bundleRegistry *images.Registry
+	policy := preflight.DefaultPermissionPolicy()
+	if runPreflight && applierBackend == applier.BackendBundleDeployment {
+		bundleRegistry = registry
+		if permissionPolicy != "" {
+			if policy, err = preflight.LoadPermissionPolicy(permissionPolicy); err != nil {
+				setupLog.Error(err, "unable to load the preflight permission policy")
+				os.Exit(1)
+			}
+		}
+	}
+
+	// OLM v1 resolves the version a ClusterExtension is pinned to against
+	// ClusterCatalogs, so it must be sourced from ClusterCatalogs as well.
+	defaultSourcer := sourcer.NewCatalogSourceHandler(mgr.GetClient(), catalogOpts, catalogCache)
+	if applierBackend == applier.BackendClusterExtension {
+		if !clusterCatalogs || len(fileBasedCatalogs) != 0 {
+			setupLog.Error(fmt.Errorf("the %s applier backend requires --cluster-catalogs and doesn't support --file-based-catalog", applierBackend), "unable to configure the applier")
+			os.Exit(1)
+		}
+		defaultSourcer = clusterCatalogSourcer
+	}
+
+	poSourcer := sourcer.NewCatalogRouter(defaultSourcer, catalogs, clusterCatalogSourcer)
 	if err = (&controllers.PlatformOperatorReconciler{
-		Client:  mgr.GetClient(),
-		Sourcer: sourcer.NewCatalogSourceHandler(mgr.GetClient()),
+		Client:     mgr.GetClient(),
+		Sourcer:    poSourcer,
+		Resolver:   resolution.NewResolver(poSourcer, clusterfacts.NewGatherer(mgr.GetClient(), mgr.GetAPIReader(), discovery.NewDiscoveryClientForConfigOrDie(mgr.GetConfig())), requireFeatures),
+		Applier:    poApplier,
+		Images:     pinner,
+		Signatures: verifier,
+
+		Preflight:        bundleRegistry,
+		PermissionPolicy: policy,
+
+		APIReader:                 mgr.GetAPIReader(),
+		GlobalPullSecret:          globalPullSecretKey,
+		PullSecretNamespace:       pullSecretNamespace,
+		ReferencedSecretNamespace: referencedSecretNamespace,
+
+		UnpackNamespace: unpackNamespace,
+		UnpackTimeout:   unpackTimeout,
+		PodLogs:         kubernetes.NewForConfigOrDie(mgr.GetConfig()).CoreV1(),
+		UnpackDeadline:  unpackDeadline,
+		InstallDeadline: installDeadline,
+
+		WorkloadGracePeriod:  workloadGracePeriod,
+		WatchClusterCatalogs: clusterCatalogs,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "PlatformOperator")
 		os.Exit(1)
@@ -122,3 +333,76 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// newCatalogdHTTPClient returns a client for streaming catalogd content that
+// trusts the CA bundle stored at caFile, in addition to the system roots.
+func newCatalogdHTTPClient(caFile string) (*http.Client, error) {
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		return nil, err
+	}
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", caFile)
+		}
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{
+		RootCAs:    pool,
+		MinVersion: tls.VersionTLS12,
+	}
+	return &http.Client{
+		Transport: transport,
+		Timeout:   5 * time.Minute,
+	}, nil
+}
+
+// newRegistryHTTPClient returns the client image registries are contacted
+// with, which trusts the system CAs and the registry CAs the cluster image
+// config adds, read through reader, when reader is set.
+func newRegistryHTTPClient(reader client.Reader) (*http.Client, error) {
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		return nil, err
+	}
+	return &http.Client{
+		Transport: images.NewTrustedTransport(reader, http.DefaultTransport.(*http.Transport), pool),
+		Timeout:   time.Minute,
+	}, nil
+}
+
+// stringsFlag collects repeated flag values.
+type stringsFlag []string
+
+func (f *stringsFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *stringsFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+// catalogFlag collects repeated <name>=<value> flag values.
+type catalogFlag map[string]string
+
+func (f catalogFlag) String() string {
+	var pairs []string
+	for name, value := range f {
+		pairs = append(pairs, name+"="+value)
+	}
+	return strings.Join(pairs, ",")
+}
+
+func (f catalogFlag) Set(s string) error {
+	name, value, ok := strings.Cut(s, "=")
+	if !ok || name == "" || value == "" {
+		return fmt.Errorf("expected a <name>=<value> pair, got %q", s)
+	}
+	f[name] = value
+	return nil
+}
@@ -0,0 +1,166 @@
+package applier
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	platformv1alpha1 "github.com/openshift/api/platform/v1alpha1"
+	platformtypes "github.com/openshift/platform-operators/api/v1alpha1"
+	"github.com/openshift/platform-operators/internal/sourcer"
+)
+
+const (
+	BackendBundleDeployment = "BundleDeployment"
+	BackendClusterExtension = "ClusterExtension"
+)
+
+// ErrOwnershipConflict is returned when the objects that have the names of a
+// PlatformOperator's installation object are controlled by other owners,
+// which are never taken over.
+var ErrOwnershipConflict = errors.New("installation object ownership conflict")
+
+// BundleDeploymentOwnerLabel and ClusterExtensionOwnerLabel are set by rukpak
+// and operator-controller on the objects they install, to the name of the
+// installation object that manages them.
+const (
+	BundleDeploymentOwnerLabel = "core.rukpak.io/owner-name"
+	ClusterExtensionOwnerLabel = "olm.operatorframework.io/owner-name"
+)
+
+// BundleDeploymentOwnerKindLabel is set by rukpak, along with
+// BundleDeploymentOwnerLabel, on the pods that unpack a BundleDeployment's
+// bundle, to the BundleDeployment kind.
+const BundleDeploymentOwnerKindLabel = "core.rukpak.io/owner-kind"
+
+// Applier installs the bundles that have been sourced for PlatformOperators
+// by managing an installation object on their behalf, and translates the
+// status of that installation object into PlatformOperator conditions.
+type Applier interface {
+	// Get returns the installation object the po PlatformOperator controls,
+	// a NotFound error when it doesn't exist, or ErrOwnershipConflict when
+	// other owners' objects have all of the names it can have.
+	Get(ctx context.Context, po *platformv1alpha1.PlatformOperator) (client.Object, error)
+	// Build returns the installation object that installs the b bundle
+	// for the po PlatformOperator, named after the PlatformOperator.
+	Build(po *platformv1alpha1.PlatformOperator, b *sourcer.Bundle) client.Object
+	// Upgrade changes the existing obj installation object in place to
+	// install the b bundle instead.
+	Upgrade(obj client.Object, b *sourcer.Bundle)
+	// Inspect returns a failing Installed condition when obj hasn't been
+	// successfully installed, or nil otherwise.
+	Inspect(ctx context.Context, obj client.Object) *metav1.Condition
+	// ObjectType returns an empty installation object, e.g. to configure
+	// watches for the installation objects managed by this Applier.
+	ObjectType() client.Object
+	// OwnerLabel returns the label that's set to the name of the installation
+	// object on the objects it installs.
+	OwnerLabel() string
+}
+
+// New returns the Applier implementation for the backend installation API.
+func New(c client.Client, backend string, opts ClusterExtensionOptions) (Applier, error) {
+	switch backend {
+	case BackendBundleDeployment:
+		return &bundleDeploymentApplier{Client: c}, nil
+	case BackendClusterExtension:
+		return &clusterExtensionApplier{Client: c, opts: opts}, nil
+	default:
+		return nil, fmt.Errorf("unsupported applier backend %q", backend)
+	}
+}
+
+// OwnerLabel returns the label the backend installation API sets to the name
+// of the installation object on the objects it installs.
+func OwnerLabel(backend string) string {
+	if backend == BackendClusterExtension {
+		return ClusterExtensionOwnerLabel
+	}
+	return BundleDeploymentOwnerLabel
+}
+
+// setBundleAnnotations records the b bundle an installation object was built
+// from, so the installed version can be read back without a catalog query.
+func setBundleAnnotations(obj client.Object, b *sourcer.Bundle) {
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	annotations[platformtypes.AnnotationBundleName] = b.Name
+	annotations[platformtypes.AnnotationBundleVersion] = b.Version
+	annotations[platformtypes.AnnotationBundleChannel] = b.Channel
+	annotations[platformtypes.AnnotationBundleImage] = b.Image
+	obj.SetAnnotations(annotations)
+}
+
+// ObjectNames returns the names the installation object of the po
+// PlatformOperator can have, in order of preference: the PlatformOperator's
+// name, and a name derived from its UID, for when another owner's object
+// already has that name.
+func ObjectNames(po *platformv1alpha1.PlatformOperator) []string {
+	sum := sha256.Sum256([]byte(po.GetUID()))
+	return []string{po.GetName(), fmt.Sprintf("%s-%x", po.GetName(), sum[:4])}
+}
+
+// getControlled returns the installation object the po PlatformOperator
+// controls, reading objects of newObject's type through c. Objects that have
+// one of its names, but that the PlatformOperator doesn't control, are never
+// returned. It returns a NotFound error when a new installation object can be
+// created, and ErrOwnershipConflict when other owners' objects have all of its
+// names.
+func getControlled(ctx context.Context, c client.Reader, po *platformv1alpha1.PlatformOperator, newObject func() client.Object) (client.Object, error) {
+	var notFound error
+	for _, name := range ObjectNames(po) {
+		obj := newObject()
+		err := c.Get(ctx, types.NamespacedName{Name: name}, obj)
+		if apierrors.IsNotFound(err) {
+			if notFound == nil {
+				notFound = err
+			}
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if metav1.IsControlledBy(obj, po) {
+			return obj, nil
+		}
+	}
+	if notFound != nil {
+		return nil, notFound
+	}
+	return nil, conflictError(po)
+}
+
+// AvailableName returns the first of the names the po PlatformOperator's
+// installation object can have that isn't taken by another owner's object,
+// reading objects of newObject's type through c, or ErrOwnershipConflict
+// when they're all taken.
+func AvailableName(ctx context.Context, c client.Reader, po *platformv1alpha1.PlatformOperator, newObject func() client.Object) (string, error) {
+	for _, name := range ObjectNames(po) {
+		obj := newObject()
+		err := c.Get(ctx, types.NamespacedName{Name: name}, obj)
+		if apierrors.IsNotFound(err) {
+			return name, nil
+		}
+		if err != nil {
+			return "", err
+		}
+		if metav1.IsControlledBy(obj, po) {
+			return name, nil
+		}
+	}
+	return "", conflictError(po)
+}
+
+func conflictError(po *platformv1alpha1.PlatformOperator) error {
+	return fmt.Errorf("the %s names are taken by objects the %s platform operator doesn't control: %w",
+		strings.Join(ObjectNames(po), " and "), po.GetName(), ErrOwnershipConflict)
+}
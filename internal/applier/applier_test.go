@@ -0,0 +1,120 @@
+package applier
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	rukpakv1alpha2 "github.com/operator-framework/rukpak/api/v1alpha2"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	platformv1alpha1 "github.com/openshift/api/platform/v1alpha1"
+)
+
+// bundleDeployments is a client.Reader serving BundleDeployments by name.
+type bundleDeployments map[string]*rukpakv1alpha2.BundleDeployment
+
+func (b bundleDeployments) Get(_ context.Context, key client.ObjectKey, obj client.Object, _ ...client.GetOption) error {
+	bd, ok := b[key.Name]
+	if !ok {
+		return apierrors.NewNotFound(schema.GroupResource{Group: "core.rukpak.io", Resource: "bundledeployments"}, key.Name)
+	}
+	bd.DeepCopyInto(obj.(*rukpakv1alpha2.BundleDeployment))
+	return nil
+}
+
+func (b bundleDeployments) List(context.Context, client.ObjectList, ...client.ListOption) error {
+	return errors.New("not implemented")
+}
+
+func TestOwnership(t *testing.T) {
+	po := &platformv1alpha1.PlatformOperator{}
+	po.SetName("foo")
+	po.SetUID("5f1c0a1e-7a4c-4d0e-9a3e-4c3b3c2f1d0a")
+	other := po.DeepCopy()
+	other.SetUID("0b6f3a8e-2c1d-4e5f-8a9b-7c6d5e4f3a2b")
+
+	names := ObjectNames(po)
+	if len(names) != 2 || names[0] != "foo" || names[1] == "foo" {
+		t.Fatalf("ObjectNames() = %v, want foo and a derived name", names)
+	}
+	newBD := func(name string, owner *platformv1alpha1.PlatformOperator) *rukpakv1alpha2.BundleDeployment {
+		bd := &rukpakv1alpha2.BundleDeployment{}
+		bd.SetName(name)
+		if owner != nil {
+			bd.SetOwnerReferences([]metav1.OwnerReference{*metav1.NewControllerRef(owner, platformv1alpha1.GroupVersion.WithKind("PlatformOperator"))})
+		}
+		return bd
+	}
+
+	tests := []struct {
+		name         string
+		existing     bundleDeployments
+		wantGet      string
+		wantNotFound bool
+		wantName     string
+		wantConflict bool
+	}{
+		{
+			name:         "NotInstalled",
+			existing:     bundleDeployments{},
+			wantNotFound: true,
+			wantName:     "foo",
+		},
+		{
+			name:     "Controlled",
+			existing: bundleDeployments{"foo": newBD("foo", po)},
+			wantGet:  "foo",
+			wantName: "foo",
+		},
+		{
+			name:         "Unowned",
+			existing:     bundleDeployments{"foo": newBD("foo", nil)},
+			wantNotFound: true,
+			wantName:     names[1],
+		},
+		{
+			name:     "DerivedNameControlled",
+			existing: bundleDeployments{"foo": newBD("foo", other), names[1]: newBD(names[1], po)},
+			wantGet:  names[1],
+			wantName: names[1],
+		},
+		{
+			name:         "AllNamesTaken",
+			existing:     bundleDeployments{"foo": newBD("foo", other), names[1]: newBD(names[1], nil)},
+			wantConflict: true,
+		},
+	}
+	newObject := func() client.Object { return &rukpakv1alpha2.BundleDeployment{} }
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			obj, err := getControlled(context.Background(), tt.existing, po, newObject)
+			switch {
+			case tt.wantConflict:
+				if !errors.Is(err, ErrOwnershipConflict) {
+					t.Errorf("getControlled() error = %v, want an ownership conflict", err)
+				}
+			case tt.wantNotFound:
+				if !apierrors.IsNotFound(err) {
+					t.Errorf("getControlled() = %v, %v, want a NotFound error", obj, err)
+				}
+			case err != nil || obj.GetName() != tt.wantGet:
+				t.Errorf("getControlled() = %v, %v, want %s", obj, err, tt.wantGet)
+			}
+
+			name, err := AvailableName(context.Background(), tt.existing, po, newObject)
+			if tt.wantConflict {
+				if !errors.Is(err, ErrOwnershipConflict) {
+					t.Errorf("AvailableName() error = %v, want an ownership conflict", err)
+				}
+				return
+			}
+			if err != nil || name != tt.wantName {
+				t.Errorf("AvailableName() = %q, %v, want %q", name, err, tt.wantName)
+			}
+		})
+	}
+}
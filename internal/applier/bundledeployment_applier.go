@@ -1,16 +1,60 @@
 package applier
 
 import (
+	"context"
+
 	rukpakv1alpha2 "github.com/operator-framework/rukpak/api/v1alpha2"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	platformv1alpha1 "github.com/openshift/api/platform/v1alpha1"
+	"github.com/openshift/platform-operators/internal/sourcer"
+	"github.com/openshift/platform-operators/internal/util"
 )
 
 const (
 	registryProvisionerID = "core-rukpak-io-registry"
 )
 
+// bundleDeploymentApplier installs bundles through rukpak BundleDeployments.
+type bundleDeploymentApplier struct {
+	client.Client
+}
+
+func (a *bundleDeploymentApplier) Get(ctx context.Context, po *platformv1alpha1.PlatformOperator) (client.Object, error) {
+	return getControlled(ctx, a.Client, po, a.ObjectType)
+}
+
+func (a *bundleDeploymentApplier) Build(po *platformv1alpha1.PlatformOperator, b *sourcer.Bundle) client.Object {
+	bd := NewBundleDeployment(po, b.Image)
+	setBundleAnnotations(bd, b)
+	return bd
+}
+
+func (a *bundleDeploymentApplier) Upgrade(obj client.Object, b *sourcer.Bundle) {
+	bd := obj.(*rukpakv1alpha2.BundleDeployment)
+	// keep the pull secret, which is managed separately.
+	pullSecret := ""
+	if bd.Spec.Source.Image != nil {
+		pullSecret = bd.Spec.Source.Image.ImagePullSecretName
+	}
+	bd.Spec = buildBundleDeployment(b.Image)
+	bd.Spec.Source.Image.ImagePullSecretName = pullSecret
+	setBundleAnnotations(bd, b)
+}
+
+func (a *bundleDeploymentApplier) Inspect(ctx context.Context, obj client.Object) *metav1.Condition {
+	return util.InspectBundleDeployment(ctx, obj.(*rukpakv1alpha2.BundleDeployment).Status.Conditions)
+}
+
+func (a *bundleDeploymentApplier) ObjectType() client.Object {
+	return &rukpakv1alpha2.BundleDeployment{}
+}
+
+func (a *bundleDeploymentApplier) OwnerLabel() string {
+	return BundleDeploymentOwnerLabel
+}
+
 func NewBundleDeployment(po *platformv1alpha1.PlatformOperator, image string) *rukpakv1alpha2.BundleDeployment {
 	bd := &rukpakv1alpha2.BundleDeployment{}
 	bd.SetName(po.GetName())
@@ -36,3 +80,16 @@ func buildBundleDeployment(image string) rukpakv1alpha2.BundleDeploymentSpec {
 		},
 	}
 }
+
+// SetPullSecret configures the obj installation object to pull its bundle
+// image with the name pull secret, from the namespace rukpak's provisioner
+// runs in, and returns whether obj changed. Installation objects that don't
+// pull bundle images are left unchanged.
+func SetPullSecret(obj client.Object, name string) bool {
+	bd, ok := obj.(*rukpakv1alpha2.BundleDeployment)
+	if !ok || bd.Spec.Source.Image == nil || bd.Spec.Source.Image.ImagePullSecretName == name {
+		return false
+	}
+	bd.Spec.Source.Image.ImagePullSecretName = name
+	return true
+}
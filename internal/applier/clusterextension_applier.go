@@ -0,0 +1,130 @@
+package applier
+
+import (
+	"context"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	platformv1alpha1 "github.com/openshift/api/platform/v1alpha1"
+	platformtypes "github.com/openshift/platform-operators/api/v1alpha1"
+	"github.com/openshift/platform-operators/internal/sourcer"
+	"github.com/openshift/platform-operators/internal/util"
+)
+
+var (
+	// ClusterExtensionGVK is the OLM v1 ClusterExtension type. The OLM v1 API
+	// isn't vendored, so ClusterExtensions are managed as unstructured objects.
+	ClusterExtensionGVK = schema.GroupVersionKind{
+		Group:   "olm.operatorframework.io",
+		Version: "v1",
+		Kind:    "ClusterExtension",
+	}
+)
+
+// clusterCatalogNameLabel is the label catalogd sets to the name of each
+// ClusterCatalog.
+const clusterCatalogNameLabel = "olm.operatorframework.io/metadata.name"
+
+// ClusterExtensionOptions configures where OLM v1 installs the content of
+// the ClusterExtensions generated for PlatformOperators.
+type ClusterExtensionOptions struct {
+	// Namespace is the namespace the bundle's namespaced resources are installed into.
+	Namespace string
+	// ServiceAccount is the name of the ServiceAccount, in Namespace, that
+	// OLM v1 uses to install and manage the bundle's resources.
+	ServiceAccount string
+}
+
+// clusterExtensionApplier installs bundles through OLM v1 ClusterExtensions.
+type clusterExtensionApplier struct {
+	client.Client
+	opts ClusterExtensionOptions
+}
+
+func (a *clusterExtensionApplier) Get(ctx context.Context, po *platformv1alpha1.PlatformOperator) (client.Object, error) {
+	return getControlled(ctx, a.Client, po, a.ObjectType)
+}
+
+// Build pins the ClusterExtension to the exact package version that was
+// sourced, and to the ClusterCatalog the po PlatformOperator selected, so OLM
+// v1 installs the same bundle this controller resolved. OLM v1 pulls the
+// bundle image itself, so it's never seen by this controller.
+func (a *clusterExtensionApplier) Build(po *platformv1alpha1.PlatformOperator, b *sourcer.Bundle) client.Object {
+	ce := newClusterExtension()
+	ce.SetName(po.GetName())
+
+	controllerRef := metav1.NewControllerRef(po, po.GroupVersionKind())
+	ce.SetOwnerReferences([]metav1.OwnerReference{*controllerRef})
+	setBundleAnnotations(ce, b)
+
+	catalog := map[string]interface{}{
+		"packageName": po.Spec.Package.Name,
+		"version":     b.Version,
+	}
+	if name := po.GetAnnotations()[platformtypes.AnnotationCatalog]; name != "" {
+		catalog["selector"] = map[string]interface{}{
+			"matchLabels": map[string]interface{}{
+				clusterCatalogNameLabel: name,
+			},
+		}
+	}
+	ce.Object["spec"] = map[string]interface{}{
+		"namespace": a.opts.Namespace,
+		"serviceAccount": map[string]interface{}{
+			"name": a.opts.ServiceAccount,
+		},
+		"source": map[string]interface{}{
+			"sourceType": "Catalog",
+			"catalog":    catalog,
+		},
+	}
+	return ce
+}
+
+func (a *clusterExtensionApplier) Upgrade(obj client.Object, b *sourcer.Bundle) {
+	ce := obj.(*unstructured.Unstructured)
+	_ = unstructured.SetNestedField(ce.Object, b.Version, "spec", "source", "catalog", "version")
+	setBundleAnnotations(ce, b)
+}
+
+func (a *clusterExtensionApplier) Inspect(ctx context.Context, obj client.Object) *metav1.Condition {
+	ce := obj.(*unstructured.Unstructured)
+	conditions, _, _ := unstructured.NestedSlice(ce.Object, "status", "conditions")
+	return util.InspectClusterExtension(ctx, toMetaV1Conditions(conditions))
+}
+
+func (a *clusterExtensionApplier) ObjectType() client.Object {
+	return newClusterExtension()
+}
+
+func (a *clusterExtensionApplier) OwnerLabel() string {
+	return ClusterExtensionOwnerLabel
+}
+
+func newClusterExtension() *unstructured.Unstructured {
+	ce := &unstructured.Unstructured{}
+	ce.SetGroupVersionKind(ClusterExtensionGVK)
+	return ce
+}
+
+// toMetaV1Conditions converts the unstructured status.conditions of an
+// object into metav1.Conditions, skipping any malformed entries.
+func toMetaV1Conditions(in []interface{}) []metav1.Condition {
+	out := make([]metav1.Condition, 0, len(in))
+	for _, c := range in {
+		condition, ok := c.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		var mc metav1.Condition
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(condition, &mc); err != nil {
+			continue
+		}
+		out = append(out, mc)
+	}
+	return out
+}
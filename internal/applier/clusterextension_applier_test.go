@@ -0,0 +1,53 @@
+package applier
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	platformv1alpha1 "github.com/openshift/api/platform/v1alpha1"
+	platformtypes "github.com/openshift/platform-operators/api/v1alpha1"
+	"github.com/openshift/platform-operators/internal/sourcer"
+)
+
+func TestClusterExtensionBuild(t *testing.T) {
+	tests := []struct {
+		name         string
+		annotations  map[string]string
+		wantSelector map[string]interface{}
+	}{
+		{
+			name: "NoCatalog",
+		},
+		{
+			name:        "ClusterCatalog",
+			annotations: map[string]string{platformtypes.AnnotationCatalog: "operatorhubio"},
+			wantSelector: map[string]interface{}{
+				"matchLabels": map[string]interface{}{
+					"olm.operatorframework.io/metadata.name": "operatorhubio",
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			po := &platformv1alpha1.PlatformOperator{}
+			po.SetName("foo")
+			po.SetAnnotations(tt.annotations)
+			po.Spec.Package.Name = "foo"
+
+			a := &clusterExtensionApplier{opts: ClusterExtensionOptions{Namespace: "ns", ServiceAccount: "installer"}}
+			ce := a.Build(po, &sourcer.Bundle{Package: "foo", Version: "1.2.3"}).(*unstructured.Unstructured)
+
+			catalog, _, _ := unstructured.NestedMap(ce.Object, "spec", "source", "catalog")
+			if catalog["packageName"] != "foo" || catalog["version"] != "1.2.3" {
+				t.Errorf("Build() catalog = %v, want the foo package pinned to 1.2.3", catalog)
+			}
+			selector, _ := catalog["selector"].(map[string]interface{})
+			if !reflect.DeepEqual(selector, tt.wantSelector) {
+				t.Errorf("Build() selector = %v, want %v", selector, tt.wantSelector)
+			}
+		})
+	}
+}
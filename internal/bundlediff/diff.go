@@ -0,0 +1,238 @@
+// Package bundlediff compares the manifests of two bundles, e.g. an
+// installed bundle and the update it would be upgraded to, so the changes an
+// upgrade makes to the cluster can be reviewed before it's approved.
+package bundlediff
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/openshift/platform-operators/internal/preflight"
+)
+
+// The categories changes are grouped in, in the order they're reported.
+const (
+	CategoryRBAC        = "RBAC"
+	CategoryCRDs        = "CRDs"
+	CategoryDeployments = "Deployments"
+	CategoryWebhooks    = "Webhooks"
+	CategoryOther       = "Other"
+)
+
+var categories = []string{CategoryRBAC, CategoryCRDs, CategoryDeployments, CategoryWebhooks, CategoryOther}
+
+// maxSummarizedNames caps the number of objects that are named for each type
+// of change in a category of the summary.
+const maxSummarizedNames = 5
+
+// The types of changes to objects and fields.
+const (
+	Added    = "Added"
+	Removed  = "Removed"
+	Modified = "Modified"
+)
+
+// Diff lists the changes between the objects of two bundles.
+type Diff struct {
+	// From and To are the names of the compared bundles.
+	From    string   `json:"from"`
+	To      string   `json:"to"`
+	Changes []Change `json:"changes,omitempty"`
+}
+
+// Change is an object that's added, removed or modified between bundles.
+type Change struct {
+	Category string `json:"category"`
+	Type     string `json:"type"`
+	Kind     string `json:"kind"`
+	Name     string `json:"name"`
+	// Fields are the changed fields of a modified object.
+	Fields []FieldChange `json:"fields,omitempty"`
+}
+
+// FieldChange is a field that's added, removed or modified in an object. The
+// values are JSON encoded, and empty when the field doesn't exist.
+type FieldChange struct {
+	Path string `json:"path"`
+	From string `json:"from,omitempty"`
+	To   string `json:"to,omitempty"`
+}
+
+// Compute returns the changes between the objects the from and to bundles
+// install, including those the bundles' ClusterServiceVersions generate.
+func Compute(from, to *preflight.Bundle) (*Diff, error) {
+	fromObjects, err := render(from)
+	if err != nil {
+		return nil, err
+	}
+	toObjects, err := render(to)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &Diff{From: from.Name, To: to.Name}
+	for key, obj := range toObjects {
+		old, ok := fromObjects[key]
+		if !ok {
+			d.Changes = append(d.Changes, Change{Category: obj.category, Type: Added, Kind: obj.kind, Name: obj.name})
+			continue
+		}
+		if fields := diffFields(old.content, obj.content); len(fields) != 0 {
+			d.Changes = append(d.Changes, Change{Category: obj.category, Type: Modified, Kind: obj.kind, Name: obj.name, Fields: fields})
+		}
+	}
+	for key, obj := range fromObjects {
+		if _, ok := toObjects[key]; !ok {
+			d.Changes = append(d.Changes, Change{Category: obj.category, Type: Removed, Kind: obj.kind, Name: obj.name})
+		}
+	}
+	sort.Slice(d.Changes, func(i, j int) bool {
+		a, b := d.Changes[i], d.Changes[j]
+		if a.Category != b.Category {
+			return categoryIndex(a.Category) < categoryIndex(b.Category)
+		}
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		return a.Name < b.Name
+	})
+	return d, nil
+}
+
+func categoryIndex(category string) int {
+	for i, c := range categories {
+		if c == category {
+			return i
+		}
+	}
+	return len(categories)
+}
+
+// diffFields returns the fields that differ between the from and to contents.
+func diffFields(from, to map[string]interface{}) []FieldChange {
+	fromFields, toFields := make(map[string]string), make(map[string]string)
+	flatten("", from, fromFields)
+	flatten("", to, toFields)
+
+	var changes []FieldChange
+	for path, value := range toFields {
+		if old := fromFields[path]; old != value {
+			changes = append(changes, FieldChange{Path: path, From: old, To: value})
+		}
+	}
+	for path, value := range fromFields {
+		if _, ok := toFields[path]; !ok {
+			changes = append(changes, FieldChange{Path: path, From: value})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes
+}
+
+// flatten adds the JSON encoded leaf values of the value at path to fields,
+// keyed by their path.
+func flatten(path string, value interface{}, fields map[string]string) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if len(v) == 0 && path != "" {
+			fields[path] = "{}"
+		}
+		for name, field := range v {
+			if path != "" {
+				name = path + "." + name
+			}
+			flatten(name, field, fields)
+		}
+	case []interface{}:
+		if len(v) == 0 {
+			fields[path] = "[]"
+		}
+		for i, item := range v {
+			flatten(fmt.Sprintf("%s[%d]", path, i), item, fields)
+		}
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			data = []byte(fmt.Sprint(v))
+		}
+		fields[path] = string(data)
+	}
+}
+
+// Summary returns a single line overview of the changes in each category,
+// e.g. for a condition message, which names up to maxSummarizedNames objects
+// for each type of change.
+func (d *Diff) Summary() string {
+	if len(d.Changes) == 0 {
+		return "no manifest changes"
+	}
+	var summaries []string
+	for _, category := range categories {
+		byType := map[string][]string{}
+		for _, c := range d.Changes {
+			if c.Category == category {
+				byType[c.Type] = append(byType[c.Type], c.Kind+" "+c.Name)
+			}
+		}
+		if len(byType) == 0 {
+			continue
+		}
+		var parts []string
+		for _, t := range []string{Added, Removed, Modified} {
+			names := byType[t]
+			if len(names) == 0 {
+				continue
+			}
+			named := names
+			if len(names) > maxSummarizedNames {
+				named = append(names[:maxSummarizedNames:maxSummarizedNames], fmt.Sprintf("and %d more", len(names)-maxSummarizedNames))
+			}
+			parts = append(parts, fmt.Sprintf("%d %s (%s)", len(names), strings.ToLower(t), strings.Join(named, ", ")))
+		}
+		summaries = append(summaries, fmt.Sprintf("%s: %s", category, strings.Join(parts, ", ")))
+	}
+	return strings.Join(summaries, "; ")
+}
+
+// String renders the diff for people, listing each changed object by
+// category, and the changed fields of modified objects.
+func (d *Diff) String() string {
+	sb := &strings.Builder{}
+	fmt.Fprintf(sb, "Changes from %s to %s:\n", d.From, d.To)
+	if len(d.Changes) == 0 {
+		sb.WriteString("  no manifest changes\n")
+		return sb.String()
+	}
+	category := ""
+	for _, c := range d.Changes {
+		if c.Category != category {
+			category = c.Category
+			fmt.Fprintf(sb, "\n%s:\n", category)
+		}
+		fmt.Fprintf(sb, "  %s %s %s\n", marker(c.Type), c.Kind, c.Name)
+		for _, f := range c.Fields {
+			switch {
+			case f.From == "":
+				fmt.Fprintf(sb, "      + %s: %s\n", f.Path, f.To)
+			case f.To == "":
+				fmt.Fprintf(sb, "      - %s: %s\n", f.Path, f.From)
+			default:
+				fmt.Fprintf(sb, "      ~ %s: %s -> %s\n", f.Path, f.From, f.To)
+			}
+		}
+	}
+	return sb.String()
+}
+
+func marker(changeType string) string {
+	switch changeType {
+	case Added:
+		return "+"
+	case Removed:
+		return "-"
+	default:
+		return "~"
+	}
+}
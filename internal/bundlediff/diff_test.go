@@ -0,0 +1,148 @@
+package bundlediff
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/openshift/platform-operators/internal/preflight"
+)
+
+const (
+	csv = `
+apiVersion: operators.coreos.com/v1alpha1
+kind: ClusterServiceVersion
+metadata:
+  name: foo.v1.0.0
+spec:
+  version: 1.0.0
+  install:
+    strategy: deployment
+    spec:
+      deployments:
+      - name: foo-operator
+        spec:
+          selector:
+            matchLabels:
+              app: foo
+          template:
+            metadata:
+              labels:
+                app: foo
+            spec:
+              containers:
+              - name: manager
+                image: quay.io/example/foo:v1.0.0
+      clusterPermissions:
+      - serviceAccountName: foo-operator
+        rules:
+        - apiGroups: [""]
+          resources: [pods]
+          verbs: [get]
+  webhookdefinitions:
+  - type: ValidatingAdmissionWebhook
+    generateName: vfoo.example.com
+    deploymentName: foo-operator
+    containerPort: 443
+    sideEffects: None
+    admissionReviewVersions: [v1]
+    rules:
+    - apiGroups: [example.com]
+      apiVersions: [v1]
+      resources: [foos]
+      operations: [CREATE]
+`
+	crd = `
+apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: foos.example.com
+spec:
+  group: example.com
+  names:
+    kind: Foo
+    plural: foos
+  scope: Namespaced
+  versions:
+  - name: v1
+    served: true
+    storage: true
+`
+	metricsRole = `
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: foo-metrics-reader
+rules:
+- nonResourceURLs: [/metrics]
+  verbs: [get]
+`
+)
+
+func bundle(t *testing.T, name string, manifests ...string) *preflight.Bundle {
+	t.Helper()
+	files := make(map[string][]byte)
+	for i, m := range manifests {
+		files[string(rune('a'+i))+".yaml"] = []byte(m)
+	}
+	b, err := preflight.ParseBundle(name, files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestCompute(t *testing.T) {
+	from := bundle(t, "foo.v1.0.0", csv, crd, metricsRole)
+	to := bundle(t, "foo.v1.1.0",
+		strings.NewReplacer(
+			"name: foo.v1.0.0", "name: foo.v1.1.0",
+			"version: 1.0.0", "version: 1.1.0",
+			"foo:v1.0.0", "foo:v1.1.0",
+			"verbs: [get]", "verbs: [get, list]",
+			"operations: [CREATE]", "operations: [CREATE, UPDATE]",
+		).Replace(csv),
+		strings.Replace(crd, "    storage: true\n", "    storage: true\n  - name: v2\n    served: true\n    storage: false\n", 1),
+	)
+
+	diff, err := Compute(from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantSummary := "RBAC: 1 removed (ClusterRole foo-metrics-reader), 1 modified (ClusterRole foo-operator); " +
+		"CRDs: 1 modified (CustomResourceDefinition foos.example.com); " +
+		"Deployments: 1 modified (Deployment foo-operator); " +
+		"Webhooks: 1 modified (ValidatingAdmissionWebhook vfoo.example.com); " +
+		"Other: 1 modified (ClusterServiceVersion foo.v1.1.0)"
+	if got := diff.Summary(); got != wantSummary {
+		t.Errorf("Summary() = %q, want %q", got, wantSummary)
+	}
+
+	rendered := diff.String()
+	for _, want := range []string{
+		"Changes from foo.v1.0.0 to foo.v1.1.0:\n\nRBAC:\n  - ClusterRole foo-metrics-reader\n  ~ ClusterRole foo-operator\n      + rules[0].verbs[1]: \"list\"\n",
+		"  ~ Deployment foo-operator\n      ~ spec.template.spec.containers[0].image: \"quay.io/example/foo:v1.0.0\" -> \"quay.io/example/foo:v1.1.0\"\n",
+		"      + rules[0].operations[1]: \"UPDATE\"\n",
+		"      + spec.versions[1].name: \"v2\"\n",
+		"      ~ spec.version: \"1.0.0\" -> \"1.1.0\"\n",
+	} {
+		if !strings.Contains(rendered, want) {
+			t.Errorf("String() = %s\nwant it to contain %q", rendered, want)
+		}
+	}
+
+	if diff, err := Compute(from, from); err != nil || diff.Summary() != "no manifest changes" {
+		t.Errorf("Compute() of the same bundle = %v, %v, want no changes", diff, err)
+	}
+}
+
+func TestSummaryCapsNames(t *testing.T) {
+	diff := &Diff{From: "foo.v1.0.0", To: "foo.v1.1.0"}
+	for i := 0; i < maxSummarizedNames+2; i++ {
+		diff.Changes = append(diff.Changes, Change{Category: CategoryOther, Type: Added, Kind: "ConfigMap", Name: fmt.Sprintf("foo-%d", i)})
+	}
+	want := "Other: 7 added (ConfigMap foo-0, ConfigMap foo-1, ConfigMap foo-2, ConfigMap foo-3, ConfigMap foo-4, and 2 more)"
+	if got := diff.Summary(); got != want {
+		t.Errorf("Summary() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,146 @@
+package bundlediff
+
+import (
+	"fmt"
+
+	operatorsv1alpha1 "github.com/operator-framework/api/pkg/operators/v1alpha1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/openshift/platform-operators/internal/preflight"
+)
+
+// object is an object a bundle installs, with the content that's compared.
+type object struct {
+	category string
+	kind     string
+	name     string
+	content  map[string]interface{}
+}
+
+// render returns the objects the b bundle installs, keyed by their identity.
+// The ClusterServiceVersion is split into the Deployments, permissions and
+// webhooks it generates, so those are compared like the bundle's other
+// objects, and is identified by its kind alone, as its name changes with
+// every version.
+func render(b *preflight.Bundle) (map[string]object, error) {
+	objects := make(map[string]object)
+	add := func(obj object, key string) {
+		objects[obj.category+"/"+obj.kind+"/"+key] = obj
+	}
+
+	for _, obj := range b.Objects {
+		gvk := obj.GroupVersionKind()
+		if gvk.Group == operatorsv1alpha1.GroupName && gvk.Kind == operatorsv1alpha1.ClusterServiceVersionKind {
+			continue
+		}
+		name := obj.GetName()
+		if obj.GetNamespace() != "" {
+			name = obj.GetNamespace() + "/" + name
+		}
+		add(object{category: category(gvk.Kind), kind: gvk.Kind, name: name, content: content(obj.Object)}, gvk.Group+"/"+name)
+	}
+
+	csv, err := b.CSV()
+	if err != nil || csv == nil {
+		return objects, err
+	}
+	strategy := csv.Spec.InstallStrategy.StrategySpec
+	for i := range strategy.DeploymentSpecs {
+		spec, err := toUnstructured(&strategy.DeploymentSpecs[i].Spec)
+		if err != nil {
+			return nil, err
+		}
+		name := strategy.DeploymentSpecs[i].Name
+		add(object{category: CategoryDeployments, kind: "Deployment", name: name, content: map[string]interface{}{"spec": spec}}, name)
+	}
+	for _, p := range strategy.Permissions {
+		rules, err := toUnstructured(&rulesHolder{Rules: p.Rules})
+		if err != nil {
+			return nil, err
+		}
+		add(object{category: CategoryRBAC, kind: "Role", name: p.ServiceAccountName, content: rules}, p.ServiceAccountName)
+	}
+	for _, p := range strategy.ClusterPermissions {
+		rules, err := toUnstructured(&rulesHolder{Rules: p.Rules})
+		if err != nil {
+			return nil, err
+		}
+		add(object{category: CategoryRBAC, kind: "ClusterRole", name: p.ServiceAccountName, content: rules}, p.ServiceAccountName)
+	}
+	for i := range csv.Spec.WebhookDefinitions {
+		desc := csv.Spec.WebhookDefinitions[i]
+		webhook, err := toUnstructured(&desc)
+		if err != nil {
+			return nil, err
+		}
+		delete(webhook, "generateName")
+		delete(webhook, "type")
+		kind := string(desc.Type)
+		add(object{category: CategoryWebhooks, kind: kind, name: desc.GenerateName, content: webhook}, desc.GenerateName)
+	}
+
+	// the rest of the ClusterServiceVersion, e.g. its version and owned APIs.
+	rest := csv.DeepCopy()
+	rest.Spec.InstallStrategy = operatorsv1alpha1.NamedInstallStrategy{}
+	rest.Spec.WebhookDefinitions = nil
+	remaining, err := toUnstructured(&rest.Spec)
+	if err != nil {
+		return nil, err
+	}
+	add(object{category: CategoryOther, kind: operatorsv1alpha1.ClusterServiceVersionKind, name: csv.GetName(), content: map[string]interface{}{"spec": remaining}}, "")
+	return objects, nil
+}
+
+// rulesHolder holds the rules of a permission, like a Role.
+type rulesHolder struct {
+	Rules []rbacv1.PolicyRule `json:"rules"`
+}
+
+func toUnstructured(obj interface{}) (map[string]interface{}, error) {
+	u, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render %T: %w", obj, err)
+	}
+	return u, nil
+}
+
+// content returns the comparable content of the obj manifest, leaving out
+// the status and the metadata the cluster manages.
+func content(obj map[string]interface{}) map[string]interface{} {
+	c := make(map[string]interface{}, len(obj))
+	for name, value := range obj {
+		switch name {
+		case "apiVersion", "kind", "status":
+		case "metadata":
+			metadata, _ := value.(map[string]interface{})
+			kept := make(map[string]interface{})
+			for _, field := range []string{"labels", "annotations"} {
+				if v, ok := metadata[field]; ok {
+					kept[field] = v
+				}
+			}
+			if len(kept) != 0 {
+				c[name] = kept
+			}
+		default:
+			c[name] = value
+		}
+	}
+	return c
+}
+
+// category returns the category of the objects of the kind.
+func category(kind string) string {
+	switch kind {
+	case "ClusterRole", "ClusterRoleBinding", "Role", "RoleBinding", "ServiceAccount":
+		return CategoryRBAC
+	case "CustomResourceDefinition":
+		return CategoryCRDs
+	case "Deployment":
+		return CategoryDeployments
+	case "ValidatingWebhookConfiguration", "MutatingWebhookConfiguration":
+		return CategoryWebhooks
+	}
+	return CategoryOther
+}
@@ -0,0 +1,220 @@
+package clusterfacts
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	configv1 "github.com/openshift/api/config/v1"
+	operatorv1alpha1 "github.com/openshift/api/operator/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	clusterVersionName   = "version"
+	clusterProxyName     = "cluster"
+	installConfigDataKey = "install-config"
+)
+
+var (
+	installConfigKey = client.ObjectKey{Namespace: "kube-system", Name: "cluster-config-v1"}
+)
+
+// Facts describes the cluster that bundles are being installed into, and is
+// what bundle constraints are evaluated against.
+type Facts struct {
+	// OpenShiftVersion is the version the cluster is being updated to, or
+	// empty when the cluster isn't an OpenShift cluster.
+	OpenShiftVersion string
+	// KubernetesVersion is the version of the Kubernetes API server.
+	KubernetesVersion string
+	// Architectures are the distinct CPU architectures of the cluster's nodes.
+	Architectures []string
+	// GVKs are the APIs currently served by the cluster.
+	GVKs []schema.GroupVersionKind
+	// Packages is keyed by the name of each installed package, and holds the
+	// installed version of that package.
+	Packages map[string]string
+	// FIPS is whether the cluster was installed in FIPS mode.
+	FIPS bool
+	// Proxy is whether a cluster-wide egress proxy is configured.
+	Proxy bool
+	// Disconnected is whether the cluster pulls images through mirrors,
+	// which is how clusters without access to public registries are set up.
+	Disconnected bool
+}
+
+// Gatherer collects the Facts about the cluster.
+type Gatherer interface {
+	Gather(ctx context.Context) (*Facts, error)
+}
+
+type gatherer struct {
+	client.Client
+	// apiReader reads objects that are only needed once per call, and
+	// aren't worth caching, e.g. the install-config ConfigMap.
+	apiReader client.Reader
+	discovery discovery.DiscoveryInterface
+}
+
+// NewGatherer returns a Gatherer that reads the Facts from the cluster on
+// every call, so they reflect the cluster's current state.
+func NewGatherer(c client.Client, apiReader client.Reader, dc discovery.DiscoveryInterface) Gatherer {
+	return &gatherer{
+		Client:    c,
+		apiReader: apiReader,
+		discovery: dc,
+	}
+}
+
+//+kubebuilder:rbac:groups=config.openshift.io,resources=clusterversions;proxies;imagedigestmirrorsets;imagetagmirrorsets,verbs=get;list;watch
+//+kubebuilder:rbac:groups=operator.openshift.io,resources=imagecontentsourcepolicies,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=nodes,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",namespace=kube-system,resources=configmaps,verbs=get
+
+func (g *gatherer) Gather(ctx context.Context) (*Facts, error) {
+	facts := &Facts{
+		Packages: make(map[string]string),
+	}
+
+	cv := &configv1.ClusterVersion{}
+	if err := g.Get(ctx, client.ObjectKey{Name: clusterVersionName}, cv); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return nil, fmt.Errorf("failed to get the cluster version: %w", err)
+		}
+	} else {
+		facts.OpenShiftVersion = cv.Status.Desired.Version
+	}
+
+	info, err := g.discovery.ServerVersion()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the Kubernetes version: %w", err)
+	}
+	facts.KubernetesVersion = strings.TrimPrefix(info.GitVersion, "v")
+
+	nodes := &corev1.NodeList{}
+	if err := g.List(ctx, nodes); err != nil {
+		return nil, fmt.Errorf("failed to list nodes: %w", err)
+	}
+	facts.Architectures = architectures(nodes.Items)
+
+	// tolerate aggregated APIs that are unavailable, as the APIs that were
+	// discovered are still accurate.
+	_, resourceLists, err := g.discovery.ServerGroupsAndResources()
+	if err != nil && !discovery.IsGroupDiscoveryFailedError(err) {
+		return nil, fmt.Errorf("failed to discover the served APIs: %w", err)
+	}
+	for _, resourceList := range resourceLists {
+		gv, err := schema.ParseGroupVersion(resourceList.GroupVersion)
+		if err != nil {
+			return nil, err
+		}
+		for _, resource := range resourceList.APIResources {
+			// skip subresources, e.g. deployments/scale.
+			if strings.Contains(resource.Name, "/") {
+				continue
+			}
+			facts.GVKs = append(facts.GVKs, gv.WithKind(resource.Kind))
+		}
+	}
+
+	if facts.FIPS, err = g.fips(ctx); err != nil {
+		return nil, err
+	}
+	if facts.Proxy, err = g.proxy(ctx); err != nil {
+		return nil, err
+	}
+	if facts.Disconnected, err = g.disconnected(ctx); err != nil {
+		return nil, err
+	}
+	return facts, nil
+}
+
+// fips reads whether FIPS mode was enabled from the install-config the
+// cluster was installed with.
+func (g *gatherer) fips(ctx context.Context) (bool, error) {
+	cm := &corev1.ConfigMap{}
+	if err := g.apiReader.Get(ctx, installConfigKey, cm); err != nil {
+		if apierrors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to get the install-config: %w", err)
+	}
+	var installConfig struct {
+		FIPS bool `json:"fips"`
+	}
+	if err := yaml.Unmarshal([]byte(cm.Data[installConfigDataKey]), &installConfig); err != nil {
+		return false, fmt.Errorf("failed to parse the install-config: %w", err)
+	}
+	return installConfig.FIPS, nil
+}
+
+func (g *gatherer) proxy(ctx context.Context) (bool, error) {
+	proxy := &configv1.Proxy{}
+	if err := g.Get(ctx, client.ObjectKey{Name: clusterProxyName}, proxy); err != nil {
+		if apierrors.IsNotFound(err) || meta.IsNoMatchError(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to get the cluster proxy: %w", err)
+	}
+	return proxy.Status.HTTPProxy != "" || proxy.Status.HTTPSProxy != "", nil
+}
+
+// disconnected reports whether any image mirroring policy is configured.
+func (g *gatherer) disconnected(ctx context.Context) (bool, error) {
+	for _, list := range []client.ObjectList{
+		&configv1.ImageDigestMirrorSetList{},
+		&configv1.ImageTagMirrorSetList{},
+		&operatorv1alpha1.ImageContentSourcePolicyList{},
+	} {
+		if err := g.List(ctx, list, client.Limit(1)); err != nil {
+			if meta.IsNoMatchError(err) {
+				continue
+			}
+			return false, fmt.Errorf("failed to list image mirroring policies: %w", err)
+		}
+		if meta.LenList(list) != 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func architectures(nodes []corev1.Node) []string {
+	seen := make(map[string]bool)
+	var archs []string
+	for _, node := range nodes {
+		arch := node.Status.NodeInfo.Architecture
+		if arch == "" || seen[arch] {
+			continue
+		}
+		seen[arch] = true
+		archs = append(archs, arch)
+	}
+	sort.Strings(archs)
+	return archs
+}
+
+// Static returns a Gatherer that always returns a copy of facts, e.g. for
+// clusters whose facts are known ahead of time.
+func Static(facts Facts) Gatherer {
+	return static(facts)
+}
+
+type static Facts
+
+func (s static) Gather(_ context.Context) (*Facts, error) {
+	facts := Facts(s)
+	facts.Packages = make(map[string]string, len(s.Packages))
+	for name, version := range s.Packages {
+		facts.Packages[name] = version
+	}
+	return &facts, nil
+}
@@ -18,6 +18,7 @@ package controllers
 
 import (
 	"context"
+	"fmt"
 
 	configv1 "github.com/openshift/api/config/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -93,11 +94,12 @@ func (r *AggregatedClusterOperatorReconciler) Reconcile(ctx context.Context, req
 
 	// TODO: consider something more fine-grained than a catch-all "PlatformOperatorError" reason.
 	//   There's a non-negligible difference between "PO is explicitly failing installation" and "PO is not yet installed"
+	deprecated := util.DeprecatedPlatformOperators(poList)
 	if statusErrorCheck := util.InspectPlatformOperators(poList); statusErrorCheck != nil {
-		coBuilder.WithAvailable(metav1.ConditionFalse, clusteroperator.ReasonPlatformOperatorError, statusErrorCheck.Error())
+		coBuilder.WithAvailable(metav1.ConditionFalse, clusteroperator.ReasonPlatformOperatorError, withDeprecations(statusErrorCheck.Error(), deprecated))
 		return ctrl.Result{}, nil
 	}
-	coBuilder.WithAvailable(metav1.ConditionTrue, clusteroperator.ReasonAsExpected, "All platform operators are in a successful state")
+	coBuilder.WithAvailable(metav1.ConditionTrue, clusteroperator.ReasonAsExpected, withDeprecations("All platform operators are in a successful state", deprecated))
 	coBuilder.WithProgressing(metav1.ConditionFalse, clusteroperator.ReasonAsExpected, "All platform operators are in a successful state")
 
 	return ctrl.Result{}, nil
@@ -113,6 +115,15 @@ func (r *AggregatedClusterOperatorReconciler) SetupWithManager(mgr ctrl.Manager)
 		Complete(r)
 }
 
+// withDeprecations appends the summary of deprecated platform operators, if
+// any, to the message.
+func withDeprecations(message, deprecated string) string {
+	if deprecated == "" {
+		return message
+	}
+	return fmt.Sprintf("%s. %s", message, deprecated)
+}
+
 func setStaticRelatedObjects(coBuilder *clusteroperator.Builder, systemNamespace string) {
 	coBuilder.
 		WithRelatedObject(configv1.ObjectReference{Group: "", Resource: "namespaces", Name: systemNamespace}).
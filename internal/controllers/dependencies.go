@@ -0,0 +1,264 @@
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/blang/semver/v4"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	platformv1alpha1 "github.com/openshift/api/platform/v1alpha1"
+	platformtypes "github.com/openshift/platform-operators/api/v1alpha1"
+	"github.com/openshift/platform-operators/internal/sourcer"
+)
+
+var (
+	errDependenciesPending     = errors.New("waiting for dependencies to be installed")
+	errDependenciesUnsatisfied = errors.New("dependencies aren't satisfied")
+)
+
+func dependencyPolicy(po *platformv1alpha1.PlatformOperator) string {
+	if po.GetAnnotations()[platformtypes.AnnotationDependencyPolicy] == platformtypes.DependencyPolicyInstall {
+		return platformtypes.DependencyPolicyInstall
+	}
+	return platformtypes.DependencyPolicyRefuse
+}
+
+// reconcileDependencies ensures every dependency of the b bundle, resolved for
+// the po PlatformOperator, has been installed before b itself is installed.
+// Missing dependencies are either installed through managed PlatformOperators,
+// or refused, depending on the po PlatformOperator's dependency policy. The
+// outcome is reported through the DependenciesResolved condition, and an error
+// wrapping errDependenciesPending or errDependenciesUnsatisfied is returned
+// when b can't be installed yet.
+func (r *PlatformOperatorReconciler) reconcileDependencies(ctx context.Context, po *platformv1alpha1.PlatformOperator, b *sourcer.Bundle, deps []sourcer.Dependency) error {
+	if len(deps) == 0 {
+		setDependenciesCondition(po, metav1.ConditionTrue, platformtypes.ReasonDependenciesSatisfied, fmt.Sprintf("The %s bundle has no dependencies", b.Name))
+		return nil
+	}
+
+	poList := &platformv1alpha1.PlatformOperatorList{}
+	if err := r.List(ctx, poList); err != nil {
+		return err
+	}
+	var (
+		missing []string
+		pending []string
+	)
+	for _, dep := range deps {
+		existing := findPlatformOperator(poList, dep.Bundle.Package)
+		if existing == nil {
+			missing = append(missing, describeDependency(dep))
+			if dependencyPolicy(po) != platformtypes.DependencyPolicyInstall {
+				continue
+			}
+			if err := r.createDependency(ctx, po, dep); err != nil {
+				return err
+			}
+			pending = append(pending, dep.Bundle.Package)
+			continue
+		}
+		if err := r.addRequiredBy(ctx, existing, po); err != nil {
+			return err
+		}
+		if !meta.IsStatusConditionTrue(existing.Status.Conditions, platformtypes.TypeInstalled) {
+			pending = append(pending, dep.Bundle.Package)
+			continue
+		}
+		if err := r.verifyInstalledVersion(ctx, existing, dep); err != nil {
+			setDependenciesCondition(po, metav1.ConditionFalse, platformtypes.ReasonDependenciesUnsatisfiable, err.Error())
+			return fmt.Errorf("%v: %w", err, errDependenciesUnsatisfied)
+		}
+	}
+
+	if len(missing) != 0 && dependencyPolicy(po) != platformtypes.DependencyPolicyInstall {
+		msg := fmt.Sprintf("The %s bundle requires the following packages that aren't installed: %s. Install them, or set the %s annotation to %q to have them installed automatically",
+			b.Name, strings.Join(missing, ", "), platformtypes.AnnotationDependencyPolicy, platformtypes.DependencyPolicyInstall)
+		setDependenciesCondition(po, metav1.ConditionFalse, platformtypes.ReasonDependenciesNotInstalled, msg)
+		return fmt.Errorf("%s: %w", msg, errDependenciesUnsatisfied)
+	}
+	if len(pending) != 0 {
+		msg := fmt.Sprintf("Waiting for the following dependencies to be installed: %s", strings.Join(pending, ", "))
+		setDependenciesCondition(po, metav1.ConditionFalse, platformtypes.ReasonDependenciesPending, msg)
+		return fmt.Errorf("%s: %w", msg, errDependenciesPending)
+	}
+	setDependenciesCondition(po, metav1.ConditionTrue, platformtypes.ReasonDependenciesSatisfied, fmt.Sprintf("All %d dependencies of the %s bundle are installed", len(deps), b.Name))
+	return nil
+}
+
+// createDependency creates the managed PlatformOperator that installs the dep
+// dependency of the po PlatformOperator, from the same catalog as po. It's
+// named apart from the PlatformOperators users create, and is owned by every
+// PlatformOperator that requires it, so it's garbage collected once they're
+// all deleted. The version range po requires, or the version of the bundle
+// that was selected when no range is required, is recorded on it, so it
+// installs a bundle that satisfies po.
+func (r *PlatformOperatorReconciler) createDependency(ctx context.Context, po *platformv1alpha1.PlatformOperator, dep sourcer.Dependency) error {
+	name, err := r.availableDependencyName(ctx, po, dep.Bundle.Package)
+	if err != nil {
+		return err
+	}
+	managed := &platformv1alpha1.PlatformOperator{}
+	managed.SetName(name)
+	managed.SetLabels(map[string]string{
+		platformtypes.LabelManagedDependency: "true",
+	})
+	versionRange := dep.VersionRange
+	if versionRange == "" {
+		versionRange = dep.Bundle.Version
+	}
+	annotations := map[string]string{
+		platformtypes.AnnotationRequiredBy:       po.GetName(),
+		platformtypes.AnnotationDependencyPolicy: platformtypes.DependencyPolicyInstall,
+		platformtypes.AnnotationVersionRange:     versionRange,
+	}
+	if catalog, ok := po.GetAnnotations()[platformtypes.AnnotationCatalog]; ok {
+		annotations[platformtypes.AnnotationCatalog] = catalog
+	}
+	managed.SetAnnotations(annotations)
+	managed.Spec.Package.Name = dep.Bundle.Package
+	if err := controllerutil.SetOwnerReference(po, managed, r.Scheme()); err != nil {
+		return err
+	}
+
+	if err := r.Create(ctx, managed); err != nil {
+		return fmt.Errorf("failed to create the %s dependency PlatformOperator: %w", managed.GetName(), err)
+	}
+	return nil
+}
+
+// dependencyNames returns the names the managed PlatformOperator installing
+// the packageName package for the po PlatformOperator can have, in order of
+// preference: a name derived from the package, and a name also derived from
+// po's UID, for when another PlatformOperator already has that name.
+func dependencyNames(po *platformv1alpha1.PlatformOperator, packageName string) []string {
+	name := packageName + "-dependency"
+	sum := sha256.Sum256([]byte(string(po.GetUID()) + "/" + packageName))
+	return []string{name, fmt.Sprintf("%s-%x", name, sum[:4])}
+}
+
+// availableDependencyName returns the first of the dependencyNames that isn't
+// taken by another PlatformOperator, or an error when they're all taken.
+func (r *PlatformOperatorReconciler) availableDependencyName(ctx context.Context, po *platformv1alpha1.PlatformOperator, packageName string) (string, error) {
+	names := dependencyNames(po, packageName)
+	for _, name := range names {
+		err := r.Get(ctx, types.NamespacedName{Name: name}, &platformv1alpha1.PlatformOperator{})
+		if apierrors.IsNotFound(err) {
+			return name, nil
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	return "", fmt.Errorf("the %s names for the %s dependency are taken by other platform operators", strings.Join(names, " and "), packageName)
+}
+
+// addRequiredBy records that the po PlatformOperator depends on the managed
+// dependency PlatformOperator, and makes po one of its owners.
+func (r *PlatformOperatorReconciler) addRequiredBy(ctx context.Context, dependency, po *platformv1alpha1.PlatformOperator) error {
+	if dependency.GetLabels()[platformtypes.LabelManagedDependency] != "true" {
+		return nil
+	}
+	base := dependency.DeepCopy()
+	if err := controllerutil.SetOwnerReference(po, dependency, r.Scheme()); err != nil {
+		return err
+	}
+
+	requiredBy := dependency.GetAnnotations()[platformtypes.AnnotationRequiredBy]
+	var names []string
+	if requiredBy != "" {
+		names = strings.Split(requiredBy, ",")
+	}
+	if !containsString(names, po.GetName()) {
+		names = append(names, po.GetName())
+		sort.Strings(names)
+	}
+	annotations := dependency.GetAnnotations()
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	annotations[platformtypes.AnnotationRequiredBy] = strings.Join(names, ",")
+	dependency.SetAnnotations(annotations)
+
+	if equality.Semantic.DeepEqual(base.ObjectMeta, dependency.ObjectMeta) {
+		return nil
+	}
+	return r.Patch(ctx, dependency, client.MergeFrom(base))
+}
+
+// verifyInstalledVersion checks that the version installed by the dependency
+// PlatformOperator is within the range required by dep.
+func (r *PlatformOperatorReconciler) verifyInstalledVersion(ctx context.Context, dependency *platformv1alpha1.PlatformOperator, dep sourcer.Dependency) error {
+	if dep.VersionRange == "" {
+		return nil
+	}
+	obj, err := r.Applier.Get(ctx, dependency)
+	if err != nil {
+		return err
+	}
+	installed := obj.GetAnnotations()[platformtypes.AnnotationBundleVersion]
+	if installed == "" {
+		// installation objects created before the bundle version was
+		// recorded can't be verified, so trust them.
+		return nil
+	}
+	versionRange, err := semver.ParseRange(dep.VersionRange)
+	if err != nil {
+		return err
+	}
+	v, err := semver.Parse(installed)
+	if err != nil {
+		return fmt.Errorf("failed to parse the %s version installed by the %s PlatformOperator: %w", installed, dependency.GetName(), err)
+	}
+	if !versionRange(v) {
+		return fmt.Errorf("the %s bundle requires the %s package in the %q range, but the %s PlatformOperator installed version %s",
+			dep.RequiredBy, dep.Bundle.Package, dep.VersionRange, dependency.GetName(), installed)
+	}
+	return nil
+}
+
+// findPlatformOperator returns the PlatformOperator installing the packageName
+// package, or nil when there's no such PlatformOperator.
+func findPlatformOperator(poList *platformv1alpha1.PlatformOperatorList, packageName string) *platformv1alpha1.PlatformOperator {
+	for i := range poList.Items {
+		if poList.Items[i].Spec.Package.Name == packageName {
+			return &poList.Items[i]
+		}
+	}
+	return nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func describeDependency(dep sourcer.Dependency) string {
+	if dep.VersionRange == "" {
+		return dep.Bundle.Package
+	}
+	return fmt.Sprintf("%s (%s)", dep.Bundle.Package, dep.VersionRange)
+}
+
+func setDependenciesCondition(po *platformv1alpha1.PlatformOperator, status metav1.ConditionStatus, reason, message string) {
+	meta.SetStatusCondition(&po.Status.Conditions, metav1.Condition{
+		Type:    platformtypes.TypeDependencies,
+		Status:  status,
+		Reason:  reason,
+		Message: message,
+	})
+}
@@ -0,0 +1,99 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	platformv1alpha1 "github.com/openshift/api/platform/v1alpha1"
+	platformtypes "github.com/openshift/platform-operators/api/v1alpha1"
+	"github.com/openshift/platform-operators/internal/sourcer"
+)
+
+func TestReconcileDependencies(t *testing.T) {
+	install := map[string]string{platformtypes.AnnotationDependencyPolicy: platformtypes.DependencyPolicyInstall}
+	dep := sourcer.Dependency{
+		Bundle:       sourcer.Bundle{Name: "database.v1.5.0", Package: "database", Version: "1.5.0"},
+		VersionRange: ">=1.0.0",
+		RequiredBy:   "app.v1.0.0",
+	}
+	app := newTestPlatformOperator("app", install)
+	web := newTestPlatformOperator("web", install)
+	// a PlatformOperator with the name the dependency would be given, which
+	// installs a different package.
+	unrelated := newTestPlatformOperator("database-dependency", nil)
+	unrelated.Spec.Package.Name = "unrelated"
+
+	rt := newReconcileTest(t, &staticSourcer{}, app, web, unrelated)
+	ctx := context.Background()
+	for _, po := range []*platformv1alpha1.PlatformOperator{app, web} {
+		err := rt.reconcileDependencies(ctx, po, &sourcer.Bundle{Name: po.GetName() + ".v1.0.0"}, []sourcer.Dependency{dep})
+		if !errors.Is(err, errDependenciesPending) {
+			t.Fatalf("reconcileDependencies() error = %v, want the dependencies to be pending", err)
+		}
+	}
+
+	managed := &platformv1alpha1.PlatformOperator{}
+	name := dependencyNames(app, "database")[1]
+	if err := rt.Get(ctx, client.ObjectKey{Name: name}, managed); err != nil {
+		t.Fatalf("failed to get the managed dependency: %v", err)
+	}
+	if managed.Spec.Package.Name != "database" || managed.GetLabels()[platformtypes.LabelManagedDependency] != "true" {
+		t.Errorf("managed dependency installs the %s package with the labels %v, want a managed database PlatformOperator", managed.Spec.Package.Name, managed.GetLabels())
+	}
+	if got := managed.GetAnnotations()[platformtypes.AnnotationVersionRange]; got != ">=1.0.0" {
+		t.Errorf("managed dependency is restricted to the %q range, want the required >=1.0.0", got)
+	}
+	if got := managed.GetAnnotations()[platformtypes.AnnotationRequiredBy]; got != "app,web" {
+		t.Errorf("managed dependency is required by %q, want app,web", got)
+	}
+	var owners []string
+	for _, ref := range managed.GetOwnerReferences() {
+		if ref.Controller != nil && *ref.Controller {
+			t.Errorf("managed dependency is controlled by %s, want it only to be owned", ref.Name)
+		}
+		owners = append(owners, ref.Name)
+	}
+	if len(owners) != 2 || owners[0] != "app" || owners[1] != "web" {
+		t.Errorf("managed dependency is owned by %v, want app and web", owners)
+	}
+
+	if err := rt.Get(ctx, client.ObjectKeyFromObject(unrelated), unrelated); err != nil || unrelated.Spec.Package.Name != "unrelated" {
+		t.Errorf("the database-dependency PlatformOperator = %v, %v, want it left unchanged", unrelated.Spec.Package.Name, err)
+	}
+
+	// once the dependency is installed, the requirements are satisfied.
+	if err := rt.Create(ctx, newTestBundleDeployment(managed, dep.Bundle, installedConditions()...)); err != nil {
+		t.Fatal(err)
+	}
+	managed.Status.Conditions = []metav1.Condition{{Type: platformtypes.TypeInstalled, Status: metav1.ConditionTrue, Reason: platformtypes.ReasonInstallSuccessful, LastTransitionTime: metav1.Now()}}
+	if err := rt.Update(ctx, managed); err != nil {
+		t.Fatal(err)
+	}
+	if err := rt.reconcileDependencies(ctx, app, &sourcer.Bundle{Name: "app.v1.0.0"}, []sourcer.Dependency{dep}); err != nil {
+		t.Errorf("reconcileDependencies() unexpected error once the dependency is installed: %v", err)
+	}
+}
+
+func TestCreateDependencyRecordsSelectedVersion(t *testing.T) {
+	po := newTestPlatformOperator("app", nil)
+	rt := newReconcileTest(t, &staticSourcer{}, po)
+	ctx := context.Background()
+
+	// the dependency was selected as a provider of a required API, so no
+	// version range was required.
+	dep := sourcer.Dependency{Bundle: sourcer.Bundle{Name: "storage.v0.1.0", Package: "storage", Version: "0.1.0"}, RequiredBy: "app.v1.0.0"}
+	if err := rt.createDependency(ctx, po, dep); err != nil {
+		t.Fatalf("createDependency() unexpected error: %v", err)
+	}
+	managed := &platformv1alpha1.PlatformOperator{}
+	if err := rt.Get(ctx, client.ObjectKey{Name: dependencyNames(po, "storage")[0]}, managed); err != nil {
+		t.Fatalf("failed to get the managed dependency: %v", err)
+	}
+	if got := managed.GetAnnotations()[platformtypes.AnnotationVersionRange]; got != "0.1.0" {
+		t.Errorf("managed dependency is restricted to the %q range, want the selected 0.1.0 version", got)
+	}
+}
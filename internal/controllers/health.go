@@ -0,0 +1,68 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	platformv1alpha1 "github.com/openshift/api/platform/v1alpha1"
+	platformtypes "github.com/openshift/platform-operators/api/v1alpha1"
+	"github.com/openshift/platform-operators/internal/health"
+)
+
+//+kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=pods,verbs=get;list
+
+// reportWorkloadHealth sets the po PlatformOperator's Available condition
+// from the health of the Deployments the obj installation object installed,
+// and returns when the workloads should be inspected again, which is zero
+// when the Deployments' watch events are enough.
+func (r *PlatformOperatorReconciler) reportWorkloadHealth(ctx context.Context, po *platformv1alpha1.PlatformOperator, obj client.Object) time.Duration {
+	workloads, err := r.listWorkloads(ctx, obj)
+	if err != nil {
+		meta.SetStatusCondition(&po.Status.Conditions, metav1.Condition{
+			Type:    platformtypes.TypeAvailable,
+			Status:  metav1.ConditionUnknown,
+			Reason:  platformtypes.ReasonWorkloadsProgressing,
+			Message: fmt.Sprintf("Failed to inspect the installed workloads: %v", err),
+		})
+		return r.WorkloadGracePeriod
+	}
+	cond, recheckIn := health.Inspect(workloads, r.WorkloadGracePeriod, time.Now())
+	meta.SetStatusCondition(&po.Status.Conditions, cond)
+	return recheckIn
+}
+
+// listWorkloads returns the Deployments the obj installation object
+// installed, along with their pods, which are read from the API server so
+// pods aren't cached in their entirety.
+func (r *PlatformOperatorReconciler) listWorkloads(ctx context.Context, obj client.Object) ([]health.Workload, error) {
+	deployments := &appsv1.DeploymentList{}
+	if err := r.List(ctx, deployments, client.MatchingLabels{r.Applier.OwnerLabel(): obj.GetName()}); err != nil {
+		return nil, fmt.Errorf("failed to list the installed deployments: %w", err)
+	}
+
+	var workloads []health.Workload
+	for _, d := range deployments.Items {
+		w := health.Workload{Deployment: d}
+		if d.Spec.Selector != nil {
+			selector, err := metav1.LabelSelectorAsSelector(d.Spec.Selector)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse the selector of the %s/%s deployment: %w", d.GetNamespace(), d.GetName(), err)
+			}
+			pods := &corev1.PodList{}
+			if err := r.APIReader.List(ctx, pods, client.InNamespace(d.GetNamespace()), client.MatchingLabelsSelector{Selector: selector}); err != nil {
+				return nil, fmt.Errorf("failed to list the pods of the %s/%s deployment: %w", d.GetNamespace(), d.GetName(), err)
+			}
+			w.Pods = pods.Items
+		}
+		workloads = append(workloads, w)
+	}
+	return workloads, nil
+}
@@ -0,0 +1,175 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	configv1 "github.com/openshift/api/config/v1"
+	operatorsv1alpha1 "github.com/operator-framework/api/pkg/operators/v1alpha1"
+	"github.com/operator-framework/operator-registry/alpha/property"
+	rukpakv1alpha2 "github.com/operator-framework/rukpak/api/v1alpha2"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	platformv1alpha1 "github.com/openshift/api/platform/v1alpha1"
+	platformtypes "github.com/openshift/platform-operators/api/v1alpha1"
+	"github.com/openshift/platform-operators/internal/applier"
+	"github.com/openshift/platform-operators/internal/clusterfacts"
+	"github.com/openshift/platform-operators/internal/resolution"
+	"github.com/openshift/platform-operators/internal/sourcer"
+)
+
+// staticSourcer is a Sourcer serving the same candidates for every package.
+type staticSourcer struct {
+	candidates []sourcer.Bundle
+	err        error
+	// revision is the catalog revision, which isn't reported when empty.
+	revision string
+	// calls counts the Candidates calls.
+	calls int
+}
+
+func (s *staticSourcer) Source(ctx context.Context, po *platformv1alpha1.PlatformOperator) (*sourcer.Bundle, error) {
+	candidates, err := s.Candidates(ctx, po)
+	if err != nil {
+		return nil, err
+	}
+	if len(candidates) == 0 {
+		return nil, errors.New("no candidates")
+	}
+	return &candidates[len(candidates)-1], nil
+}
+
+func (s *staticSourcer) Candidates(context.Context, *platformv1alpha1.PlatformOperator) ([]sourcer.Bundle, error) {
+	s.calls++
+	return s.candidates, s.err
+}
+
+func (s *staticSourcer) Revision(context.Context, *platformv1alpha1.PlatformOperator) (string, error) {
+	return s.revision, nil
+}
+
+func (s *staticSourcer) Providers(context.Context, *platformv1alpha1.PlatformOperator, property.GVK) ([]sourcer.Bundle, error) {
+	return nil, nil
+}
+
+// reconcileTest runs a PlatformOperatorReconciler against a fake client.
+type reconcileTest struct {
+	*PlatformOperatorReconciler
+	// status is the status that was last applied to a PlatformOperator.
+	status *platformv1alpha1.PlatformOperator
+}
+
+func newTestScheme(t *testing.T) *runtime.Scheme {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	for _, install := range []func(*runtime.Scheme) error{
+		clientgoscheme.AddToScheme,
+		platformv1alpha1.Install,
+		configv1.Install,
+		operatorsv1alpha1.AddToScheme,
+		rukpakv1alpha2.AddToScheme,
+	} {
+		if err := install(scheme); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return scheme
+}
+
+// newReconcileTest returns a reconcileTest whose client serves objs, and
+// which installs PlatformOperators through BundleDeployments.
+func newReconcileTest(t *testing.T, s sourcer.Sourcer, objs ...client.Object) *reconcileTest {
+	t.Helper()
+	rt := &reconcileTest{}
+	c := fake.NewClientBuilder().
+		WithScheme(newTestScheme(t)).
+		WithObjects(objs...).
+		WithIndex(&platformv1alpha1.PlatformOperator{}, pullSecretIndex, indexPullSecret).
+		WithInterceptorFuncs(interceptor.Funcs{
+			// the fake client doesn't support server-side apply, so the
+			// applied status is recorded instead.
+			SubResourcePatch: func(ctx context.Context, c client.Client, subResource string, obj client.Object, patch client.Patch, opts ...client.SubResourcePatchOption) error {
+				if po, ok := obj.(*platformv1alpha1.PlatformOperator); ok && patch.Type() == types.ApplyPatchType {
+					rt.status = po.DeepCopy()
+					return nil
+				}
+				return c.SubResource(subResource).Patch(ctx, obj, patch, opts...)
+			},
+		}).
+		Build()
+	a, err := applier.New(c, applier.BackendBundleDeployment, applier.ClusterExtensionOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rt.PlatformOperatorReconciler = &PlatformOperatorReconciler{
+		Client:    c,
+		APIReader: c,
+		Sourcer:   s,
+		Resolver:  resolution.NewResolver(s, clusterfacts.Static(clusterfacts.Facts{}), false),
+		Applier:   a,
+	}
+	return rt
+}
+
+func (rt *reconcileTest) reconcile(t *testing.T, name string) (ctrl.Result, error) {
+	t.Helper()
+	rt.status = nil
+	return rt.Reconcile(context.Background(), ctrl.Request{NamespacedName: types.NamespacedName{Name: name}})
+}
+
+// condition returns the conditionType condition from the last applied
+// status, or nil when it wasn't set.
+func (rt *reconcileTest) condition(conditionType string) *metav1.Condition {
+	if rt.status == nil {
+		return nil
+	}
+	return meta.FindStatusCondition(rt.status.Status.Conditions, conditionType)
+}
+
+func newTestPlatformOperator(name string, annotations map[string]string) *platformv1alpha1.PlatformOperator {
+	return &platformv1alpha1.PlatformOperator{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: platformv1alpha1.GroupVersion.String(),
+			Kind:       "PlatformOperator",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			UID:         types.UID(name + "-uid"),
+			Annotations: annotations,
+		},
+		Spec: platformv1alpha1.PlatformOperatorSpec{
+			Package: platformv1alpha1.Package{Name: name},
+		},
+	}
+}
+
+// newTestBundleDeployment returns the BundleDeployment that installed the b
+// bundle for the po PlatformOperator, reporting the conditions.
+func newTestBundleDeployment(po *platformv1alpha1.PlatformOperator, b sourcer.Bundle, conditions ...metav1.Condition) *rukpakv1alpha2.BundleDeployment {
+	bd := applier.NewBundleDeployment(po, b.Image)
+	bd.SetAnnotations(map[string]string{
+		platformtypes.AnnotationBundleName:    b.Name,
+		platformtypes.AnnotationBundleVersion: b.Version,
+		platformtypes.AnnotationBundleChannel: b.Channel,
+	})
+	bd.Status.Conditions = conditions
+	return bd
+}
+
+// installedConditions are the conditions of a BundleDeployment that has
+// successfully installed its bundle.
+func installedConditions() []metav1.Condition {
+	return []metav1.Condition{
+		{Type: rukpakv1alpha2.TypeHasValidBundle, Status: metav1.ConditionTrue, Reason: rukpakv1alpha2.ReasonUnpackSuccessful},
+		{Type: rukpakv1alpha2.TypeInstalled, Status: metav1.ConditionTrue, Reason: rukpakv1alpha2.ReasonInstallationSucceeded},
+	}
+}
@@ -0,0 +1,87 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	logr "sigs.k8s.io/controller-runtime/pkg/log"
+
+	platformv1alpha1 "github.com/openshift/api/platform/v1alpha1"
+	platformtypes "github.com/openshift/platform-operators/api/v1alpha1"
+	"github.com/openshift/platform-operators/internal/images"
+	"github.com/openshift/platform-operators/internal/sourcer"
+)
+
+// pinBundleImage resolves the b bundle's image to a digest, following the
+// cluster's image mirroring policies, so the installation object always
+// unpacks the exact image that was resolved, even when its tag moves. The
+// result is reported through the po PlatformOperator's ImagePinned condition.
+func (r *PlatformOperatorReconciler) pinBundleImage(ctx context.Context, po *platformv1alpha1.PlatformOperator, b *sourcer.Bundle, keychain images.Keychain) error {
+	pinned, err := r.Images.Pin(ctx, b.Image, keychain)
+	if err != nil {
+		meta.SetStatusCondition(&po.Status.Conditions, metav1.Condition{
+			Type:    platformtypes.TypeImagePinned,
+			Status:  metav1.ConditionFalse,
+			Reason:  platformtypes.ReasonImagePinFailed,
+			Message: fmt.Sprintf("Failed to pin the image of the %s bundle: %v", b.Name, err),
+		})
+		return err
+	}
+
+	msg := fmt.Sprintf("Pinned the image of the %s bundle to the %s digest", b.Name, pinned.Digest)
+	if pinned.Mirror != "" {
+		msg += fmt.Sprintf(", which is available from the %s mirror", pinned.Mirror)
+	}
+	meta.SetStatusCondition(&po.Status.Conditions, metav1.Condition{
+		Type:    platformtypes.TypeImagePinned,
+		Status:  metav1.ConditionTrue,
+		Reason:  platformtypes.ReasonImagePinned,
+		Message: msg,
+	})
+	b.Image = pinned.Image
+	return nil
+}
+
+// verifyBundleSignature checks that the b bundle's image is signed by one of
+// the trusted keys, and reports the result through the po PlatformOperator's
+// SignatureVerified condition. An error is only returned when verification
+// is enforced, which prevents the bundle from being installed. Whatever the
+// outcome, the b bundle's image is pinned to the digest that was checked, so
+// the image that's unpacked is the one the condition reports on, even when
+// its tag moves.
+func (r *PlatformOperatorReconciler) verifyBundleSignature(ctx context.Context, po *platformv1alpha1.PlatformOperator, b *sourcer.Bundle, keychain images.Keychain) error {
+	digest, err := r.Signatures.Verify(ctx, b.Image, keychain)
+	if digest != "" {
+		ref, perr := images.ParseReference(b.Image)
+		if perr != nil {
+			return perr
+		}
+		b.Image = ref.WithDigest(digest).String()
+	}
+	if err != nil {
+		msg := fmt.Sprintf("Failed to verify the signature of the %s bundle image: %v", b.Name, err)
+		if !r.Signatures.Enforced() {
+			msg += ". Installing it anyway as signature verification isn't enforced"
+		}
+		meta.SetStatusCondition(&po.Status.Conditions, metav1.Condition{
+			Type:    platformtypes.TypeSignatureVerified,
+			Status:  metav1.ConditionFalse,
+			Reason:  platformtypes.ReasonSignatureNotVerified,
+			Message: msg,
+		})
+		if r.Signatures.Enforced() {
+			return err
+		}
+		logr.FromContext(ctx).Info("installing a bundle whose signature couldn't be verified", "bundle", b.Name, "error", err.Error())
+		return nil
+	}
+	meta.SetStatusCondition(&po.Status.Conditions, metav1.Condition{
+		Type:    platformtypes.TypeSignatureVerified,
+		Status:  metav1.ConditionTrue,
+		Reason:  platformtypes.ReasonSignatureVerified,
+		Message: fmt.Sprintf("The image of the %s bundle is signed by a trusted key for the %s digest", b.Name, digest),
+	})
+	return nil
+}
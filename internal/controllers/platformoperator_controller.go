@@ -20,21 +20,31 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
 
 	operatorsv1alpha1 "github.com/operator-framework/api/pkg/operators/v1alpha1"
-	rukpakv1alpha2 "github.com/operator-framework/rukpak/api/v1alpha2"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/types"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	logr "sigs.k8s.io/controller-runtime/pkg/log"
 
 	platformv1alpha1 "github.com/openshift/api/platform/v1alpha1"
 	platformtypes "github.com/openshift/platform-operators/api/v1alpha1"
 	"github.com/openshift/platform-operators/internal/applier"
+	"github.com/openshift/platform-operators/internal/images"
+	"github.com/openshift/platform-operators/internal/metrics"
+	"github.com/openshift/platform-operators/internal/preflight"
+	"github.com/openshift/platform-operators/internal/resolution"
 	"github.com/openshift/platform-operators/internal/sourcer"
 	"github.com/openshift/platform-operators/internal/util"
 )
@@ -46,13 +56,75 @@ var (
 // PlatformOperatorReconciler reconciles a PlatformOperator object
 type PlatformOperatorReconciler struct {
 	client.Client
-	Sourcer sourcer.Sourcer
+	Sourcer  sourcer.Sourcer
+	Resolver *resolution.Resolver
+	Applier  applier.Applier
+	// Images pins the resolved bundle images to digests, and is nil when
+	// bundle images are installed by the reference the catalog lists.
+	Images *images.Pinner
+	// Signatures verifies the signatures of the resolved bundle images
+	// before they're installed, and is nil when they aren't verified.
+	Signatures *images.Verifier
+	// Preflight unpacks the resolved bundle images, whose manifests are
+	// checked for conflicts with the cluster before they're installed, and
+	// is nil when bundles aren't checked.
+	Preflight *images.Registry
+	// PermissionPolicy lists the cluster permissions the preflight checks
+	// refuse to install bundles with.
+	PermissionPolicy preflight.PermissionPolicy
+	// APIReader reads Subscriptions, ClusterServiceVersions and Secrets
+	// directly from the API server, so they aren't cached in their entirety.
+	APIReader client.Reader
+	// GlobalPullSecret is the cluster's global pull secret, whose credentials
+	// are used for every PlatformOperator's bundle images, when set.
+	GlobalPullSecret types.NamespacedName
+	// ReferencedSecretNamespace is the namespace the pull secrets that
+	// PlatformOperators reference must be in, so a PlatformOperator can't
+	// copy the credentials of an arbitrary Secret. References are refused
+	// when it's empty.
+	ReferencedSecretNamespace string
+	// PullSecretNamespace is the namespace bundle images are unpacked in,
+	// which the pull secrets are propagated to. Pull secrets aren't
+	// propagated when it's empty.
+	PullSecretNamespace string
+	// UnpackNamespace is the namespace rukpak unpacks bundle images in,
+	// whose unpack pods are diagnosed when unpacking fails. Unpack pods
+	// aren't diagnosed when it's empty.
+	UnpackNamespace string
+	// UnpackTimeout is how long an unpack pod can run without succeeding,
+	// before it's deleted so the unpack is retried. Unpacks aren't retried
+	// when it's zero.
+	UnpackTimeout time.Duration
+	// PodLogs reads the logs of unpack pods, and is nil when they aren't
+	// reported.
+	PodLogs corev1client.PodsGetter
+	// UnpackDeadline and InstallDeadline are how long installation objects
+	// can take to unpack and install their bundles, before PlatformOperators
+	// report they're Degraded and recreate them, depending on their recovery
+	// policy. Installation objects aren't recovered when they're zero.
+	UnpackDeadline  time.Duration
+	InstallDeadline time.Duration
+	// WorkloadGracePeriod is how long the installed workloads can be
+	// unavailable after their rollout last made progress, before
+	// PlatformOperators report that they're unavailable.
+	WorkloadGracePeriod time.Duration
+	// WatchClusterCatalogs refreshes PlatformOperators as catalogd
+	// ClusterCatalogs change, which requires the ClusterCatalog API to be served.
+	WatchClusterCatalogs bool
+
+	// updates caches the available updates of each PlatformOperator.
+	updates updatesCache
+	// upgradeSummaries caches the summary of the changes of each
+	// PlatformOperator's upgrade that's waiting for approval.
+	upgradeSummaries upgradeSummaryCache
 }
 
 //+kubebuilder:rbac:groups=platform.openshift.io,resources=platformoperators,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=platform.openshift.io,resources=platformoperators/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=platform.openshift.io,resources=platformoperators/finalizers,verbs=update
 //+kubebuilder:rbac:groups=operators.coreos.com,resources=catalogsources,verbs=get;list;watch
+//+kubebuilder:rbac:groups=olm.operatorframework.io,resources=clustercatalogs,verbs=get;list;watch
+//+kubebuilder:rbac:groups=olm.operatorframework.io,resources=clusterextensions,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=core.rukpak.io,resources=bundledeployments,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=core.rukpak.io,resources=bundles,verbs=get;list;watch;create;update;patch;delete
 
@@ -68,6 +140,11 @@ func (r *PlatformOperatorReconciler) Reconcile(ctx context.Context, req ctrl.Req
 
 	po := &platformv1alpha1.PlatformOperator{}
 	if err := r.Get(ctx, req.NamespacedName, po); err != nil {
+		if apierrors.IsNotFound(err) {
+			metrics.PendingUpdates.DeleteLabelValues(req.Name)
+			r.updates.evict(req.Name)
+			r.upgradeSummaries.evict(req.Name)
+		}
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
 	defer func() {
@@ -78,7 +155,7 @@ func (r *PlatformOperatorReconciler) Reconcile(ctx context.Context, req ctrl.Req
 		}
 	}()
 
-	bd, err := r.ensureDesiredBundleDeployment(ctx, po)
+	obj, err := r.ensureDesiredInstallation(ctx, po)
 	if err != nil {
 		// check whether we failed to return an active BundleDeployment
 		// resource due to sourcing failures. These sourcing failures are
@@ -88,66 +165,209 @@ func (r *PlatformOperatorReconciler) Reconcile(ctx context.Context, req ctrl.Req
 		if errors.Is(err, errSourceFailed) {
 			reason = platformtypes.ReasonSourceFailed
 		}
+		if errors.Is(err, errDependenciesPending) {
+			reason = platformtypes.ReasonInstallPending
+		}
+		if errors.Is(err, applier.ErrOwnershipConflict) {
+			reason = platformtypes.ReasonOwnershipConflict
+		}
 		meta.SetStatusCondition(&po.Status.Conditions, metav1.Condition{
 			Type:    platformtypes.TypeInstalled,
 			Status:  metav1.ConditionFalse,
 			Reason:  reason,
 			Message: err.Error(),
 		})
+		// avoid requeueing while dependencies are being installed, or until
+		// they can be satisfied, as the controller watches PlatformOperators
+		// and catalogs for those changes.
+		if errors.Is(err, errDependenciesPending) || errors.Is(err, errDependenciesUnsatisfied) {
+			return ctrl.Result{}, nil
+		}
 		return ctrl.Result{}, err
 	}
+	if obj == nil {
+		// the adopted Subscription's objects are still being released, which
+		// doesn't produce any events, so the release is checked again later.
+		meta.SetStatusCondition(&po.Status.Conditions, metav1.Condition{
+			Type:    platformtypes.TypeInstalled,
+			Status:  metav1.ConditionFalse,
+			Reason:  platformtypes.ReasonInstallPending,
+			Message: "Waiting for the adopted Subscription to release the objects it installed",
+		})
+		return ctrl.Result{RequeueAfter: releaseRecheckInterval}, nil
+	}
 
-	// check whether the generated BundleDeployment are reporting any
+	r.reportAvailableUpdates(ctx, po, obj)
+
+	// check whether the generated installation object is reporting any
 	// failures when attempting to unpack the configured registry+v1
 	// bundle contents, or persisting those unpack contents to the cluster.
-	if failureCond := util.InspectBundleDeployment(ctx, bd.Status.Conditions); failureCond != nil {
-		// avoid returning an error here as the controller is watching for BD resource
-		// events. this should avoid unnecessary requeues when the BD is still in the
-		// same state.
+	if failureCond := r.Applier.Inspect(ctx, obj); failureCond != nil {
+		// the installation object's unpack failures are often vague, so they're
+		// expanded with the diagnostics of the pod unpacking the bundle.
+		recheckIn, err := r.diagnoseUnpack(ctx, obj, failureCond)
+		// avoid returning an error here as the controller is watching for installation
+		// object events. this should avoid unnecessary requeues when the object is still
+		// in the same state.
 		meta.SetStatusCondition(&po.Status.Conditions, *failureCond)
-		return ctrl.Result{}, nil
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		// the Subscription the PlatformOperator has adopted keeps managing
+		// the package until the installation object has installed it.
+		if adoptionEnabled(po) {
+			if err := r.reconcileAdoption(ctx, po, false); err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+		// installation objects that are stuck don't produce any events, so
+		// they're checked again once they pass their deadline.
+		deadlineIn, err := r.recoverStuckInstallation(ctx, po, obj)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if recheckIn == 0 || (deadlineIn != 0 && deadlineIn < recheckIn) {
+			recheckIn = deadlineIn
+		}
+		return ctrl.Result{RequeueAfter: recheckIn}, nil
+	}
+	gvk, err := apiutil.GVKForObject(obj, r.Scheme())
+	if err != nil {
+		return ctrl.Result{}, err
 	}
 	meta.SetStatusCondition(&po.Status.Conditions, metav1.Condition{
 		Type:    platformtypes.TypeInstalled,
 		Status:  metav1.ConditionTrue,
 		Reason:  platformtypes.ReasonInstallSuccessful,
-		Message: fmt.Sprintf("Successfully applied the %s BundleDeployment resource", bd.GetName()),
+		Message: fmt.Sprintf("Successfully applied the %s %s resource", obj.GetName(), gvk.Kind),
+	})
+	platformtypes.SetActiveBundleDeployment(po, obj.GetName())
+	meta.SetStatusCondition(&po.Status.Conditions, metav1.Condition{
+		Type:    platformtypes.TypeDegraded,
+		Status:  metav1.ConditionFalse,
+		Reason:  platformtypes.ReasonInstallSuccessful,
+		Message: "The bundle was unpacked and installed",
 	})
-	platformtypes.SetActiveBundleDeployment(po, bd.GetName())
+	meta.RemoveStatusCondition(&po.Status.Conditions, platformtypes.TypeRecreated)
+
+	// retire the Subscription the PlatformOperator has adopted now that the
+	// installation object has successfully taken over managing the package.
+	if adoptionEnabled(po) {
+		if err := r.reconcileAdoption(ctx, po, true); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
 
-	return ctrl.Result{}, nil
+	// the installation object only reports that the bundle was applied, so
+	// the workloads it installed are inspected for whether they're running.
+	// Problems still within their grace period are checked again once it
+	// expires, as they may not trigger another Deployment event.
+	return ctrl.Result{RequeueAfter: r.reportWorkloadHealth(ctx, po, obj)}, nil
 }
 
-func (r *PlatformOperatorReconciler) ensureDesiredBundleDeployment(ctx context.Context, po *platformv1alpha1.PlatformOperator) (*rukpakv1alpha2.BundleDeployment, error) {
-	bd := &rukpakv1alpha2.BundleDeployment{}
+func (r *PlatformOperatorReconciler) ensureDesiredInstallation(ctx context.Context, po *platformv1alpha1.PlatformOperator) (client.Object, error) {
+	pullSecret, keychain, err := r.reconcilePullSecret(ctx, po)
+	if err != nil {
+		return nil, err
+	}
 
-	// check whether the underlying BD has already been generated to determine
-	// whether the sourcing logic needs to be run to avoid performing unnecessary
-	// work given upgrades aren't supported during phase 0. Note: this logic
-	// doesn't compare the current and desired status of the BD resource so it's
-	// possible that users/controllers/etc. can modify the generated BD resource.
+	// check whether the underlying installation object has already been generated
+	// to determine whether the sourcing logic needs to be run to avoid performing
+	// unnecessary work given upgrades are opt-in. Note: this
+	// logic doesn't compare the current and desired status of the object so it's
+	// possible that users/controllers/etc. can modify the generated resource.
 	// See https://github.com/openshift/platform-operators/issues/47 for more details.
-	if err := r.Get(ctx, types.NamespacedName{Name: po.GetName()}, bd); err != nil {
-		if !apierrors.IsNotFound(err) {
-			return nil, err
+	obj, err := r.Applier.Get(ctx, po)
+	if err == nil {
+		// PlatformOperators that opted into upgrades are the exception, whose
+		// installation objects move to the preferred update once approved.
+		if upgradesEnabled(po) {
+			if err := r.upgrade(ctx, po, obj, keychain); err != nil {
+				return nil, err
+			}
 		}
-		sourcedBundle, err := r.Sourcer.Source(ctx, po)
-		if err != nil {
+		// the pull secret is kept in sync too, so credentials can be added
+		// or removed after installation.
+		if applier.SetPullSecret(obj, pullSecret) {
+			if err := r.Update(ctx, obj); err != nil {
+				return nil, err
+			}
+		}
+		return obj, nil
+	}
+	if !apierrors.IsNotFound(err) {
+		return nil, err
+	}
+	adopted, sub, err := r.adoptedBundle(ctx, po)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", err, errSourceFailed)
+	}
+	selection, err := r.resolve(ctx, po, adopted)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", err, errSourceFailed)
+	}
+	if r.Images != nil {
+		if err := r.pinBundleImage(ctx, po, &selection.Bundle, keychain); err != nil {
 			return nil, fmt.Errorf("%v: %w", err, errSourceFailed)
 		}
-		bd = applier.NewBundleDeployment(po, sourcedBundle.Image)
-		if err := r.Create(ctx, bd); err != nil {
+	}
+	if r.Signatures != nil {
+		if err := r.verifyBundleSignature(ctx, po, &selection.Bundle, keychain); err != nil {
 			return nil, err
 		}
 	}
-	return bd, nil
+	// the installation object is named after the PlatformOperator, unless
+	// another owner's object has that name, which is never taken over.
+	name, err := applier.AvailableName(ctx, r.APIReader, po, r.Applier.ObjectType)
+	if err != nil {
+		return nil, err
+	}
+	if r.Preflight != nil {
+		if err := r.runPreflight(ctx, po, name, &selection.Bundle, keychain); err != nil {
+			return nil, err
+		}
+	}
+	// install the bundle's dependencies first, so the bundle's installation
+	// object is only created once everything it requires is available.
+	if err := r.reconcileDependencies(ctx, po, &selection.Bundle, selection.Dependencies); err != nil {
+		return nil, err
+	}
+	// the installation object can only take over the objects of an adopted
+	// Subscription once its ClusterServiceVersion has released them.
+	if sub != nil {
+		released, err := r.releaseSubscription(ctx, po, sub)
+		if err != nil || !released {
+			return nil, err
+		}
+	}
+	obj = r.Applier.Build(po, &selection.Bundle)
+	obj.SetName(name)
+	applier.SetPullSecret(obj, pullSecret)
+	if err := r.Create(ctx, obj); err != nil {
+		return nil, err
+	}
+	return obj, nil
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *PlatformOperatorReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &platformv1alpha1.PlatformOperator{}, pullSecretIndex, indexPullSecret); err != nil {
+		return err
+	}
+	b := ctrl.NewControllerManagedBy(mgr).
 		For(&platformv1alpha1.PlatformOperator{}).
 		Watches(&operatorsv1alpha1.CatalogSource{}, handler.EnqueueRequestsFromMapFunc(util.RequeuePlatformOperators(mgr.GetClient()))).
-		Watches(&rukpakv1alpha2.BundleDeployment{}, handler.EnqueueRequestsFromMapFunc(util.RequeueBundleDeployment(mgr.GetClient()))).
-		Complete(r)
+		Watches(&platformv1alpha1.PlatformOperator{}, handler.EnqueueRequestsFromMapFunc(util.RequeueDependentPlatformOperators(mgr.GetClient()))).
+		Watches(r.Applier.ObjectType(), handler.EnqueueRequestsFromMapFunc(util.RequeueOwnerPlatformOperator(mgr.GetClient()))).
+		Watches(&appsv1.Deployment{}, handler.EnqueueRequestsFromMapFunc(util.RequeueInstallingPlatformOperator(mgr.GetClient(), r.Applier.ObjectType, r.Applier.OwnerLabel()))).
+		// only the metadata of Secrets is watched, which is enough to know
+		// when a pull secret changed. The manager limits the watch to the
+		// namespaces pull secrets are read from and propagated to.
+		Watches(&corev1.Secret{}, handler.EnqueueRequestsFromMapFunc(r.requeuePullSecretUsers), builder.OnlyMetadata)
+	if r.WatchClusterCatalogs {
+		clusterCatalog := &unstructured.Unstructured{}
+		clusterCatalog.SetGroupVersionKind(sourcer.ClusterCatalogGVK)
+		b = b.Watches(clusterCatalog, handler.EnqueueRequestsFromMapFunc(util.RequeuePlatformOperators(mgr.GetClient())))
+	}
+	return b.Complete(r)
 }
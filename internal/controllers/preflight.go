@@ -0,0 +1,83 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	platformv1alpha1 "github.com/openshift/api/platform/v1alpha1"
+	platformtypes "github.com/openshift/platform-operators/api/v1alpha1"
+	"github.com/openshift/platform-operators/internal/images"
+	"github.com/openshift/platform-operators/internal/preflight"
+	"github.com/openshift/platform-operators/internal/sourcer"
+)
+
+//+kubebuilder:rbac:groups=apiextensions.k8s.io,resources=customresourcedefinitions,verbs=get;list
+//+kubebuilder:rbac:groups=apiregistration.k8s.io,resources=apiservices,verbs=get;list
+//+kubebuilder:rbac:groups=admissionregistration.k8s.io,resources=validatingwebhookconfigurations;mutatingwebhookconfigurations,verbs=get;list
+
+// runPreflight unpacks the manifests of the b bundle's image and checks them
+// for conflicts with what's already installed on the cluster, along with the
+// extra checks, so bundles that would fail to install, or take over another
+// installation's objects, are never handed to the installation object named
+// installation. The result is reported through the po PlatformOperator's
+// PreflightPassed condition, and an error is returned when a check fails.
+func (r *PlatformOperatorReconciler) runPreflight(ctx context.Context, po *platformv1alpha1.PlatformOperator, installation string, b *sourcer.Bundle, keychain images.Keychain, extra ...preflight.Check) error {
+	failed := func(msg string) error {
+		meta.SetStatusCondition(&po.Status.Conditions, metav1.Condition{
+			Type:    platformtypes.TypePreflightPassed,
+			Status:  metav1.ConditionFalse,
+			Reason:  platformtypes.ReasonPreflightFailed,
+			Message: msg,
+		})
+		return errors.New(msg)
+	}
+
+	bundle, err := r.unpackBundle(ctx, b, keychain)
+	if err != nil {
+		return failed(err.Error())
+	}
+
+	owner := preflight.Owner{
+		Name:           installation,
+		AllowUnmanaged: adoptionEnabled(po),
+	}
+	checks := append([]preflight.Check{
+		preflight.CRDOwnership(r.APIReader, owner),
+		preflight.APIServiceConflicts(r.APIReader, owner),
+		preflight.ClusterPermissions(r.PermissionPolicy),
+		preflight.WebhookOverlaps(r.APIReader, owner),
+	}, extra...)
+	failures, err := preflight.Run(ctx, bundle, checks...)
+	if err != nil {
+		return failed(err.Error())
+	}
+	if len(failures) != 0 {
+		reasons := make([]string, 0, len(failures))
+		for _, f := range failures {
+			reasons = append(reasons, f.String())
+		}
+		return failed(fmt.Sprintf("The %s bundle failed preflight checks: %s", b.Name, strings.Join(reasons, "; ")))
+	}
+
+	meta.SetStatusCondition(&po.Status.Conditions, metav1.Condition{
+		Type:    platformtypes.TypePreflightPassed,
+		Status:  metav1.ConditionTrue,
+		Reason:  platformtypes.ReasonPreflightPassed,
+		Message: fmt.Sprintf("The manifests of the %s bundle passed every preflight check", b.Name),
+	})
+	return nil
+}
+
+// unpackBundle returns the manifests of the b bundle's image.
+func (r *PlatformOperatorReconciler) unpackBundle(ctx context.Context, b *sourcer.Bundle, keychain images.Keychain) (*preflight.Bundle, error) {
+	files, err := r.Preflight.WithKeychain(keychain).Unpack(ctx, b.Image, "manifests")
+	if err != nil {
+		return nil, fmt.Errorf("failed to unpack the manifests of the %s bundle: %w", b.Name, err)
+	}
+	return preflight.ParseBundle(b.Name, files)
+}
@@ -0,0 +1,247 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+
+	operatorsv1alpha1 "github.com/operator-framework/api/pkg/operators/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	platformv1alpha1 "github.com/openshift/api/platform/v1alpha1"
+	platformtypes "github.com/openshift/platform-operators/api/v1alpha1"
+	"github.com/openshift/platform-operators/internal/images"
+	"github.com/openshift/platform-operators/internal/sourcer"
+)
+
+//+kubebuilder:rbac:groups="",namespace=openshift-platform-operators,resources=secrets,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",namespace=openshift-config,resources=secrets,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",namespace=openshift-marketplace,resources=secrets,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",namespace=openshift-rukpak,resources=secrets,verbs=get;list;watch;create;update;delete
+
+// pullSecretIndex is the field index of PlatformOperators by the pull secret
+// they reference.
+const pullSecretIndex = "metadata.annotations.pullSecret"
+
+// indexPullSecret returns the pull secret the obj PlatformOperator
+// references, for the pullSecretIndex field index.
+func indexPullSecret(obj client.Object) []string {
+	ref, ok := obj.GetAnnotations()[platformtypes.AnnotationPullSecret]
+	if !ok {
+		return nil
+	}
+	return []string{ref}
+}
+
+// pullSecretName returns the name of the pull secret that's propagated for
+// the po PlatformOperator's bundle images.
+func pullSecretName(po *platformv1alpha1.PlatformOperator) string {
+	return po.GetName() + "-pull-secret"
+}
+
+// reconcilePullSecret merges the pull secrets that apply to the po
+// PlatformOperator, i.e. the cluster's global pull secret, the default
+// CatalogSource's secrets and the secret the PlatformOperator references,
+// with later secrets taking precedence for the same registry or repository.
+// The merged credentials are copied to the namespace the bundle images are
+// unpacked in, and kept in sync as the secrets rotate. The name of that copy,
+// which is empty when there are no credentials, is returned with the
+// credentials.
+func (r *PlatformOperatorReconciler) reconcilePullSecret(ctx context.Context, po *platformv1alpha1.PlatformOperator) (string, images.Keychain, error) {
+	keychain, err := r.pullSecretKeychain(ctx, po)
+	if err != nil {
+		return "", nil, err
+	}
+	if r.PullSecretNamespace == "" {
+		return "", keychain, nil
+	}
+
+	key := types.NamespacedName{Name: pullSecretName(po), Namespace: r.PullSecretNamespace}
+	existing := &corev1.Secret{}
+	if err := r.APIReader.Get(ctx, key, existing); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return "", nil, err
+		}
+		existing = nil
+	}
+	if len(keychain) == 0 {
+		// the credentials were removed, so the stale copy is too.
+		if existing != nil {
+			if err := r.Delete(ctx, existing); client.IgnoreNotFound(err) != nil {
+				return "", nil, fmt.Errorf("failed to delete the %s pull secret: %w", key, err)
+			}
+		}
+		return "", keychain, nil
+	}
+
+	data, err := keychain.DockerConfig()
+	if err != nil {
+		return "", nil, err
+	}
+	if existing == nil {
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace},
+			Type:       corev1.SecretTypeDockerConfigJson,
+			Data:       map[string][]byte{corev1.DockerConfigJsonKey: data},
+		}
+		if err := controllerutil.SetOwnerReference(po, secret, r.Scheme()); err != nil {
+			return "", nil, err
+		}
+		if err := r.Create(ctx, secret); err != nil {
+			return "", nil, fmt.Errorf("failed to create the %s pull secret: %w", key, err)
+		}
+		return key.Name, keychain, nil
+	}
+	if !bytes.Equal(existing.Data[corev1.DockerConfigJsonKey], data) {
+		existing.Data = map[string][]byte{corev1.DockerConfigJsonKey: data}
+		if err := r.Update(ctx, existing); err != nil {
+			return "", nil, fmt.Errorf("failed to update the %s pull secret: %w", key, err)
+		}
+	}
+	return key.Name, keychain, nil
+}
+
+// pullSecretKeychain returns the merged credentials of the pull secrets that
+// apply to the po PlatformOperator. The global and catalog secrets are
+// optional, whereas the secret the PlatformOperator references must exist in
+// the namespace pull secrets can be referenced from.
+func (r *PlatformOperatorReconciler) pullSecretKeychain(ctx context.Context, po *platformv1alpha1.PlatformOperator) (images.Keychain, error) {
+	var keychains []images.Keychain
+	if r.GlobalPullSecret.Name != "" {
+		keychain, err := r.readPullSecret(ctx, r.GlobalPullSecret)
+		if client.IgnoreNotFound(err) != nil {
+			return nil, err
+		}
+		keychains = append(keychains, keychain)
+	}
+
+	if po.GetAnnotations()[platformtypes.AnnotationCatalog] == "" {
+		catalog := &operatorsv1alpha1.CatalogSource{}
+		if err := r.Get(ctx, sourcer.DefaultCatalogSourceKey(), catalog); client.IgnoreNotFound(err) != nil {
+			return nil, err
+		}
+		for _, name := range catalog.Spec.Secrets {
+			keychain, err := r.readPullSecret(ctx, types.NamespacedName{Name: name, Namespace: catalog.GetNamespace()})
+			if client.IgnoreNotFound(err) != nil {
+				return nil, err
+			}
+			keychains = append(keychains, keychain)
+		}
+	}
+
+	if ref, ok := po.GetAnnotations()[platformtypes.AnnotationPullSecret]; ok {
+		key, err := parsePullSecretReference(ref)
+		if err != nil {
+			return nil, err
+		}
+		if key.Namespace != r.ReferencedSecretNamespace {
+			return nil, fmt.Errorf("the %s pull secret referenced by the %s platform operator isn't in the %q namespace that pull secrets can be referenced from", key, po.GetName(), r.ReferencedSecretNamespace)
+		}
+		keychain, err := r.readPullSecret(ctx, key)
+		if err != nil {
+			if apierrors.IsNotFound(err) {
+				return nil, fmt.Errorf("the %s pull secret referenced by the %s platform operator doesn't exist", key, po.GetName())
+			}
+			return nil, err
+		}
+		keychains = append(keychains, keychain)
+	}
+	return images.Keychain{}.Merge(keychains...), nil
+}
+
+// readPullSecret returns the credentials in the kubernetes.io/dockerconfigjson,
+// or legacy kubernetes.io/dockercfg, secret with the key.
+func (r *PlatformOperatorReconciler) readPullSecret(ctx context.Context, key types.NamespacedName) (images.Keychain, error) {
+	secret := &corev1.Secret{}
+	if err := r.APIReader.Get(ctx, key, secret); err != nil {
+		return nil, err
+	}
+	data, ok := secret.Data[corev1.DockerConfigJsonKey]
+	if !ok {
+		legacy, ok := secret.Data[corev1.DockerConfigKey]
+		if !ok {
+			return nil, fmt.Errorf("the %s secret isn't a %s or %s secret", key, corev1.SecretTypeDockerConfigJson, corev1.SecretTypeDockercfg)
+		}
+		data = []byte(fmt.Sprintf(`{"auths":%s}`, legacy))
+	}
+	keychain, err := images.ParseDockerConfig(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the %s pull secret: %w", key, err)
+	}
+	return keychain, nil
+}
+
+// parsePullSecretReference parses the <namespace>/<name> value of the
+// platformtypes.AnnotationPullSecret annotation.
+func parsePullSecretReference(ref string) (types.NamespacedName, error) {
+	namespace, name, ok := strings.Cut(ref, "/")
+	if !ok || namespace == "" || name == "" {
+		return types.NamespacedName{}, fmt.Errorf("invalid %s annotation %q: expected <namespace>/<name>", platformtypes.AnnotationPullSecret, ref)
+	}
+	return types.NamespacedName{Namespace: namespace, Name: name}, nil
+}
+
+// requeuePullSecretUsers requeues the PlatformOperators whose credentials
+// come from the secret that triggered the event, or that own the secret's
+// propagated copy, so the copies are rotated along with the secrets.
+// PlatformOperators are only listed for the secrets they may use.
+func (r *PlatformOperatorReconciler) requeuePullSecretUsers(ctx context.Context, obj client.Object) []reconcile.Request {
+	key := client.ObjectKeyFromObject(obj)
+	if key == r.GlobalPullSecret {
+		return r.requeuePlatformOperators(ctx, nil)
+	}
+
+	var requests []reconcile.Request
+	if r.isCatalogPullSecret(ctx, key) {
+		requests = append(requests, r.requeuePlatformOperators(ctx, func(po platformv1alpha1.PlatformOperator) bool {
+			return po.GetAnnotations()[platformtypes.AnnotationCatalog] == ""
+		})...)
+	}
+	if key.Namespace == r.ReferencedSecretNamespace {
+		requests = append(requests, r.requeuePlatformOperators(ctx, nil, client.MatchingFields{pullSecretIndex: key.String()})...)
+	}
+	if key.Namespace == r.PullSecretNamespace {
+		for _, ref := range obj.GetOwnerReferences() {
+			if ref.APIVersion == platformv1alpha1.GroupVersion.String() && ref.Kind == "PlatformOperator" {
+				requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: ref.Name}})
+			}
+		}
+	}
+	return requests
+}
+
+// requeuePlatformOperators returns requests for the listed PlatformOperators
+// that match, or for all of them when match is nil.
+func (r *PlatformOperatorReconciler) requeuePlatformOperators(ctx context.Context, match func(platformv1alpha1.PlatformOperator) bool, opts ...client.ListOption) []reconcile.Request {
+	poList := &platformv1alpha1.PlatformOperatorList{}
+	if err := r.List(ctx, poList, opts...); err != nil {
+		return nil
+	}
+	var requests []reconcile.Request
+	for _, po := range poList.Items {
+		if match == nil || match(po) {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: po.GetName()}})
+		}
+	}
+	return requests
+}
+
+// isCatalogPullSecret returns whether the default CatalogSource lists the key
+// secret in its spec.secrets.
+func (r *PlatformOperatorReconciler) isCatalogPullSecret(ctx context.Context, key types.NamespacedName) bool {
+	if key.Namespace != sourcer.DefaultCatalogSourceKey().Namespace {
+		return false
+	}
+	catalog := &operatorsv1alpha1.CatalogSource{}
+	if err := r.Get(ctx, sourcer.DefaultCatalogSourceKey(), catalog); err != nil {
+		return false
+	}
+	return containsString(catalog.Spec.Secrets, key.Name)
+}
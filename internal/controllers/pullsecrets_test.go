@@ -0,0 +1,207 @@
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	operatorsv1alpha1 "github.com/operator-framework/api/pkg/operators/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	platformv1alpha1 "github.com/openshift/api/platform/v1alpha1"
+	platformtypes "github.com/openshift/platform-operators/api/v1alpha1"
+	"github.com/openshift/platform-operators/internal/images"
+	"github.com/openshift/platform-operators/internal/sourcer"
+)
+
+const (
+	testUnpackNamespace = "openshift-rukpak"
+	testSystemNamespace = "openshift-platform-operators"
+)
+
+func newTestPullSecret(t *testing.T, key types.NamespacedName, keychain images.Keychain) *corev1.Secret {
+	t.Helper()
+	data, err := keychain.DockerConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace},
+		Type:       corev1.SecretTypeDockerConfigJson,
+		Data:       map[string][]byte{corev1.DockerConfigJsonKey: data},
+	}
+}
+
+func TestReconcilePullSecret(t *testing.T) {
+	var (
+		globalKey     = types.NamespacedName{Name: "pull-secret", Namespace: "openshift-config"}
+		referencedKey = types.NamespacedName{Name: "foo-credentials", Namespace: testSystemNamespace}
+		copyKey       = types.NamespacedName{Name: "foo-pull-secret", Namespace: testUnpackNamespace}
+	)
+	global := newTestPullSecret(t, globalKey, images.Keychain{"registry.redhat.io": {Username: "cluster", Password: "global"}})
+	referenced := newTestPullSecret(t, referencedKey, images.Keychain{"quay.io": {Username: "foo", Password: "v1"}})
+	outside := newTestPullSecret(t, types.NamespacedName{Name: "foo-credentials", Namespace: "default"}, images.Keychain{"quay.io": {Username: "foo", Password: "v1"}})
+	po := newTestPlatformOperator("foo", map[string]string{platformtypes.AnnotationPullSecret: referencedKey.String()})
+
+	rt := newReconcileTest(t, &staticSourcer{}, po, global, referenced, outside)
+	rt.GlobalPullSecret = globalKey
+	rt.PullSecretNamespace = testUnpackNamespace
+	rt.ReferencedSecretNamespace = testSystemNamespace
+	ctx := context.Background()
+
+	copied := func(t *testing.T) images.Keychain {
+		t.Helper()
+		secret := &corev1.Secret{}
+		if err := rt.Get(ctx, copyKey, secret); err != nil {
+			t.Fatalf("failed to get the propagated pull secret: %v", err)
+		}
+		keychain, err := images.ParseDockerConfig(secret.Data[corev1.DockerConfigJsonKey])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return keychain
+	}
+
+	t.Run("Create", func(t *testing.T) {
+		name, keychain, err := rt.reconcilePullSecret(ctx, po)
+		if err != nil {
+			t.Fatalf("reconcilePullSecret() unexpected error: %v", err)
+		}
+		if name != copyKey.Name {
+			t.Errorf("reconcilePullSecret() = %q, want %q", name, copyKey.Name)
+		}
+		want := images.Keychain{
+			"registry.redhat.io": {Username: "cluster", Password: "global"},
+			"quay.io":            {Username: "foo", Password: "v1"},
+		}
+		if !reflect.DeepEqual(keychain, want) {
+			t.Errorf("reconcilePullSecret() keychain = %v, want %v", keychain, want)
+		}
+		if got := copied(t); !reflect.DeepEqual(got, want) {
+			t.Errorf("propagated pull secret = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		rotated := newTestPullSecret(t, referencedKey, images.Keychain{"quay.io": {Username: "foo", Password: "v2"}})
+		referenced.Data = rotated.Data
+		if err := rt.Update(ctx, referenced); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := rt.reconcilePullSecret(ctx, po); err != nil {
+			t.Fatalf("reconcilePullSecret() unexpected error: %v", err)
+		}
+		if got := copied(t)["quay.io"].Password; got != "v2" {
+			t.Errorf("propagated pull secret has the %q password, want the rotated v2 password", got)
+		}
+	})
+
+	t.Run("OutsideReferencedNamespace", func(t *testing.T) {
+		outsider := po.DeepCopy()
+		outsider.SetAnnotations(map[string]string{platformtypes.AnnotationPullSecret: client.ObjectKeyFromObject(outside).String()})
+		_, _, err := rt.reconcilePullSecret(ctx, outsider)
+		if err == nil || !strings.Contains(err.Error(), "can be referenced from") {
+			t.Errorf("reconcilePullSecret() error = %v, want the reference to be refused", err)
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		if err := rt.Delete(ctx, global); err != nil {
+			t.Fatal(err)
+		}
+		unreferenced := po.DeepCopy()
+		unreferenced.SetAnnotations(nil)
+		name, _, err := rt.reconcilePullSecret(ctx, unreferenced)
+		if err != nil {
+			t.Fatalf("reconcilePullSecret() unexpected error: %v", err)
+		}
+		if name != "" {
+			t.Errorf("reconcilePullSecret() = %q, want no pull secret", name)
+		}
+		if err := rt.Get(ctx, copyKey, &corev1.Secret{}); !apierrors.IsNotFound(err) {
+			t.Errorf("the propagated pull secret wasn't deleted: %v", err)
+		}
+	})
+}
+
+func TestRequeuePullSecretUsers(t *testing.T) {
+	catalog := &operatorsv1alpha1.CatalogSource{
+		ObjectMeta: metav1.ObjectMeta{Name: sourcer.DefaultCatalogSourceKey().Name, Namespace: sourcer.DefaultCatalogSourceKey().Namespace},
+		Spec:       operatorsv1alpha1.CatalogSourceSpec{Secrets: []string{"catalog-credentials"}},
+	}
+	var (
+		defaultCatalog = newTestPlatformOperator("default", nil)
+		otherCatalog   = newTestPlatformOperator("other", map[string]string{platformtypes.AnnotationCatalog: "other"})
+		referencing    = newTestPlatformOperator("referencing", map[string]string{
+			platformtypes.AnnotationCatalog:    "other",
+			platformtypes.AnnotationPullSecret: testSystemNamespace + "/referencing-credentials",
+		})
+	)
+	rt := newReconcileTest(t, &staticSourcer{}, catalog, defaultCatalog, otherCatalog, referencing)
+	rt.GlobalPullSecret = types.NamespacedName{Name: "pull-secret", Namespace: "openshift-config"}
+	rt.PullSecretNamespace = testUnpackNamespace
+	rt.ReferencedSecretNamespace = testSystemNamespace
+
+	tests := []struct {
+		name   string
+		secret types.NamespacedName
+		owner  string
+		want   []string
+	}{
+		{
+			name:   "GlobalPullSecret",
+			secret: rt.GlobalPullSecret,
+			want:   []string{"default", "other", "referencing"},
+		},
+		{
+			name:   "CatalogSecret",
+			secret: types.NamespacedName{Name: "catalog-credentials", Namespace: catalog.GetNamespace()},
+			want:   []string{"default"},
+		},
+		{
+			name:   "UnrelatedCatalogNamespaceSecret",
+			secret: types.NamespacedName{Name: "unrelated", Namespace: catalog.GetNamespace()},
+		},
+		{
+			name:   "ReferencedSecret",
+			secret: types.NamespacedName{Name: "referencing-credentials", Namespace: testSystemNamespace},
+			want:   []string{"referencing"},
+		},
+		{
+			name:   "UnreferencedSecret",
+			secret: types.NamespacedName{Name: "unreferenced", Namespace: testSystemNamespace},
+		},
+		{
+			name:   "PropagatedCopy",
+			secret: types.NamespacedName{Name: "other-pull-secret", Namespace: testUnpackNamespace},
+			owner:  "other",
+			want:   []string{"other"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: tt.secret.Name, Namespace: tt.secret.Namespace}}
+			if tt.owner != "" {
+				secret.SetOwnerReferences([]metav1.OwnerReference{{
+					APIVersion: platformv1alpha1.GroupVersion.String(),
+					Kind:       "PlatformOperator",
+					Name:       tt.owner,
+				}})
+			}
+			var got []string
+			for _, req := range rt.requeuePullSecretUsers(context.Background(), secret) {
+				got = append(got, req.Name)
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("requeuePullSecretUsers() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
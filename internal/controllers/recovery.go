@@ -0,0 +1,144 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	rukpakv1alpha2 "github.com/operator-framework/rukpak/api/v1alpha2"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logr "sigs.k8s.io/controller-runtime/pkg/log"
+
+	platformv1alpha1 "github.com/openshift/api/platform/v1alpha1"
+	platformtypes "github.com/openshift/platform-operators/api/v1alpha1"
+)
+
+// maxRecoveryDeadline caps the deadline, which doubles with every attempt
+// to recover a stuck installation object.
+const maxRecoveryDeadline = 24 * time.Hour
+
+func recoveryPolicy(po *platformv1alpha1.PlatformOperator) string {
+	if po.GetAnnotations()[platformtypes.AnnotationRecoveryPolicy] == platformtypes.RecoveryPolicyRecreate {
+		return platformtypes.RecoveryPolicyRecreate
+	}
+	return platformtypes.RecoveryPolicyNone
+}
+
+// recreatedMessage is the message of the Recreated condition, which records
+// the number of recovery attempts.
+const recreatedMessage = "Recreated the stuck installation object %d times since the bundle was last installed"
+
+// recoveryAttempts returns the number of times the po PlatformOperator's
+// installation object was recreated since it was last installed, as recorded
+// by its Recreated condition.
+func recoveryAttempts(po *platformv1alpha1.PlatformOperator) int {
+	c := meta.FindStatusCondition(po.Status.Conditions, platformtypes.TypeRecreated)
+	if c == nil {
+		return 0
+	}
+	var attempts int
+	if _, err := fmt.Sscanf(c.Message, recreatedMessage, &attempts); err != nil || attempts < 0 {
+		return 0
+	}
+	return attempts
+}
+
+// recoveryDeadline returns the deadline after the given number of attempts,
+// which doubles with every attempt, up to maxRecoveryDeadline.
+func recoveryDeadline(deadline time.Duration, attempts int) time.Duration {
+	for i := 0; i < attempts && deadline < maxRecoveryDeadline; i++ {
+		deadline *= 2
+	}
+	if deadline > maxRecoveryDeadline {
+		return maxRecoveryDeadline
+	}
+	return deadline
+}
+
+// recoverStuckInstallation reports the po PlatformOperator as Degraded once
+// the obj installation object has been unpacking or installing its bundle
+// for longer than the unpack or install deadline, and, with the recreate
+// policy, deletes it so it's recreated, unless it has ever installed its
+// bundle. The deadline doubles with every recreation, which the Recreated
+// condition counts until the PlatformOperator is installed. It returns when
+// the installation object should be checked again, as it doesn't change
+// while it's stuck.
+func (r *PlatformOperatorReconciler) recoverStuckInstallation(ctx context.Context, po *platformv1alpha1.PlatformOperator, obj client.Object) (time.Duration, error) {
+	bd, ok := obj.(*rukpakv1alpha2.BundleDeployment)
+	if !ok || !bd.GetDeletionTimestamp().IsZero() {
+		return 0, nil
+	}
+	// the conditions describe the previous spec until rukpak has seen the
+	// last change, e.g. an upgrade, and it reports on the change soon after,
+	// which requeues the PlatformOperator.
+	if bd.Status.ObservedGeneration < bd.GetGeneration() {
+		return 0, nil
+	}
+
+	// the unpack starts once the BundleDeployment is created, or once its
+	// bundle stops being valid, e.g. as an upgrade unpacks the new bundle.
+	phase, deadline, reason := "unpacked", r.UnpackDeadline, platformtypes.ReasonUnpackDeadlineExceeded
+	since := bd.GetCreationTimestamp().Time
+	unpacked := meta.FindStatusCondition(bd.Status.Conditions, rukpakv1alpha2.TypeHasValidBundle)
+	switch {
+	case unpacked != nil && unpacked.Status == metav1.ConditionTrue:
+		phase, deadline, reason = "installed", r.InstallDeadline, platformtypes.ReasonInstallDeadlineExceeded
+		since = unpacked.LastTransitionTime.Time
+	case unpacked != nil && unpacked.LastTransitionTime.Time.After(since):
+		since = unpacked.LastTransitionTime.Time
+	}
+	if deadline == 0 {
+		return 0, nil
+	}
+	attempts := recoveryAttempts(po)
+	deadline = recoveryDeadline(deadline, attempts)
+	if elapsed := time.Since(since); elapsed < deadline {
+		return deadline - elapsed, nil
+	}
+
+	degraded := func(msg string) {
+		meta.SetStatusCondition(&po.Status.Conditions, metav1.Condition{
+			Type:    platformtypes.TypeDegraded,
+			Status:  metav1.ConditionTrue,
+			Reason:  reason,
+			Message: fmt.Sprintf("The bundle wasn't %s within %s after %d recreation attempts, %s", phase, deadline, attempts, msg),
+		})
+	}
+	if recoveryPolicy(po) != platformtypes.RecoveryPolicyRecreate {
+		degraded(fmt.Sprintf("and it's recreated once the %s annotation is set to %s", platformtypes.AnnotationRecoveryPolicy, platformtypes.RecoveryPolicyRecreate))
+		return 0, nil
+	}
+	// recreating a BundleDeployment uninstalls its bundle, which would take
+	// down the previously installed bundle when an upgrade is stuck.
+	if everInstalled(po, bd) {
+		degraded("and it isn't recreated as that would uninstall the previously installed bundle")
+		return 0, nil
+	}
+
+	if err := r.Delete(ctx, bd, client.Preconditions{UID: &bd.UID}); err != nil && !apierrors.IsNotFound(err) {
+		return 0, fmt.Errorf("failed to delete the stuck %s BundleDeployment: %w", bd.GetName(), err)
+	}
+	meta.SetStatusCondition(&po.Status.Conditions, metav1.Condition{
+		Type:    platformtypes.TypeRecreated,
+		Status:  metav1.ConditionTrue,
+		Reason:  platformtypes.ReasonStuckInstallRecreated,
+		Message: fmt.Sprintf(recreatedMessage, attempts+1),
+	})
+	logr.FromContext(ctx).Info("recreating a stuck installation", "name", bd.GetName(), "phase", phase, "attempt", attempts+1)
+	degraded("and it's being recreated")
+	return 0, nil
+}
+
+// everInstalled returns whether the bd BundleDeployment has installed a
+// bundle at some point, which the po PlatformOperator records as its active
+// BundleDeployment, or which rukpak reports as it upgrades the bundle.
+func everInstalled(po *platformv1alpha1.PlatformOperator, bd *rukpakv1alpha2.BundleDeployment) bool {
+	if po.Status.ActiveBundleDeployment.Name == bd.GetName() {
+		return true
+	}
+	installed := meta.FindStatusCondition(bd.Status.Conditions, rukpakv1alpha2.TypeInstalled)
+	return installed != nil && (installed.Status == metav1.ConditionTrue || installed.Reason == rukpakv1alpha2.ReasonUpgradeFailed)
+}
@@ -0,0 +1,202 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	rukpakv1alpha2 "github.com/operator-framework/rukpak/api/v1alpha2"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	platformv1alpha1 "github.com/openshift/api/platform/v1alpha1"
+	platformtypes "github.com/openshift/platform-operators/api/v1alpha1"
+	"github.com/openshift/platform-operators/internal/sourcer"
+)
+
+func TestRecoverStuckInstallation(t *testing.T) {
+	const deadline = 30 * time.Minute
+	b := sourcer.Bundle{Name: "foo.v1.0.0", Image: "quay.io/example/foo:v1.0.0"}
+	ago := func(d time.Duration) metav1.Time { return metav1.NewTime(time.Now().Add(-d)) }
+	unpacking := func(since time.Duration) []metav1.Condition {
+		return []metav1.Condition{{Type: rukpakv1alpha2.TypeHasValidBundle, Status: metav1.ConditionFalse, Reason: rukpakv1alpha2.ReasonUnpackPending, LastTransitionTime: ago(since)}}
+	}
+	installing := func(since time.Duration, reason string) []metav1.Condition {
+		return []metav1.Condition{
+			{Type: rukpakv1alpha2.TypeHasValidBundle, Status: metav1.ConditionTrue, Reason: rukpakv1alpha2.ReasonUnpackSuccessful, LastTransitionTime: ago(since)},
+			{Type: rukpakv1alpha2.TypeInstalled, Status: metav1.ConditionFalse, Reason: reason},
+		}
+	}
+
+	tests := []struct {
+		name string
+		// created is how long ago the BundleDeployment was created.
+		created    time.Duration
+		conditions []metav1.Condition
+		// stale makes the conditions describe a previous generation.
+		stale    bool
+		policy   string
+		attempts int
+		// active is the BundleDeployment the PlatformOperator last installed.
+		active string
+
+		wantRequeue  time.Duration
+		wantReason   string
+		wantMessage  string
+		wantDeleted  bool
+		wantAttempts int
+	}{
+		{
+			name:        "UnpackWithinDeadline",
+			created:     time.Minute,
+			conditions:  unpacking(time.Minute),
+			wantRequeue: deadline - time.Minute,
+		},
+		{
+			// the unpack of an upgraded bundle starts once the bundle
+			// stops being valid, rather than once the BundleDeployment was
+			// created.
+			name:        "UpgradeUnpackWithinDeadline",
+			created:     24 * time.Hour,
+			conditions:  unpacking(time.Minute),
+			wantRequeue: deadline - time.Minute,
+		},
+		{
+			name:       "StaleConditions",
+			created:    24 * time.Hour,
+			conditions: unpacking(24 * time.Hour),
+			stale:      true,
+			policy:     platformtypes.RecoveryPolicyRecreate,
+		},
+		{
+			name:        "UnpackDeadlineExceeded",
+			created:     time.Hour,
+			conditions:  unpacking(time.Hour),
+			wantReason:  platformtypes.ReasonUnpackDeadlineExceeded,
+			wantMessage: "The bundle wasn't unpacked within 30m0s after 0 recreation attempts, and it's recreated once the platform.openshift.io/recovery-policy annotation is set to Recreate",
+		},
+		{
+			name:        "InstallDeadlineExceeded",
+			created:     2 * time.Hour,
+			conditions:  installing(time.Hour, rukpakv1alpha2.ReasonInstallFailed),
+			wantReason:  platformtypes.ReasonInstallDeadlineExceeded,
+			wantMessage: "The bundle wasn't installed within 30m0s",
+		},
+		{
+			name:         "Recreated",
+			created:      time.Hour,
+			conditions:   unpacking(time.Hour),
+			policy:       platformtypes.RecoveryPolicyRecreate,
+			wantReason:   platformtypes.ReasonUnpackDeadlineExceeded,
+			wantMessage:  "after 0 recreation attempts, and it's being recreated",
+			wantDeleted:  true,
+			wantAttempts: 1,
+		},
+		{
+			// the deadline doubles with each attempt.
+			name:         "BackoffWithinDeadline",
+			created:      45 * time.Minute,
+			conditions:   unpacking(45 * time.Minute),
+			policy:       platformtypes.RecoveryPolicyRecreate,
+			attempts:     1,
+			wantRequeue:  2*deadline - 45*time.Minute,
+			wantAttempts: 1,
+		},
+		{
+			name:         "BackoffDeadlineExceeded",
+			created:      2 * time.Hour,
+			conditions:   unpacking(2 * time.Hour),
+			policy:       platformtypes.RecoveryPolicyRecreate,
+			attempts:     1,
+			wantReason:   platformtypes.ReasonUnpackDeadlineExceeded,
+			wantMessage:  "The bundle wasn't unpacked within 1h0m0s after 1 recreation attempts, and it's being recreated",
+			wantDeleted:  true,
+			wantAttempts: 2,
+		},
+		{
+			name:        "PreviouslyInstalled",
+			created:     2 * time.Hour,
+			conditions:  unpacking(time.Hour),
+			policy:      platformtypes.RecoveryPolicyRecreate,
+			active:      "foo",
+			wantReason:  platformtypes.ReasonUnpackDeadlineExceeded,
+			wantMessage: "and it isn't recreated as that would uninstall the previously installed bundle",
+		},
+		{
+			name:        "UpgradeFailed",
+			created:     2 * time.Hour,
+			conditions:  installing(time.Hour, rukpakv1alpha2.ReasonUpgradeFailed),
+			policy:      platformtypes.RecoveryPolicyRecreate,
+			wantReason:  platformtypes.ReasonInstallDeadlineExceeded,
+			wantMessage: "and it isn't recreated as that would uninstall the previously installed bundle",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			po := newTestPlatformOperator("foo", map[string]string{platformtypes.AnnotationRecoveryPolicy: tt.policy})
+			if tt.attempts != 0 {
+				meta.SetStatusCondition(&po.Status.Conditions, metav1.Condition{
+					Type:    platformtypes.TypeRecreated,
+					Status:  metav1.ConditionTrue,
+					Reason:  platformtypes.ReasonStuckInstallRecreated,
+					Message: fmt.Sprintf(recreatedMessage, tt.attempts),
+				})
+			}
+			po.Status.ActiveBundleDeployment = platformv1alpha1.ActiveBundleDeployment{Name: tt.active}
+			bd := newTestBundleDeployment(po, b, tt.conditions...)
+			bd.SetUID("foo-bd-uid")
+			bd.SetCreationTimestamp(ago(tt.created))
+			bd.SetGeneration(2)
+			bd.Status.ObservedGeneration = 2
+			if tt.stale {
+				bd.Status.ObservedGeneration = 1
+			}
+			rt := newReconcileTest(t, &staticSourcer{}, po, bd)
+			rt.UnpackDeadline = deadline
+			rt.InstallDeadline = deadline
+
+			requeue, err := rt.recoverStuckInstallation(context.Background(), po, bd)
+			if err != nil {
+				t.Fatalf("recoverStuckInstallation() unexpected error: %v", err)
+			}
+			// the requeue is measured from the current time, which keeps moving.
+			if requeue > tt.wantRequeue || requeue < tt.wantRequeue-time.Minute {
+				t.Errorf("recoverStuckInstallation() requeue = %s, want %s", requeue, tt.wantRequeue)
+			}
+
+			c := meta.FindStatusCondition(po.Status.Conditions, platformtypes.TypeDegraded)
+			if tt.wantReason == "" {
+				if c != nil {
+					t.Errorf("Degraded condition = %+v, want none", c)
+				}
+			} else if c == nil || c.Status != metav1.ConditionTrue || c.Reason != tt.wantReason || !strings.Contains(c.Message, tt.wantMessage) {
+				t.Errorf("Degraded condition = %+v, want reason %s and a message containing %q", c, tt.wantReason, tt.wantMessage)
+			}
+
+			err = rt.Get(context.Background(), client.ObjectKeyFromObject(bd), &rukpakv1alpha2.BundleDeployment{})
+			if deleted := apierrors.IsNotFound(err); deleted != tt.wantDeleted {
+				t.Errorf("BundleDeployment deleted = %v, want %v", deleted, tt.wantDeleted)
+			}
+			if got := recoveryAttempts(po); got != tt.wantAttempts {
+				t.Errorf("recovery attempts = %d, want %d", got, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestRecoveryDeadline(t *testing.T) {
+	for attempts, want := range map[int]time.Duration{
+		0:  30 * time.Minute,
+		1:  time.Hour,
+		3:  4 * time.Hour,
+		10: maxRecoveryDeadline,
+	} {
+		if got := recoveryDeadline(30*time.Minute, attempts); got != want {
+			t.Errorf("recoveryDeadline() after %d attempts = %s, want %s", attempts, got, want)
+		}
+	}
+}
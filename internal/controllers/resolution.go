@@ -0,0 +1,189 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	logr "sigs.k8s.io/controller-runtime/pkg/log"
+
+	platformv1alpha1 "github.com/openshift/api/platform/v1alpha1"
+	platformtypes "github.com/openshift/platform-operators/api/v1alpha1"
+	"github.com/openshift/platform-operators/internal/applier"
+	"github.com/openshift/platform-operators/internal/resolution"
+	"github.com/openshift/platform-operators/internal/sourcer"
+)
+
+// resolve selects the bundle to install for the po PlatformOperator by
+// resolving every PlatformOperator in the cluster together, so po's bundle
+// doesn't conflict with what the other PlatformOperators have installed or
+// will install. The adopted bundle, when po is adopting a Subscription, is
+// kept. The outcome is reported through the Resolved condition.
+func (r *PlatformOperatorReconciler) resolve(ctx context.Context, po *platformv1alpha1.PlatformOperator, adopted *sourcer.Bundle) (*resolution.Selection, error) {
+	log := logr.FromContext(ctx)
+
+	pos, installed, err := r.resolutionInput(ctx, po)
+	if err != nil {
+		return nil, err
+	}
+	if adopted != nil {
+		installed[po.GetName()] = *adopted
+	}
+
+	solution, err := r.Resolver.Resolve(ctx, pos, installed)
+	if err == nil {
+		// po was left out of the resolution, which doesn't affect the
+		// other PlatformOperators.
+		err = solution.Unresolvable[po.GetName()]
+	}
+	if err != nil {
+		meta.SetStatusCondition(&po.Status.Conditions, metav1.Condition{
+			Type:    platformtypes.TypeResolved,
+			Status:  metav1.ConditionFalse,
+			Reason:  platformtypes.ReasonResolutionFailed,
+			Message: err.Error(),
+		})
+		return nil, err
+	}
+	var selected []string
+	for _, b := range solution.Bundles {
+		selected = append(selected, b.Name)
+	}
+	log.V(1).Info("resolved platform operators", "bundles", selected)
+
+	selection := solution.Selections[po.GetName()]
+	meta.SetStatusCondition(&po.Status.Conditions, metav1.Condition{
+		Type:   platformtypes.TypeResolved,
+		Status: metav1.ConditionTrue,
+		Reason: platformtypes.ReasonResolutionSuccessful,
+		Message: fmt.Sprintf("Selected the %s bundle from the %s channel. The cluster-wide resolution selected: %s",
+			selection.Bundle.Name, selection.Bundle.Channel, strings.Join(selected, ", ")),
+	})
+	setCandidatesFilteredCondition(po, selection.Rejections)
+	setDeprecatedCondition(po, selection.Bundle)
+	return &selection, nil
+}
+
+// resolveUpgrade selects the bundle the po PlatformOperator's installed
+// bundle is upgraded to from its updates, by resolving po together with every
+// other PlatformOperator in the cluster, whose installed bundles are kept. An
+// error is returned when none of the updates can be selected.
+func (r *PlatformOperatorReconciler) resolveUpgrade(ctx context.Context, po *platformv1alpha1.PlatformOperator, updates []sourcer.Bundle) (*resolution.Selection, error) {
+	pos, installed, err := r.resolutionInput(ctx, po)
+	if err != nil {
+		return nil, err
+	}
+	solution, err := r.Resolver.ResolveUpgrade(ctx, pos, installed, po.GetName(), updates)
+	if err != nil {
+		return nil, err
+	}
+	if err := solution.Unresolvable[po.GetName()]; err != nil {
+		return nil, err
+	}
+	selection := solution.Selections[po.GetName()]
+	return &selection, nil
+}
+
+// resolutionInput returns every PlatformOperator in the cluster, with po
+// first, along with the bundles they've installed.
+func (r *PlatformOperatorReconciler) resolutionInput(ctx context.Context, po *platformv1alpha1.PlatformOperator) ([]platformv1alpha1.PlatformOperator, map[string]sourcer.Bundle, error) {
+	poList := &platformv1alpha1.PlatformOperatorList{}
+	if err := r.List(ctx, poList); err != nil {
+		return nil, nil, err
+	}
+	// prefer the copy of po that's being reconciled over the cached one.
+	pos := []platformv1alpha1.PlatformOperator{*po}
+	for _, item := range poList.Items {
+		if item.GetName() != po.GetName() {
+			pos = append(pos, item)
+		}
+	}
+	installed, err := r.installedBundles(ctx, pos)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pos, installed, nil
+}
+
+// setDeprecatedCondition reports whether the catalog deprecated the package,
+// channel or bundle that was selected for the po PlatformOperator.
+func setDeprecatedCondition(po *platformv1alpha1.PlatformOperator, b sourcer.Bundle) {
+	if !b.Deprecation.IsDeprecated() {
+		meta.SetStatusCondition(&po.Status.Conditions, metav1.Condition{
+			Type:    platformtypes.TypeDeprecated,
+			Status:  metav1.ConditionFalse,
+			Reason:  platformtypes.ReasonNotDeprecated,
+			Message: fmt.Sprintf("The %s bundle isn't deprecated", b.Name),
+		})
+		return
+	}
+	meta.SetStatusCondition(&po.Status.Conditions, metav1.Condition{
+		Type:    platformtypes.TypeDeprecated,
+		Status:  metav1.ConditionTrue,
+		Reason:  platformtypes.ReasonDeprecated,
+		Message: b.Deprecation.Describe(b),
+	})
+}
+
+// maxReportedRejections caps the number of rejected candidates that are listed
+// in the CandidatesFiltered condition's message.
+const maxReportedRejections = 10
+
+// setCandidatesFilteredCondition summarizes which filter rejected each of the
+// po PlatformOperator's candidates that couldn't be selected, and why.
+func setCandidatesFilteredCondition(po *platformv1alpha1.PlatformOperator, rejections []sourcer.Rejection) {
+	if len(rejections) == 0 {
+		meta.SetStatusCondition(&po.Status.Conditions, metav1.Condition{
+			Type:    platformtypes.TypeCandidatesFiltered,
+			Status:  metav1.ConditionFalse,
+			Reason:  platformtypes.ReasonCandidatesAccepted,
+			Message: "No candidate bundles were rejected",
+		})
+		return
+	}
+	var reported []string
+	for i, r := range rejections {
+		if i == maxReportedRejections {
+			reported = append(reported, fmt.Sprintf("and %d more", len(rejections)-i))
+			break
+		}
+		reported = append(reported, r.String())
+	}
+	meta.SetStatusCondition(&po.Status.Conditions, metav1.Condition{
+		Type:    platformtypes.TypeCandidatesFiltered,
+		Status:  metav1.ConditionTrue,
+		Reason:  platformtypes.ReasonCandidatesRejected,
+		Message: fmt.Sprintf("Rejected %d candidate bundles: %s", len(rejections), strings.Join(reported, "; ")),
+	})
+}
+
+// installedBundles returns the bundle installed for each of the pos
+// PlatformOperators, keyed by PlatformOperator name, so resolution doesn't
+// select a different bundle for a PlatformOperator that's already installed.
+func (r *PlatformOperatorReconciler) installedBundles(ctx context.Context, pos []platformv1alpha1.PlatformOperator) (map[string]sourcer.Bundle, error) {
+	installed := make(map[string]sourcer.Bundle)
+	for i := range pos {
+		obj, err := r.Applier.Get(ctx, &pos[i])
+		if err != nil {
+			if apierrors.IsNotFound(err) || errors.Is(err, applier.ErrOwnershipConflict) {
+				continue
+			}
+			return nil, err
+		}
+		annotations := obj.GetAnnotations()
+		if annotations[platformtypes.AnnotationBundleName] == "" {
+			continue
+		}
+		installed[pos[i].GetName()] = sourcer.Bundle{
+			Name:    annotations[platformtypes.AnnotationBundleName],
+			Package: pos[i].Spec.Package.Name,
+			Version: annotations[platformtypes.AnnotationBundleVersion],
+			Channel: annotations[platformtypes.AnnotationBundleChannel],
+		}
+	}
+	return installed, nil
+}
@@ -0,0 +1,39 @@
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	platformv1alpha1 "github.com/openshift/api/platform/v1alpha1"
+	"github.com/openshift/platform-operators/internal/applier"
+	"github.com/openshift/platform-operators/internal/sourcer"
+)
+
+func TestInstalledBundles(t *testing.T) {
+	foo := newTestPlatformOperator("foo", nil)
+	bar := newTestPlatformOperator("bar", nil)
+	baz := newTestPlatformOperator("baz", nil)
+	other := newTestPlatformOperator("other", nil)
+	b := sourcer.Bundle{Name: "foo.v1.0.0", Version: "1.0.0", Channel: "stable", Image: "quay.io/example/foo:v1.0.0"}
+
+	objs := []client.Object{newTestBundleDeployment(foo, b, installedConditions()...)}
+	// every name bar's BundleDeployment can have is taken by another owner.
+	for _, name := range applier.ObjectNames(bar) {
+		bd := newTestBundleDeployment(other, sourcer.Bundle{Name: "other.v1.0.0", Image: "quay.io/example/other:v1.0.0"})
+		bd.SetName(name)
+		objs = append(objs, bd)
+	}
+	rt := newReconcileTest(t, &staticSourcer{}, objs...)
+
+	installed, err := rt.installedBundles(context.Background(), []platformv1alpha1.PlatformOperator{*foo, *bar, *baz})
+	if err != nil {
+		t.Fatalf("installedBundles() unexpected error: %v", err)
+	}
+	want := sourcer.Bundle{Name: b.Name, Package: "foo", Version: b.Version, Channel: b.Channel}
+	if len(installed) != 1 || !reflect.DeepEqual(installed["foo"], want) {
+		t.Errorf("installedBundles() = %+v, want only foo's %+v", installed, want)
+	}
+}
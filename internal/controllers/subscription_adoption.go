@@ -0,0 +1,224 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	operatorsv1alpha1 "github.com/operator-framework/api/pkg/operators/v1alpha1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	platformv1alpha1 "github.com/openshift/api/platform/v1alpha1"
+	platformtypes "github.com/openshift/platform-operators/api/v1alpha1"
+	"github.com/openshift/platform-operators/internal/sourcer"
+)
+
+var (
+	errInstalledVersionNotFound = errors.New("the installed version isn't available in the catalog")
+)
+
+// releaseRecheckInterval is how often an adopted Subscription's
+// ClusterServiceVersion is checked for having released its objects.
+const releaseRecheckInterval = 5 * time.Second
+
+//+kubebuilder:rbac:groups=operators.coreos.com,resources=subscriptions,verbs=get;list;watch;patch;delete
+//+kubebuilder:rbac:groups=operators.coreos.com,resources=clusterserviceversions,verbs=get;list;watch;delete
+
+func adoptionEnabled(po *platformv1alpha1.PlatformOperator) bool {
+	return po.GetAnnotations()[platformtypes.AnnotationAdoptSubscription] == "true"
+}
+
+// adoptedBundle returns the bundle matching the ClusterServiceVersion that the
+// Subscription adopted by the po PlatformOperator has installed, so the
+// installation doesn't change the running operator's version, along with that
+// Subscription. A nil bundle is returned when po hasn't opted into adoption,
+// or there's no Subscription to adopt.
+func (r *PlatformOperatorReconciler) adoptedBundle(ctx context.Context, po *platformv1alpha1.PlatformOperator) (*sourcer.Bundle, *operatorsv1alpha1.Subscription, error) {
+	if !adoptionEnabled(po) {
+		return nil, nil, nil
+	}
+	sub, err := r.findSubscription(ctx, po)
+	if err != nil {
+		return nil, nil, err
+	}
+	if sub == nil {
+		return nil, nil, nil
+	}
+
+	b, err := r.sourceAdoptedBundle(ctx, po, sub)
+	if err != nil {
+		meta.SetStatusCondition(&po.Status.Conditions, metav1.Condition{
+			Type:    platformtypes.TypeSubscriptionAdopted,
+			Status:  metav1.ConditionFalse,
+			Reason:  platformtypes.ReasonInstalledVersionNotFound,
+			Message: err.Error(),
+		})
+		return nil, nil, err
+	}
+	return b, sub, nil
+}
+
+// releaseSubscription hands the objects installed by the sub Subscription over
+// to the po PlatformOperator. OLM is stopped from installing the package again
+// by requiring manual approval of the Subscription's InstallPlans, and the
+// ClusterServiceVersion it installed is deleted with an orphan propagation
+// policy, so the operator's workloads keep running without an owner. It
+// reports whether the ClusterServiceVersion is gone, and has released them.
+func (r *PlatformOperatorReconciler) releaseSubscription(ctx context.Context, po *platformv1alpha1.PlatformOperator, sub *operatorsv1alpha1.Subscription) (bool, error) {
+	if sub.Spec.InstallPlanApproval != operatorsv1alpha1.ApprovalManual {
+		patch := client.MergeFrom(sub.DeepCopy())
+		sub.Spec.InstallPlanApproval = operatorsv1alpha1.ApprovalManual
+		if err := r.Patch(ctx, sub, patch); err != nil {
+			return false, fmt.Errorf("failed to require manual approval for the %s Subscription: %w", client.ObjectKeyFromObject(sub), err)
+		}
+	}
+
+	csv := &operatorsv1alpha1.ClusterServiceVersion{}
+	if err := r.APIReader.Get(ctx, client.ObjectKey{Namespace: sub.GetNamespace(), Name: sub.Status.InstalledCSV}, csv); err != nil {
+		if apierrors.IsNotFound(err) {
+			return true, nil
+		}
+		return false, err
+	}
+	if csv.GetDeletionTimestamp().IsZero() {
+		if err := r.Delete(ctx, csv, client.PropagationPolicy(metav1.DeletePropagationOrphan)); client.IgnoreNotFound(err) != nil {
+			return false, fmt.Errorf("failed to delete the %s ClusterServiceVersion: %w", client.ObjectKeyFromObject(csv), err)
+		}
+	}
+	meta.SetStatusCondition(&po.Status.Conditions, metav1.Condition{
+		Type:    platformtypes.TypeSubscriptionAdopted,
+		Status:  metav1.ConditionFalse,
+		Reason:  platformtypes.ReasonAdoptionPending,
+		Message: fmt.Sprintf("Waiting for the %s ClusterServiceVersion to release the objects it installed", client.ObjectKeyFromObject(csv)),
+	})
+	return false, nil
+}
+
+// reconcileAdoption retires the Subscription that was adopted by the po
+// PlatformOperator once its installation object has installed the bundle,
+// and reports the progress of the adoption through the SubscriptionAdopted
+// condition. Until then, the Subscription is left managing the package.
+func (r *PlatformOperatorReconciler) reconcileAdoption(ctx context.Context, po *platformv1alpha1.PlatformOperator, installed bool) error {
+	sub, err := r.findSubscription(ctx, po)
+	if err != nil {
+		return err
+	}
+	if sub == nil {
+		// the Subscription has either already been retired, or never existed.
+		if meta.IsStatusConditionTrue(po.Status.Conditions, platformtypes.TypeSubscriptionAdopted) {
+			return nil
+		}
+		meta.SetStatusCondition(&po.Status.Conditions, metav1.Condition{
+			Type:    platformtypes.TypeSubscriptionAdopted,
+			Status:  metav1.ConditionFalse,
+			Reason:  platformtypes.ReasonSubscriptionNotFound,
+			Message: fmt.Sprintf("No Subscription is installing the %s package", po.Spec.Package.Name),
+		})
+		return nil
+	}
+	if !installed {
+		meta.SetStatusCondition(&po.Status.Conditions, metav1.Condition{
+			Type:    platformtypes.TypeSubscriptionAdopted,
+			Status:  metav1.ConditionFalse,
+			Reason:  platformtypes.ReasonAdoptionPending,
+			Message: fmt.Sprintf("The %s Subscription is retired once the bundle is installed", client.ObjectKeyFromObject(sub)),
+		})
+		return nil
+	}
+
+	if err := r.retireSubscription(ctx, sub); err != nil {
+		meta.SetStatusCondition(&po.Status.Conditions, metav1.Condition{
+			Type:    platformtypes.TypeSubscriptionAdopted,
+			Status:  metav1.ConditionFalse,
+			Reason:  platformtypes.ReasonRetireFailed,
+			Message: err.Error(),
+		})
+		return err
+	}
+	meta.SetStatusCondition(&po.Status.Conditions, metav1.Condition{
+		Type:    platformtypes.TypeSubscriptionAdopted,
+		Status:  metav1.ConditionTrue,
+		Reason:  platformtypes.ReasonAdoptionSuccessful,
+		Message: fmt.Sprintf("Adopted the %s Subscription and its %s ClusterServiceVersion", client.ObjectKeyFromObject(sub), sub.Status.InstalledCSV),
+	})
+	return nil
+}
+
+// findSubscription returns the Subscription installing the po PlatformOperator's
+// package from the CatalogSource po is sourced from, or nil when there's no
+// such Subscription. PlatformOperators that select a catalog aren't sourced
+// from a CatalogSource, so they never adopt a Subscription.
+func (r *PlatformOperatorReconciler) findSubscription(ctx context.Context, po *platformv1alpha1.PlatformOperator) (*operatorsv1alpha1.Subscription, error) {
+	if po.GetAnnotations()[platformtypes.AnnotationCatalog] != "" {
+		return nil, nil
+	}
+	catalog := sourcer.DefaultCatalogSourceKey()
+
+	subs := &operatorsv1alpha1.SubscriptionList{}
+	if err := r.APIReader.List(ctx, subs); err != nil {
+		return nil, err
+	}
+
+	var matches []operatorsv1alpha1.Subscription
+	for _, sub := range subs.Items {
+		if sub.Spec == nil || sub.Spec.Package != po.Spec.Package.Name {
+			continue
+		}
+		if sub.Spec.CatalogSource != catalog.Name || sub.Spec.CatalogSourceNamespace != catalog.Namespace {
+			continue
+		}
+		matches = append(matches, sub)
+	}
+	switch len(matches) {
+	case 0:
+		return nil, nil
+	case 1:
+		return &matches[0], nil
+	default:
+		return nil, fmt.Errorf("found %d Subscriptions installing the %s package, refusing to adopt any of them", len(matches), po.Spec.Package.Name)
+	}
+}
+
+// sourceAdoptedBundle returns the catalog bundle containing the
+// ClusterServiceVersion installed by the sub Subscription, preferring the
+// bundle from the channel the Subscription was tracking. Bundles are named
+// after the ClusterServiceVersion they contain, so the bundle is still found
+// once that ClusterServiceVersion has been deleted.
+func (r *PlatformOperatorReconciler) sourceAdoptedBundle(ctx context.Context, po *platformv1alpha1.PlatformOperator, sub *operatorsv1alpha1.Subscription) (*sourcer.Bundle, error) {
+	if sub.Status.InstalledCSV == "" {
+		return nil, fmt.Errorf("the %s Subscription hasn't installed a ClusterServiceVersion yet", client.ObjectKeyFromObject(sub))
+	}
+
+	candidates, err := r.Sourcer.Candidates(ctx, po)
+	if err != nil {
+		return nil, err
+	}
+	var adopted *sourcer.Bundle
+	for _, b := range candidates {
+		b := b
+		if b.Name != sub.Status.InstalledCSV {
+			continue
+		}
+		if adopted == nil || b.Channel == sub.Spec.Channel {
+			adopted = &b
+		}
+	}
+	if adopted == nil {
+		return nil, fmt.Errorf("failed to adopt the %s ClusterServiceVersion: %w", sub.Status.InstalledCSV, errInstalledVersionNotFound)
+	}
+	return adopted, nil
+}
+
+// retireSubscription deletes the sub Subscription, once its
+// ClusterServiceVersion has released the objects it installed and the
+// installation object has taken them over.
+func (r *PlatformOperatorReconciler) retireSubscription(ctx context.Context, sub *operatorsv1alpha1.Subscription) error {
+	if err := r.Delete(ctx, sub); client.IgnoreNotFound(err) != nil {
+		return fmt.Errorf("failed to delete the %s Subscription: %w", client.ObjectKeyFromObject(sub), err)
+	}
+	return nil
+}
@@ -0,0 +1,246 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/blang/semver/v4"
+	"github.com/operator-framework/api/pkg/lib/version"
+	operatorsv1alpha1 "github.com/operator-framework/api/pkg/operators/v1alpha1"
+	rukpakv1alpha2 "github.com/operator-framework/rukpak/api/v1alpha2"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	platformtypes "github.com/openshift/platform-operators/api/v1alpha1"
+	"github.com/openshift/platform-operators/internal/sourcer"
+)
+
+func newTestSubscription(packageName, channel, installedCSV string) *operatorsv1alpha1.Subscription {
+	catalog := sourcer.DefaultCatalogSourceKey()
+	sub := &operatorsv1alpha1.Subscription{
+		Spec: &operatorsv1alpha1.SubscriptionSpec{
+			CatalogSource:          catalog.Name,
+			CatalogSourceNamespace: catalog.Namespace,
+			Package:                packageName,
+			Channel:                channel,
+		},
+	}
+	sub.SetName(packageName)
+	sub.SetNamespace("operators")
+	sub.Status.InstalledCSV = installedCSV
+	return sub
+}
+
+func newTestCSV(name, v string) *operatorsv1alpha1.ClusterServiceVersion {
+	csv := &operatorsv1alpha1.ClusterServiceVersion{}
+	csv.SetName(name)
+	csv.SetNamespace("operators")
+	csv.Spec.Version = version.OperatorVersion{Version: semver.MustParse(v)}
+	return csv
+}
+
+func TestAdoptedBundle(t *testing.T) {
+	po := newTestPlatformOperator("foo", map[string]string{platformtypes.AnnotationAdoptSubscription: "true"})
+	candidates := []sourcer.Bundle{
+		{Name: "foo.v1.0.0", Package: "foo", Channel: "candidate", Version: "1.0.0"},
+		{Name: "foo.v1.0.0", Package: "foo", Channel: "stable", Version: "1.0.0"},
+		{Name: "foo.v1.1.0", Package: "foo", Channel: "stable", Version: "1.1.0"},
+	}
+	otherCatalogSub := newTestSubscription("foo", "stable", "foo.v1.0.0")
+	otherCatalogSub.Spec.CatalogSource = "community-operators"
+
+	tests := []struct {
+		name        string
+		objs        []client.Object
+		wantVersion string
+		wantChannel string
+		wantErr     bool
+	}{
+		{
+			name: "NoSubscription",
+		},
+		{
+			name: "SubscriptionFromAnotherCatalog",
+			objs: []client.Object{otherCatalogSub, newTestCSV("foo.v1.0.0", "1.0.0")},
+		},
+		{
+			name:        "InstalledVersionInSubscribedChannel",
+			objs:        []client.Object{newTestSubscription("foo", "stable", "foo.v1.0.0"), newTestCSV("foo.v1.0.0", "1.0.0")},
+			wantVersion: "1.0.0",
+			wantChannel: "stable",
+		},
+		{
+			name:    "InstalledVersionNotInCatalog",
+			objs:    []client.Object{newTestSubscription("foo", "stable", "foo.v0.9.0"), newTestCSV("foo.v0.9.0", "0.9.0")},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rt := newReconcileTest(t, &staticSourcer{candidates: candidates}, tt.objs...)
+			po := po.DeepCopy()
+			b, _, err := rt.adoptedBundle(context.Background(), po)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("adoptedBundle() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			switch {
+			case tt.wantErr:
+				if c := meta.FindStatusCondition(po.Status.Conditions, platformtypes.TypeSubscriptionAdopted); c == nil || c.Reason != platformtypes.ReasonInstalledVersionNotFound {
+					t.Errorf("SubscriptionAdopted condition = %v, want the %s reason", c, platformtypes.ReasonInstalledVersionNotFound)
+				}
+			case tt.wantVersion == "":
+				if b != nil {
+					t.Errorf("adoptedBundle() = %v, want nil", b)
+				}
+			case b == nil || b.Version != tt.wantVersion || b.Channel != tt.wantChannel:
+				t.Errorf("adoptedBundle() = %v, want %s from the %s channel", b, tt.wantVersion, tt.wantChannel)
+			}
+		})
+	}
+}
+
+func TestReconcileAdoption(t *testing.T) {
+	installed := sourcer.Bundle{Name: "foo.v1.0.0", Package: "foo", Channel: "stable", Version: "1.0.0", Image: "quay.io/example/foo-bundle:v1.0.0"}
+	failedConditions := []metav1.Condition{
+		{Type: rukpakv1alpha2.TypeHasValidBundle, Status: metav1.ConditionTrue, Reason: rukpakv1alpha2.ReasonUnpackSuccessful},
+		{Type: rukpakv1alpha2.TypeInstalled, Status: metav1.ConditionFalse, Reason: rukpakv1alpha2.ReasonInstallFailed, Message: "conflicting objects"},
+	}
+
+	tests := []struct {
+		name          string
+		conditions    []metav1.Condition
+		subscription  bool
+		wantRetired   bool
+		wantInstalled metav1.ConditionStatus
+		wantReason    string
+	}{
+		{
+			name:          "RetiredOnceInstalled",
+			conditions:    installedConditions(),
+			subscription:  true,
+			wantRetired:   true,
+			wantInstalled: metav1.ConditionTrue,
+			wantReason:    platformtypes.ReasonAdoptionSuccessful,
+		},
+		{
+			name:          "KeptWhileInstallFails",
+			conditions:    failedConditions,
+			subscription:  true,
+			wantInstalled: metav1.ConditionFalse,
+			wantReason:    platformtypes.ReasonAdoptionPending,
+		},
+		{
+			name:          "NoSubscription",
+			conditions:    installedConditions(),
+			wantInstalled: metav1.ConditionTrue,
+			wantReason:    platformtypes.ReasonSubscriptionNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			po := newTestPlatformOperator("foo", map[string]string{platformtypes.AnnotationAdoptSubscription: "true"})
+			sub := newTestSubscription("foo", "stable", "foo.v1.0.0")
+			csv := newTestCSV("foo.v1.0.0", "1.0.0")
+			objs := []client.Object{po, newTestBundleDeployment(po, installed, tt.conditions...)}
+			if tt.subscription {
+				objs = append(objs, sub, csv)
+			}
+			rt := newReconcileTest(t, &staticSourcer{candidates: []sourcer.Bundle{installed}}, objs...)
+
+			if _, err := rt.reconcile(t, po.GetName()); err != nil {
+				t.Fatalf("Reconcile() unexpected error: %v", err)
+			}
+			if c := rt.condition(platformtypes.TypeInstalled); c == nil || c.Status != tt.wantInstalled {
+				t.Errorf("Installed condition = %v, want %s", c, tt.wantInstalled)
+			}
+			if c := rt.condition(platformtypes.TypeSubscriptionAdopted); c == nil || c.Reason != tt.wantReason {
+				t.Errorf("SubscriptionAdopted condition = %v, want the %s reason", c, tt.wantReason)
+			}
+			if !tt.subscription {
+				return
+			}
+			err := rt.Get(context.Background(), client.ObjectKeyFromObject(sub), sub)
+			if retired := apierrors.IsNotFound(err); retired != tt.wantRetired {
+				t.Errorf("Subscription retired = %v, want %v (err: %v)", retired, tt.wantRetired, err)
+			}
+		})
+	}
+}
+
+func TestSubscriptionHandover(t *testing.T) {
+	ctx := context.Background()
+	adopted := sourcer.Bundle{Name: "foo.v1.0.0", Package: "foo", Channel: "stable", Version: "1.0.0", Image: "quay.io/example/foo-bundle:v1.0.0"}
+	candidates := []sourcer.Bundle{adopted, {Name: "foo.v1.1.0", Package: "foo", Channel: "stable", Version: "1.1.0", Image: "quay.io/example/foo-bundle:v1.1.0"}}
+
+	po := newTestPlatformOperator("foo", map[string]string{platformtypes.AnnotationAdoptSubscription: "true"})
+	sub := newTestSubscription("foo", "stable", "foo.v1.0.0")
+	sub.Spec.InstallPlanApproval = operatorsv1alpha1.ApprovalAutomatic
+	csv := newTestCSV("foo.v1.0.0", "1.0.0")
+	// the garbage collector holds the ClusterServiceVersion back with the
+	// orphan finalizer until it has released the objects it owns.
+	csv.SetFinalizers([]string{metav1.FinalizerOrphanDependents})
+	rt := newReconcileTest(t, &staticSourcer{candidates: candidates}, po, sub, csv)
+
+	// the ClusterServiceVersion is released before the installation object is created.
+	res, err := rt.reconcile(t, po.GetName())
+	if err != nil {
+		t.Fatalf("Reconcile() unexpected error: %v", err)
+	}
+	if res.RequeueAfter == 0 {
+		t.Errorf("Reconcile() = %v, want a requeue while the objects are released", res)
+	}
+	if c := rt.condition(platformtypes.TypeSubscriptionAdopted); c == nil || c.Reason != platformtypes.ReasonAdoptionPending {
+		t.Errorf("SubscriptionAdopted condition = %v, want the %s reason", c, platformtypes.ReasonAdoptionPending)
+	}
+	if err := rt.Get(ctx, client.ObjectKeyFromObject(sub), sub); err != nil {
+		t.Fatal(err)
+	}
+	if sub.Spec.InstallPlanApproval != operatorsv1alpha1.ApprovalManual {
+		t.Errorf("Subscription installPlanApproval = %s, want %s", sub.Spec.InstallPlanApproval, operatorsv1alpha1.ApprovalManual)
+	}
+	if err := rt.Get(ctx, client.ObjectKeyFromObject(csv), csv); err != nil {
+		t.Fatal(err)
+	}
+	if csv.GetDeletionTimestamp().IsZero() {
+		t.Error("ClusterServiceVersion wasn't deleted")
+	}
+	bd := &rukpakv1alpha2.BundleDeployment{}
+	if err := rt.Get(ctx, client.ObjectKey{Name: po.GetName()}, bd); !apierrors.IsNotFound(err) {
+		t.Fatalf("BundleDeployment was created before the objects were released (err: %v)", err)
+	}
+
+	// once released, the installation object installs the adopted bundle.
+	csv.SetFinalizers(nil)
+	if err := rt.Update(ctx, csv); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rt.reconcile(t, po.GetName()); err != nil {
+		t.Fatalf("Reconcile() unexpected error: %v", err)
+	}
+	if err := rt.Get(ctx, client.ObjectKey{Name: po.GetName()}, bd); err != nil {
+		t.Fatalf("BundleDeployment wasn't created: %v", err)
+	}
+	if got := bd.Spec.Source.Image.Ref; got != adopted.Image {
+		t.Errorf("BundleDeployment image = %s, want the adopted %s", got, adopted.Image)
+	}
+	if err := rt.Get(ctx, client.ObjectKeyFromObject(sub), sub); err != nil {
+		t.Fatalf("Subscription was retired before the bundle was installed: %v", err)
+	}
+
+	// the Subscription is retired once the bundle is installed.
+	bd.Status.Conditions = installedConditions()
+	if err := rt.Update(ctx, bd); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rt.reconcile(t, po.GetName()); err != nil {
+		t.Fatalf("Reconcile() unexpected error: %v", err)
+	}
+	if c := rt.condition(platformtypes.TypeSubscriptionAdopted); c == nil || c.Reason != platformtypes.ReasonAdoptionSuccessful {
+		t.Errorf("SubscriptionAdopted condition = %v, want the %s reason", c, platformtypes.ReasonAdoptionSuccessful)
+	}
+	if err := rt.Get(ctx, client.ObjectKeyFromObject(sub), sub); !apierrors.IsNotFound(err) {
+		t.Errorf("Subscription wasn't retired (err: %v)", err)
+	}
+}
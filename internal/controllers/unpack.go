@@ -0,0 +1,144 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	rukpakv1alpha2 "github.com/operator-framework/rukpak/api/v1alpha2"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logr "sigs.k8s.io/controller-runtime/pkg/log"
+
+	platformtypes "github.com/openshift/platform-operators/api/v1alpha1"
+	"github.com/openshift/platform-operators/internal/applier"
+	"github.com/openshift/platform-operators/internal/util"
+)
+
+const (
+	// unpackLogLines is the number of lines from the end of an unpack pod's
+	// logs that are reported.
+	unpackLogLines = 5
+	// unpackLogBytes caps the size of the reported logs, as lines can be long.
+	unpackLogBytes = 1024
+)
+
+//+kubebuilder:rbac:groups="",namespace=openshift-rukpak,resources=pods,verbs=list;delete
+//+kubebuilder:rbac:groups="",namespace=openshift-rukpak,resources=pods/log,verbs=get
+
+// diagnoseUnpack adds the diagnostics of the pod that unpacks the bundle of
+// the obj installation object to the failing cond Installed condition, when
+// the bundle isn't unpacked yet. Unpack pods that don't succeed within the
+// unpack timeout are deleted, so rukpak retries the unpack with a new pod.
+// It returns when the unpack should be checked again, which is zero when the
+// installation object's events are enough.
+func (r *PlatformOperatorReconciler) diagnoseUnpack(ctx context.Context, obj client.Object, cond *metav1.Condition) (time.Duration, error) {
+	bd, ok := obj.(*rukpakv1alpha2.BundleDeployment)
+	if !ok || r.UnpackNamespace == "" || meta.IsStatusConditionTrue(bd.Status.Conditions, rukpakv1alpha2.TypeHasValidBundle) {
+		return 0, nil
+	}
+
+	pod, err := r.unpackPod(ctx, bd)
+	if err != nil {
+		return 0, err
+	}
+	if pod == nil || pod.Status.Phase == corev1.PodSucceeded {
+		return 0, nil
+	}
+
+	logs, err := r.unpackLogs(ctx, pod)
+	if err != nil {
+		logr.FromContext(ctx).Info("failed to read the logs of an unpack pod", "pod", client.ObjectKeyFromObject(pod), "error", err.Error())
+	}
+	diagnostics := util.DiagnoseUnpackPod(pod, logs)
+	if diagnostics != "" {
+		cond.Message = fmt.Sprintf("%s: %s", cond.Message, diagnostics)
+	}
+
+	if r.UnpackTimeout == 0 {
+		return 0, nil
+	}
+	age := time.Since(pod.GetCreationTimestamp().Time)
+	if age < r.UnpackTimeout {
+		return r.UnpackTimeout - age, nil
+	}
+	if err := r.Delete(ctx, pod, client.Preconditions{UID: &pod.UID}); err != nil && !apierrors.IsNotFound(err) {
+		if apierrors.IsForbidden(err) {
+			// retrying again won't help until the manager is granted
+			// deleting pods in the unpack namespace.
+			cond.Reason = platformtypes.ReasonUnpackTimeout
+			cond.Message = fmt.Sprintf("The bundle wasn't unpacked within %s, and the unpack can't be retried without permission to delete pods in the %s namespace", r.UnpackTimeout, r.UnpackNamespace)
+			if diagnostics != "" {
+				cond.Message += fmt.Sprintf(": %s", diagnostics)
+			}
+			return 0, nil
+		}
+		return 0, fmt.Errorf("failed to delete the %s/%s unpack pod that timed out: %w", pod.GetNamespace(), pod.GetName(), err)
+	}
+	logr.FromContext(ctx).Info("retrying a bundle unpack that timed out", "pod", client.ObjectKeyFromObject(pod), "timeout", r.UnpackTimeout)
+	cond.Reason = platformtypes.ReasonUnpackTimeout
+	cond.Message = fmt.Sprintf("The bundle wasn't unpacked within %s, and the unpack is being retried", r.UnpackTimeout)
+	if diagnostics != "" {
+		cond.Message += fmt.Sprintf(": %s", diagnostics)
+	}
+	return r.UnpackTimeout, nil
+}
+
+// unpackPod returns the most recently created pod unpacking the bd
+// BundleDeployment's bundle, which rukpak labels with its owner, or nil when
+// there's none.
+func (r *PlatformOperatorReconciler) unpackPod(ctx context.Context, bd *rukpakv1alpha2.BundleDeployment) (*corev1.Pod, error) {
+	pods := &corev1.PodList{}
+	if err := r.APIReader.List(ctx, pods, client.InNamespace(r.UnpackNamespace), client.MatchingLabels{
+		applier.BundleDeploymentOwnerKindLabel: rukpakv1alpha2.BundleDeploymentKind,
+		applier.BundleDeploymentOwnerLabel:     bd.GetName(),
+	}); err != nil {
+		return nil, err
+	}
+	var pod *corev1.Pod
+	for i := range pods.Items {
+		if pod == nil || pod.CreationTimestamp.Before(&pods.Items[i].CreationTimestamp) {
+			pod = &pods.Items[i]
+		}
+	}
+	return pod, nil
+}
+
+// unpackLogs returns the tail of the pod's logs, or an empty string when the
+// pod's containers haven't started or its logs can't be read.
+func (r *PlatformOperatorReconciler) unpackLogs(ctx context.Context, pod *corev1.Pod) (string, error) {
+	if r.PodLogs == nil || !containerStarted(pod) {
+		return "", nil
+	}
+	tailLines, limitBytes := int64(unpackLogLines), int64(unpackLogBytes)
+	opts := &corev1.PodLogOptions{TailLines: &tailLines, LimitBytes: &limitBytes}
+	if len(pod.Spec.Containers) != 0 {
+		opts.Container = pod.Spec.Containers[0].Name
+	}
+	stream, err := r.PodLogs.Pods(pod.GetNamespace()).GetLogs(pod.GetName(), opts).Stream(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer stream.Close()
+	logs := &bytes.Buffer{}
+	if _, err := io.Copy(logs, io.LimitReader(stream, unpackLogBytes)); err != nil {
+		return "", err
+	}
+	return logs.String(), nil
+}
+
+// containerStarted returns whether one of the pod's containers has run, and
+// could have logged something.
+func containerStarted(pod *corev1.Pod) bool {
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.State.Running != nil || status.State.Terminated != nil || status.LastTerminationState.Terminated != nil {
+			return true
+		}
+	}
+	return false
+}
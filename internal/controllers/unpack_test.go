@@ -0,0 +1,174 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	rukpakv1alpha2 "github.com/operator-framework/rukpak/api/v1alpha2"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	platformtypes "github.com/openshift/platform-operators/api/v1alpha1"
+	"github.com/openshift/platform-operators/internal/applier"
+	"github.com/openshift/platform-operators/internal/sourcer"
+)
+
+// newTestUnpackPod returns the pod unpacking the bd BundleDeployment's bundle,
+// created age ago, whose unpack container can't pull the bundle image.
+func newTestUnpackPod(bd *rukpakv1alpha2.BundleDeployment, age time.Duration) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: testUnpackNamespace,
+			Name:      bd.GetName() + "-unpack",
+			UID:       "unpack-pod-uid",
+			Labels: map[string]string{
+				applier.BundleDeploymentOwnerKindLabel: rukpakv1alpha2.BundleDeploymentKind,
+				applier.BundleDeploymentOwnerLabel:     bd.GetName(),
+			},
+			CreationTimestamp: metav1.NewTime(time.Now().Add(-age)),
+		},
+		Status: corev1.PodStatus{
+			Phase: corev1.PodPending,
+			ContainerStatuses: []corev1.ContainerStatus{{
+				Name: "unpack",
+				State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{
+					Reason:  "ImagePullBackOff",
+					Message: "Back-off pulling image",
+				}},
+			}},
+		},
+	}
+}
+
+func TestDiagnoseUnpack(t *testing.T) {
+	const timeout = 10 * time.Minute
+	b := sourcer.Bundle{Name: "foo.v1.0.0", Image: "quay.io/example/foo:v1.0.0"}
+	po := newTestPlatformOperator("foo", nil)
+	unpacking := []metav1.Condition{{Type: rukpakv1alpha2.TypeHasValidBundle, Status: metav1.ConditionFalse, Reason: rukpakv1alpha2.ReasonUnpackPending}}
+
+	tests := []struct {
+		name       string
+		conditions []metav1.Condition
+		age        time.Duration
+		timeout    time.Duration
+		deleteErr  error
+		// otherOwner labels the pod as unpacking another BundleDeployment.
+		otherOwner bool
+
+		wantRequeue time.Duration
+		wantDeleted bool
+		wantReason  string
+		wantMessage string
+		wantErr     bool
+	}{
+		{
+			name:        "WithinTimeout",
+			conditions:  unpacking,
+			age:         time.Minute,
+			timeout:     timeout,
+			wantRequeue: timeout - time.Minute,
+			wantReason:  platformtypes.ReasonUnpackPending,
+			wantMessage: "Unpacking: the " + testUnpackNamespace + "/foo-unpack unpack pod is Pending: the unpack container is ImagePullBackOff: Back-off pulling image",
+		},
+		{
+			name:        "TimedOut",
+			conditions:  unpacking,
+			age:         timeout + time.Minute,
+			timeout:     timeout,
+			wantRequeue: timeout,
+			wantDeleted: true,
+			wantReason:  platformtypes.ReasonUnpackTimeout,
+			wantMessage: "The bundle wasn't unpacked within 10m0s, and the unpack is being retried: the " + testUnpackNamespace + "/foo-unpack unpack pod is Pending",
+		},
+		{
+			name:        "DeleteForbidden",
+			conditions:  unpacking,
+			age:         timeout + time.Minute,
+			timeout:     timeout,
+			deleteErr:   apierrors.NewForbidden(schema.GroupResource{Resource: "pods"}, "foo", errors.New("delete isn't granted")),
+			wantReason:  platformtypes.ReasonUnpackTimeout,
+			wantMessage: "the unpack can't be retried without permission to delete pods in the " + testUnpackNamespace + " namespace",
+		},
+		{
+			name:       "DeleteFailed",
+			conditions: unpacking,
+			age:        timeout + time.Minute,
+			timeout:    timeout,
+			deleteErr:  errors.New("connection refused"),
+			wantErr:    true,
+		},
+		{
+			name:        "RetriesDisabled",
+			conditions:  unpacking,
+			age:         timeout + time.Minute,
+			wantReason:  platformtypes.ReasonUnpackPending,
+			wantMessage: "the unpack container is ImagePullBackOff",
+		},
+		{
+			name:        "Unpacked",
+			conditions:  installedConditions(),
+			age:         timeout + time.Minute,
+			timeout:     timeout,
+			wantReason:  platformtypes.ReasonUnpackPending,
+			wantMessage: "Unpacking",
+		},
+		{
+			name:        "OtherOwner",
+			conditions:  unpacking,
+			age:         timeout + time.Minute,
+			timeout:     timeout,
+			otherOwner:  true,
+			wantReason:  platformtypes.ReasonUnpackPending,
+			wantMessage: "Unpacking",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bd := newTestBundleDeployment(po, b, tt.conditions...)
+			pod := newTestUnpackPod(bd, tt.age)
+			if tt.otherOwner {
+				pod.Labels[applier.BundleDeploymentOwnerLabel] = "bar"
+			}
+			rt := newReconcileTest(t, &staticSourcer{}, bd, pod)
+			rt.UnpackNamespace = testUnpackNamespace
+			rt.UnpackTimeout = tt.timeout
+			if tt.deleteErr != nil {
+				rt.Client = interceptor.NewClient(rt.Client.(client.WithWatch), interceptor.Funcs{
+					Delete: func(context.Context, client.WithWatch, client.Object, ...client.DeleteOption) error {
+						return tt.deleteErr
+					},
+				})
+			}
+
+			cond := &metav1.Condition{Type: platformtypes.TypeInstalled, Status: metav1.ConditionFalse, Reason: platformtypes.ReasonUnpackPending, Message: "Unpacking"}
+			requeue, err := rt.diagnoseUnpack(context.Background(), bd, cond)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("diagnoseUnpack() didn't return an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("diagnoseUnpack() unexpected error: %v", err)
+			}
+			// the requeue is measured from the pod's age, which keeps growing.
+			if requeue > tt.wantRequeue || requeue < tt.wantRequeue-time.Minute {
+				t.Errorf("diagnoseUnpack() requeue = %s, want %s", requeue, tt.wantRequeue)
+			}
+			if cond.Reason != tt.wantReason || !strings.Contains(cond.Message, tt.wantMessage) {
+				t.Errorf("condition = %s: %q, want %s containing %q", cond.Reason, cond.Message, tt.wantReason, tt.wantMessage)
+			}
+			err = rt.APIReader.Get(context.Background(), client.ObjectKeyFromObject(pod), &corev1.Pod{})
+			if deleted := apierrors.IsNotFound(err); deleted != tt.wantDeleted {
+				t.Errorf("unpack pod deleted = %v, want %v", deleted, tt.wantDeleted)
+			}
+		})
+	}
+}
@@ -0,0 +1,184 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	platformv1alpha1 "github.com/openshift/api/platform/v1alpha1"
+	platformtypes "github.com/openshift/platform-operators/api/v1alpha1"
+	"github.com/openshift/platform-operators/internal/metrics"
+	"github.com/openshift/platform-operators/internal/sourcer"
+)
+
+// maxReportedUpdates caps the number of updates that are listed in the
+// UpdatesAvailable condition's message.
+const maxReportedUpdates = 10
+
+// reportAvailableUpdates lists the bundles that the bundle installed by the
+// obj installation object can be upgraded to, within the po PlatformOperator's
+// channel, version range and version policy, through the UpdatesAvailable
+// condition, and records how many there are in the pending updates metric.
+// The updates are only derived again once the catalog, the installed bundle
+// or po's restrictions change.
+func (r *PlatformOperatorReconciler) reportAvailableUpdates(ctx context.Context, po *platformv1alpha1.PlatformOperator, obj client.Object) {
+	installed, ok := installedBundle(po, obj)
+	if !ok {
+		// the installation object doesn't record which bundle it installed.
+		return
+	}
+
+	updates, err := r.availableUpdates(ctx, po, installed)
+	if err != nil {
+		// the last count may no longer be accurate.
+		metrics.PendingUpdates.DeleteLabelValues(po.GetName())
+		meta.SetStatusCondition(&po.Status.Conditions, metav1.Condition{
+			Type:    platformtypes.TypeUpdatesAvailable,
+			Status:  metav1.ConditionUnknown,
+			Reason:  platformtypes.ReasonUpdatesUnknown,
+			Message: fmt.Sprintf("Failed to find the updates of the %s bundle: %v", installed.Name, err),
+		})
+		return
+	}
+	metrics.PendingUpdates.WithLabelValues(po.GetName()).Set(float64(len(updates)))
+	if len(updates) == 0 {
+		meta.SetStatusCondition(&po.Status.Conditions, metav1.Condition{
+			Type:    platformtypes.TypeUpdatesAvailable,
+			Status:  metav1.ConditionFalse,
+			Reason:  platformtypes.ReasonNoUpdatesAvailable,
+			Message: fmt.Sprintf("The installed %s bundle is up to date", installed.Name),
+		})
+		return
+	}
+	var reported []string
+	for i, b := range updates {
+		if i == maxReportedUpdates {
+			reported = append(reported, fmt.Sprintf("and %d more", len(updates)-i))
+			break
+		}
+		reported = append(reported, fmt.Sprintf("%s from the %s channel (%s)", b.Version, b.Channel, b.Image))
+	}
+	meta.SetStatusCondition(&po.Status.Conditions, metav1.Condition{
+		Type:   platformtypes.TypeUpdatesAvailable,
+		Status: metav1.ConditionTrue,
+		Reason: platformtypes.ReasonUpdatesAvailable,
+		Message: fmt.Sprintf("The installed %s bundle can be upgraded to %d bundles: %s",
+			installed.Name, len(updates), strings.Join(reported, "; ")),
+	})
+}
+
+// updatesCache holds the updates that were last found for each
+// PlatformOperator, along with the key identifying what they were derived from.
+type updatesCache struct {
+	mu      sync.Mutex
+	entries map[string]updatesEntry
+}
+
+type updatesEntry struct {
+	key     string
+	updates []sourcer.Bundle
+}
+
+func (c *updatesCache) get(name, key string) ([]sourcer.Bundle, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[name]
+	if !ok || entry.key != key {
+		return nil, false
+	}
+	return entry.updates, true
+}
+
+func (c *updatesCache) set(name, key string, updates []sourcer.Bundle) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[string]updatesEntry)
+	}
+	c.entries[name] = updatesEntry{key: key, updates: updates}
+}
+
+func (c *updatesCache) evict(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, name)
+}
+
+// updatesKey identifies the updates of the installed bundle that are found
+// for the po PlatformOperator in the revision of its catalog.
+func updatesKey(po *platformv1alpha1.PlatformOperator, installed sourcer.Bundle, revision string) string {
+	annotations := po.GetAnnotations()
+	return strings.Join([]string{
+		revision,
+		installed.Name,
+		installed.Channel,
+		annotations[platformtypes.AnnotationCatalog],
+		annotations[platformtypes.AnnotationChannel],
+		annotations[platformtypes.AnnotationVersionRange],
+		annotations[platformtypes.AnnotationAllowPrereleases],
+	}, "|")
+}
+
+// installedBundle returns the bundle that the obj installation object was
+// built from, and false when obj doesn't record it.
+func installedBundle(po *platformv1alpha1.PlatformOperator, obj client.Object) (sourcer.Bundle, bool) {
+	annotations := obj.GetAnnotations()
+	if annotations[platformtypes.AnnotationBundleName] == "" {
+		return sourcer.Bundle{}, false
+	}
+	return sourcer.Bundle{
+		Name:    annotations[platformtypes.AnnotationBundleName],
+		Package: po.Spec.Package.Name,
+		Version: annotations[platformtypes.AnnotationBundleVersion],
+		Channel: annotations[platformtypes.AnnotationBundleChannel],
+		Image:   annotations[platformtypes.AnnotationBundleImage],
+	}, true
+}
+
+// availableUpdates returns the po PlatformOperator's candidates that the
+// installed bundle can be upgraded to. The updates are cached until the
+// catalog changes, when the Sourcer can tell, so they aren't derived from the
+// catalog on every reconcile.
+func (r *PlatformOperatorReconciler) availableUpdates(ctx context.Context, po *platformv1alpha1.PlatformOperator, installed sourcer.Bundle) ([]sourcer.Bundle, error) {
+	revision, err := sourcer.Revision(ctx, r.Sourcer, po)
+	if err != nil {
+		return nil, err
+	}
+	var key string
+	if revision != "" {
+		key = updatesKey(po, installed, revision)
+		if updates, ok := r.updates.get(po.GetName(), key); ok {
+			return updates, nil
+		}
+	}
+
+	candidates, err := r.Sourcer.Candidates(ctx, po)
+	if err != nil {
+		return nil, err
+	}
+	filters, err := sourcer.PlatformOperatorFilters(po)
+	if err != nil {
+		return nil, err
+	}
+	accepted, rejected, err := sourcer.NewPipeline(filters...).Run(candidates)
+	if err != nil {
+		return nil, err
+	}
+	// the installed bundle is still needed to find the channels it's in, even
+	// when it no longer passes po's filters.
+	for _, rejection := range rejected {
+		if rejection.Bundle.Name == installed.Name {
+			accepted = append(accepted, rejection.Bundle)
+		}
+	}
+	updates := sourcer.Updates(installed, accepted)
+	if key != "" {
+		r.updates.set(po.GetName(), key, updates)
+	}
+	return updates, nil
+}
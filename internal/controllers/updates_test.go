@@ -0,0 +1,83 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	dto "github.com/prometheus/client_model/go"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	platformtypes "github.com/openshift/platform-operators/api/v1alpha1"
+	"github.com/openshift/platform-operators/internal/metrics"
+	"github.com/openshift/platform-operators/internal/sourcer"
+)
+
+func pendingUpdates(t *testing.T, name string) float64 {
+	t.Helper()
+	m := &dto.Metric{}
+	if err := metrics.PendingUpdates.WithLabelValues(name).Write(m); err != nil {
+		t.Fatal(err)
+	}
+	return m.GetGauge().GetValue()
+}
+
+func TestReportAvailableUpdates(t *testing.T) {
+	installed := sourcer.Bundle{Name: "foo.v1.0.0", Package: "foo", Channel: "stable", Version: "1.0.0", Image: "quay.io/example/foo:v1.0.0"}
+	s := &staticSourcer{
+		candidates: []sourcer.Bundle{
+			installed,
+			{Name: "foo.v1.1.0", Package: "foo", Channel: "stable", Version: "1.1.0", Replaces: "foo.v1.0.0"},
+			{Name: "foo.v2.0.0", Package: "foo", Channel: "stable", Version: "2.0.0", Replaces: "foo.v1.1.0"},
+		},
+		revision: "1",
+	}
+	po := newTestPlatformOperator("foo", nil)
+	bd := newTestBundleDeployment(po, installed, installedConditions()...)
+	rt := newReconcileTest(t, s)
+	defer metrics.PendingUpdates.DeleteLabelValues(po.GetName())
+
+	report := func(wantStatus metav1.ConditionStatus, wantCalls int) {
+		t.Helper()
+		po.Status.Conditions = nil
+		rt.reportAvailableUpdates(context.Background(), po, bd)
+		if s.calls != wantCalls {
+			t.Errorf("reportAvailableUpdates() listed the candidates %d times, want %d", s.calls, wantCalls)
+		}
+		c := meta.FindStatusCondition(po.Status.Conditions, platformtypes.TypeUpdatesAvailable)
+		if c == nil || c.Status != wantStatus {
+			t.Fatalf("UpdatesAvailable condition = %+v, want status %s", c, wantStatus)
+		}
+	}
+
+	report(metav1.ConditionTrue, 1)
+	if got := pendingUpdates(t, po.GetName()); got != 2 {
+		t.Errorf("pending updates = %v, want 2", got)
+	}
+
+	// the updates aren't derived again until the catalog changes.
+	report(metav1.ConditionTrue, 1)
+	s.candidates = s.candidates[:1]
+	s.revision = "2"
+	report(metav1.ConditionFalse, 2)
+	if got := pendingUpdates(t, po.GetName()); got != 0 {
+		t.Errorf("pending updates = %v, want 0", got)
+	}
+
+	// changing the PlatformOperator's restrictions derives them again.
+	po.SetAnnotations(map[string]string{platformtypes.AnnotationChannel: "stable"})
+	report(metav1.ConditionFalse, 3)
+
+	// Sourcers that don't report a revision are always asked.
+	s.revision = ""
+	report(metav1.ConditionFalse, 4)
+	report(metav1.ConditionFalse, 5)
+
+	s.err = errors.New("catalog unavailable")
+	s.revision = "3"
+	report(metav1.ConditionUnknown, 6)
+	if metrics.PendingUpdates.DeleteLabelValues(po.GetName()) {
+		t.Errorf("the pending updates of %s are still reported after failing to find them", po.GetName())
+	}
+}
@@ -0,0 +1,218 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logr "sigs.k8s.io/controller-runtime/pkg/log"
+
+	platformv1alpha1 "github.com/openshift/api/platform/v1alpha1"
+	platformtypes "github.com/openshift/platform-operators/api/v1alpha1"
+	"github.com/openshift/platform-operators/internal/bundlediff"
+	"github.com/openshift/platform-operators/internal/images"
+	"github.com/openshift/platform-operators/internal/preflight"
+	"github.com/openshift/platform-operators/internal/sourcer"
+)
+
+// maxConditionMessageLength is the longest message the API server accepts
+// for a condition.
+const maxConditionMessageLength = 32768
+
+func upgradesEnabled(po *platformv1alpha1.PlatformOperator) bool {
+	return po.GetAnnotations()[platformtypes.AnnotationUpgradePolicy] == platformtypes.UpgradePolicyManual
+}
+
+// upgradeApproved returns whether the po PlatformOperator's upgrade to the
+// target bundle was approved.
+func upgradeApproved(po *platformv1alpha1.PlatformOperator, target string) bool {
+	return po.GetAnnotations()[platformtypes.AnnotationApprovedBundle] == target
+}
+
+// upgrade changes the obj installation object to install the most preferred
+// update of its installed bundle that resolves together with the bundles the
+// other PlatformOperators have installed, once the update is approved, passes
+// the same checks as an installation, its CustomResourceDefinitions are
+// compatible with the custom resources on the cluster, and its dependencies
+// are installed. Updates that don't pass, can't be found or resolved, or are
+// waiting for approval, are reported through the po PlatformOperator's
+// Upgradeable condition, and leave the installed bundle in place.
+func (r *PlatformOperatorReconciler) upgrade(ctx context.Context, po *platformv1alpha1.PlatformOperator, obj client.Object, keychain images.Keychain) error {
+	installed, ok := installedBundle(po, obj)
+	if !ok {
+		return nil
+	}
+	updates, err := r.availableUpdates(ctx, po, installed)
+	if err != nil {
+		// the installed bundle is still in place, so only upgrading it is
+		// affected.
+		meta.SetStatusCondition(&po.Status.Conditions, metav1.Condition{
+			Type:    platformtypes.TypeUpgradeable,
+			Status:  metav1.ConditionUnknown,
+			Reason:  platformtypes.ReasonUpdatesUnknown,
+			Message: fmt.Sprintf("Failed to find the updates of the installed %s bundle: %v", installed.Name, err),
+		})
+		return nil
+	}
+	if len(updates) == 0 {
+		return nil
+	}
+	selection, err := r.resolveUpgrade(ctx, po, updates)
+	if err != nil {
+		meta.SetStatusCondition(&po.Status.Conditions, metav1.Condition{
+			Type:    platformtypes.TypeUpgradeable,
+			Status:  metav1.ConditionFalse,
+			Reason:  platformtypes.ReasonUpgradeBlocked,
+			Message: fmt.Sprintf("None of the %d updates of the installed %s bundle can be resolved with the bundles the other platform operators installed: %v", len(updates), installed.Name, err),
+		})
+		return nil
+	}
+	target := selection.Bundle
+
+	blocked := func(err error) error {
+		meta.SetStatusCondition(&po.Status.Conditions, metav1.Condition{
+			Type:    platformtypes.TypeUpgradeable,
+			Status:  metav1.ConditionFalse,
+			Reason:  platformtypes.ReasonUpgradeBlocked,
+			Message: fmt.Sprintf("The upgrade from the installed %s bundle to the %s bundle is blocked: %v", installed.Name, target.Name, err),
+		})
+		logr.FromContext(ctx).Info("blocked the upgrade of a platform operator", "from", installed.Name, "to", target.Name, "reason", err.Error())
+		return nil
+	}
+	if r.Images != nil {
+		if err := r.pinBundleImage(ctx, po, &target, keychain); err != nil {
+			return blocked(err)
+		}
+	}
+	if r.Signatures != nil {
+		if err := r.verifyBundleSignature(ctx, po, &target, keychain); err != nil {
+			return blocked(err)
+		}
+	}
+	if !upgradeApproved(po, target.Name) {
+		r.requestUpgradeApproval(ctx, po, &installed, &target, keychain)
+		return nil
+	}
+	if r.Preflight != nil {
+		manifests, err := r.unpackBundle(ctx, &installed, keychain)
+		if err != nil {
+			return blocked(err)
+		}
+		if err := r.runPreflight(ctx, po, obj.GetName(), &target, keychain, preflight.CRDCompatibility(r.APIReader, manifests)); err != nil {
+			return blocked(err)
+		}
+	}
+	if err := r.reconcileDependencies(ctx, po, &target, selection.Dependencies); err != nil {
+		if errors.Is(err, errDependenciesPending) || errors.Is(err, errDependenciesUnsatisfied) {
+			return blocked(err)
+		}
+		return err
+	}
+
+	r.Applier.Upgrade(obj, &target)
+	if err := r.Update(ctx, obj); err != nil {
+		return err
+	}
+	meta.SetStatusCondition(&po.Status.Conditions, metav1.Condition{
+		Type:    platformtypes.TypeUpgradeable,
+		Status:  metav1.ConditionTrue,
+		Reason:  platformtypes.ReasonUpgradeApplied,
+		Message: fmt.Sprintf("Upgraded the installed %s bundle to the %s bundle", installed.Name, target.Name),
+	})
+	return nil
+}
+
+// requestUpgradeApproval reports that the po PlatformOperator's upgrade from
+// the installed bundle to the target bundle is waiting for approval, along
+// with a summary of the changes the upgrade makes to the bundle's manifests,
+// when they can be unpacked. The summary is only computed once for each
+// target bundle, and cut short when it doesn't fit in the condition message.
+func (r *PlatformOperatorReconciler) requestUpgradeApproval(ctx context.Context, po *platformv1alpha1.PlatformOperator, installed, target *sourcer.Bundle, keychain images.Keychain) {
+	msg := fmt.Sprintf("The upgrade from the installed %s bundle to the %s bundle is waiting for approval, which is given by setting the %s annotation to %s",
+		installed.Name, target.Name, platformtypes.AnnotationApprovedBundle, target.Name)
+	if r.Preflight != nil {
+		key := upgradeSummaryKey(installed, target)
+		summary, ok := r.upgradeSummaries.get(po.GetName(), key)
+		if !ok {
+			diff, err := r.diffBundles(ctx, installed, target, keychain)
+			if err != nil {
+				summary = fmt.Sprintf("The changes are unknown: %v", err)
+			} else {
+				summary = fmt.Sprintf("The changes are: %s", diff.Summary())
+				r.upgradeSummaries.set(po.GetName(), key, summary)
+			}
+		}
+		msg += ". " + summary
+	}
+	if len(msg) > maxConditionMessageLength {
+		msg = msg[:maxConditionMessageLength-len("...")] + "..."
+	}
+	meta.SetStatusCondition(&po.Status.Conditions, metav1.Condition{
+		Type:    platformtypes.TypeUpgradeable,
+		Status:  metav1.ConditionFalse,
+		Reason:  platformtypes.ReasonUpgradeApprovalPending,
+		Message: msg,
+	})
+}
+
+// diffBundles returns the changes between the manifests of the from and to
+// bundles' images.
+func (r *PlatformOperatorReconciler) diffBundles(ctx context.Context, from, to *sourcer.Bundle, keychain images.Keychain) (*bundlediff.Diff, error) {
+	fromManifests, err := r.unpackBundle(ctx, from, keychain)
+	if err != nil {
+		return nil, err
+	}
+	toManifests, err := r.unpackBundle(ctx, to, keychain)
+	if err != nil {
+		return nil, err
+	}
+	return bundlediff.Compute(fromManifests, toManifests)
+}
+
+// upgradeSummaryCache holds the summary of the changes of the upgrade that
+// was last waiting for approval for each PlatformOperator, along with the key
+// identifying the upgrade.
+type upgradeSummaryCache struct {
+	mu      sync.Mutex
+	entries map[string]upgradeSummaryEntry
+}
+
+type upgradeSummaryEntry struct {
+	key     string
+	summary string
+}
+
+func (c *upgradeSummaryCache) get(name, key string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[name]
+	if !ok || entry.key != key {
+		return "", false
+	}
+	return entry.summary, true
+}
+
+func (c *upgradeSummaryCache) set(name, key, summary string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[string]upgradeSummaryEntry)
+	}
+	c.entries[name] = upgradeSummaryEntry{key: key, summary: summary}
+}
+
+func (c *upgradeSummaryCache) evict(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, name)
+}
+
+// upgradeSummaryKey identifies the upgrade from the installed bundle to the
+// target bundle by the images whose manifests are compared.
+func upgradeSummaryKey(installed, target *sourcer.Bundle) string {
+	return installed.Image + "|" + target.Image
+}
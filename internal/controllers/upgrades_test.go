@@ -0,0 +1,182 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/operator-framework/operator-registry/alpha/property"
+	rukpakv1alpha2 "github.com/operator-framework/rukpak/api/v1alpha2"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	platformtypes "github.com/openshift/platform-operators/api/v1alpha1"
+	"github.com/openshift/platform-operators/internal/images"
+	"github.com/openshift/platform-operators/internal/sourcer"
+)
+
+func TestUpgrade(t *testing.T) {
+	installed := sourcer.Bundle{Name: "foo.v1.0.0", Package: "foo", Channel: "stable", Version: "1.0.0", Image: "quay.io/example/foo:v1.0.0"}
+	update := sourcer.Bundle{Name: "foo.v1.1.0", Package: "foo", Channel: "stable", Version: "1.1.0", Image: "quay.io/example/foo:v1.1.0", Replaces: "foo.v1.0.0"}
+
+	// heldBack requires a package no catalog provides.
+	heldBack := sourcer.Bundle{Name: "foo.v1.2.0", Package: "foo", Channel: "stable", Version: "1.2.0", Image: "quay.io/example/foo:v1.2.0", Replaces: "foo.v1.0.0",
+		Properties: []property.Property{property.MustBuildPackageRequired("bar", ">=1.0.0")}}
+
+	tests := []struct {
+		name       string
+		approved   string
+		candidates []sourcer.Bundle
+		err        error
+		// wantImage is the image the BundleDeployment installs afterwards.
+		wantImage  string
+		wantStatus metav1.ConditionStatus
+		wantReason string
+	}{
+		{
+			name:       "UpToDate",
+			candidates: []sourcer.Bundle{installed},
+			wantImage:  installed.Image,
+		},
+		{
+			name:       "ApprovalPending",
+			candidates: []sourcer.Bundle{installed, update},
+			wantImage:  installed.Image,
+			wantStatus: metav1.ConditionFalse,
+			wantReason: platformtypes.ReasonUpgradeApprovalPending,
+		},
+		{
+			name:       "Approved",
+			approved:   update.Name,
+			candidates: []sourcer.Bundle{installed, update},
+			wantImage:  update.Image,
+			wantStatus: metav1.ConditionTrue,
+			wantReason: platformtypes.ReasonUpgradeApplied,
+		},
+		{
+			name:       "OtherBundleApproved",
+			approved:   "foo.v2.0.0",
+			candidates: []sourcer.Bundle{installed, update},
+			wantImage:  installed.Image,
+			wantStatus: metav1.ConditionFalse,
+			wantReason: platformtypes.ReasonUpgradeApprovalPending,
+		},
+		{
+			name:       "Unresolvable",
+			approved:   heldBack.Name,
+			candidates: []sourcer.Bundle{installed, heldBack},
+			wantImage:  installed.Image,
+			wantStatus: metav1.ConditionFalse,
+			wantReason: platformtypes.ReasonUpgradeBlocked,
+		},
+		{
+			// the installed bundle's status is left alone when its catalog
+			// can't be reached.
+			name:       "UpdatesUnknown",
+			approved:   update.Name,
+			err:        errors.New("catalog unavailable"),
+			wantImage:  installed.Image,
+			wantStatus: metav1.ConditionUnknown,
+			wantReason: platformtypes.ReasonUpdatesUnknown,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			po := newTestPlatformOperator("foo", map[string]string{
+				platformtypes.AnnotationUpgradePolicy:  platformtypes.UpgradePolicyManual,
+				platformtypes.AnnotationApprovedBundle: tt.approved,
+			})
+			rt := newReconcileTest(t, &staticSourcer{candidates: tt.candidates, err: tt.err}, newTestBundleDeployment(po, installed, installedConditions()...))
+			obj, err := rt.Applier.Get(context.Background(), po)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := rt.upgrade(context.Background(), po, obj, nil); err != nil {
+				t.Fatalf("upgrade() unexpected error: %v", err)
+			}
+			bd := &rukpakv1alpha2.BundleDeployment{}
+			if err := rt.Get(context.Background(), client.ObjectKeyFromObject(obj), bd); err != nil {
+				t.Fatal(err)
+			}
+			if got := bd.Spec.Source.Image.Ref; got != tt.wantImage {
+				t.Errorf("BundleDeployment image = %s, want %s", got, tt.wantImage)
+			}
+			c := meta.FindStatusCondition(po.Status.Conditions, platformtypes.TypeUpgradeable)
+			if tt.wantReason == "" {
+				if c != nil {
+					t.Errorf("Upgradeable condition = %+v, want none", c)
+				}
+				return
+			}
+			if c == nil || c.Status != tt.wantStatus || c.Reason != tt.wantReason {
+				t.Errorf("Upgradeable condition = %+v, want status %s and reason %s", c, tt.wantStatus, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestUpgradesEnabled(t *testing.T) {
+	for policy, want := range map[string]bool{
+		"":                                false,
+		"Automatic":                       false,
+		platformtypes.UpgradePolicyManual: true,
+	} {
+		po := newTestPlatformOperator("foo", map[string]string{platformtypes.AnnotationUpgradePolicy: policy})
+		if got := upgradesEnabled(po); got != want {
+			t.Errorf("upgradesEnabled() with the %q policy = %v, want %v", policy, got, want)
+		}
+	}
+}
+
+func TestRequestUpgradeApproval(t *testing.T) {
+	installed := &sourcer.Bundle{Name: "foo.v1.0.0", Image: "registry.invalid/example/foo:v1.0.0"}
+	target := &sourcer.Bundle{Name: "foo.v1.1.0", Image: "registry.invalid/example/foo:v1.1.0"}
+	po := newTestPlatformOperator("foo", nil)
+	rt := newReconcileTest(t, &staticSourcer{})
+	rt.Preflight = images.NewRegistry(&http.Client{Transport: roundTripperFunc(func(*http.Request) (*http.Response, error) {
+		return nil, errors.New("registry unavailable")
+	})})
+
+	message := func() string {
+		t.Helper()
+		po.Status.Conditions = nil
+		rt.requestUpgradeApproval(context.Background(), po, installed, target, nil)
+		c := meta.FindStatusCondition(po.Status.Conditions, platformtypes.TypeUpgradeable)
+		if c == nil || c.Reason != platformtypes.ReasonUpgradeApprovalPending {
+			t.Fatalf("Upgradeable condition = %+v, want reason %s", c, platformtypes.ReasonUpgradeApprovalPending)
+		}
+		return c.Message
+	}
+
+	// summaries that couldn't be computed are tried again.
+	if got := message(); !strings.Contains(got, "The changes are unknown") {
+		t.Errorf("Upgradeable message = %q, want the changes to be unknown", got)
+	}
+	if _, ok := rt.upgradeSummaries.get(po.GetName(), upgradeSummaryKey(installed, target)); ok {
+		t.Errorf("the summary of a failed diff was cached")
+	}
+
+	// the cached summary of the target is reused without unpacking either
+	// bundle, and cut short to fit in the condition.
+	rt.upgradeSummaries.set(po.GetName(), upgradeSummaryKey(installed, target), "The changes are: "+strings.Repeat("x", maxConditionMessageLength))
+	got := message()
+	if len(got) != maxConditionMessageLength || !strings.HasSuffix(got, "x...") {
+		t.Errorf("Upgradeable message is %d characters long ending in %q, want %d ending in \"x...\"", len(got), got[len(got)-4:], maxConditionMessageLength)
+	}
+
+	// a different target is diffed again.
+	target = &sourcer.Bundle{Name: "foo.v1.2.0", Image: "registry.invalid/example/foo:v1.2.0"}
+	if got := message(); !strings.Contains(got, "The changes are unknown") {
+		t.Errorf("Upgradeable message = %q, want the changes to be unknown", got)
+	}
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
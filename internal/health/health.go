@@ -0,0 +1,162 @@
+// Package health determines whether the workloads a bundle installs are
+// running, as opposed to only having been applied to the cluster.
+package health
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	platformtypes "github.com/openshift/platform-operators/api/v1alpha1"
+)
+
+// Workload is a Deployment a bundle installs, along with its pods.
+type Workload struct {
+	Deployment appsv1.Deployment
+	Pods       []corev1.Pod
+}
+
+// problem is a reason a workload isn't available.
+type problem struct {
+	reason  string
+	message string
+	// settling is whether the problem may still resolve on its own, as the
+	// workload's rollout made progress within the grace period.
+	settling bool
+}
+
+// problemReasons are the reasons of problems, from the most to the least
+// severe, which determines the reason reported when there are several.
+var problemReasons = []string{
+	platformtypes.ReasonCrashLoopBackOff,
+	platformtypes.ReasonRolloutFailed,
+	platformtypes.ReasonReplicasUnavailable,
+}
+
+// Inspect returns the Available condition of the workloads, which is False
+// when a workload's pods are crash looping, its rollout failed or replicas
+// are missing. Problems of workloads whose rollouts made progress within the
+// grace period, e.g. right after they're installed or upgraded, are reported
+// through an Unknown status instead, until the grace period expires, which
+// is returned so the workloads can be inspected again.
+func Inspect(workloads []Workload, gracePeriod time.Duration, now time.Time) (metav1.Condition, time.Duration) {
+	var (
+		problems   []problem
+		recheckIn  time.Duration
+		deployment []string
+	)
+	for _, w := range workloads {
+		deployment = append(deployment, name(&w.Deployment))
+		settleBy := lastProgress(&w.Deployment).Add(gracePeriod)
+		settling := now.Before(settleBy)
+		for _, p := range inspectWorkload(w) {
+			p.settling = settling && p.reason != platformtypes.ReasonRolloutFailed
+			problems = append(problems, p)
+			if wait := settleBy.Sub(now); p.settling && (recheckIn == 0 || wait < recheckIn) {
+				recheckIn = wait
+			}
+		}
+	}
+
+	if len(problems) == 0 {
+		msg := "The bundle doesn't install any Deployments"
+		if len(deployment) != 0 {
+			msg = fmt.Sprintf("The %s Deployments are available", strings.Join(deployment, ", "))
+		}
+		return metav1.Condition{
+			Type:    platformtypes.TypeAvailable,
+			Status:  metav1.ConditionTrue,
+			Reason:  platformtypes.ReasonWorkloadsAvailable,
+			Message: msg,
+		}, 0
+	}
+
+	var messages []string
+	reason := ""
+	for _, r := range problemReasons {
+		for _, p := range problems {
+			if p.reason != r {
+				continue
+			}
+			messages = append(messages, p.message)
+			if !p.settling && reason == "" {
+				reason = r
+			}
+		}
+	}
+	if reason == "" {
+		return metav1.Condition{
+			Type:    platformtypes.TypeAvailable,
+			Status:  metav1.ConditionUnknown,
+			Reason:  platformtypes.ReasonWorkloadsProgressing,
+			Message: fmt.Sprintf("Waiting for the workloads to become available: %s", strings.Join(messages, "; ")),
+		}, recheckIn
+	}
+	return metav1.Condition{
+		Type:    platformtypes.TypeAvailable,
+		Status:  metav1.ConditionFalse,
+		Reason:  reason,
+		Message: fmt.Sprintf("The workloads aren't available: %s", strings.Join(messages, "; ")),
+	}, recheckIn
+}
+
+// inspectWorkload returns the problems of the w workload.
+func inspectWorkload(w Workload) []problem {
+	d := &w.Deployment
+	var problems []problem
+	for _, pod := range w.Pods {
+		statuses := append(append([]corev1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...)
+		for _, status := range statuses {
+			if status.State.Waiting == nil || status.State.Waiting.Reason != "CrashLoopBackOff" {
+				continue
+			}
+			problems = append(problems, problem{
+				reason: platformtypes.ReasonCrashLoopBackOff,
+				message: fmt.Sprintf("the %s container of the %s pod of the %s Deployment is crash looping after %d restarts",
+					status.Name, pod.GetName(), name(d), status.RestartCount),
+			})
+		}
+	}
+
+	for _, c := range d.Status.Conditions {
+		if c.Type == appsv1.DeploymentProgressing && c.Status == corev1.ConditionFalse && c.Reason == "ProgressDeadlineExceeded" {
+			problems = append(problems, problem{
+				reason:  platformtypes.ReasonRolloutFailed,
+				message: fmt.Sprintf("the rollout of the %s Deployment failed: %s", name(d), c.Message),
+			})
+		}
+	}
+
+	desired := int32(1)
+	if d.Spec.Replicas != nil {
+		desired = *d.Spec.Replicas
+	}
+	if d.Status.AvailableReplicas < desired || d.Status.UpdatedReplicas < desired {
+		problems = append(problems, problem{
+			reason: platformtypes.ReasonReplicasUnavailable,
+			message: fmt.Sprintf("the %s Deployment has %d of %d updated replicas, and %d of %d available replicas",
+				name(d), d.Status.UpdatedReplicas, desired, d.Status.AvailableReplicas, desired),
+		})
+	}
+	return problems
+}
+
+// lastProgress returns when the d Deployment's rollout last made progress,
+// or when it was created, if it hasn't reported any progress.
+func lastProgress(d *appsv1.Deployment) time.Time {
+	last := d.GetCreationTimestamp().Time
+	for _, c := range d.Status.Conditions {
+		if c.Type == appsv1.DeploymentProgressing && c.LastUpdateTime.After(last) {
+			last = c.LastUpdateTime.Time
+		}
+	}
+	return last
+}
+
+func name(d *appsv1.Deployment) string {
+	return d.GetNamespace() + "/" + d.GetName()
+}
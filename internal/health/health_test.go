@@ -0,0 +1,112 @@
+package health
+
+import (
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	platformtypes "github.com/openshift/platform-operators/api/v1alpha1"
+)
+
+func TestInspect(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	deployment := func(replicas, available int32, lastProgress time.Time, progressing ...appsv1.DeploymentCondition) appsv1.Deployment {
+		return appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "foo", Name: "foo-operator", CreationTimestamp: metav1.NewTime(lastProgress)},
+			Spec:       appsv1.DeploymentSpec{Replicas: &replicas},
+			Status: appsv1.DeploymentStatus{
+				UpdatedReplicas:   replicas,
+				AvailableReplicas: available,
+				Conditions:        progressing,
+			},
+		}
+	}
+	crashLooping := corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "foo-operator-abc"},
+		Status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{{
+			Name:         "manager",
+			RestartCount: 4,
+			State:        corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}},
+		}}},
+	}
+	deadlineExceeded := appsv1.DeploymentCondition{
+		Type:           appsv1.DeploymentProgressing,
+		Status:         corev1.ConditionFalse,
+		Reason:         "ProgressDeadlineExceeded",
+		LastUpdateTime: metav1.NewTime(now.Add(-time.Minute)),
+	}
+
+	tests := []struct {
+		name          string
+		workloads     []Workload
+		wantStatus    metav1.ConditionStatus
+		wantReason    string
+		wantRecheckIn time.Duration
+	}{
+		{
+			name:       "NoDeployments",
+			wantStatus: metav1.ConditionTrue,
+			wantReason: platformtypes.ReasonWorkloadsAvailable,
+		},
+		{
+			name:       "Available",
+			workloads:  []Workload{{Deployment: deployment(2, 2, now.Add(-time.Hour))}},
+			wantStatus: metav1.ConditionTrue,
+			wantReason: platformtypes.ReasonWorkloadsAvailable,
+		},
+		{
+			name:          "ReplicasMissingWithinGracePeriod",
+			workloads:     []Workload{{Deployment: deployment(2, 1, now.Add(-time.Minute))}},
+			wantStatus:    metav1.ConditionUnknown,
+			wantReason:    platformtypes.ReasonWorkloadsProgressing,
+			wantRecheckIn: 4 * time.Minute,
+		},
+		{
+			name:       "ReplicasMissing",
+			workloads:  []Workload{{Deployment: deployment(2, 1, now.Add(-time.Hour))}},
+			wantStatus: metav1.ConditionFalse,
+			wantReason: platformtypes.ReasonReplicasUnavailable,
+		},
+		{
+			name:       "RolloutFailedWithinGracePeriod",
+			workloads:  []Workload{{Deployment: deployment(1, 0, now.Add(-time.Hour), deadlineExceeded)}},
+			wantStatus: metav1.ConditionFalse,
+			wantReason: platformtypes.ReasonRolloutFailed,
+			// the missing replica is still within the grace period, which
+			// started when the rollout last reported progress.
+			wantRecheckIn: 4 * time.Minute,
+		},
+		{
+			name: "CrashLoopBackOff",
+			workloads: []Workload{
+				{Deployment: deployment(1, 0, now.Add(-time.Hour)), Pods: []corev1.Pod{crashLooping}},
+			},
+			wantStatus: metav1.ConditionFalse,
+			wantReason: platformtypes.ReasonCrashLoopBackOff,
+		},
+		{
+			name: "MostSevereSettledProblem",
+			workloads: []Workload{
+				{Deployment: deployment(1, 0, now.Add(-time.Minute)), Pods: []corev1.Pod{crashLooping}},
+				{Deployment: deployment(1, 0, now.Add(-time.Hour))},
+			},
+			wantStatus:    metav1.ConditionFalse,
+			wantReason:    platformtypes.ReasonReplicasUnavailable,
+			wantRecheckIn: 4 * time.Minute,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cond, recheckIn := Inspect(tt.workloads, 5*time.Minute, now)
+			if cond.Type != platformtypes.TypeAvailable || cond.Status != tt.wantStatus || cond.Reason != tt.wantReason {
+				t.Errorf("Inspect() = %s %s %s (%s), want %s %s", cond.Type, cond.Status, cond.Reason, cond.Message, tt.wantStatus, tt.wantReason)
+			}
+			if recheckIn != tt.wantRecheckIn {
+				t.Errorf("Inspect() recheck in = %s, want %s", recheckIn, tt.wantRecheckIn)
+			}
+		})
+	}
+}
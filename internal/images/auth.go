@@ -0,0 +1,132 @@
+package images
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+)
+
+// Credentials authenticate requests to a registry.
+type Credentials struct {
+	Username string
+	Password string
+}
+
+// Keychain holds the credentials for each registry, keyed by the registry's
+// host, e.g. quay.io or docker.io, or by a repository path within it, e.g.
+// quay.io/org, for credentials that are scoped to that path.
+type Keychain map[string]Credentials
+
+// dockerConfig is the format of kubernetes.io/dockerconfigjson secrets.
+type dockerConfig struct {
+	Auths map[string]dockerAuth `json:"auths"`
+}
+
+type dockerAuth struct {
+	Auth     string `json:"auth,omitempty"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+}
+
+// ParseDockerConfig returns the credentials in the data of a
+// kubernetes.io/dockerconfigjson secret.
+func ParseDockerConfig(data []byte) (Keychain, error) {
+	var config dockerConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to decode the docker config: %w", err)
+	}
+	keychain := make(Keychain, len(config.Auths))
+	for server, auth := range config.Auths {
+		creds := Credentials{Username: auth.Username, Password: auth.Password}
+		if auth.Auth != "" {
+			decoded, err := base64.StdEncoding.DecodeString(auth.Auth)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode the credentials for %s: %w", server, err)
+			}
+			user, password, ok := strings.Cut(string(decoded), ":")
+			if !ok {
+				return nil, fmt.Errorf("the credentials for %s aren't of the form <username>:<password>", server)
+			}
+			creds = Credentials{Username: user, Password: password}
+		}
+		keychain[registryKey(server)] = creds
+	}
+	return keychain, nil
+}
+
+// DockerConfig returns the keychain in the format of a
+// kubernetes.io/dockerconfigjson secret.
+func (k Keychain) DockerConfig() ([]byte, error) {
+	config := dockerConfig{Auths: make(map[string]dockerAuth, len(k))}
+	for key, creds := range k {
+		config.Auths[key] = dockerAuth{
+			Auth: basicAuth(creds),
+		}
+	}
+	return json.Marshal(config)
+}
+
+// Merge returns a keychain with the credentials of k and others, where the
+// credentials of later keychains replace earlier ones for the same key. Keys
+// scoped to a repository path are kept apart from the registry's own key.
+func (k Keychain) Merge(others ...Keychain) Keychain {
+	merged := make(Keychain, len(k))
+	for _, keychain := range append([]Keychain{k}, others...) {
+		for key, creds := range keychain {
+			merged[key] = creds
+		}
+	}
+	return merged
+}
+
+// Resolve implements authn.Keychain, returning the credentials for the
+// target repository, or anonymous access when there are none.
+func (k Keychain) Resolve(target authn.Resource) (authn.Authenticator, error) {
+	creds, ok := k.lookup(target.String())
+	if !ok {
+		return authn.Anonymous, nil
+	}
+	return authn.FromConfig(authn.AuthConfig{Username: creds.Username, Password: creds.Password}), nil
+}
+
+// lookup returns the credentials whose key is the longest prefix of the
+// repository, e.g. quay.io/org/repo, on path boundaries. The registry's own
+// key is the shortest prefix that's considered.
+func (k Keychain) lookup(repository string) (Credentials, bool) {
+	key := registryKey(repository)
+	for {
+		if creds, ok := k[key]; ok {
+			return creds, true
+		}
+		i := strings.LastIndex(key, "/")
+		if i < 0 {
+			return Credentials{}, false
+		}
+		key = key[:i]
+	}
+}
+
+// registryKey normalizes the keys used in docker configs, which may be URLs,
+// e.g. https://index.docker.io/v1/, to a registry host followed by the
+// repository path the credentials are scoped to, if any.
+func registryKey(server string) string {
+	key := strings.TrimPrefix(strings.TrimPrefix(server, "https://"), "http://")
+	key = strings.TrimSuffix(key, "/")
+	host, path, _ := strings.Cut(key, "/")
+	switch host {
+	case "index.docker.io", "registry-1.docker.io":
+		host = defaultRegistry
+	}
+	// the paths of registry API URLs aren't repository paths.
+	if path == "" || path == "v1" || path == "v2" {
+		return host
+	}
+	return host + "/" + path
+}
+
+func basicAuth(creds Credentials) string {
+	return base64.StdEncoding.EncodeToString([]byte(creds.Username + ":" + creds.Password))
+}
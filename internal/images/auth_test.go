@@ -0,0 +1,157 @@
+package images
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+func TestParseDockerConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		want    Keychain
+		wantErr bool
+	}{
+		{
+			name:   "Auth",
+			config: `{"auths":{"quay.io":{"auth":"dXNlcjpzZWNyZXQ="}}}`,
+			want:   Keychain{"quay.io": {Username: "user", Password: "secret"}},
+		},
+		{
+			name:   "UsernameAndPassword",
+			config: `{"auths":{"registry.example.com:5000":{"username":"user","password":"secret"}}}`,
+			want:   Keychain{"registry.example.com:5000": {Username: "user", Password: "secret"}},
+		},
+		{
+			name:   "DockerHubURL",
+			config: `{"auths":{"https://index.docker.io/v1/":{"auth":"dXNlcjpzZWNyZXQ="}}}`,
+			want:   Keychain{"docker.io": {Username: "user", Password: "secret"}},
+		},
+		{
+			name:   "RepositoryScoped",
+			config: `{"auths":{"quay.io/org/":{"auth":"dXNlcjpzZWNyZXQ="}}}`,
+			want:   Keychain{"quay.io/org": {Username: "user", Password: "secret"}},
+		},
+		{
+			name:    "InvalidAuth",
+			config:  `{"auths":{"quay.io":{"auth":"dXNlcg=="}}}`,
+			wantErr: true,
+		},
+		{
+			name:    "InvalidJSON",
+			config:  `{`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseDockerConfig([]byte(tt.config))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseDockerConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseDockerConfig() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeychainMerge(t *testing.T) {
+	global := Keychain{
+		"quay.io":         {Username: "global", Password: "secret"},
+		"registry.io:443": {Username: "global", Password: "secret"},
+	}
+	specific := Keychain{
+		"quay.io":             {Username: "specific", Password: "secret"},
+		"registry.io:443/org": {Username: "specific", Password: "secret"},
+	}
+
+	merged := global.Merge(specific)
+	want := Keychain{
+		"quay.io":             {Username: "specific", Password: "secret"},
+		"registry.io:443":     {Username: "global", Password: "secret"},
+		"registry.io:443/org": {Username: "specific", Password: "secret"},
+	}
+	if !reflect.DeepEqual(merged, want) {
+		t.Errorf("Merge() = %v, want %v", merged, want)
+	}
+
+	data, err := merged.DockerConfig()
+	if err != nil {
+		t.Fatalf("DockerConfig() unexpected error: %v", err)
+	}
+	parsed, err := ParseDockerConfig(data)
+	if err != nil {
+		t.Fatalf("ParseDockerConfig() unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(parsed, want) {
+		t.Errorf("ParseDockerConfig(DockerConfig()) = %v, want %v", parsed, want)
+	}
+}
+
+func TestKeychainResolve(t *testing.T) {
+	keychain := Keychain{
+		"quay.io":          {Username: "host", Password: "secret"},
+		"quay.io/org":      {Username: "org", Password: "secret"},
+		"quay.io/org/team": {Username: "team", Password: "secret"},
+		"docker.io":        {Username: "hub", Password: "secret"},
+	}
+	tests := []struct {
+		name       string
+		repository string
+		want       *authn.AuthConfig
+	}{
+		{
+			name:       "Registry",
+			repository: "quay.io/other/repo",
+			want:       &authn.AuthConfig{Username: "host", Password: "secret"},
+		},
+		{
+			name:       "Repository",
+			repository: "quay.io/org/repo",
+			want:       &authn.AuthConfig{Username: "org", Password: "secret"},
+		},
+		{
+			name:       "LongestPrefix",
+			repository: "quay.io/org/team/repo",
+			want:       &authn.AuthConfig{Username: "team", Password: "secret"},
+		},
+		{
+			name:       "PathBoundary",
+			repository: "quay.io/organization/repo",
+			want:       &authn.AuthConfig{Username: "host", Password: "secret"},
+		},
+		{
+			name:       "DockerHub",
+			repository: "library/busybox",
+			want:       &authn.AuthConfig{Username: "hub", Password: "secret"},
+		},
+		{
+			name:       "Anonymous",
+			repository: "registry.example.com/repo",
+			want:       &authn.AuthConfig{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo, err := name.NewRepository(tt.repository)
+			if err != nil {
+				t.Fatal(err)
+			}
+			auth, err := keychain.Resolve(repo)
+			if err != nil {
+				t.Fatalf("Resolve() unexpected error: %v", err)
+			}
+			got, err := auth.Authorization()
+			if err != nil {
+				t.Fatalf("Authorization() unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Resolve() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package images
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	configv1 "github.com/openshift/api/config/v1"
+	operatorv1alpha1 "github.com/openshift/api/operator/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// MirrorRule mirrors the images under a source repository, or under a
+// registry or namespace, to other locations.
+type MirrorRule struct {
+	// Source is the repository, namespace or registry the rule applies to.
+	Source string
+	// Mirrors are the locations Source is mirrored to, in priority order.
+	Mirrors []string
+	// Tags is set for rules from ImageTagMirrorSets, which apply to tag
+	// references, and unset for rules from ImageDigestMirrorSets and
+	// ImageContentSourcePolicies, which apply to digest references.
+	Tags bool
+	// NeverContactSource is set when images must only be pulled from the
+	// mirrors.
+	NeverContactSource bool
+}
+
+//+kubebuilder:rbac:groups=config.openshift.io,resources=imagedigestmirrorsets;imagetagmirrorsets,verbs=get;list;watch
+//+kubebuilder:rbac:groups=operator.openshift.io,resources=imagecontentsourcepolicies,verbs=get;list;watch
+
+// LoadMirrorRules returns the rules of the cluster's ImageDigestMirrorSets,
+// ImageTagMirrorSets and legacy ImageContentSourcePolicies. APIs the cluster
+// doesn't serve are skipped.
+func LoadMirrorRules(ctx context.Context, c client.Reader) ([]MirrorRule, error) {
+	var rules []MirrorRule
+
+	idms := &configv1.ImageDigestMirrorSetList{}
+	if err := list(ctx, c, idms); err != nil {
+		return nil, err
+	}
+	for _, set := range idms.Items {
+		for _, m := range set.Spec.ImageDigestMirrors {
+			rules = append(rules, MirrorRule{
+				Source:             m.Source,
+				Mirrors:            mirrors(m.Mirrors),
+				NeverContactSource: m.MirrorSourcePolicy == configv1.NeverContactSource,
+			})
+		}
+	}
+
+	itms := &configv1.ImageTagMirrorSetList{}
+	if err := list(ctx, c, itms); err != nil {
+		return nil, err
+	}
+	for _, set := range itms.Items {
+		for _, m := range set.Spec.ImageTagMirrors {
+			rules = append(rules, MirrorRule{
+				Source:             m.Source,
+				Mirrors:            mirrors(m.Mirrors),
+				Tags:               true,
+				NeverContactSource: m.MirrorSourcePolicy == configv1.NeverContactSource,
+			})
+		}
+	}
+
+	icsp := &operatorv1alpha1.ImageContentSourcePolicyList{}
+	if err := list(ctx, c, icsp); err != nil {
+		return nil, err
+	}
+	for _, policy := range icsp.Items {
+		for _, m := range policy.Spec.RepositoryDigestMirrors {
+			rules = append(rules, MirrorRule{
+				Source:  m.Source,
+				Mirrors: m.Mirrors,
+			})
+		}
+	}
+	return rules, nil
+}
+
+func list(ctx context.Context, c client.Reader, l client.ObjectList) error {
+	if err := c.List(ctx, l); err != nil && !meta.IsNoMatchError(err) {
+		return fmt.Errorf("failed to list image mirroring policies: %w", err)
+	}
+	return nil
+}
+
+func mirrors(ms []configv1.ImageMirror) []string {
+	out := make([]string, 0, len(ms))
+	for _, m := range ms {
+		out = append(out, string(m))
+	}
+	return out
+}
+
+// Sources returns the references ref can be pulled from, in the order the
+// cluster tries them: the mirrors of each rule that applies to ref, and then
+// ref itself, unless one of those rules never contacts the source.
+func Sources(rules []MirrorRule, ref Reference) []Reference {
+	var (
+		sources       []Reference
+		seen          = map[string]bool{}
+		contactSource = true
+	)
+	for _, rule := range rules {
+		if rule.Tags != (ref.Digest == "") {
+			continue
+		}
+		rest, ok := matchSource(rule.Source, ref.Name())
+		if !ok {
+			continue
+		}
+		if rule.NeverContactSource {
+			contactSource = false
+		}
+		for _, mirror := range rule.Mirrors {
+			m, err := ParseReference(mirror + rest + suffix(ref))
+			if err != nil || seen[m.String()] {
+				continue
+			}
+			seen[m.String()] = true
+			sources = append(sources, m)
+		}
+	}
+	if contactSource && !seen[ref.String()] {
+		sources = append(sources, ref)
+	}
+	return sources
+}
+
+// matchSource returns the part of name below source, when source is name
+// itself or one of its parent namespaces or registries.
+func matchSource(source, name string) (string, bool) {
+	source = strings.TrimSuffix(source, "/")
+	if source == name {
+		return "", true
+	}
+	if strings.HasPrefix(name, source+"/") {
+		return strings.TrimPrefix(name, source), true
+	}
+	return "", false
+}
+
+// suffix returns the tag or digest part of the ref reference.
+func suffix(ref Reference) string {
+	if ref.Digest != "" {
+		return "@" + ref.Digest
+	}
+	return ":" + ref.Tag
+}
@@ -0,0 +1,63 @@
+package images
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSources(t *testing.T) {
+	rules := []MirrorRule{
+		{Source: "quay.io/example", Mirrors: []string{"mirror.local/example", "backup.local/example"}},
+		{Source: "quay.io/example/foo-bundle", Mirrors: []string{"mirror.local/example/foo-bundle"}},
+		{Source: "registry.example.com", Mirrors: []string{"mirror.local/registry"}, NeverContactSource: true},
+		{Source: "quay.io/example", Mirrors: []string{"tags.local/example"}, Tags: true},
+	}
+	tests := []struct {
+		name  string
+		image string
+		want  []string
+	}{
+		{
+			name:  "NamespaceAndRepositoryRules",
+			image: "quay.io/example/foo-bundle@sha256:abc",
+			want: []string{
+				"mirror.local/example/foo-bundle@sha256:abc",
+				"backup.local/example/foo-bundle@sha256:abc",
+				"quay.io/example/foo-bundle@sha256:abc",
+			},
+		},
+		{
+			name:  "TagRules",
+			image: "quay.io/example/foo-bundle:v1.0.0",
+			want: []string{
+				"tags.local/example/foo-bundle:v1.0.0",
+				"quay.io/example/foo-bundle:v1.0.0",
+			},
+		},
+		{
+			name:  "NeverContactSource",
+			image: "registry.example.com/team/foo-bundle@sha256:abc",
+			want:  []string{"mirror.local/registry/team/foo-bundle@sha256:abc"},
+		},
+		{
+			name:  "PartialPathSegment",
+			image: "quay.io/examples/foo-bundle@sha256:abc",
+			want:  []string{"quay.io/examples/foo-bundle@sha256:abc"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ref, err := ParseReference(tt.image)
+			if err != nil {
+				t.Fatalf("ParseReference() unexpected error: %v", err)
+			}
+			var got []string
+			for _, source := range Sources(rules, ref) {
+				got = append(got, source.String())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Sources() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
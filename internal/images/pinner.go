@@ -0,0 +1,100 @@
+package images
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Pinned is an image reference that was resolved to a digest.
+type Pinned struct {
+	// Image is the reference to install, pinned to Digest.
+	Image string
+	// Digest is the digest of the image's manifest.
+	Digest string
+	// Mirror is the mirror the pinned image was found in, or empty when
+	// it was found in its source registry.
+	Mirror string
+}
+
+// Pinner resolves image references to digests, following the cluster's
+// image mirroring policies so disconnected clusters resolve tags and check
+// for images in their mirrors rather than in the unreachable sources.
+type Pinner struct {
+	client   client.Reader
+	registry *Registry
+	rewrite  bool
+}
+
+// NewPinner returns a Pinner that reads the mirroring policies with the
+// client and queries registries with the registry. When rewrite is set,
+// pinned references are rewritten to the first mirror that serves them.
+func NewPinner(c client.Reader, registry *Registry, rewrite bool) *Pinner {
+	return &Pinner{
+		client:   c,
+		registry: registry,
+		rewrite:  rewrite,
+	}
+}
+
+// Pin resolves the image reference to a digest, and checks that the pinned
+// image can be pulled from the source or one of its mirrors, authenticating
+// with the credentials in the keychain.
+func (p *Pinner) Pin(ctx context.Context, image string, keychain Keychain) (*Pinned, error) {
+	ref, err := ParseReference(image)
+	if err != nil {
+		return nil, err
+	}
+	rules, err := LoadMirrorRules(ctx, p.client)
+	if err != nil {
+		return nil, err
+	}
+
+	registry := p.registry.WithKeychain(keychain)
+	digest := ref.Digest
+	if digest == "" {
+		var errs []error
+		for _, source := range Sources(rules, ref) {
+			d, err := registry.Resolve(ctx, source)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			digest = d
+			break
+		}
+		if digest == "" {
+			return nil, fmt.Errorf("failed to resolve the %s image to a digest: %w", image, sourcesError(errs))
+		}
+	}
+
+	pinned := ref.WithDigest(digest)
+	var errs []error
+	for _, source := range Sources(rules, pinned) {
+		if _, err := registry.Resolve(ctx, source); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		out := &Pinned{Image: pinned.String(), Digest: digest}
+		if source != pinned {
+			out.Mirror = source.Name()
+			if p.rewrite {
+				out.Image = source.String()
+			}
+		}
+		return out, nil
+	}
+	return nil, fmt.Errorf("the %s image isn't available from its source or any of its mirrors: %w", pinned, sourcesError(errs))
+}
+
+// sourcesError returns the errors from the sources of an image, or an error
+// explaining that the mirroring policies left no source to try.
+func sourcesError(errs []error) error {
+	if len(errs) == 0 {
+		return errors.New("the image mirroring policies don't allow any source or mirror")
+	}
+	return utilerrors.NewAggregate(errs)
+}
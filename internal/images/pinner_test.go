@@ -0,0 +1,135 @@
+package images
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	configv1 "github.com/openshift/api/config/v1"
+	operatorv1alpha1 "github.com/openshift/api/operator/v1alpha1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// policies is a client.Reader that lists fixed image mirroring policies.
+type policies struct {
+	client.Reader
+	idms []configv1.ImageDigestMirrors
+	itms []configv1.ImageTagMirrors
+	icsp []operatorv1alpha1.RepositoryDigestMirrors
+}
+
+func (p policies) List(_ context.Context, list client.ObjectList, _ ...client.ListOption) error {
+	switch l := list.(type) {
+	case *configv1.ImageDigestMirrorSetList:
+		l.Items = []configv1.ImageDigestMirrorSet{{Spec: configv1.ImageDigestMirrorSetSpec{ImageDigestMirrors: p.idms}}}
+	case *configv1.ImageTagMirrorSetList:
+		l.Items = []configv1.ImageTagMirrorSet{{Spec: configv1.ImageTagMirrorSetSpec{ImageTagMirrors: p.itms}}}
+	case *operatorv1alpha1.ImageContentSourcePolicyList:
+		l.Items = []operatorv1alpha1.ImageContentSourcePolicy{{Spec: operatorv1alpha1.ImageContentSourcePolicySpec{RepositoryDigestMirrors: p.icsp}}}
+	}
+	return nil
+}
+
+func TestPinnerPin(t *testing.T) {
+	const manifest = `{"schemaVersion":2}`
+	var (
+		source = newTestRegistry(t, map[string]string{"example/foo-bundle:v1.0.0": manifest})
+		mirror = newTestRegistry(t, map[string]string{"mirror/foo-bundle:v1.0.0": manifest})
+		empty  = newTestRegistry(t, nil)
+		digest = sha256Digest([]byte(manifest))
+	)
+	tests := []struct {
+		name       string
+		image      string
+		policies   policies
+		rewrite    bool
+		wantImage  string
+		wantMirror string
+		wantErr    string
+	}{
+		{
+			name:      "Tag",
+			image:     source.host() + "/example/foo-bundle:v1.0.0",
+			wantImage: source.host() + "/example/foo-bundle@" + digest,
+		},
+		{
+			name:      "Digest",
+			image:     source.host() + "/example/foo-bundle@" + digest,
+			wantImage: source.host() + "/example/foo-bundle@" + digest,
+		},
+		{
+			name:  "DigestMirror",
+			image: source.host() + "/example/foo-bundle:v1.0.0",
+			policies: policies{idms: []configv1.ImageDigestMirrors{{
+				Source:  source.host() + "/example",
+				Mirrors: []configv1.ImageMirror{configv1.ImageMirror(empty.host() + "/mirror"), configv1.ImageMirror(mirror.host() + "/mirror")},
+			}}},
+			wantImage:  source.host() + "/example/foo-bundle@" + digest,
+			wantMirror: mirror.host() + "/mirror/foo-bundle",
+		},
+		{
+			name:  "RewriteToMirror",
+			image: source.host() + "/example/foo-bundle:v1.0.0",
+			policies: policies{icsp: []operatorv1alpha1.RepositoryDigestMirrors{{
+				Source:  source.host() + "/example/foo-bundle",
+				Mirrors: []string{mirror.host() + "/mirror/foo-bundle"},
+			}}},
+			rewrite:    true,
+			wantImage:  mirror.host() + "/mirror/foo-bundle@" + digest,
+			wantMirror: mirror.host() + "/mirror/foo-bundle",
+		},
+		{
+			name:  "DisconnectedTagMirror",
+			image: "registry.invalid/example/foo-bundle:v1.0.0",
+			policies: policies{
+				itms: []configv1.ImageTagMirrors{{
+					Source:             "registry.invalid/example",
+					Mirrors:            []configv1.ImageMirror{configv1.ImageMirror(mirror.host() + "/mirror")},
+					MirrorSourcePolicy: configv1.NeverContactSource,
+				}},
+				idms: []configv1.ImageDigestMirrors{{
+					Source:             "registry.invalid/example",
+					Mirrors:            []configv1.ImageMirror{configv1.ImageMirror(mirror.host() + "/mirror")},
+					MirrorSourcePolicy: configv1.NeverContactSource,
+				}},
+			},
+			rewrite:    true,
+			wantImage:  mirror.host() + "/mirror/foo-bundle@" + digest,
+			wantMirror: mirror.host() + "/mirror/foo-bundle",
+		},
+		{
+			name:    "MissingTag",
+			image:   source.host() + "/example/foo-bundle:v2.0.0",
+			wantErr: "failed to resolve",
+		},
+		{
+			name:  "UnavailableFromMirrors",
+			image: source.host() + "/example/foo-bundle:v1.0.0",
+			policies: policies{idms: []configv1.ImageDigestMirrors{{
+				Source:             source.host() + "/example",
+				Mirrors:            []configv1.ImageMirror{configv1.ImageMirror(empty.host() + "/mirror")},
+				MirrorSourcePolicy: configv1.NeverContactSource,
+			}}},
+			wantErr: "isn't available from its source or any of its mirrors",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pinner := NewPinner(tt.policies, NewRegistry(&http.Client{}), tt.rewrite)
+			got, err := pinner.Pin(context.Background(), tt.image, nil)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Pin() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Pin() unexpected error: %v", err)
+			}
+			if got.Image != tt.wantImage || got.Digest != digest || got.Mirror != tt.wantMirror {
+				t.Errorf("Pin() = %+v, want image %s, digest %s and mirror %q", got, tt.wantImage, digest, tt.wantMirror)
+			}
+		})
+	}
+}
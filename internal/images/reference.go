@@ -0,0 +1,83 @@
+package images
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+const (
+	defaultRegistry  = "docker.io"
+	officialImageOrg = "library"
+)
+
+// Reference is a parsed image reference, e.g.
+// quay.io/example/foo-bundle:v1.0.0 or quay.io/example/foo-bundle@sha256:...
+type Reference struct {
+	Registry   string
+	Repository string
+	Tag        string
+	Digest     string
+}
+
+// ParseReference parses the image reference s, defaulting the registry to
+// docker.io, and the tag to latest when neither a tag nor a digest is set.
+func ParseReference(s string) (Reference, error) {
+	var ref Reference
+	name := s
+	if i := strings.Index(name, "@"); i != -1 {
+		name, ref.Digest = name[:i], name[i+1:]
+		if !strings.Contains(ref.Digest, ":") {
+			return Reference{}, fmt.Errorf("invalid image reference %q: the digest isn't of the form <algorithm>:<hex>", s)
+		}
+	}
+	if i := strings.LastIndex(name, ":"); i != -1 && !strings.Contains(name[i:], "/") {
+		name, ref.Tag = name[:i], name[i+1:]
+	}
+	if ref.Tag == "" && ref.Digest == "" {
+		ref.Tag = "latest"
+	}
+
+	ref.Registry, ref.Repository = defaultRegistry, name
+	if i := strings.Index(name, "/"); i != -1 {
+		domain := name[:i]
+		if strings.ContainsAny(domain, ".:") || domain == "localhost" {
+			ref.Registry, ref.Repository = domain, name[i+1:]
+		}
+	}
+	if ref.Registry == defaultRegistry && !strings.Contains(ref.Repository, "/") {
+		ref.Repository = officialImageOrg + "/" + ref.Repository
+	}
+	if ref.Repository == "" || strings.ToLower(ref.Repository) != ref.Repository {
+		return Reference{}, fmt.Errorf("invalid image reference %q: the repository must be a non-empty lowercase name", s)
+	}
+	return ref, nil
+}
+
+// Name returns the registry and repository of the reference, e.g.
+// quay.io/example/foo-bundle.
+func (r Reference) Name() string {
+	return r.Registry + "/" + r.Repository
+}
+
+// String returns the reference, preferring the digest over the tag when
+// both are set.
+func (r Reference) String() string {
+	if r.Digest != "" {
+		return r.Name() + "@" + r.Digest
+	}
+	return r.Name() + ":" + r.Tag
+}
+
+// WithDigest returns a copy of the reference that's pinned to digest.
+func (r Reference) WithDigest(digest string) Reference {
+	r.Tag, r.Digest = "", digest
+	return r
+}
+
+// name returns the reference in the form go-containerregistry requests it
+// by.
+func (r Reference) name() (name.Reference, error) {
+	return name.ParseReference(r.String(), name.StrictValidation)
+}
@@ -0,0 +1,76 @@
+package images
+
+import (
+	"testing"
+)
+
+func TestParseReference(t *testing.T) {
+	tests := []struct {
+		name    string
+		image   string
+		want    Reference
+		wantErr bool
+	}{
+		{
+			name:  "Tag",
+			image: "quay.io/example/foo-bundle:v1.0.0",
+			want:  Reference{Registry: "quay.io", Repository: "example/foo-bundle", Tag: "v1.0.0"},
+		},
+		{
+			name:  "Digest",
+			image: "quay.io/example/foo-bundle@sha256:abc",
+			want:  Reference{Registry: "quay.io", Repository: "example/foo-bundle", Digest: "sha256:abc"},
+		},
+		{
+			name:  "TagAndDigest",
+			image: "quay.io/example/foo-bundle:v1.0.0@sha256:abc",
+			want:  Reference{Registry: "quay.io", Repository: "example/foo-bundle", Tag: "v1.0.0", Digest: "sha256:abc"},
+		},
+		{
+			name:  "RegistryPort",
+			image: "localhost:5000/foo-bundle",
+			want:  Reference{Registry: "localhost:5000", Repository: "foo-bundle", Tag: "latest"},
+		},
+		{
+			name:  "DockerHub",
+			image: "example/foo-bundle:v1.0.0",
+			want:  Reference{Registry: "docker.io", Repository: "example/foo-bundle", Tag: "v1.0.0"},
+		},
+		{
+			name:  "DockerHubOfficialImage",
+			image: "busybox",
+			want:  Reference{Registry: "docker.io", Repository: "library/busybox", Tag: "latest"},
+		},
+		{
+			name:    "InvalidDigest",
+			image:   "quay.io/example/foo-bundle@abc",
+			wantErr: true,
+		},
+		{
+			name:    "Uppercase",
+			image:   "quay.io/Example/foo-bundle:v1.0.0",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseReference(tt.image)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseReference() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseReference() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReferenceString(t *testing.T) {
+	ref := Reference{Registry: "quay.io", Repository: "example/foo-bundle", Tag: "v1.0.0"}
+	if got, want := ref.String(), "quay.io/example/foo-bundle:v1.0.0"; got != want {
+		t.Errorf("String() = %s, want %s", got, want)
+	}
+	if got, want := ref.WithDigest("sha256:abc").String(), "quay.io/example/foo-bundle@sha256:abc"; got != want {
+		t.Errorf("WithDigest().String() = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,191 @@
+package images
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// maxBodySize caps the size of the manifests and blobs that are read as
+	// documents, which are only ever small JSON documents.
+	maxBodySize = 4 << 20
+	// maxLayerSize caps the size of the layers that are unpacked, which is
+	// far larger than a bundle image's layers.
+	maxLayerSize = 64 << 20
+)
+
+var (
+	// ErrManifestNotFound is returned when a registry doesn't serve the
+	// manifest an image reference points to.
+	ErrManifestNotFound = errors.New("manifest not found")
+)
+
+// Registry resolves image references through the registry HTTP API,
+// authenticating with the credentials of its keychain, or anonymously, when
+// a registry challenges a request.
+type Registry struct {
+	client   *http.Client
+	keychain Keychain
+	// mirrors reads the cluster's image mirroring policies, which images are
+	// unpacked and verified through, and is nil when they're ignored.
+	mirrors client.Reader
+}
+
+// NewRegistry returns a Registry that sends requests through the client's
+// transport, and gives up on each call after the client's timeout.
+func NewRegistry(client *http.Client) *Registry {
+	return &Registry{client: client}
+}
+
+// WithKeychain returns a copy of the registry that authenticates with the
+// credentials in the keychain.
+func (r *Registry) WithKeychain(keychain Keychain) *Registry {
+	return &Registry{client: r.client, keychain: keychain, mirrors: r.mirrors}
+}
+
+// WithMirrors returns a copy of the registry that unpacks and verifies images
+// through the mirrors of the image mirroring policies read with c, in the
+// order the cluster tries them.
+func (r *Registry) WithMirrors(c client.Reader) *Registry {
+	return &Registry{client: r.client, keychain: r.keychain, mirrors: c}
+}
+
+// sources returns the references the ref image is pulled from, which is only
+// ref itself when the registry ignores the image mirroring policies.
+func (r *Registry) sources(ctx context.Context, ref Reference) ([]Reference, error) {
+	if r.mirrors == nil {
+		return []Reference{ref}, nil
+	}
+	rules, err := LoadMirrorRules(ctx, r.mirrors)
+	if err != nil {
+		return nil, err
+	}
+	return Sources(rules, ref), nil
+}
+
+// options returns the go-containerregistry options each call is made with.
+func (r *Registry) options(ctx context.Context) []remote.Option {
+	transport := r.client.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	opts := []remote.Option{
+		remote.WithContext(ctx),
+		remote.WithTransport(transport),
+	}
+	if r.keychain != nil {
+		opts = append(opts, remote.WithAuthFromKeychain(r.keychain))
+	}
+	return opts
+}
+
+// withTimeout bounds ctx by the client's timeout, when it has one.
+func (r *Registry) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if r.client.Timeout == 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, r.client.Timeout)
+}
+
+// Resolve returns the digest of the manifest the ref reference points to.
+// An error wrapping ErrManifestNotFound is returned when the registry doesn't
+// serve that manifest.
+func (r *Registry) Resolve(ctx context.Context, ref Reference) (string, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	n, err := ref.name()
+	if err != nil {
+		return "", err
+	}
+	desc, err := remote.Head(n, r.options(ctx)...)
+	if err == nil {
+		return desc.Digest.String(), nil
+	}
+	if isNotFound(err) {
+		return "", fmt.Errorf("%s: %w", ref, ErrManifestNotFound)
+	}
+
+	// not every registry reports the digest of a HEAD request, so it's
+	// computed from the manifest's contents instead.
+	_, digest, err := r.manifest(ctx, ref, n)
+	return digest, err
+}
+
+// Manifest returns the contents and the digest of the manifest the ref
+// reference points to.
+func (r *Registry) Manifest(ctx context.Context, ref Reference) ([]byte, string, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	n, err := ref.name()
+	if err != nil {
+		return nil, "", err
+	}
+	return r.manifest(ctx, ref, n)
+}
+
+func (r *Registry) manifest(ctx context.Context, ref Reference, n name.Reference) ([]byte, string, error) {
+	desc, err := remote.Get(n, r.options(ctx)...)
+	if err != nil {
+		return nil, "", wrapError(ref, err)
+	}
+	if desc.Size > maxBodySize {
+		return nil, "", fmt.Errorf("%s is larger than %d bytes", ref, maxBodySize)
+	}
+	return desc.Manifest, desc.Digest.String(), nil
+}
+
+// Blob returns the contents of the blob with the digest in the ref
+// reference's repository.
+func (r *Registry) Blob(ctx context.Context, ref Reference, digest string) ([]byte, error) {
+	return r.blob(ctx, ref, digest, maxBodySize)
+}
+
+func (r *Registry) blob(ctx context.Context, ref Reference, digest string, limit int) ([]byte, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	n, err := name.NewDigest(ref.Name()+"@"+digest, name.StrictValidation)
+	if err != nil {
+		return nil, err
+	}
+	layer, err := remote.Layer(n, r.options(ctx)...)
+	if err != nil {
+		return nil, wrapError(ref.WithDigest(digest), err)
+	}
+	rc, err := layer.Compressed()
+	if err != nil {
+		return nil, wrapError(ref.WithDigest(digest), err)
+	}
+	defer rc.Close()
+
+	// the reader checks the blob against its digest once it's fully read.
+	body, err := io.ReadAll(io.LimitReader(rc, int64(limit)+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the %s blob of %s: %w", digest, ref.Name(), err)
+	}
+	if len(body) > limit {
+		return nil, fmt.Errorf("the %s blob of %s is larger than %d bytes", digest, ref.Name(), limit)
+	}
+	return body, nil
+}
+
+// wrapError wraps err with ErrManifestNotFound when the registry reported
+// that the ref reference doesn't exist.
+func wrapError(ref Reference, err error) error {
+	if isNotFound(err) {
+		return fmt.Errorf("%s: %w", ref, ErrManifestNotFound)
+	}
+	return fmt.Errorf("failed to get %s: %w", ref, err)
+}
+
+func isNotFound(err error) bool {
+	var terr *transport.Error
+	return errors.As(err, &terr) && terr.StatusCode == http.StatusNotFound
+}
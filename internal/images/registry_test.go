@@ -0,0 +1,188 @@
+package images
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// testRegistry is a stand-in for an image registry that serves manifests by
+// tag and by digest. It serves plain HTTP, which go-containerregistry talks
+// to loopback registries over.
+type testRegistry struct {
+	*httptest.Server
+	// manifests are the manifest contents by repository and tag.
+	manifests map[string]string
+	// blobs are the blob contents by digest, in every repository.
+	blobs map[string]string
+	// requireToken makes the registry challenge requests without a token.
+	requireToken bool
+	// omitDigest leaves the Docker-Content-Digest header out of responses.
+	omitDigest bool
+	// credentials are required to get a token, when set.
+	credentials *Credentials
+}
+
+func newTestRegistry(t *testing.T, manifests map[string]string) *testRegistry {
+	t.Helper()
+	r := &testRegistry{manifests: manifests}
+	r.Server = httptest.NewServer(http.HandlerFunc(r.serve))
+	t.Cleanup(r.Close)
+	return r
+}
+
+// host returns the registry's host, e.g. for use in image references.
+func (r *testRegistry) host() string {
+	return strings.TrimPrefix(r.URL, "http://")
+}
+
+func (r *testRegistry) serve(w http.ResponseWriter, req *http.Request) {
+	if req.URL.Path == "/token" {
+		if req.URL.Query().Get("scope") == "" {
+			http.Error(w, "missing scope", http.StatusBadRequest)
+			return
+		}
+		if r.credentials != nil {
+			if user, password, ok := req.BasicAuth(); !ok || user != r.credentials.Username || password != r.credentials.Password {
+				http.Error(w, "invalid credentials", http.StatusUnauthorized)
+				return
+			}
+		}
+		fmt.Fprint(w, `{"token":"secret"}`)
+		return
+	}
+	if r.requireToken && req.Header.Get("Authorization") != "Bearer secret" {
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="test"`, r.URL))
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	path := strings.TrimPrefix(req.URL.Path, "/v2/")
+	if path == "" {
+		return
+	}
+	if _, digest, ok := strings.Cut(path, "/blobs/"); ok {
+		blob, ok := r.blobs[digest]
+		if !ok {
+			http.NotFound(w, req)
+			return
+		}
+		fmt.Fprint(w, blob)
+		return
+	}
+	repo, ref, ok := strings.Cut(path, "/manifests/")
+	if !ok {
+		http.NotFound(w, req)
+		return
+	}
+	for key, manifest := range r.manifests {
+		name, tag, _ := strings.Cut(key, ":")
+		digest := sha256Digest([]byte(manifest))
+		if name != repo || (ref != tag && ref != digest) {
+			continue
+		}
+		if !r.omitDigest {
+			w.Header().Set("Docker-Content-Digest", digest)
+		}
+		w.Header().Set("Content-Type", mediaType(manifest))
+		if req.Method == http.MethodGet {
+			fmt.Fprint(w, manifest)
+		}
+		return
+	}
+	http.NotFound(w, req)
+}
+
+// mediaType returns the media type the manifest declares, defaulting to an
+// OCI image manifest.
+func mediaType(manifest string) string {
+	var m struct {
+		MediaType string `json:"mediaType"`
+	}
+	if json.Unmarshal([]byte(manifest), &m) != nil || m.MediaType == "" {
+		return "application/vnd.oci.image.manifest.v1+json"
+	}
+	return m.MediaType
+}
+
+func sha256Digest(b []byte) string {
+	sum := sha256.Sum256(b)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+func TestRegistryResolve(t *testing.T) {
+	const manifest = `{"schemaVersion":2}`
+	tests := []struct {
+		name         string
+		image        string
+		requireToken bool
+		omitDigest   bool
+		credentials  *Credentials
+		authenticate bool
+		wantNotFound bool
+		wantErr      bool
+	}{
+		{name: "Tag", image: "example/foo-bundle:v1.0.0"},
+		{name: "Digest", image: "example/foo-bundle@" + sha256Digest([]byte(manifest))},
+		{name: "TokenChallenge", image: "example/foo-bundle:v1.0.0", requireToken: true},
+		{
+			name:         "TokenWithCredentials",
+			image:        "example/foo-bundle:v1.0.0",
+			requireToken: true,
+			credentials:  &Credentials{Username: "user", Password: "secret"},
+			authenticate: true,
+		},
+		{
+			name:         "MissingCredentials",
+			image:        "example/foo-bundle:v1.0.0",
+			requireToken: true,
+			credentials:  &Credentials{Username: "user", Password: "secret"},
+			wantErr:      true,
+		},
+		{name: "ComputedDigest", image: "example/foo-bundle:v1.0.0", omitDigest: true},
+		{name: "MissingTag", image: "example/foo-bundle:v2.0.0", wantNotFound: true},
+		{name: "MissingRepository", image: "example/bar-bundle:v1.0.0", wantNotFound: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registry := newTestRegistry(t, map[string]string{"example/foo-bundle:v1.0.0": manifest})
+			registry.requireToken = tt.requireToken
+			registry.omitDigest = tt.omitDigest
+			registry.credentials = tt.credentials
+
+			ref, err := ParseReference(registry.host() + "/" + tt.image)
+			if err != nil {
+				t.Fatalf("ParseReference() unexpected error: %v", err)
+			}
+			keychain := Keychain{}
+			if tt.authenticate {
+				keychain[registry.host()] = *tt.credentials
+			}
+			got, err := NewRegistry(&http.Client{}).WithKeychain(keychain).Resolve(context.Background(), ref)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Resolve() unexpectedly succeeded")
+				}
+				return
+			}
+			if tt.wantNotFound {
+				if !errors.Is(err, ErrManifestNotFound) {
+					t.Errorf("Resolve() error = %v, want %v", err, ErrManifestNotFound)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Resolve() unexpected error: %v", err)
+			}
+			if want := sha256Digest([]byte(manifest)); got != want {
+				t.Errorf("Resolve() = %s, want %s", got, want)
+			}
+		})
+	}
+}
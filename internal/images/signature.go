@@ -0,0 +1,211 @@
+package images
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/sigstore/cosign/v2/pkg/cosign"
+	ociremote "github.com/sigstore/cosign/v2/pkg/oci/remote"
+	"github.com/sigstore/sigstore/pkg/signature"
+)
+
+const (
+	// ModeEnforce refuses to install bundle images that aren't signed by
+	// one of the configured keys.
+	ModeEnforce = "Enforce"
+	// ModeWarn reports bundle images that aren't signed by one of the
+	// configured keys, but installs them anyway.
+	ModeWarn = "Warn"
+)
+
+var (
+	// ErrNotSigned is returned when an image has no signatures.
+	ErrNotSigned = errors.New("no signatures found")
+)
+
+// Verifier verifies the cosign signatures of images against a set of
+// trusted public keys. Signatures are looked up in the image's repository,
+// under the sha256-<digest>.sig tag cosign pushes them to, and the
+// transparency log isn't consulted, so verification works offline against a
+// mirror registry.
+type Verifier struct {
+	registry  *Registry
+	verifiers []signature.Verifier
+	mode      string
+}
+
+// NewVerifier returns a Verifier that trusts signatures made with any of the
+// keys, and applies the mode to images that fail verification.
+func NewVerifier(registry *Registry, keys []crypto.PublicKey, mode string) (*Verifier, error) {
+	if mode != ModeEnforce && mode != ModeWarn {
+		return nil, fmt.Errorf("unknown signature verification mode %q, expected %q or %q", mode, ModeEnforce, ModeWarn)
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("at least one public key is required to verify signatures")
+	}
+	verifiers := make([]signature.Verifier, 0, len(keys))
+	for _, key := range keys {
+		verifier, err := signature.LoadVerifier(key, crypto.SHA256)
+		if err != nil {
+			return nil, err
+		}
+		verifiers = append(verifiers, verifier)
+	}
+	return &Verifier{
+		registry:  registry,
+		verifiers: verifiers,
+		mode:      mode,
+	}, nil
+}
+
+// Enforced returns whether images that fail verification must not be
+// installed.
+func (v *Verifier) Enforced() bool {
+	return v.mode == ModeEnforce
+}
+
+// Verify checks that the image is signed by one of the trusted keys. It
+// returns the digest the image resolved to, which is the digest that was
+// checked, along with any verification error; the digest is only empty when
+// the image couldn't be resolved. The registry is authenticated with the
+// credentials in the keychain. The digest is resolved, and the signatures are
+// looked up, through the image's mirrors when the registry follows the
+// cluster's image mirroring policies, in which case the signatures of the
+// first repository that has any are verified.
+func (v *Verifier) Verify(ctx context.Context, image string, keychain Keychain) (string, error) {
+	ref, err := ParseReference(image)
+	if err != nil {
+		return "", err
+	}
+	registry := v.registry.WithKeychain(keychain)
+	digest := ref.Digest
+	if digest == "" {
+		sources, err := registry.sources(ctx, ref)
+		if err != nil {
+			return "", err
+		}
+		var errs []error
+		for _, source := range sources {
+			d, err := registry.Resolve(ctx, source)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			digest = d
+			break
+		}
+		if digest == "" {
+			return "", sourcesError(errs)
+		}
+	}
+
+	sources, err := registry.sources(ctx, ref.WithDigest(digest))
+	if err != nil {
+		return digest, err
+	}
+	var errs []error
+	for _, source := range sources {
+		found, err := v.verify(ctx, registry, source)
+		if found {
+			return digest, err
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) != 0 {
+		return digest, fmt.Errorf("failed to get the signatures of %s: %w", ref.WithDigest(digest), sourcesError(errs))
+	}
+	return digest, fmt.Errorf("%s: %w", ref.WithDigest(digest), ErrNotSigned)
+}
+
+// verify checks the signatures of the ref image, which is pinned to a digest,
+// and returns whether its repository has any.
+func (v *Verifier) verify(ctx context.Context, registry *Registry, ref Reference) (bool, error) {
+	ctx, cancel := registry.withTimeout(ctx)
+	defer cancel()
+	n, err := name.NewDigest(ref.String(), name.StrictValidation)
+	if err != nil {
+		return false, err
+	}
+	hash, err := v1.NewHash(ref.Digest)
+	if err != nil {
+		return false, err
+	}
+	opts := []ociremote.Option{ociremote.WithRemoteOptions(registry.options(ctx)...)}
+	sigTag, err := ociremote.SignatureTag(n, opts...)
+	if err != nil {
+		return false, err
+	}
+	sigs, err := ociremote.Signatures(sigTag, opts...)
+	if err != nil {
+		return false, err
+	}
+	list, err := sigs.Get()
+	if err != nil {
+		return false, err
+	}
+	if len(list) == 0 {
+		return false, nil
+	}
+
+	var errs []string
+	for _, sig := range list {
+		for _, verifier := range v.verifiers {
+			_, err := cosign.VerifyImageSignature(ctx, sig, hash, &cosign.CheckOpts{
+				SigVerifier:   verifier,
+				ClaimVerifier: cosign.SimpleClaimVerifier,
+				IgnoreTlog:    true,
+			})
+			if err == nil {
+				return true, nil
+			}
+			errs = append(errs, err.Error())
+		}
+	}
+	return true, fmt.Errorf("none of the signatures of %s could be verified: %s", ref, strings.Join(errs, ", "))
+}
+
+// LoadPublicKeys reads the PEM encoded public keys in the files. ECDSA, RSA
+// and Ed25519 keys are supported, which covers the keys cosign generates.
+func LoadPublicKeys(paths ...string) ([]crypto.PublicKey, error) {
+	var keys []crypto.PublicKey
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var found bool
+		for block, rest := pem.Decode(data); block != nil; block, rest = pem.Decode(rest) {
+			if block.Type != "PUBLIC KEY" {
+				continue
+			}
+			key, err := x509.ParsePKIXPublicKey(block.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse a public key in %s: %w", path, err)
+			}
+			switch key.(type) {
+			case *ecdsa.PublicKey, *rsa.PublicKey, ed25519.PublicKey:
+			default:
+				return nil, fmt.Errorf("unsupported %T public key in %s", key, path)
+			}
+			keys = append(keys, key)
+			found = true
+		}
+		if !found {
+			return nil, fmt.Errorf("no public keys found in %s", path)
+		}
+	}
+	return keys, nil
+}
@@ -0,0 +1,214 @@
+package images
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	configv1 "github.com/openshift/api/config/v1"
+)
+
+const (
+	// simpleSigningMediaType is the media type of the layers of a cosign
+	// signature image, each of which holds a signed payload.
+	simpleSigningMediaType = "application/vnd.dev.cosign.simplesigning.v1+json"
+	// signatureAnnotation is the layer annotation holding the base64
+	// encoded signature of the layer's payload.
+	signatureAnnotation = "dev.cosignproject.cosign/signature"
+)
+
+// signatureManifest returns a cosign signature manifest for the image with
+// the digest, signed with the key, and the payload blob it refers to.
+func signatureManifest(t *testing.T, key *ecdsa.PrivateKey, digest string) (string, string) {
+	t.Helper()
+	payload := fmt.Sprintf(`{"critical":{"identity":{"docker-reference":"example/foo-bundle"},"image":{"docker-manifest-digest":%q},"type":"cosign container image signature"},"optional":null}`, digest)
+	sum := sha256.Sum256([]byte(payload))
+	sig, err := ecdsa.SignASN1(rand.Reader, key, sum[:])
+	if err != nil {
+		t.Fatalf("failed to sign the payload: %v", err)
+	}
+	manifest := fmt.Sprintf(`{"schemaVersion":2,"layers":[{"mediaType":%q,"digest":%q,"size":%d,"annotations":{%q:%q}}]}`,
+		simpleSigningMediaType, sha256Digest([]byte(payload)), len(payload), signatureAnnotation, base64.StdEncoding.EncodeToString(sig))
+	return manifest, payload
+}
+
+func generateKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate a key: %v", err)
+	}
+	return key
+}
+
+func TestVerifierVerify(t *testing.T) {
+	const manifest = `{"schemaVersion":2}`
+	var (
+		trusted   = generateKey(t)
+		untrusted = generateKey(t)
+		digest    = sha256Digest([]byte(manifest))
+		sigTag    = "sha256-" + strings.TrimPrefix(digest, "sha256:") + ".sig"
+	)
+	signed, payload := signatureManifest(t, trusted, digest)
+	signedByUntrusted, untrustedPayload := signatureManifest(t, untrusted, digest)
+	signedOtherDigest, otherPayload := signatureManifest(t, trusted, sha256Digest([]byte("other")))
+
+	tests := []struct {
+		name      string
+		manifests map[string]string
+		blobs     map[string]string
+		image     string
+		wantErr   string
+	}{
+		{
+			name:      "Signed",
+			manifests: map[string]string{"example/foo-bundle:v1.0.0": manifest, "example/foo-bundle:" + sigTag: signed},
+			blobs:     map[string]string{sha256Digest([]byte(payload)): payload},
+			image:     "example/foo-bundle@" + digest,
+		},
+		{
+			name:      "SignedTag",
+			manifests: map[string]string{"example/foo-bundle:v1.0.0": manifest, "example/foo-bundle:" + sigTag: signed},
+			blobs:     map[string]string{sha256Digest([]byte(payload)): payload},
+			image:     "example/foo-bundle:v1.0.0",
+		},
+		{
+			name:      "Unsigned",
+			manifests: map[string]string{"example/foo-bundle:v1.0.0": manifest},
+			image:     "example/foo-bundle@" + digest,
+			wantErr:   ErrNotSigned.Error(),
+		},
+		{
+			name:      "UntrustedKey",
+			manifests: map[string]string{"example/foo-bundle:v1.0.0": manifest, "example/foo-bundle:" + sigTag: signedByUntrusted},
+			blobs:     map[string]string{sha256Digest([]byte(untrustedPayload)): untrustedPayload},
+			image:     "example/foo-bundle@" + digest,
+			wantErr:   "invalid signature",
+		},
+		{
+			name:      "OtherDigest",
+			manifests: map[string]string{"example/foo-bundle:v1.0.0": manifest, "example/foo-bundle:" + sigTag: signedOtherDigest},
+			blobs:     map[string]string{sha256Digest([]byte(otherPayload)): otherPayload},
+			image:     "example/foo-bundle@" + digest,
+			wantErr:   "invalid or missing digest in claim: " + sha256Digest([]byte("other")),
+		},
+		{
+			name:      "MissingPayload",
+			manifests: map[string]string{"example/foo-bundle:v1.0.0": manifest, "example/foo-bundle:" + sigTag: signed},
+			image:     "example/foo-bundle@" + digest,
+			wantErr:   "404 Not Found",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registry := newTestRegistry(t, tt.manifests)
+			registry.blobs = tt.blobs
+			verifier, err := NewVerifier(NewRegistry(&http.Client{}), []crypto.PublicKey{&trusted.PublicKey}, ModeEnforce)
+			if err != nil {
+				t.Fatalf("NewVerifier() unexpected error: %v", err)
+			}
+			got, err := verifier.Verify(context.Background(), registry.host()+"/"+tt.image, nil)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Verify() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Verify() unexpected error: %v", err)
+			}
+			if got != digest {
+				t.Errorf("Verify() = %s, want %s", got, digest)
+			}
+		})
+	}
+}
+
+func TestVerifierVerifyMirrors(t *testing.T) {
+	const manifest = `{"schemaVersion":2}`
+	var (
+		key    = generateKey(t)
+		digest = sha256Digest([]byte(manifest))
+		sigTag = "sha256-" + strings.TrimPrefix(digest, "sha256:") + ".sig"
+	)
+	signed, payload := signatureManifest(t, key, digest)
+	source := newTestRegistry(t, map[string]string{"example/foo-bundle:v1.0.0": manifest})
+	mirror := newTestRegistry(t, map[string]string{"mirror/foo-bundle:v1.0.0": manifest, "mirror/foo-bundle:" + sigTag: signed})
+	mirror.blobs = map[string]string{sha256Digest([]byte(payload)): payload}
+
+	registry := NewRegistry(&http.Client{}).WithMirrors(policies{idms: []configv1.ImageDigestMirrors{{
+		Source:  source.host() + "/example/foo-bundle",
+		Mirrors: []configv1.ImageMirror{configv1.ImageMirror(mirror.host() + "/mirror/foo-bundle")},
+	}}})
+	verifier, err := NewVerifier(registry, []crypto.PublicKey{&key.PublicKey}, ModeEnforce)
+	if err != nil {
+		t.Fatalf("NewVerifier() unexpected error: %v", err)
+	}
+	// the signatures are only served by the mirror, which the digest the
+	// source's tag resolves to is pulled from.
+	got, err := verifier.Verify(context.Background(), source.host()+"/example/foo-bundle:v1.0.0", nil)
+	if err != nil {
+		t.Fatalf("Verify() unexpected error: %v", err)
+	}
+	if got != digest {
+		t.Errorf("Verify() = %s, want %s", got, digest)
+	}
+}
+
+func TestVerifierUnsignedError(t *testing.T) {
+	registry := newTestRegistry(t, map[string]string{"example/foo-bundle:v1.0.0": `{}`})
+	verifier, err := NewVerifier(NewRegistry(&http.Client{}), []crypto.PublicKey{&generateKey(t).PublicKey}, ModeWarn)
+	if err != nil {
+		t.Fatalf("NewVerifier() unexpected error: %v", err)
+	}
+	if verifier.Enforced() {
+		t.Errorf("Enforced() = true, want false")
+	}
+	digest, err := verifier.Verify(context.Background(), registry.host()+"/example/foo-bundle:v1.0.0", nil)
+	if !errors.Is(err, ErrNotSigned) {
+		t.Errorf("Verify() error = %v, want %v", err, ErrNotSigned)
+	}
+	if want := sha256Digest([]byte(`{}`)); digest != want {
+		t.Errorf("Verify() = %q, want the checked %s digest", digest, want)
+	}
+}
+
+func TestLoadPublicKeys(t *testing.T) {
+	der, err := x509.MarshalPKIXPublicKey(&generateKey(t).PublicKey)
+	if err != nil {
+		t.Fatalf("failed to marshal the public key: %v", err)
+	}
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "cosign.pub")
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	emptyFile := filepath.Join(dir, "empty.pub")
+	if err := os.WriteFile(emptyFile, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := LoadPublicKeys(keyFile)
+	if err != nil {
+		t.Fatalf("LoadPublicKeys() unexpected error: %v", err)
+	}
+	if len(keys) != 1 {
+		t.Errorf("LoadPublicKeys() returned %d keys, want 1", len(keys))
+	}
+	if _, err := LoadPublicKeys(keyFile, emptyFile); err == nil {
+		t.Errorf("LoadPublicKeys() with a file without keys unexpectedly succeeded")
+	}
+}
@@ -0,0 +1,134 @@
+package images
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	configv1 "github.com/openshift/api/config/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	clusterImageConfigName = "cluster"
+	// trustedCANamespace is the namespace of the ConfigMap that the cluster
+	// image config's additionalTrustedCA references.
+	trustedCANamespace = "openshift-config"
+	// trustedCARefresh is how long the cluster's registry CAs are used before
+	// they're read again.
+	trustedCARefresh = time.Minute
+)
+
+//+kubebuilder:rbac:groups=config.openshift.io,resources=images,verbs=get
+//+kubebuilder:rbac:groups="",namespace=openshift-config,resources=configmaps,verbs=get
+
+// TrustedTransport is an http.RoundTripper that verifies each registry's
+// serving certificate against the system CAs, and the CAs the cluster image
+// config's additionalTrustedCA adds for that registry.
+type TrustedTransport struct {
+	reader client.Reader
+	base   *http.Transport
+	roots  *x509.CertPool
+
+	mu         sync.Mutex
+	loaded     time.Time
+	cas        map[string]string
+	transports map[string]*http.Transport
+}
+
+// NewTrustedTransport returns a TrustedTransport that reads the cluster image
+// config through reader, and that sends requests through clones of base that
+// trust the roots CAs. Only the roots CAs are trusted when reader is nil.
+func NewTrustedTransport(reader client.Reader, base *http.Transport, roots *x509.CertPool) *TrustedTransport {
+	return &TrustedTransport{
+		reader:     reader,
+		base:       base,
+		roots:      roots,
+		transports: make(map[string]*http.Transport),
+	}
+}
+
+func (t *TrustedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	transport, err := t.transportFor(req.Context(), req.URL.Host)
+	if err != nil {
+		return nil, err
+	}
+	return transport.RoundTrip(req)
+}
+
+// transportFor returns the transport that trusts the CAs of the registry
+// served at host, which are keyed by hostname, or by hostname..port for
+// registries on a non-default port, in the additionalTrustedCA ConfigMap.
+func (t *TrustedTransport) transportFor(ctx context.Context, host string) (*http.Transport, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if time.Since(t.loaded) > trustedCARefresh {
+		cas, err := loadTrustedCAs(ctx, t.reader)
+		if err != nil {
+			return nil, err
+		}
+		// the transports of registries whose CAs changed are rebuilt.
+		for h, transport := range t.transports {
+			if cas[h] != t.cas[h] {
+				transport.CloseIdleConnections()
+				delete(t.transports, h)
+			}
+		}
+		t.cas = cas
+		t.loaded = time.Now()
+	}
+
+	key := strings.ReplaceAll(host, ":", "..")
+	if transport, ok := t.transports[key]; ok {
+		return transport, nil
+	}
+	roots := t.roots.Clone()
+	if ca := t.cas[key]; ca != "" && !roots.AppendCertsFromPEM([]byte(ca)) {
+		return nil, fmt.Errorf("the additional trusted CA of the %s registry doesn't contain any PEM encoded certificates", host)
+	}
+	transport := t.base.Clone()
+	transport.TLSClientConfig = &tls.Config{
+		RootCAs:    roots,
+		MinVersion: tls.VersionTLS12,
+	}
+	t.transports[key] = transport
+	return transport, nil
+}
+
+// loadTrustedCAs returns the PEM encoded CAs of the ConfigMap that the
+// cluster image config's additionalTrustedCA references, keyed by registry.
+// Clusters that don't serve the image config API, or don't reference a
+// ConfigMap, have no additional CAs.
+func loadTrustedCAs(ctx context.Context, c client.Reader) (map[string]string, error) {
+	if c == nil {
+		return nil, nil
+	}
+	image := &configv1.Image{}
+	if err := c.Get(ctx, client.ObjectKey{Name: clusterImageConfigName}, image); err != nil {
+		if apierrors.IsNotFound(err) || meta.IsNoMatchError(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get the cluster image config: %w", err)
+	}
+	name := image.Spec.AdditionalTrustedCA.Name
+	if name == "" {
+		return nil, nil
+	}
+	cm := &corev1.ConfigMap{}
+	if err := c.Get(ctx, client.ObjectKey{Namespace: trustedCANamespace, Name: name}, cm); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get the %s/%s additional trusted CA ConfigMap: %w", trustedCANamespace, name, err)
+	}
+	return cm.Data, nil
+}
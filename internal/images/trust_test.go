@@ -0,0 +1,96 @@
+package images
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	configv1 "github.com/openshift/api/config/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// imageConfig is a client.Reader serving the cluster image config, which
+// references the cas ConfigMap when it's set.
+type imageConfig struct {
+	client.Reader
+	cas map[string]string
+}
+
+func (c imageConfig) Get(_ context.Context, key client.ObjectKey, obj client.Object, _ ...client.GetOption) error {
+	switch o := obj.(type) {
+	case *configv1.Image:
+		if c.cas != nil {
+			o.Spec.AdditionalTrustedCA.Name = "registry-cas"
+		}
+		return nil
+	case *corev1.ConfigMap:
+		if key.Namespace != trustedCANamespace || key.Name != "registry-cas" {
+			return apierrors.NewNotFound(schema.GroupResource{Resource: "configmaps"}, key.Name)
+		}
+		o.Data = c.cas
+		return nil
+	}
+	return apierrors.NewNotFound(schema.GroupResource{}, key.Name)
+}
+
+func TestTrustedTransport(t *testing.T) {
+	registry := httptest.NewTLSServer(http.NotFoundHandler())
+	t.Cleanup(registry.Close)
+	ca := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: registry.Certificate().Raw}))
+	key := strings.ReplaceAll(strings.TrimPrefix(registry.URL, "https://"), ":", "..")
+
+	tests := []struct {
+		name    string
+		reader  client.Reader
+		wantErr string
+	}{
+		{
+			name:    "NoReader",
+			wantErr: "certificate",
+		},
+		{
+			name:    "NoAdditionalTrustedCA",
+			reader:  imageConfig{},
+			wantErr: "certificate",
+		},
+		{
+			name:    "OtherRegistryCA",
+			reader:  imageConfig{cas: map[string]string{"quay.io": ca}},
+			wantErr: "certificate",
+		},
+		{
+			name:   "RegistryCA",
+			reader: imageConfig{cas: map[string]string{key: ca}},
+		},
+		{
+			name:    "InvalidRegistryCA",
+			reader:  imageConfig{cas: map[string]string{key: "not a certificate"}},
+			wantErr: "doesn't contain any PEM encoded certificates",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &http.Client{Transport: NewTrustedTransport(tt.reader, http.DefaultTransport.(*http.Transport), x509.NewCertPool())}
+			resp, err := c.Get(registry.URL + "/v2/")
+			if err == nil {
+				resp.Body.Close()
+			}
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Get() unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Get() error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package images
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+const (
+	whiteoutPrefix = ".wh."
+	// opaqueWhiteout hides every file of the lower layers in its directory.
+	opaqueWhiteout = whiteoutPrefix + whiteoutPrefix + ".opq"
+)
+
+// Unpack returns the regular files under the dir directory of the image's
+// filesystem, e.g. the manifests directory of a bundle image, keyed by their
+// path relative to dir. Multi-platform images are unpacked for linux/amd64,
+// or their first platform, which is the same for bundle images. The image is
+// unpacked from the first of its mirrors, or its source, that serves it.
+func (r *Registry) Unpack(ctx context.Context, image, dir string) (map[string][]byte, error) {
+	ref, err := ParseReference(image)
+	if err != nil {
+		return nil, err
+	}
+	sources, err := r.sources(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	var errs []error
+	for _, source := range sources {
+		files, err := r.unpack(ctx, source, dir)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		return files, nil
+	}
+	return nil, sourcesError(errs)
+}
+
+// unpack returns the regular files under the dir directory of the ref image.
+func (r *Registry) unpack(ctx context.Context, ref Reference, dir string) (map[string][]byte, error) {
+	image := ref.String()
+	body, _, err := r.Manifest(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	var manifest struct {
+		Manifests []struct {
+			Digest   string `json:"digest"`
+			Platform struct {
+				OS           string `json:"os"`
+				Architecture string `json:"architecture"`
+			} `json:"platform"`
+		} `json:"manifests"`
+		Layers []struct {
+			MediaType string `json:"mediaType"`
+			Digest    string `json:"digest"`
+		} `json:"layers"`
+	}
+	if err := json.Unmarshal(body, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to decode the manifest of %s: %w", image, err)
+	}
+	if len(manifest.Manifests) != 0 {
+		digest := manifest.Manifests[0].Digest
+		for _, m := range manifest.Manifests {
+			if m.Platform.OS == "linux" && m.Platform.Architecture == "amd64" {
+				digest = m.Digest
+				break
+			}
+		}
+		return r.unpack(ctx, ref.WithDigest(digest), dir)
+	}
+
+	prefix := strings.TrimSuffix(path.Clean("/"+dir), "/") + "/"
+	files := make(map[string][]byte)
+	for _, layer := range manifest.Layers {
+		blob, err := r.blob(ctx, ref, layer.Digest, maxLayerSize)
+		if err != nil {
+			return nil, err
+		}
+		if err := extract(files, blob, prefix); err != nil {
+			return nil, fmt.Errorf("failed to unpack the %s layer of %s: %w", layer.Digest, image, err)
+		}
+	}
+	return files, nil
+}
+
+// extract adds the regular files under the prefix directory in the layer to
+// files, applying the layer's whiteouts to the files of the layers below it.
+func extract(files map[string][]byte, layer []byte, prefix string) error {
+	var r io.Reader = bytes.NewReader(layer)
+	if gz, err := gzip.NewReader(bytes.NewReader(layer)); err == nil {
+		defer gz.Close()
+		r = gz
+	}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		name := path.Clean("/" + hdr.Name)
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		rel := strings.TrimPrefix(name, prefix)
+		if base := path.Base(rel); strings.HasPrefix(base, whiteoutPrefix) {
+			deleted := path.Join(path.Dir(rel), strings.TrimPrefix(base, whiteoutPrefix))
+			if base == opaqueWhiteout {
+				deleted = path.Dir(rel)
+			}
+			for file := range files {
+				if deleted == "." || file == deleted || strings.HasPrefix(file, deleted+"/") {
+					delete(files, file)
+				}
+			}
+			continue
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		content, err := io.ReadAll(io.LimitReader(tr, maxBodySize+1))
+		if err != nil {
+			return err
+		}
+		if len(content) > maxBodySize {
+			return fmt.Errorf("%s is larger than %d bytes", name, maxBodySize)
+		}
+		files[rel] = content
+	}
+}
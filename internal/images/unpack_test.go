@@ -0,0 +1,108 @@
+package images
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+
+	configv1 "github.com/openshift/api/config/v1"
+)
+
+// layer returns a gzipped tar layer with the files, where a nil content
+// adds a directory.
+func layer(t *testing.T, files map[string][]byte) string {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		hdr := &tar.Header{Name: name, Mode: 0o644, Size: int64(len(content)), Typeflag: tar.TypeReg}
+		if content == nil {
+			hdr.Typeflag = tar.TypeDir
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestRegistryUnpack(t *testing.T) {
+	base := layer(t, map[string][]byte{
+		"manifests/":                  nil,
+		"manifests/foo.crd.yaml":      []byte("kind: CustomResourceDefinition"),
+		"manifests/foo.csv.yaml":      []byte("kind: ClusterServiceVersion"),
+		"manifests/removed.yaml":      []byte("kind: ConfigMap"),
+		"metadata/annotations.yaml":   []byte("annotations: {}"),
+		"./manifests/nested/bar.yaml": []byte("kind: Service"),
+	})
+	upper := layer(t, map[string][]byte{
+		"manifests/.wh.removed.yaml": {},
+		"manifests/foo.csv.yaml":     []byte("kind: ClusterServiceVersion # patched"),
+	})
+	image := fmt.Sprintf(`{"schemaVersion":2,"layers":[{"mediaType":"application/vnd.oci.image.layer.v1.tar+gzip","digest":%q,"size":%d},{"mediaType":"application/vnd.oci.image.layer.v1.tar+gzip","digest":%q,"size":%d}]}`,
+		sha256Digest([]byte(base)), len(base), sha256Digest([]byte(upper)), len(upper))
+	index := fmt.Sprintf(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.index.v1+json","manifests":[{"digest":"sha256:arm64","platform":{"os":"linux","architecture":"arm64"}},{"digest":%q,"platform":{"os":"linux","architecture":"amd64"}}]}`,
+		sha256Digest([]byte(image)))
+
+	registry := newTestRegistry(t, map[string]string{
+		"example/foo-bundle:v1.0.0": image,
+		"example/foo-bundle:multi":  index,
+	})
+	registry.blobs = map[string]string{
+		sha256Digest([]byte(base)):  base,
+		sha256Digest([]byte(upper)): upper,
+	}
+	want := map[string][]byte{
+		"foo.crd.yaml":    []byte("kind: CustomResourceDefinition"),
+		"foo.csv.yaml":    []byte("kind: ClusterServiceVersion # patched"),
+		"nested/bar.yaml": []byte("kind: Service"),
+	}
+	for _, tag := range []string{"v1.0.0", "multi"} {
+		t.Run(tag, func(t *testing.T) {
+			got, err := NewRegistry(&http.Client{}).Unpack(context.Background(), registry.host()+"/example/foo-bundle:"+tag, "manifests")
+			if err != nil {
+				t.Fatalf("Unpack() unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Unpack() = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestRegistryUnpackMirrors(t *testing.T) {
+	content := layer(t, map[string][]byte{"manifests/foo.csv.yaml": []byte("kind: ClusterServiceVersion")})
+	image := fmt.Sprintf(`{"schemaVersion":2,"layers":[{"mediaType":"application/vnd.oci.image.layer.v1.tar+gzip","digest":%q,"size":%d}]}`, sha256Digest([]byte(content)), len(content))
+	var (
+		source = newTestRegistry(t, nil)
+		mirror = newTestRegistry(t, map[string]string{"mirror/foo-bundle:v1.0.0": image})
+	)
+	mirror.blobs = map[string]string{sha256Digest([]byte(content)): content}
+
+	registry := NewRegistry(&http.Client{}).WithMirrors(policies{idms: []configv1.ImageDigestMirrors{{
+		Source:  source.host() + "/example/foo-bundle",
+		Mirrors: []configv1.ImageMirror{configv1.ImageMirror(mirror.host() + "/mirror/foo-bundle")},
+	}}})
+	got, err := registry.Unpack(context.Background(), source.host()+"/example/foo-bundle@"+sha256Digest([]byte(image)), "manifests")
+	if err != nil {
+		t.Fatalf("Unpack() unexpected error: %v", err)
+	}
+	if want := map[string][]byte{"foo.csv.yaml": []byte("kind: ClusterServiceVersion")}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Unpack() = %q, want %q", got, want)
+	}
+}
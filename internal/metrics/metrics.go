@@ -0,0 +1,44 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const (
+	namespace = "platform_operators"
+)
+
+var (
+	// CatalogConnectionsOpen tracks the number of registry gRPC connections
+	// that are currently held open by the catalog connection manager.
+	CatalogConnectionsOpen = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "catalog_connections_open",
+		Help:      "Number of open gRPC connections to catalog registry servers.",
+	})
+
+	// CatalogConnectionFailures counts the number of failed attempts to dial,
+	// or call, a catalog registry server.
+	CatalogConnectionFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "catalog_connection_failures_total",
+		Help:      "Number of failed dials or calls to catalog registry servers.",
+	}, []string{"catalog"})
+
+	// PendingUpdates tracks the number of bundles each PlatformOperator's
+	// installed bundle can be upgraded to.
+	PendingUpdates = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "pending_updates",
+		Help:      "Number of bundles a platform operator's installed bundle can be upgraded to.",
+	}, []string{"platformoperator"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(
+		CatalogConnectionsOpen,
+		CatalogConnectionFailures,
+		PendingUpdates,
+	)
+}
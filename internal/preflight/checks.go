@@ -0,0 +1,376 @@
+package preflight
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	operatorsv1alpha1 "github.com/operator-framework/api/pkg/operators/v1alpha1"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/openshift/platform-operators/internal/applier"
+)
+
+// ownerNameLabels are the labels rukpak and operator-controller set on the
+// objects they install to the name of the installation that manages them.
+var ownerNameLabels = []string{
+	applier.BundleDeploymentOwnerLabel,
+	applier.ClusterExtensionOwnerLabel,
+}
+
+// APIServiceGVK is the GroupVersionKind of aggregated APIServices.
+var APIServiceGVK = schema.GroupVersionKind{Group: "apiregistration.k8s.io", Version: "v1", Kind: "APIService"}
+
+// Owner identifies the installation the checked bundle is installed by.
+type Owner struct {
+	// Name is the name of the installation object, e.g. BundleDeployment.
+	Name string
+	// AllowUnmanaged allows the bundle to take over objects that no
+	// installation manages, e.g. those of an OLM Subscription that's being
+	// adopted.
+	AllowUnmanaged bool
+}
+
+// conflict returns a description of the installation that manages the obj
+// object, or an empty string when the owner can manage obj.
+func (o Owner) conflict(obj metav1.Object) string {
+	for _, label := range ownerNameLabels {
+		name, ok := obj.GetLabels()[label]
+		if !ok {
+			continue
+		}
+		if name == o.Name {
+			return ""
+		}
+		return fmt.Sprintf("the %s installation", name)
+	}
+	if o.AllowUnmanaged {
+		return ""
+	}
+	return "an installer other than platform operators"
+}
+
+// installation returns a description of the installation other than the
+// owner that manages the obj object, or an empty string when obj isn't
+// managed by another installation.
+func (o Owner) installation(obj metav1.Object) string {
+	for _, label := range ownerNameLabels {
+		if name, ok := obj.GetLabels()[label]; ok && name != o.Name {
+			return fmt.Sprintf("the %s installation", name)
+		}
+	}
+	return ""
+}
+
+// CRDOwnership returns a Check that fails bundles with CustomResourceDefinitions
+// that already exist, and are managed by another installation.
+func CRDOwnership(c client.Reader, owner Owner) Check {
+	return Check{
+		Name: CheckCRDOwnership,
+		Run: func(ctx context.Context, b *Bundle) ([]string, error) {
+			crds, err := b.CRDs()
+			if err != nil {
+				return nil, err
+			}
+			var reasons []string
+			for _, crd := range crds {
+				existing := &apiextensionsv1.CustomResourceDefinition{}
+				if err := c.Get(ctx, client.ObjectKey{Name: crd.GetName()}, existing); err != nil {
+					if apierrors.IsNotFound(err) {
+						continue
+					}
+					return nil, err
+				}
+				if by := owner.conflict(existing); by != "" {
+					reasons = append(reasons, fmt.Sprintf("the %s CustomResourceDefinition is already managed by %s", crd.GetName(), by))
+				}
+			}
+			return reasons, nil
+		},
+	}
+}
+
+// APIServiceConflicts returns a Check that fails bundles whose APIs are
+// already served, either by aggregated APIServices another installation
+// manages, or for CustomResourceDefinitions, by any aggregated APIService.
+func APIServiceConflicts(c client.Reader, owner Owner) Check {
+	return Check{
+		Name: CheckAPIServiceConflict,
+		Run: func(ctx context.Context, b *Bundle) ([]string, error) {
+			csv, err := b.CSV()
+			if err != nil {
+				return nil, err
+			}
+			var names []string
+			if csv != nil {
+				for _, desc := range csv.Spec.APIServiceDefinitions.Owned {
+					names = append(names, desc.Version+"."+desc.Group)
+				}
+			}
+			for _, obj := range b.objects(APIServiceGVK.Group, APIServiceGVK.Kind) {
+				names = append(names, obj.GetName())
+			}
+
+			var reasons []string
+			for _, name := range names {
+				existing, err := getAPIService(ctx, c, name)
+				if err != nil {
+					return nil, err
+				}
+				if existing == nil {
+					continue
+				}
+				if by := owner.conflict(existing); by != "" {
+					reasons = append(reasons, fmt.Sprintf("the %s APIService is already managed by %s", name, by))
+				}
+			}
+
+			crds, err := b.CRDs()
+			if err != nil {
+				return nil, err
+			}
+			for _, crd := range crds {
+				for _, version := range crd.Spec.Versions {
+					name := version.Name + "." + crd.Spec.Group
+					existing, err := getAPIService(ctx, c, name)
+					if err != nil {
+						return nil, err
+					}
+					// every CRD version has a local APIService, whereas
+					// aggregated APIServices are backed by a service.
+					if existing == nil {
+						continue
+					}
+					if _, aggregated, _ := unstructured.NestedMap(existing.Object, "spec", "service"); aggregated {
+						reasons = append(reasons, fmt.Sprintf("the %s API of the %s CustomResourceDefinition is already served by the %s aggregated APIService", version.Name, crd.GetName(), name))
+					}
+				}
+			}
+			return reasons, nil
+		},
+	}
+}
+
+func getAPIService(ctx context.Context, c client.Reader, name string) (*unstructured.Unstructured, error) {
+	apiService := &unstructured.Unstructured{}
+	apiService.SetGroupVersionKind(APIServiceGVK)
+	if err := c.Get(ctx, client.ObjectKey{Name: name}, apiService); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return apiService, nil
+}
+
+// ClusterPermissions returns a Check that fails bundles that request cluster
+// permissions, through their ClusterServiceVersion or ClusterRoles, that the
+// policy denies.
+func ClusterPermissions(policy PermissionPolicy) Check {
+	return Check{
+		Name: CheckClusterPermissions,
+		Run: func(_ context.Context, b *Bundle) ([]string, error) {
+			csv, err := b.CSV()
+			if err != nil {
+				return nil, err
+			}
+			type requested struct {
+				by    string
+				rules []rbacv1.PolicyRule
+			}
+			var permissions []requested
+			if csv != nil {
+				for _, p := range csv.Spec.InstallStrategy.StrategySpec.ClusterPermissions {
+					permissions = append(permissions, requested{by: fmt.Sprintf("the %s service account", p.ServiceAccountName), rules: p.Rules})
+				}
+			}
+			for _, obj := range b.objects(rbacv1.GroupName, "ClusterRole") {
+				role := &rbacv1.ClusterRole{}
+				if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, role); err != nil {
+					return nil, fmt.Errorf("failed to decode the %s ClusterRole: %w", obj.GetName(), err)
+				}
+				permissions = append(permissions, requested{by: fmt.Sprintf("the %s ClusterRole", role.GetName()), rules: role.Rules})
+			}
+
+			var reasons []string
+			for _, p := range permissions {
+				for _, rule := range p.rules {
+					if denied, ok := policy.denies(rule); ok {
+						reasons = append(reasons, fmt.Sprintf("%s requests %s, which overlaps the %s permissions the policy denies", p.by, describeRule(rule), describeRule(denied)))
+					}
+				}
+			}
+			return reasons, nil
+		},
+	}
+}
+
+func describeRule(rule rbacv1.PolicyRule) string {
+	list := func(values []string) string {
+		if len(values) == 0 {
+			return "*"
+		}
+		return strings.Join(values, ",")
+	}
+	return fmt.Sprintf("[%s] on [%s] in the [%s] API groups", list(rule.Verbs), list(rule.Resources), list(rule.APIGroups))
+}
+
+// WebhookOverlaps returns a Check that fails bundles with admission webhooks
+// that intercept the same requests as the webhooks of the same type that
+// another installation manages.
+func WebhookOverlaps(c client.Reader, owner Owner) Check {
+	return Check{
+		Name: CheckWebhookOverlap,
+		Run: func(ctx context.Context, b *Bundle) ([]string, error) {
+			bundleHooks, err := bundleWebhooks(b)
+			if err != nil {
+				return nil, err
+			}
+			if len(bundleHooks) == 0 {
+				return nil, nil
+			}
+			clusterHooks, err := clusterWebhooks(ctx, c, owner)
+			if err != nil {
+				return nil, err
+			}
+
+			var reasons []string
+			for _, bh := range bundleHooks {
+				for _, ch := range clusterHooks {
+					if bh.mutating != ch.mutating || !rulesOverlap(bh.rules, ch.rules) {
+						continue
+					}
+					reasons = append(reasons, fmt.Sprintf("the %s webhook intercepts the same requests as the %s webhook of the %s configuration, managed by %s", bh.name, ch.name, ch.configuration, ch.managedBy))
+				}
+			}
+			return reasons, nil
+		},
+	}
+}
+
+type webhook struct {
+	name          string
+	configuration string
+	managedBy     string
+	mutating      bool
+	rules         []admissionregistrationv1.RuleWithOperations
+}
+
+// bundleWebhooks returns the admission webhooks the b bundle declares in its
+// ClusterServiceVersion or webhook configurations.
+func bundleWebhooks(b *Bundle) ([]webhook, error) {
+	var hooks []webhook
+	csv, err := b.CSV()
+	if err != nil {
+		return nil, err
+	}
+	if csv != nil {
+		for _, desc := range csv.Spec.WebhookDefinitions {
+			switch desc.Type {
+			case operatorsv1alpha1.ValidatingAdmissionWebhook, operatorsv1alpha1.MutatingAdmissionWebhook:
+				hooks = append(hooks, webhook{
+					name:     desc.GenerateName,
+					mutating: desc.Type == operatorsv1alpha1.MutatingAdmissionWebhook,
+					rules:    desc.Rules,
+				})
+			}
+		}
+	}
+	for _, obj := range b.objects(admissionregistrationv1.GroupName, "ValidatingWebhookConfiguration") {
+		config := &admissionregistrationv1.ValidatingWebhookConfiguration{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, config); err != nil {
+			return nil, fmt.Errorf("failed to decode the %s ValidatingWebhookConfiguration: %w", obj.GetName(), err)
+		}
+		for _, w := range config.Webhooks {
+			hooks = append(hooks, webhook{name: w.Name, rules: w.Rules})
+		}
+	}
+	for _, obj := range b.objects(admissionregistrationv1.GroupName, "MutatingWebhookConfiguration") {
+		config := &admissionregistrationv1.MutatingWebhookConfiguration{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, config); err != nil {
+			return nil, fmt.Errorf("failed to decode the %s MutatingWebhookConfiguration: %w", obj.GetName(), err)
+		}
+		for _, w := range config.Webhooks {
+			hooks = append(hooks, webhook{name: w.Name, mutating: true, rules: w.Rules})
+		}
+	}
+	return hooks, nil
+}
+
+// clusterWebhooks returns the admission webhooks on the cluster that are
+// managed by installations other than the owner. The webhooks of the platform
+// itself, which no installation manages, routinely intercept the same requests
+// as those of operators, so they're left out.
+func clusterWebhooks(ctx context.Context, c client.Reader, owner Owner) ([]webhook, error) {
+	var hooks []webhook
+	validating := &admissionregistrationv1.ValidatingWebhookConfigurationList{}
+	if err := c.List(ctx, validating); err != nil {
+		return nil, err
+	}
+	for _, config := range validating.Items {
+		by := owner.installation(&config)
+		if by == "" {
+			continue
+		}
+		for _, w := range config.Webhooks {
+			hooks = append(hooks, webhook{name: w.Name, configuration: config.GetName(), managedBy: by, rules: w.Rules})
+		}
+	}
+	mutating := &admissionregistrationv1.MutatingWebhookConfigurationList{}
+	if err := c.List(ctx, mutating); err != nil {
+		return nil, err
+	}
+	for _, config := range mutating.Items {
+		by := owner.installation(&config)
+		if by == "" {
+			continue
+		}
+		for _, w := range config.Webhooks {
+			hooks = append(hooks, webhook{name: w.Name, configuration: config.GetName(), managedBy: by, mutating: true, rules: w.Rules})
+		}
+	}
+	return hooks, nil
+}
+
+// rulesOverlap returns whether any request matches a rule of both a and b.
+func rulesOverlap(a, b []admissionregistrationv1.RuleWithOperations) bool {
+	for _, ra := range a {
+		for _, rb := range b {
+			ops := func(ops []admissionregistrationv1.OperationType) []string {
+				out := make([]string, 0, len(ops))
+				for _, op := range ops {
+					out = append(out, string(op))
+				}
+				return out
+			}
+			if overlaps(ops(ra.Operations), ops(rb.Operations)) &&
+				overlaps(ra.APIGroups, rb.APIGroups) &&
+				overlaps(ra.APIVersions, rb.APIVersions) &&
+				overlaps(ra.Resources, rb.Resources) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// overlaps returns whether a and b have a value in common, where "*" is
+// every value.
+func overlaps(a, b []string) bool {
+	for _, va := range a {
+		for _, vb := range b {
+			if va == vb || va == "*" || vb == "*" {
+				return true
+			}
+		}
+	}
+	return false
+}
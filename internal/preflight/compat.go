@@ -0,0 +1,184 @@
+package preflight
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	platformtypes "github.com/openshift/platform-operators/api/v1alpha1"
+)
+
+// maxReportedInvalid caps the number of invalid custom resources that are
+// reported for each CustomResourceDefinition version.
+const maxReportedInvalid = 5
+
+// CRDCompatibility returns a Check that fails upgrades from the installed
+// bundle to bundles whose CustomResourceDefinitions would break the existing
+// custom resources, i.e. that drop a version objects are stored in, stop
+// serving a version while custom resources exist, or whose schemas the
+// existing custom resources don't validate against.
+func CRDCompatibility(c client.Reader, installed *Bundle) Check {
+	return Check{
+		Name: CheckCRDCompatibility,
+		Run: func(ctx context.Context, b *Bundle) ([]string, error) {
+			installedCRDs, err := installed.CRDs()
+			if err != nil {
+				return nil, err
+			}
+			targetCRDs, err := b.CRDs()
+			if err != nil {
+				return nil, err
+			}
+			targets := make(map[string]*apiextensionsv1.CustomResourceDefinition, len(targetCRDs))
+			for i := range targetCRDs {
+				targets[targetCRDs[i].GetName()] = &targetCRDs[i]
+			}
+
+			var reasons []string
+			for _, crd := range installedCRDs {
+				existing := &apiextensionsv1.CustomResourceDefinition{}
+				if err := c.Get(ctx, client.ObjectKey{Name: crd.GetName()}, existing); err != nil {
+					if apierrors.IsNotFound(err) {
+						continue
+					}
+					return nil, err
+				}
+				crdReasons, err := checkCRDCompatibility(ctx, c, existing, targets[crd.GetName()])
+				if err != nil {
+					return nil, err
+				}
+				reasons = append(reasons, crdReasons...)
+			}
+			return reasons, nil
+		},
+	}
+}
+
+// checkCRDCompatibility returns a description of each way the target
+// CustomResourceDefinition breaks the custom resources of the existing one.
+// A nil target means the CustomResourceDefinition is removed. The custom
+// resources are only listed when a ClusterRole aggregated to the manager
+// grants it, and the upgrade can't be checked otherwise.
+func checkCRDCompatibility(ctx context.Context, c client.Reader, existing, target *apiextensionsv1.CustomResourceDefinition) ([]string, error) {
+	reasons, err := checkCustomResources(ctx, c, existing, target)
+	if apierrors.IsForbidden(err) {
+		return []string{fmt.Sprintf("the %s custom resources can't be checked, which requires granting list access to them through a ClusterRole with the %s label",
+			existing.GetName(), platformtypes.LabelAggregateToCustomResourceReader)}, nil
+	}
+	return reasons, err
+}
+
+func checkCustomResources(ctx context.Context, c client.Reader, existing, target *apiextensionsv1.CustomResourceDefinition) ([]string, error) {
+	inUse, err := hasCustomResources(ctx, c, existing)
+	if err != nil {
+		return nil, err
+	}
+	if target == nil {
+		if inUse {
+			return []string{fmt.Sprintf("the %s CustomResourceDefinition is removed, although custom resources exist", existing.GetName())}, nil
+		}
+		return nil, nil
+	}
+
+	targetVersions := make(map[string]*apiextensionsv1.CustomResourceDefinitionVersion, len(target.Spec.Versions))
+	for i := range target.Spec.Versions {
+		targetVersions[target.Spec.Versions[i].Name] = &target.Spec.Versions[i]
+	}
+
+	var reasons []string
+	for _, stored := range existing.Status.StoredVersions {
+		if _, ok := targetVersions[stored]; !ok {
+			reasons = append(reasons, fmt.Sprintf("the %s version of the %s CustomResourceDefinition is dropped, although objects are stored in it", stored, existing.GetName()))
+		}
+	}
+	for _, version := range existing.Spec.Versions {
+		if !version.Served {
+			continue
+		}
+		if tv, ok := targetVersions[version.Name]; (!ok || !tv.Served) && inUse {
+			reasons = append(reasons, fmt.Sprintf("the %s version of the %s CustomResourceDefinition is no longer served, although custom resources exist", version.Name, existing.GetName()))
+		}
+	}
+	if !inUse {
+		return reasons, nil
+	}
+
+	// the existing custom resources are validated against each version of
+	// the target's schemas that they can already be read in.
+	for _, version := range existing.Spec.Versions {
+		tv, ok := targetVersions[version.Name]
+		if !version.Served || !ok || !tv.Served || tv.Schema == nil {
+			continue
+		}
+		list, err := listCustomResources(ctx, c, existing, version.Name, 0)
+		if err != nil {
+			return nil, err
+		}
+		var invalid []string
+		for _, cr := range list.Items {
+			errs, err := validateCustomResource(ctx, tv.Schema.OpenAPIV3Schema, cr.Object)
+			if err != nil {
+				return nil, fmt.Errorf("failed to validate custom resources against the %s schema of the target %s CustomResourceDefinition: %w", version.Name, existing.GetName(), err)
+			}
+			if len(errs) == 0 {
+				continue
+			}
+			name := cr.GetName()
+			if cr.GetNamespace() != "" {
+				name = cr.GetNamespace() + "/" + name
+			}
+			invalid = append(invalid, fmt.Sprintf("%s (%s)", name, strings.Join(errs, ", ")))
+		}
+		if len(invalid) == 0 {
+			continue
+		}
+		reported := invalid
+		if len(invalid) > maxReportedInvalid {
+			reported = append(invalid[:maxReportedInvalid:maxReportedInvalid], fmt.Sprintf("and %d more", len(invalid)-maxReportedInvalid))
+		}
+		reasons = append(reasons, fmt.Sprintf("%d %s custom resources fail validation against the %s schema of the target %s CustomResourceDefinition: %s",
+			len(invalid), existing.Spec.Names.Kind, version.Name, existing.GetName(), strings.Join(reported, "; ")))
+	}
+	return reasons, nil
+}
+
+// hasCustomResources returns whether any custom resources of the crd
+// CustomResourceDefinition exist.
+func hasCustomResources(ctx context.Context, c client.Reader, crd *apiextensionsv1.CustomResourceDefinition) (bool, error) {
+	for _, version := range crd.Spec.Versions {
+		if !version.Served {
+			continue
+		}
+		list, err := listCustomResources(ctx, c, crd, version.Name, 1)
+		if err != nil {
+			return false, err
+		}
+		return len(list.Items) != 0, nil
+	}
+	return false, nil
+}
+
+// listCustomResources lists the custom resources of the crd
+// CustomResourceDefinition in the version, or up to limit of them when it
+// isn't zero.
+func listCustomResources(ctx context.Context, c client.Reader, crd *apiextensionsv1.CustomResourceDefinition, version string, limit int64) (*unstructured.UnstructuredList, error) {
+	list := &unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(schema.GroupVersionKind{Group: crd.Spec.Group, Version: version, Kind: crd.Spec.Names.ListKind})
+	if list.GetKind() == "" {
+		list.SetKind(crd.Spec.Names.Kind + "List")
+	}
+	var opts []client.ListOption
+	if limit != 0 {
+		opts = append(opts, client.Limit(limit))
+	}
+	if err := c.List(ctx, list, opts...); err != nil {
+		return nil, fmt.Errorf("failed to list the %s custom resources: %w", crd.GetName(), err)
+	}
+	return list, nil
+}
@@ -0,0 +1,277 @@
+package preflight
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+)
+
+const widgetCRD = `
+apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: widgets.example.com
+spec:
+  group: example.com
+  names:
+    kind: Widget
+    listKind: WidgetList
+    plural: widgets
+  scope: Namespaced
+  versions:
+  - name: v1alpha1
+    served: true
+    storage: false
+    schema:
+      openAPIV3Schema:
+        type: object
+  - name: v1
+    served: true
+    storage: true
+    schema:
+      openAPIV3Schema:
+        type: object
+        properties:
+          spec:
+            type: object
+            properties:
+              size:
+                type: integer
+                maximum: 10
+              mode:
+                type: string
+                enum: [fast, slow]
+`
+
+func widget(name string, spec map[string]interface{}) unstructured.Unstructured {
+	obj := unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "example.com/v1",
+		"kind":       "Widget",
+		"spec":       spec,
+	}}
+	obj.SetName(name)
+	obj.SetNamespace("default")
+	return obj
+}
+
+func existingWidgetCRD(t *testing.T, storedVersions ...string) apiextensionsv1.CustomResourceDefinition {
+	t.Helper()
+	crd := apiextensionsv1.CustomResourceDefinition{}
+	if err := yaml.Unmarshal([]byte(widgetCRD), &crd); err != nil {
+		t.Fatal(err)
+	}
+	crd.Status.StoredVersions = storedVersions
+	return crd
+}
+
+func TestCRDCompatibility(t *testing.T) {
+	widgets := map[string][]unstructured.Unstructured{
+		"v1alpha1": {widget("small", map[string]interface{}{"size": int64(2), "mode": "fast"})},
+		"v1":       {widget("small", map[string]interface{}{"size": int64(2), "mode": "fast"})},
+	}
+	tests := []struct {
+		name            string
+		target          string
+		storedVersions  []string
+		customResources map[string][]unstructured.Unstructured
+		forbidden       bool
+		want            []string
+	}{
+		{
+			name:            "Unchanged",
+			target:          widgetCRD,
+			storedVersions:  []string{"v1"},
+			customResources: widgets,
+		},
+		{
+			name:           "StoredVersionDropped",
+			target:         strings.Replace(widgetCRD, "- name: v1alpha1\n    served: true", "- name: v1beta1\n    served: true", 1),
+			storedVersions: []string{"v1alpha1", "v1"},
+			want: []string{
+				"crd-compatibility: the v1alpha1 version of the widgets.example.com CustomResourceDefinition is dropped, although objects are stored in it",
+			},
+		},
+		{
+			name:            "ServedVersionInUse",
+			target:          strings.Replace(widgetCRD, "- name: v1alpha1\n    served: true", "- name: v1alpha1\n    served: false", 1),
+			storedVersions:  []string{"v1"},
+			customResources: widgets,
+			want: []string{
+				"crd-compatibility: the v1alpha1 version of the widgets.example.com CustomResourceDefinition is no longer served, although custom resources exist",
+			},
+		},
+		{
+			name:           "ServedVersionUnused",
+			target:         strings.Replace(widgetCRD, "- name: v1alpha1\n    served: true", "- name: v1alpha1\n    served: false", 1),
+			storedVersions: []string{"v1"},
+		},
+		{
+			name:            "CRDRemovedInUse",
+			target:          fooCRD,
+			storedVersions:  []string{"v1"},
+			customResources: widgets,
+			want: []string{
+				"crd-compatibility: the widgets.example.com CustomResourceDefinition is removed, although custom resources exist",
+			},
+		},
+		{
+			name:           "SchemaTightened",
+			target:         strings.Replace(strings.Replace(widgetCRD, "maximum: 10", "maximum: 1", 1), "enum: [fast, slow]", "enum: [slow]", 1),
+			storedVersions: []string{"v1"},
+			customResources: map[string][]unstructured.Unstructured{
+				"v1alpha1": widgets["v1alpha1"],
+				"v1": {
+					widgets["v1"][0],
+					widget("tiny", map[string]interface{}{"size": int64(1), "mode": "slow"}),
+				},
+			},
+			want: []string{
+				"crd-compatibility: 1 Widget custom resources fail validation against the v1 schema of the target widgets.example.com CustomResourceDefinition: default/small (spec.mode: Unsupported value: \"fast\": supported values: \"slow\", spec.size: Invalid value: 2: spec.size in body should be less than or equal to 1)",
+			},
+		},
+		{
+			name: "RequiredFieldAdded",
+			target: strings.Replace(widgetCRD, `          spec:
+            type: object
+            properties:`, `          spec:
+            type: object
+            required: [owner]
+            properties:
+              owner:
+                type: string`, 1),
+			storedVersions:  []string{"v1"},
+			customResources: widgets,
+			want: []string{
+				"crd-compatibility: 1 Widget custom resources fail validation against the v1 schema of the target widgets.example.com CustomResourceDefinition: default/small (spec.owner: Required value)",
+			},
+		},
+		{
+			name:           "CustomResourcesForbidden",
+			target:         widgetCRD,
+			storedVersions: []string{"v1"},
+			forbidden:      true,
+			want: []string{
+				"crd-compatibility: the widgets.example.com custom resources can't be checked, which requires granting list access to them through a ClusterRole with the platform.openshift.io/aggregate-to-custom-resource-reader label",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := cluster{
+				crds:                     []apiextensionsv1.CustomResourceDefinition{existingWidgetCRD(t, tt.storedVersions...)},
+				customResources:          tt.customResources,
+				customResourcesForbidden: tt.forbidden,
+			}
+			failures, err := Run(context.Background(), fooBundle(t, tt.target), CRDCompatibility(c, fooBundle(t, widgetCRD)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, f := range failures {
+				got = append(got, f.String())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Run() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateCustomResource(t *testing.T) {
+	var s apiextensionsv1.JSONSchemaProps
+	if err := yaml.Unmarshal([]byte(`
+type: object
+required: [metadata, spec]
+properties:
+  spec:
+    type: object
+    x-kubernetes-validations:
+    - rule: self.min <= self.max
+      message: min must not exceed max
+    properties:
+      name:
+        type: string
+        minLength: 2
+        pattern: "^[a-z]+$"
+      port:
+        x-kubernetes-int-or-string: true
+      replicas:
+        type: integer
+        minimum: 0
+        exclusiveMinimum: true
+      tags:
+        type: array
+        maxItems: 1
+        items:
+          type: string
+      email:
+        type: string
+        format: email
+      target:
+        type: object
+        oneOf:
+        - required: [host]
+        - required: [ip]
+        properties:
+          host:
+            type: string
+          ip:
+            type: string
+      min:
+        type: integer
+      max:
+        type: integer
+`), &s); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		spec map[string]interface{}
+		want []string
+	}{
+		{
+			name: "Valid",
+			spec: map[string]interface{}{"name": "foo", "port": "http", "replicas": int64(1), "tags": []interface{}{"a"}, "email": "foo@example.com", "target": map[string]interface{}{"host": "foo"}, "min": int64(1), "max": int64(2), "unknown": true},
+		},
+		{
+			name: "Invalid",
+			spec: map[string]interface{}{"name": "F", "replicas": int64(0), "tags": []interface{}{"a", "b"}, "email": "foo", "target": map[string]interface{}{"host": "foo", "ip": "10.0.0.1"}, "min": int64(2), "max": int64(1)},
+			want: []string{
+				"spec.name: Invalid value",
+				"spec.replicas: Invalid value",
+				"spec.tags: Too many",
+				"spec.email: Invalid value",
+				`"spec.target" must validate one and only one schema (oneOf)`,
+				"spec: Invalid value: \"object\": min must not exceed max",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			obj := map[string]interface{}{
+				"apiVersion": "example.com/v1",
+				"kind":       "Widget",
+				"metadata":   map[string]interface{}{"name": "small"},
+				"spec":       tt.spec,
+			}
+			got, err := validateCustomResource(context.Background(), &s, obj)
+			if err != nil {
+				t.Fatal(err)
+			}
+			joined := strings.Join(got, "\n")
+			for _, want := range tt.want {
+				if !strings.Contains(joined, want) {
+					t.Errorf("validateCustomResource() = %q, want it to contain %q", got, want)
+				}
+			}
+			if len(tt.want) == 0 && len(got) != 0 {
+				t.Errorf("validateCustomResource() = %q, want no errors", got)
+			}
+		})
+	}
+}
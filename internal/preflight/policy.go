@@ -0,0 +1,77 @@
+package preflight
+
+import (
+	"fmt"
+	"os"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// PermissionPolicy lists the cluster permissions bundles may not request.
+type PermissionPolicy struct {
+	// Denied are the rules the requested cluster permissions may not
+	// overlap with. A requested rule overlaps a denied rule when it grants
+	// any of its verbs, on any of its resources, in any of its API groups.
+	// A "*" in a requested rule grants every value, whereas a "*" in a
+	// denied rule only matches a requested "*", and an empty list in a
+	// denied rule matches every value.
+	Denied []rbacv1.PolicyRule `json:"denied"`
+}
+
+// DefaultPermissionPolicy denies cluster-admin equivalent permissions, and
+// the permissions that allow escalating beyond the bundle's own.
+func DefaultPermissionPolicy() PermissionPolicy {
+	return PermissionPolicy{
+		Denied: []rbacv1.PolicyRule{
+			{APIGroups: []string{"*"}, Resources: []string{"*"}, Verbs: []string{"*"}},
+			{APIGroups: []string{rbacv1.GroupName}, Verbs: []string{"escalate", "bind"}},
+			{Verbs: []string{"impersonate"}},
+		},
+	}
+}
+
+// LoadPermissionPolicy reads a PermissionPolicy from the YAML file at path.
+func LoadPermissionPolicy(path string) (PermissionPolicy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return PermissionPolicy{}, err
+	}
+	var policy PermissionPolicy
+	if err := yaml.UnmarshalStrict(data, &policy); err != nil {
+		return PermissionPolicy{}, fmt.Errorf("failed to parse the permission policy in %s: %w", path, err)
+	}
+	return policy, nil
+}
+
+// denies returns the first denied rule that the requested rule overlaps.
+// Rules for non-resource URLs are never denied.
+func (p PermissionPolicy) denies(requested rbacv1.PolicyRule) (rbacv1.PolicyRule, bool) {
+	if len(requested.Resources) == 0 {
+		return rbacv1.PolicyRule{}, false
+	}
+	for _, denied := range p.Denied {
+		if grants(requested.Verbs, denied.Verbs) &&
+			grants(requested.Resources, denied.Resources) &&
+			grants(requested.APIGroups, denied.APIGroups) {
+			return denied, true
+		}
+	}
+	return rbacv1.PolicyRule{}, false
+}
+
+// grants returns whether the requested values grant any of the denied
+// values.
+func grants(requested, denied []string) bool {
+	if len(denied) == 0 {
+		return true
+	}
+	for _, r := range requested {
+		for _, d := range denied {
+			if r == d || r == "*" {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,144 @@
+// Package preflight inspects the manifests of a bundle before it's installed,
+// so conflicts with what's already on the cluster are reported up front
+// rather than as installation failures.
+package preflight
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+
+	operatorsv1alpha1 "github.com/operator-framework/api/pkg/operators/v1alpha1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+)
+
+const (
+	CheckCRDOwnership       = "crd-ownership"
+	CheckAPIServiceConflict = "apiservice-conflict"
+	CheckClusterPermissions = "cluster-permissions"
+	CheckWebhookOverlap     = "webhook-overlap"
+	CheckCRDCompatibility   = "crd-compatibility"
+)
+
+// Bundle holds the manifests of a bundle.
+type Bundle struct {
+	// Name is the name of the bundle.
+	Name string
+	// Objects are the manifests in the bundle's manifests directory.
+	Objects []unstructured.Unstructured
+}
+
+// ParseBundle parses the YAML or JSON manifests in files, keyed by their
+// path, into the name bundle. Multi-document files are supported.
+func ParseBundle(name string, files map[string][]byte) (*Bundle, error) {
+	paths := make([]string, 0, len(files))
+	for p := range files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	b := &Bundle{Name: name}
+	for _, p := range paths {
+		switch path.Ext(p) {
+		case ".yaml", ".yml", ".json":
+		default:
+			continue
+		}
+		decoder := utilyaml.NewYAMLOrJSONDecoder(bytes.NewReader(files[p]), 4096)
+		for {
+			obj := unstructured.Unstructured{}
+			if err := decoder.Decode(&obj.Object); err != nil {
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				return nil, fmt.Errorf("failed to parse the %s manifest of the %s bundle: %w", p, name, err)
+			}
+			if len(obj.Object) == 0 {
+				continue
+			}
+			b.Objects = append(b.Objects, obj)
+		}
+	}
+	return b, nil
+}
+
+// CRDs returns the CustomResourceDefinitions in the bundle.
+func (b *Bundle) CRDs() ([]apiextensionsv1.CustomResourceDefinition, error) {
+	var crds []apiextensionsv1.CustomResourceDefinition
+	for _, obj := range b.objects("apiextensions.k8s.io", "CustomResourceDefinition") {
+		crd := apiextensionsv1.CustomResourceDefinition{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &crd); err != nil {
+			return nil, fmt.Errorf("failed to decode the %s CustomResourceDefinition: %w", obj.GetName(), err)
+		}
+		crds = append(crds, crd)
+	}
+	return crds, nil
+}
+
+// CSV returns the bundle's ClusterServiceVersion, or nil when it has none.
+func (b *Bundle) CSV() (*operatorsv1alpha1.ClusterServiceVersion, error) {
+	objs := b.objects(operatorsv1alpha1.GroupName, operatorsv1alpha1.ClusterServiceVersionKind)
+	if len(objs) == 0 {
+		return nil, nil
+	}
+	csv := &operatorsv1alpha1.ClusterServiceVersion{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(objs[0].Object, csv); err != nil {
+		return nil, fmt.Errorf("failed to decode the %s ClusterServiceVersion: %w", objs[0].GetName(), err)
+	}
+	return csv, nil
+}
+
+// objects returns the bundle's objects of the group and kind.
+func (b *Bundle) objects(group, kind string) []unstructured.Unstructured {
+	var objs []unstructured.Unstructured
+	for _, obj := range b.Objects {
+		gvk := obj.GroupVersionKind()
+		if gvk.Group == group && gvk.Kind == kind {
+			objs = append(objs, obj)
+		}
+	}
+	return objs
+}
+
+// Check inspects a bundle for a kind of conflict with the cluster.
+type Check struct {
+	// Name identifies the check in the failures it reports.
+	Name string
+	// Run returns a description of each conflict found in the bundle, or
+	// an error when the check couldn't be run.
+	Run func(ctx context.Context, b *Bundle) ([]string, error)
+}
+
+// Failure is a conflict found by a preflight check.
+type Failure struct {
+	Check  string
+	Reason string
+}
+
+func (f Failure) String() string {
+	return fmt.Sprintf("%s: %s", f.Check, f.Reason)
+}
+
+// Run runs every check against the b bundle, and returns the conflicts they
+// found. Checks keep running after one fails, so every conflict is reported
+// at once.
+func Run(ctx context.Context, b *Bundle, checks ...Check) ([]Failure, error) {
+	var failures []Failure
+	for _, check := range checks {
+		reasons, err := check.Run(ctx, b)
+		if err != nil {
+			return nil, fmt.Errorf("failed to run the %s preflight check on the %s bundle: %w", check.Name, b.Name, err)
+		}
+		for _, reason := range reasons {
+			failures = append(failures, Failure{Check: check.Name, Reason: reason})
+		}
+	}
+	return failures, nil
+}
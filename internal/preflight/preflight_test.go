@@ -0,0 +1,379 @@
+package preflight
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// cluster is a client.Reader that serves fixed cluster objects.
+type cluster struct {
+	client.Reader
+	crds        []apiextensionsv1.CustomResourceDefinition
+	apiServices []unstructured.Unstructured
+	validating  []admissionregistrationv1.ValidatingWebhookConfiguration
+	mutating    []admissionregistrationv1.MutatingWebhookConfiguration
+	// customResources are keyed by the version they're listed in.
+	customResources map[string][]unstructured.Unstructured
+	// customResourcesForbidden refuses to list custom resources.
+	customResourcesForbidden bool
+}
+
+func (c cluster) Get(_ context.Context, key client.ObjectKey, obj client.Object, _ ...client.GetOption) error {
+	switch o := obj.(type) {
+	case *apiextensionsv1.CustomResourceDefinition:
+		for _, crd := range c.crds {
+			if crd.GetName() == key.Name {
+				crd.DeepCopyInto(o)
+				return nil
+			}
+		}
+	case *unstructured.Unstructured:
+		for _, apiService := range c.apiServices {
+			if apiService.GetName() == key.Name {
+				apiService.DeepCopyInto(o)
+				return nil
+			}
+		}
+	}
+	return apierrors.NewNotFound(schema.GroupResource{}, key.Name)
+}
+
+func (c cluster) List(_ context.Context, list client.ObjectList, _ ...client.ListOption) error {
+	switch l := list.(type) {
+	case *admissionregistrationv1.ValidatingWebhookConfigurationList:
+		l.Items = c.validating
+	case *admissionregistrationv1.MutatingWebhookConfigurationList:
+		l.Items = c.mutating
+	case *unstructured.UnstructuredList:
+		if c.customResourcesForbidden {
+			return apierrors.NewForbidden(schema.GroupResource{Group: l.GroupVersionKind().Group}, "", errors.New("list isn't granted"))
+		}
+		l.Items = c.customResources[l.GroupVersionKind().Version]
+	}
+	return nil
+}
+
+func labeled(owner string) metav1.ObjectMeta {
+	if owner == "" {
+		return metav1.ObjectMeta{}
+	}
+	return metav1.ObjectMeta{Labels: map[string]string{"core.rukpak.io/owner-name": owner}}
+}
+
+func crd(name, owner string) apiextensionsv1.CustomResourceDefinition {
+	crd := apiextensionsv1.CustomResourceDefinition{ObjectMeta: labeled(owner)}
+	crd.SetName(name)
+	return crd
+}
+
+func apiService(name, owner string, aggregated bool) unstructured.Unstructured {
+	obj := unstructured.Unstructured{}
+	obj.SetGroupVersionKind(APIServiceGVK)
+	obj.SetName(name)
+	obj.SetLabels(labeled(owner).Labels)
+	if aggregated {
+		_ = unstructured.SetNestedField(obj.Object, "foo-service", "spec", "service", "name")
+	}
+	return obj
+}
+
+const (
+	fooCRD = `
+apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: foos.example.com
+spec:
+  group: example.com
+  names:
+    kind: Foo
+    plural: foos
+  scope: Namespaced
+  versions:
+  - name: v1
+    served: true
+    storage: true
+`
+	fooCSV = `
+apiVersion: operators.coreos.com/v1alpha1
+kind: ClusterServiceVersion
+metadata:
+  name: foo.v1.0.0
+spec:
+  apiservicedefinitions:
+    owned:
+    - group: metrics.example.com
+      version: v1beta1
+      kind: Metric
+      name: metrics
+  install:
+    strategy: deployment
+    spec:
+      clusterPermissions:
+      - serviceAccountName: foo-operator
+        rules:
+        - apiGroups: [""]
+          resources: [pods]
+          verbs: [get, list, watch]
+  webhookdefinitions:
+  - type: ValidatingAdmissionWebhook
+    generateName: vfoo.example.com
+    rules:
+    - apiGroups: [example.com]
+      apiVersions: [v1]
+      resources: [foos]
+      operations: [CREATE, UPDATE]
+`
+)
+
+func fooBundle(t *testing.T, manifests ...string) *Bundle {
+	t.Helper()
+	files := make(map[string][]byte)
+	for i, m := range manifests {
+		files[filepath.Join("manifests", string(rune('a'+i))+".yaml")] = []byte(m)
+	}
+	b, err := ParseBundle("foo.v1.0.0", files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestParseBundle(t *testing.T) {
+	b, err := ParseBundle("foo.v1.0.0", map[string][]byte{
+		"foo.clusterserviceversion.yaml": []byte(fooCSV),
+		"crds.yaml":                      []byte(fooCRD + "---\n" + strings.Replace(fooCRD, "foos", "bars", -1)),
+		"README.md":                      []byte("# foo"),
+		"empty.yaml":                     []byte("---\n"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	crds, err := b.CRDs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, crd := range crds {
+		names = append(names, crd.GetName())
+	}
+	if want := []string{"foos.example.com", "bars.example.com"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("CRDs() = %v, want %v", names, want)
+	}
+	csv, err := b.CSV()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if csv == nil || csv.GetName() != "foo.v1.0.0" {
+		t.Errorf("CSV() = %v, want the foo.v1.0.0 ClusterServiceVersion", csv)
+	}
+
+	if _, err := ParseBundle("foo.v1.0.0", map[string][]byte{"bad.yaml": []byte("kind: [")}); err == nil {
+		t.Error("ParseBundle() of an invalid manifest succeeded, want an error")
+	}
+}
+
+func TestChecks(t *testing.T) {
+	owner := Owner{Name: "foo"}
+	tests := []struct {
+		name    string
+		bundle  []string
+		check   func(c cluster) Check
+		cluster cluster
+		want    []string
+	}{
+		{
+			name:   "CRDNotInstalled",
+			bundle: []string{fooCRD},
+			check:  func(c cluster) Check { return CRDOwnership(c, owner) },
+		},
+		{
+			name:    "CRDOwned",
+			bundle:  []string{fooCRD},
+			check:   func(c cluster) Check { return CRDOwnership(c, owner) },
+			cluster: cluster{crds: []apiextensionsv1.CustomResourceDefinition{crd("foos.example.com", "foo")}},
+		},
+		{
+			name:    "CRDOwnedByAnotherInstallation",
+			bundle:  []string{fooCRD},
+			check:   func(c cluster) Check { return CRDOwnership(c, owner) },
+			cluster: cluster{crds: []apiextensionsv1.CustomResourceDefinition{crd("foos.example.com", "bar")}},
+			want:    []string{"crd-ownership: the foos.example.com CustomResourceDefinition is already managed by the bar installation"},
+		},
+		{
+			name:    "CRDUnmanaged",
+			bundle:  []string{fooCRD},
+			check:   func(c cluster) Check { return CRDOwnership(c, owner) },
+			cluster: cluster{crds: []apiextensionsv1.CustomResourceDefinition{crd("foos.example.com", "")}},
+			want:    []string{"crd-ownership: the foos.example.com CustomResourceDefinition is already managed by an installer other than platform operators"},
+		},
+		{
+			name:    "CRDUnmanagedAdopted",
+			bundle:  []string{fooCRD},
+			check:   func(c cluster) Check { return CRDOwnership(c, Owner{Name: "foo", AllowUnmanaged: true}) },
+			cluster: cluster{crds: []apiextensionsv1.CustomResourceDefinition{crd("foos.example.com", "")}},
+		},
+		{
+			name:    "APIServiceOwnedByAnotherInstallation",
+			bundle:  []string{fooCSV},
+			check:   func(c cluster) Check { return APIServiceConflicts(c, owner) },
+			cluster: cluster{apiServices: []unstructured.Unstructured{apiService("v1beta1.metrics.example.com", "bar", true)}},
+			want:    []string{"apiservice-conflict: the v1beta1.metrics.example.com APIService is already managed by the bar installation"},
+		},
+		{
+			name:    "CRDServedByAggregatedAPIService",
+			bundle:  []string{fooCRD},
+			check:   func(c cluster) Check { return APIServiceConflicts(c, owner) },
+			cluster: cluster{apiServices: []unstructured.Unstructured{apiService("v1.example.com", "bar", true)}},
+			want:    []string{"apiservice-conflict: the v1 API of the foos.example.com CustomResourceDefinition is already served by the v1.example.com aggregated APIService"},
+		},
+		{
+			name:    "CRDServedLocally",
+			bundle:  []string{fooCRD},
+			check:   func(c cluster) Check { return APIServiceConflicts(c, owner) },
+			cluster: cluster{apiServices: []unstructured.Unstructured{apiService("v1.example.com", "", false)}},
+		},
+		{
+			name:   "ClusterPermissionsAllowed",
+			bundle: []string{fooCSV},
+			check:  func(cluster) Check { return ClusterPermissions(DefaultPermissionPolicy()) },
+		},
+		{
+			name:   "ClusterPermissionsClusterAdmin",
+			bundle: []string{strings.NewReplacer(`[""]`, `["*"]`, `[pods]`, `["*"]`, `[get, list, watch]`, `["*"]`).Replace(fooCSV)},
+			check:  func(cluster) Check { return ClusterPermissions(DefaultPermissionPolicy()) },
+			want:   []string{"cluster-permissions: the foo-operator service account requests [*] on [*] in the [*] API groups, which overlaps the [*] on [*] in the [*] API groups permissions the policy denies"},
+		},
+		{
+			name: "ClusterRoleEscalates",
+			bundle: []string{`
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: foo-admin
+rules:
+- apiGroups: [rbac.authorization.k8s.io]
+  resources: [clusterroles]
+  verbs: ["*"]
+- nonResourceURLs: [/metrics]
+  verbs: ["*"]
+`},
+			check: func(cluster) Check { return ClusterPermissions(DefaultPermissionPolicy()) },
+			want:  []string{"cluster-permissions: the foo-admin ClusterRole requests [*] on [clusterroles] in the [rbac.authorization.k8s.io] API groups, which overlaps the [escalate,bind] on [*] in the [rbac.authorization.k8s.io] API groups permissions the policy denies"},
+		},
+		{
+			name:   "WebhookOverlap",
+			bundle: []string{fooCSV},
+			check:  func(c cluster) Check { return WebhookOverlaps(c, owner) },
+			cluster: cluster{
+				validating: []admissionregistrationv1.ValidatingWebhookConfiguration{{
+					ObjectMeta: metav1.ObjectMeta{Name: "bar-webhooks", Labels: labeled("bar").Labels},
+					Webhooks: []admissionregistrationv1.ValidatingWebhook{{
+						Name: "vbar.example.com",
+						Rules: []admissionregistrationv1.RuleWithOperations{{
+							Operations: []admissionregistrationv1.OperationType{admissionregistrationv1.OperationAll},
+							Rule:       admissionregistrationv1.Rule{APIGroups: []string{"*"}, APIVersions: []string{"*"}, Resources: []string{"foos"}},
+						}},
+					}},
+				}},
+				mutating: []admissionregistrationv1.MutatingWebhookConfiguration{{
+					ObjectMeta: metav1.ObjectMeta{Name: "baz-webhooks", Labels: labeled("baz").Labels},
+					Webhooks: []admissionregistrationv1.MutatingWebhook{{
+						Name: "mbaz.example.com",
+						Rules: []admissionregistrationv1.RuleWithOperations{{
+							Operations: []admissionregistrationv1.OperationType{admissionregistrationv1.Create},
+							Rule:       admissionregistrationv1.Rule{APIGroups: []string{"example.com"}, APIVersions: []string{"v1"}, Resources: []string{"foos"}},
+						}},
+					}},
+				}},
+			},
+			want: []string{"webhook-overlap: the vfoo.example.com webhook intercepts the same requests as the vbar.example.com webhook of the bar-webhooks configuration, managed by the bar installation"},
+		},
+		{
+			name:   "WebhookUnmanaged",
+			bundle: []string{fooCSV},
+			check:  func(c cluster) Check { return WebhookOverlaps(c, owner) },
+			cluster: cluster{
+				validating: []admissionregistrationv1.ValidatingWebhookConfiguration{{
+					ObjectMeta: metav1.ObjectMeta{Name: "platform-webhooks"},
+					Webhooks: []admissionregistrationv1.ValidatingWebhook{{
+						Name: "vplatform.example.com",
+						Rules: []admissionregistrationv1.RuleWithOperations{{
+							Operations: []admissionregistrationv1.OperationType{admissionregistrationv1.OperationAll},
+							Rule:       admissionregistrationv1.Rule{APIGroups: []string{"*"}, APIVersions: []string{"*"}, Resources: []string{"*"}},
+						}},
+					}},
+				}},
+			},
+		},
+		{
+			name:   "WebhookOwned",
+			bundle: []string{fooCSV},
+			check:  func(c cluster) Check { return WebhookOverlaps(c, owner) },
+			cluster: cluster{
+				validating: []admissionregistrationv1.ValidatingWebhookConfiguration{{
+					ObjectMeta: metav1.ObjectMeta{Name: "foo-webhooks", Labels: labeled("foo").Labels},
+					Webhooks: []admissionregistrationv1.ValidatingWebhook{{
+						Name: "vfoo.example.com",
+						Rules: []admissionregistrationv1.RuleWithOperations{{
+							Operations: []admissionregistrationv1.OperationType{admissionregistrationv1.Create},
+							Rule:       admissionregistrationv1.Rule{APIGroups: []string{"example.com"}, APIVersions: []string{"v1"}, Resources: []string{"foos"}},
+						}},
+					}},
+				}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			failures, err := Run(context.Background(), fooBundle(t, tt.bundle...), tt.check(tt.cluster))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, f := range failures {
+				got = append(got, f.String())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Run() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadPermissionPolicy(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "policy.yaml")
+	if err := os.WriteFile(path, []byte("denied:\n- apiGroups: ['']\n  resources: [secrets]\n  verbs: [get]\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	policy, err := LoadPermissionPolicy(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := PermissionPolicy{Denied: []rbacv1.PolicyRule{{APIGroups: []string{""}, Resources: []string{"secrets"}, Verbs: []string{"get"}}}}
+	if !reflect.DeepEqual(policy, want) {
+		t.Errorf("LoadPermissionPolicy() = %+v, want %+v", policy, want)
+	}
+
+	if err := os.WriteFile(path, []byte("allowed: []\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadPermissionPolicy(path); err == nil {
+		t.Error("LoadPermissionPolicy() of an unknown field succeeded, want an error")
+	}
+}
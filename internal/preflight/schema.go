@@ -0,0 +1,74 @@
+package preflight
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"k8s.io/apiextensions-apiserver/pkg/apis/apiextensions"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	structuralschema "k8s.io/apiextensions-apiserver/pkg/apiserver/schema"
+	"k8s.io/apiextensions-apiserver/pkg/apiserver/schema/cel"
+	"k8s.io/apiextensions-apiserver/pkg/apiserver/validation"
+	celconfig "k8s.io/apiserver/pkg/apis/cel"
+)
+
+// validateCustomResource validates the obj custom resource against the s
+// schema the way the API server does, including its x-kubernetes-validations
+// rules, leaving out the fields every object has, which the API server
+// validates on its own, and the status, which is a subresource. Fields the
+// schema doesn't declare are ignored, as the API server prunes them.
+func validateCustomResource(ctx context.Context, s *apiextensionsv1.JSONSchemaProps, obj map[string]interface{}) ([]string, error) {
+	if s == nil {
+		return nil, nil
+	}
+	ignored := map[string]bool{"apiVersion": true, "kind": true, "metadata": true, "status": true}
+	root := *s
+	root.Required = nil
+	for _, name := range s.Required {
+		if !ignored[name] {
+			root.Required = append(root.Required, name)
+		}
+	}
+	internal := &apiextensions.JSONSchemaProps{}
+	if err := apiextensionsv1.Convert_v1_JSONSchemaProps_To_apiextensions_JSONSchemaProps(&root, internal, nil); err != nil {
+		return nil, fmt.Errorf("failed to convert the schema: %w", err)
+	}
+
+	fields := make(map[string]interface{}, len(obj))
+	for name, value := range obj {
+		if !ignored[name] {
+			fields[name] = value
+		}
+	}
+	validator, _, err := validation.NewSchemaValidator(internal)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build the schema validator: %w", err)
+	}
+	errs := validation.ValidateCustomResource(nil, fields, validator)
+
+	// the rules only apply to structural schemas, which the API server
+	// requires of CustomResourceDefinitions that have any.
+	structural, err := structuralschema.NewStructural(internal)
+	if err == nil {
+		if celValidator := cel.NewValidator(structural, true, celconfig.PerCallLimit); celValidator != nil {
+			// the rules of the root can refer to the object's metadata.
+			withMetadata := make(map[string]interface{}, len(fields)+1)
+			for name, value := range fields {
+				withMetadata[name] = value
+			}
+			if metadata, ok := obj["metadata"]; ok {
+				withMetadata["metadata"] = metadata
+			}
+			celErrs, _ := celValidator.Validate(ctx, nil, structural, withMetadata, nil, celconfig.RuntimeCELCostBudget)
+			errs = append(errs, celErrs...)
+		}
+	}
+
+	reasons := make([]string, 0, len(errs))
+	for _, err := range errs {
+		reasons = append(reasons, err.Error())
+	}
+	sort.Strings(reasons)
+	return reasons, nil
+}
@@ -0,0 +1,186 @@
+package resolution
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/blang/semver/v4"
+	"github.com/operator-framework/api/pkg/constraints"
+	"github.com/operator-framework/operator-registry/alpha/property"
+
+	"github.com/openshift/platform-operators/internal/clusterfacts"
+	"github.com/openshift/platform-operators/internal/sourcer"
+)
+
+// The property types that cluster facts are exposed to CEL rules as, through
+// the "properties" variable. Installed APIs and packages reuse the olm.gvk and
+// olm.package property types, and their value schemas.
+const (
+	FactOpenShiftVersion  = "cluster.openshift.version"
+	FactKubernetesVersion = "cluster.kubernetes.version"
+	FactArchitecture      = "cluster.architecture"
+)
+
+var (
+	celEnvOnce sync.Once
+	celEnv     *constraints.CelEnvironment
+)
+
+func celEnvironment() *constraints.CelEnvironment {
+	celEnvOnce.Do(func() {
+		celEnv = constraints.NewCelEnvironment()
+	})
+	return celEnv
+}
+
+// constraintEvaluator evaluates the olm.constraint properties of bundles
+// against the facts of the cluster they'd be installed into.
+type constraintEvaluator struct {
+	facts      *clusterfacts.Facts
+	properties []interface{}
+}
+
+func newConstraintEvaluator(facts *clusterfacts.Facts) *constraintEvaluator {
+	return &constraintEvaluator{
+		facts:      facts,
+		properties: factProperties(facts),
+	}
+}
+
+// factProperties converts the facts into the list of properties CEL rules
+// are evaluated against.
+func factProperties(facts *clusterfacts.Facts) []interface{} {
+	var props []interface{}
+	add := func(t string, v interface{}) {
+		props = append(props, map[string]interface{}{"type": t, "value": v})
+	}
+	if facts.OpenShiftVersion != "" {
+		add(FactOpenShiftVersion, facts.OpenShiftVersion)
+	}
+	if facts.KubernetesVersion != "" {
+		add(FactKubernetesVersion, facts.KubernetesVersion)
+	}
+	for _, arch := range facts.Architectures {
+		add(FactArchitecture, arch)
+	}
+	for _, gvk := range facts.GVKs {
+		add(property.TypeGVK, map[string]interface{}{"group": gvk.Group, "version": gvk.Version, "kind": gvk.Kind})
+	}
+	for name, version := range facts.Packages {
+		add(property.TypePackage, map[string]interface{}{"packageName": name, "version": version})
+	}
+	return props
+}
+
+// filter returns a Filter that rejects the bundles whose olm.constraint
+// properties aren't satisfied by the cluster.
+func (e *constraintEvaluator) filter() sourcer.Filter {
+	return sourcer.Filter{Name: sourcer.FilterConstraints, Reject: e.evaluate}
+}
+
+// evaluate returns an empty string when every olm.constraint of the b bundle
+// is satisfied, and otherwise the reason the first unsatisfied constraint
+// wasn't. Constraints that hold a single package or gvk constraint are
+// dependencies that resolution satisfies, so they're not evaluated here.
+func (e *constraintEvaluator) evaluate(b sourcer.Bundle) (string, error) {
+	cs, err := b.Constraints()
+	if err != nil {
+		return "", err
+	}
+	for _, c := range cs {
+		if c.Package != nil || c.GVK != nil {
+			continue
+		}
+		ok, err := e.satisfied(c)
+		if err != nil {
+			return "", fmt.Errorf("failed to evaluate an %s property of the %s bundle: %w", constraints.OLMConstraintType, b.Name, err)
+		}
+		if ok {
+			continue
+		}
+		if c.FailureMessage != "" {
+			return c.FailureMessage, nil
+		}
+		return fmt.Sprintf("the cluster doesn't satisfy %s", describeConstraint(c)), nil
+	}
+	return "", nil
+}
+
+func (e *constraintEvaluator) satisfied(c constraints.Constraint) (bool, error) {
+	switch {
+	case c.Cel != nil:
+		program, err := celEnvironment().Validate(c.Cel.Rule)
+		if err != nil {
+			return false, err
+		}
+		return program.Evaluate(map[string]interface{}{constraints.PropertiesKey: e.properties})
+	case c.GVK != nil:
+		for _, gvk := range e.facts.GVKs {
+			if gvk.Group == c.GVK.Group && gvk.Version == c.GVK.Version && gvk.Kind == c.GVK.Kind {
+				return true, nil
+			}
+		}
+		return false, nil
+	case c.Package != nil:
+		installed, ok := e.facts.Packages[c.Package.PackageName]
+		if !ok {
+			return false, nil
+		}
+		versionRange, err := semver.ParseRange(c.Package.VersionRange)
+		if err != nil {
+			return false, err
+		}
+		return inRange(versionRange, installed), nil
+	case c.All != nil:
+		for _, nested := range c.All.Constraints {
+			ok, err := e.satisfied(nested)
+			if err != nil || !ok {
+				return false, err
+			}
+		}
+		return true, nil
+	case c.Any != nil:
+		for _, nested := range c.Any.Constraints {
+			ok, err := e.satisfied(nested)
+			if err != nil || ok {
+				return ok, err
+			}
+		}
+		return false, nil
+	case c.Not != nil:
+		for _, nested := range c.Not.Constraints {
+			ok, err := e.satisfied(nested)
+			if err != nil || ok {
+				return false, err
+			}
+		}
+		return true, nil
+	}
+	return false, fmt.Errorf("the constraint doesn't set any of cel, gvk, package, all, any or not")
+}
+
+func describeConstraint(c constraints.Constraint) string {
+	describeAll := func(op string, cs []constraints.Constraint) string {
+		var nested []string
+		for _, c := range cs {
+			nested = append(nested, describeConstraint(c))
+		}
+		return fmt.Sprintf("%s of [%s]", op, strings.Join(nested, ", "))
+	}
+	switch {
+	case c.Cel != nil:
+		return fmt.Sprintf("the %q rule", c.Cel.Rule)
+	case c.GVK != nil:
+		return fmt.Sprintf("the %s/%s, Kind=%s API being served", c.GVK.Group, c.GVK.Version, c.GVK.Kind)
+	case c.Package != nil:
+		return fmt.Sprintf("the %s package being installed in the %q range", c.Package.PackageName, c.Package.VersionRange)
+	case c.All != nil:
+		return describeAll("all", c.All.Constraints)
+	case c.Any != nil:
+		return describeAll("any", c.Any.Constraints)
+	case c.Not != nil:
+		return describeAll("none", c.Not.Constraints)
+	}
+	return "an empty constraint"
+}
@@ -0,0 +1,861 @@
+package resolution
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/blang/semver/v4"
+	"github.com/operator-framework/operator-registry/alpha/property"
+	logr "sigs.k8s.io/controller-runtime/pkg/log"
+
+	platformv1alpha1 "github.com/openshift/api/platform/v1alpha1"
+	"github.com/openshift/platform-operators/internal/clusterfacts"
+	"github.com/openshift/platform-operators/internal/sourcer"
+)
+
+var (
+	// ErrUnsatisfiable is returned when there's no set of bundles that
+	// satisfies every PlatformOperator at once.
+	ErrUnsatisfiable = errors.New("the platform operators can't be resolved")
+	// ErrBudgetExceeded is returned when resolution gives up on a problem
+	// that's too large to solve.
+	ErrBudgetExceeded = errors.New("resolution exceeded its budget")
+)
+
+// UnsatisfiableError explains why resolution failed through the smallest set
+// of constraints that can't be satisfied together.
+type UnsatisfiableError struct {
+	Constraints []string
+}
+
+func (e *UnsatisfiableError) Error() string {
+	return fmt.Sprintf("%v: %s", ErrUnsatisfiable, strings.Join(e.Constraints, "; "))
+}
+
+func (e *UnsatisfiableError) Unwrap() error {
+	return ErrUnsatisfiable
+}
+
+// Selection is the outcome of resolution for a single PlatformOperator.
+type Selection struct {
+	// Bundle is the bundle selected for the PlatformOperator's package.
+	Bundle sourcer.Bundle
+	// Dependencies are the selected bundles the Bundle requires, in
+	// installation order.
+	Dependencies []sourcer.Dependency
+	// Rejections explain why the other candidates from the
+	// PlatformOperator's package couldn't be selected.
+	Rejections []sourcer.Rejection
+}
+
+// Solution is the set of bundles that satisfies every PlatformOperator that
+// could be resolved.
+type Solution struct {
+	// Selections is keyed by PlatformOperator name.
+	Selections map[string]Selection
+	// Bundles is every selected bundle, sorted by package name.
+	Bundles []sourcer.Bundle
+	// Unresolvable holds why each of the PlatformOperators that were left
+	// out of the resolution couldn't be resolved, keyed by PlatformOperator
+	// name.
+	Unresolvable map[string]error
+}
+
+// Resolver selects bundles for every PlatformOperator in the cluster at once,
+// so the bundles selected for one PlatformOperator never conflict with those
+// selected for another.
+type Resolver struct {
+	sourcer         sourcer.Sourcer
+	facts           clusterfacts.Gatherer
+	requireFeatures bool
+}
+
+// NewResolver returns a Resolver that selects bundles from the s Sourcer, and
+// only selects bundles that are compatible with, and whose olm.constraint
+// properties are satisfied by, the cluster facts returned by the facts
+// Gatherer. Bundles that don't declare support for the cluster's
+// infrastructure features are only excluded when requireFeatures is set.
+func NewResolver(s sourcer.Sourcer, facts clusterfacts.Gatherer, requireFeatures bool) *Resolver {
+	return &Resolver{sourcer: s, facts: facts, requireFeatures: requireFeatures}
+}
+
+// variable is a candidate bundle that may be selected. A bundle that's
+// present in several channels of its package is a single variable.
+type variable struct {
+	bundle   sourcer.Bundle
+	channels []string
+	// channelDeprecations holds the deprecation message of each of the
+	// channels, keyed by channel name.
+	channelDeprecations map[string]string
+}
+
+// problem accumulates the variables and constraints of a resolution.
+type problem struct {
+	s sourcer.Sourcer
+	// exclusions rejects the bundles that can't be selected for any
+	// PlatformOperator, e.g. because they're incompatible with the cluster.
+	exclusions sourcer.Pipeline
+	// installed holds the bundleKey of every installed bundle, which are
+	// never excluded, as they've already been installed.
+	installed map[string]bool
+
+	variables []*variable
+	// index is keyed by bundleKey.
+	index       map[string]int
+	packages    map[string][]int
+	constraints []constraint
+	// auxiliary counts the variables that don't stand for a bundle, which
+	// are numbered after the bundle variables.
+	auxiliary int
+	// visited tracks the bundles whose requirements have been expanded.
+	visited map[int]bool
+	// excluded holds the Rejection of every excluded bundle, keyed by
+	// bundleKey, and rejections those of every PlatformOperator's
+	// candidates, keyed by PlatformOperator name.
+	excluded   map[string]sourcer.Rejection
+	rejections map[string][]sourcer.Rejection
+}
+
+// Resolve selects a bundle for each of the pos PlatformOperators. The
+// installed map, keyed by PlatformOperator name, holds the bundle that must be
+// selected for that PlatformOperator because it's already installed. Between
+// several satisfying solutions, higher versions are preferred for
+// PlatformOperators earlier in name order. A PlatformOperator that can't be
+// resolved, e.g. because its package doesn't exist or its catalog can't be
+// read, is left out of the resolution so it doesn't hold back the others, and
+// the reason is reported in the Solution's Unresolvable map. When no solution
+// exists, the PlatformOperators whose requirements conflict are left out one
+// at a time, starting from the last in name order, and are reported with an
+// error wrapping ErrUnsatisfiable.
+func (r *Resolver) Resolve(ctx context.Context, pos []platformv1alpha1.PlatformOperator, installed map[string]sourcer.Bundle) (*Solution, error) {
+	return r.resolve(ctx, pos, installed, nil)
+}
+
+// ResolveUpgrade selects the bundle the PlatformOperator with the name is
+// upgraded to from its updates, by resolving it together with the other pos
+// PlatformOperators, whose installed bundles are kept. The updates must
+// already satisfy the PlatformOperator's channel, version range and version
+// policy, and are preferred like any other candidates. The PlatformOperator
+// is reported in the Solution's Unresolvable map when none of its updates can
+// be selected.
+func (r *Resolver) ResolveUpgrade(ctx context.Context, pos []platformv1alpha1.PlatformOperator, installed map[string]sourcer.Bundle, name string, updates []sourcer.Bundle) (*Solution, error) {
+	others := make(map[string]sourcer.Bundle, len(installed))
+	for po, b := range installed {
+		if po != name {
+			others[po] = b
+		}
+	}
+	allowed := make(map[string]bool, len(updates))
+	for _, b := range updates {
+		allowed[b.Name] = true
+	}
+	return r.resolve(ctx, pos, others, map[string]map[string]bool{name: allowed})
+}
+
+// resolve implements Resolve, where the candidates of the PlatformOperators
+// in the upgrades map, keyed by PlatformOperator name, are limited to the
+// names of the bundles they may be upgraded to.
+func (r *Resolver) resolve(ctx context.Context, pos []platformv1alpha1.PlatformOperator, installed map[string]sourcer.Bundle, upgrades map[string]map[string]bool) (*Solution, error) {
+	pos = append([]platformv1alpha1.PlatformOperator(nil), pos...)
+	sort.Slice(pos, func(i, j int) bool { return pos[i].GetName() < pos[j].GetName() })
+
+	facts, err := r.facts.Gather(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to gather cluster facts: %w", err)
+	}
+	pinned := make(map[string]string, len(installed))
+	for name, b := range installed {
+		pinned[name] = b.Name
+		if _, ok := facts.Packages[b.Package]; !ok {
+			facts.Packages[b.Package] = b.Version
+		}
+	}
+	exclusions := sourcer.NewPipeline(
+		// prereleases are allowed per PlatformOperator, but invalid versions
+		// are never allowed.
+		sourcer.VersionPolicyFilter(true),
+		sourcer.CompatibilityFilter(facts, r.requireFeatures),
+		newConstraintEvaluator(facts).filter(),
+	)
+
+	b := newBudget(ctx)
+	unresolvable := make(map[string]error)
+	for {
+		var remaining []platformv1alpha1.PlatformOperator
+		for _, po := range pos {
+			if _, ok := unresolvable[po.GetName()]; !ok {
+				remaining = append(remaining, po)
+			}
+		}
+		p := &problem{
+			s:          r.sourcer,
+			exclusions: exclusions,
+			installed:  make(map[string]bool, len(installed)),
+			index:      make(map[string]int),
+			packages:   make(map[string][]int),
+			visited:    make(map[int]bool),
+			excluded:   make(map[string]sourcer.Rejection),
+			rejections: make(map[string][]sourcer.Rejection),
+		}
+		for _, bundle := range installed {
+			p.installed[bundleKey(bundle)] = true
+		}
+		resolved, err := p.build(ctx, remaining, pinned, upgrades)
+		if err != nil {
+			var poErr *platformOperatorError
+			if !errors.As(err, &poErr) {
+				return nil, err
+			}
+			logr.FromContext(ctx).V(1).Info("leaving platform operator out of the resolution", "platformoperator", poErr.name, "reason", poErr.err.Error())
+			unresolvable[poErr.name] = poErr.err
+			continue
+		}
+
+		values, ok, err := newSolver(len(p.variables)+p.auxiliary, p.constraints, b).solve()
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			solution, err := p.solution(resolved, values)
+			if err != nil {
+				return nil, err
+			}
+			solution.Unresolvable = unresolvable
+			return solution, nil
+		}
+		core, err := explain(len(p.variables)+p.auxiliary, p.constraints, b)
+		if err != nil {
+			return nil, err
+		}
+		var explanation []string
+		for _, c := range core {
+			explanation = append(explanation, c.description)
+		}
+		unsat := &UnsatisfiableError{Constraints: explanation}
+		conflicting := conflictingPlatformOperator(core, pinned)
+		if conflicting == "" {
+			return nil, unsat
+		}
+		logr.FromContext(ctx).V(1).Info("leaving platform operator out of the resolution", "platformoperator", conflicting, "reason", unsat.Error())
+		unresolvable[conflicting] = unsat
+	}
+}
+
+// platformOperatorError is returned when a single PlatformOperator can't be
+// added to the problem.
+type platformOperatorError struct {
+	name string
+	err  error
+}
+
+func (e *platformOperatorError) Error() string {
+	return fmt.Sprintf("failed to resolve the %s PlatformOperator: %v", e.name, e.err)
+}
+
+func (e *platformOperatorError) Unwrap() error {
+	return e.err
+}
+
+// build adds the constraints for the pos PlatformOperators, and the
+// requirements of their candidates, to the problem, and returns the
+// PlatformOperators that are part of it. A failure that's specific to one of
+// the PlatformOperators is returned as a platformOperatorError.
+func (p *problem) build(ctx context.Context, pos []platformv1alpha1.PlatformOperator, pinned map[string]string, upgrades map[string]map[string]bool) ([]platformv1alpha1.PlatformOperator, error) {
+	var (
+		resolved []platformv1alpha1.PlatformOperator
+		required = make(map[string][]int, len(pos))
+	)
+	for i := range pos {
+		po := &pos[i]
+		candidates, ok, err := p.mandatory(ctx, po, pinned[po.GetName()], upgrades[po.GetName()])
+		if err != nil {
+			return nil, &platformOperatorError{name: po.GetName(), err: err}
+		}
+		if !ok {
+			continue
+		}
+		resolved = append(resolved, *po)
+		required[po.GetName()] = candidates
+	}
+	for i := range resolved {
+		for _, v := range required[resolved[i].GetName()] {
+			if err := p.expand(ctx, &resolved[i], v); err != nil {
+				return nil, &platformOperatorError{name: resolved[i].GetName(), err: err}
+			}
+		}
+	}
+	p.uniqueness()
+	return resolved, nil
+}
+
+// conflictingPlatformOperator returns the PlatformOperator to leave out of the
+// resolution to resolve the conflict explained by the core constraints. The
+// last of the PlatformOperators that haven't installed anything yet is
+// preferred, so the PlatformOperators that are already installed keep their
+// bundles.
+func conflictingPlatformOperator(core []constraint, pinned map[string]string) string {
+	var conflicting, installed string
+	for _, c := range core {
+		if c.owner == "" {
+			continue
+		}
+		if pinned[c.owner] != "" {
+			if c.owner > installed {
+				installed = c.owner
+			}
+			continue
+		}
+		if c.owner > conflicting {
+			conflicting = c.owner
+		}
+	}
+	if conflicting != "" {
+		return conflicting
+	}
+	return installed
+}
+
+// mandatory requires one of the po PlatformOperator's candidates, the pinned
+// bundle when set, or one of the updates when po is being upgraded, to be
+// selected, and returns those candidates. An
+// UnsatisfiableError is returned when po has no candidate to select. A
+// PlatformOperator whose pinned bundle is no longer in the catalog is left
+// out of the problem, as nothing is known about what that bundle requires
+// or provides, and false is returned.
+func (p *problem) mandatory(ctx context.Context, po *platformv1alpha1.PlatformOperator, pinned string, updates map[string]bool) ([]int, bool, error) {
+	candidates, err := p.s.Candidates(ctx, po)
+	if err != nil {
+		return nil, false, err
+	}
+	filters, err := sourcer.PlatformOperatorFilters(po)
+	if err != nil {
+		return nil, false, err
+	}
+	filtered, rejected, err := sourcer.NewPipeline(filters...).Run(candidates)
+	if err != nil {
+		return nil, false, err
+	}
+	if pinned != "" && !containsBundle(candidates, pinned) {
+		return nil, false, nil
+	}
+	if pinned != "" {
+		// the pinned bundle was installed under the restrictions in place
+		// at the time, which may have since changed.
+		filtered, rejected = candidates, nil
+	}
+	if updates != nil {
+		// the updates were already filtered by po's restrictions.
+		filtered, rejected = nil, nil
+		for _, b := range candidates {
+			if updates[b.Name] {
+				filtered = append(filtered, b)
+			}
+		}
+	}
+	if err := p.addAll(ctx, candidates); err != nil {
+		return nil, false, err
+	}
+	p.rejections[po.GetName()] = p.explainRejections(ctx, po, filtered, rejected)
+
+	description := fmt.Sprintf("the %s PlatformOperator requires a bundle from the %s package", po.GetName(), po.Spec.Package.Name)
+	var (
+		vars []int
+		// deprecated tracks whether each candidate is deprecated in every
+		// channel po allows it to be sourced from.
+		deprecated = make(map[int]bool)
+	)
+	for _, b := range filtered {
+		if pinned != "" && b.Name != pinned {
+			continue
+		}
+		v := p.index[bundleKey(b)]
+		if _, ok := deprecated[v]; !ok {
+			deprecated[v] = true
+		}
+		deprecated[v] = deprecated[v] && b.Deprecation.IsDeprecated()
+		vars = append(vars, v)
+	}
+	if pinned != "" {
+		description = fmt.Sprintf("the %s PlatformOperator has installed the %s bundle", po.GetName(), pinned)
+	} else if updates != nil {
+		description = fmt.Sprintf("the %s PlatformOperator requires an update of its installed bundle", po.GetName())
+	} else if len(filtered) != len(candidates) {
+		description += " that matches its channel, version range and version policy"
+	}
+	if len(vars) == 0 {
+		return nil, false, &UnsatisfiableError{Constraints: []string{description}}
+	}
+	vars = p.preferred(uniq(vars), func(v int) bool { return deprecated[v] })
+	p.constraints = append(p.constraints, constraint{
+		description: description,
+		clauses:     []clause{positive(vars)},
+		owner:       po.GetName(),
+	})
+	return vars, true, nil
+}
+
+// expand adds the constraints for the requirements of the v bundle, and
+// recursively for every bundle that may satisfy them.
+func (p *problem) expand(ctx context.Context, po *platformv1alpha1.PlatformOperator, v int) error {
+	if p.visited[v] {
+		return nil
+	}
+	p.visited[v] = true
+
+	b := p.variables[v].bundle
+	packages, gvks, err := b.Requirements()
+	if err != nil {
+		return err
+	}
+	var dependencies []int
+	for _, required := range packages {
+		versionRange, err := semver.ParseRange(required.VersionRange)
+		if err != nil {
+			return fmt.Errorf("the %s bundle requires the %s package with the invalid %q version range: %w", b.Name, required.PackageName, required.VersionRange, err)
+		}
+		candidates, err := p.s.Candidates(ctx, forPackage(po, required.PackageName))
+		if err != nil {
+			return err
+		}
+		if err := p.addAll(ctx, candidates); err != nil {
+			return err
+		}
+
+		var vars []int
+		for _, candidate := range candidates {
+			if inRange(versionRange, candidate.Version) && p.allowed(po, candidate) {
+				vars = append(vars, p.index[bundleKey(candidate)])
+			}
+		}
+		vars = p.preferred(uniq(vars), p.deprecated)
+		p.constraints = append(p.constraints, constraint{
+			description: fmt.Sprintf("the %s bundle requires the %s package in the %q range", b.Name, required.PackageName, required.VersionRange),
+			clauses:     []clause{requires(v, vars)},
+		})
+		dependencies = append(dependencies, vars...)
+	}
+	for _, required := range gvks {
+		gvk := property.GVK(required)
+		providers, err := p.s.Providers(ctx, po, gvk)
+		if err != nil {
+			return err
+		}
+		if err := p.addAll(ctx, providers); err != nil {
+			return err
+		}
+
+		var vars []int
+		for _, provider := range providers {
+			if p.allowed(po, provider) {
+				vars = append(vars, p.index[bundleKey(provider)])
+			}
+		}
+		vars = p.preferred(uniq(vars), p.deprecated)
+		p.constraints = append(p.constraints, constraint{
+			description: fmt.Sprintf("the %s bundle requires the %s", b.Name, describeGVK(gvk)),
+			clauses:     []clause{requires(v, vars)},
+		})
+		dependencies = append(dependencies, vars...)
+	}
+	for _, dependency := range dependencies {
+		if err := p.expand(ctx, po, dependency); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// uniqueness prevents two bundles from the same package, or two packages
+// providing the same API, from being selected together. It allocates
+// auxiliary variables, so no bundle can be added to the problem afterwards.
+func (p *problem) uniqueness() {
+	packageNames := make([]string, 0, len(p.packages))
+	for name := range p.packages {
+		packageNames = append(packageNames, name)
+	}
+	sort.Strings(packageNames)
+	for _, name := range packageNames {
+		vars := p.packages[name]
+		if len(vars) < 2 {
+			continue
+		}
+		p.constraints = append(p.constraints, constraint{
+			description: fmt.Sprintf("only one bundle from the %s package can be installed", name),
+			clauses:     p.atMostOne(vars),
+		})
+	}
+
+	providers := make(map[property.GVK][]int)
+	for v, variable := range p.variables {
+		for _, gvk := range providedGVKs(variable.bundle) {
+			providers[gvk] = append(providers[gvk], v)
+		}
+	}
+	gvks := make([]property.GVK, 0, len(providers))
+	for gvk := range providers {
+		gvks = append(gvks, gvk)
+	}
+	sort.Slice(gvks, func(i, j int) bool { return describeGVK(gvks[i]) < describeGVK(gvks[j]) })
+	for _, gvk := range gvks {
+		// an auxiliary variable stands for each providing package, and is
+		// true when one of the package's providing bundles is selected.
+		var (
+			clauses  []clause
+			packages []int
+			index    = make(map[string]int)
+		)
+		for _, v := range providers[gvk] {
+			name := p.variables[v].bundle.Package
+			if _, ok := index[name]; !ok {
+				index[name] = p.newAuxiliary()
+				packages = append(packages, index[name])
+			}
+			clauses = append(clauses, requires(v, []int{index[name]}))
+		}
+		if len(packages) < 2 {
+			continue
+		}
+		clauses = append(clauses, p.atMostOne(packages)...)
+		p.constraints = append(p.constraints, constraint{
+			description: fmt.Sprintf("only one package can provide the %s", describeGVK(gvk)),
+			clauses:     clauses,
+		})
+	}
+}
+
+// solution converts the satisfying assignment back into bundles.
+func (p *problem) solution(pos []platformv1alpha1.PlatformOperator, values []bool) (*Solution, error) {
+	selected := make(map[string]sourcer.Bundle)
+	for v, value := range values[:len(p.variables)] {
+		if value {
+			b := p.variables[v].bundle
+			selected[b.Package] = b
+		}
+	}
+
+	solution := &Solution{Selections: make(map[string]Selection, len(pos))}
+	for _, po := range pos {
+		b, ok := selected[po.Spec.Package.Name]
+		if !ok {
+			return nil, fmt.Errorf("resolution didn't select a bundle for the %s PlatformOperator", po.GetName())
+		}
+		b.Channel = p.selectedChannel(&po, b)
+		b.Deprecation.Channel = p.variables[p.index[bundleKey(b)]].channelDeprecations[b.Channel]
+		deps, err := dependencies(b, selected)
+		if err != nil {
+			return nil, err
+		}
+		solution.Selections[po.GetName()] = Selection{
+			Bundle:       b,
+			Dependencies: deps,
+			Rejections:   p.rejections[po.GetName()],
+		}
+	}
+	for _, b := range selected {
+		solution.Bundles = append(solution.Bundles, b)
+	}
+	sort.Slice(solution.Bundles, func(i, j int) bool { return solution.Bundles[i].Package < solution.Bundles[j].Package })
+	return solution, nil
+}
+
+// selectedChannel returns the channel of the b bundle that satisfied the po
+// PlatformOperator's channel restriction, if any, preferring the channels
+// that aren't deprecated.
+func (p *problem) selectedChannel(po *platformv1alpha1.PlatformOperator, b sourcer.Bundle) string {
+	filters, err := sourcer.PlatformOperatorFilters(po)
+	if err != nil {
+		return b.Channel
+	}
+	var (
+		pipeline = sourcer.NewPipeline(filters...)
+		v        = p.variables[p.index[bundleKey(b)]]
+		selected string
+	)
+	for _, channel := range v.channels {
+		candidate := b
+		candidate.Channel = channel
+		if rejection, err := pipeline.Reject(candidate); err != nil || rejection != nil {
+			continue
+		}
+		if v.channelDeprecations[channel] == "" {
+			return channel
+		}
+		if selected == "" {
+			selected = channel
+		}
+	}
+	if selected != "" {
+		return selected
+	}
+	return b.Channel
+}
+
+// dependencies returns the selected bundles that the b bundle transitively
+// requires, ordered so every bundle comes after the bundles it requires.
+func dependencies(b sourcer.Bundle, selected map[string]sourcer.Bundle) ([]sourcer.Dependency, error) {
+	var (
+		ordered []sourcer.Dependency
+		visited = map[string]bool{b.Package: true}
+	)
+	var visit func(b sourcer.Bundle) error
+	visit = func(b sourcer.Bundle) error {
+		packages, gvks, err := b.Requirements()
+		if err != nil {
+			return err
+		}
+		type requirement struct {
+			dependency   sourcer.Bundle
+			versionRange string
+		}
+		var requirements []requirement
+		for _, required := range packages {
+			requirements = append(requirements, requirement{selected[required.PackageName], required.VersionRange})
+		}
+		for _, required := range gvks {
+			for _, candidate := range selected {
+				if candidate.Provides(property.GVK(required)) {
+					requirements = append(requirements, requirement{dependency: candidate})
+					break
+				}
+			}
+		}
+		for _, r := range requirements {
+			if visited[r.dependency.Package] {
+				continue
+			}
+			visited[r.dependency.Package] = true
+			if err := visit(r.dependency); err != nil {
+				return err
+			}
+			ordered = append(ordered, sourcer.Dependency{
+				Bundle:       r.dependency,
+				VersionRange: r.versionRange,
+				RequiredBy:   b.Name,
+			})
+		}
+		return nil
+	}
+	return ordered, visit(b)
+}
+
+// addAll registers the bundles as variables, merging the channels of bundles
+// that have already been registered. Bundles rejected by the exclusions
+// pipeline, e.g. because they're incompatible with the cluster or their
+// olm.constraint properties aren't satisfied, are excluded from being selected.
+func (p *problem) addAll(ctx context.Context, bundles []sourcer.Bundle) error {
+	for _, b := range bundles {
+		if v, ok := p.index[bundleKey(b)]; ok {
+			p.variables[v].channels = appendUnique(p.variables[v].channels, b.Channel)
+			p.variables[v].channelDeprecations[b.Channel] = b.Deprecation.Channel
+			continue
+		}
+		v := len(p.variables)
+		p.index[bundleKey(b)] = v
+		p.packages[b.Package] = append(p.packages[b.Package], v)
+		p.variables = append(p.variables, &variable{
+			bundle:              b,
+			channels:            []string{b.Channel},
+			channelDeprecations: map[string]string{b.Channel: b.Deprecation.Channel},
+		})
+
+		if p.installed[bundleKey(b)] {
+			continue
+		}
+		rejection, err := p.exclusions.Reject(b)
+		if err != nil {
+			return err
+		}
+		if rejection == nil {
+			continue
+		}
+		logr.FromContext(ctx).V(1).Info("excluding bundle", "bundle", b.Name, "filter", rejection.Filter, "reason", rejection.Reason)
+		p.excluded[bundleKey(b)] = *rejection
+		p.constraints = append(p.constraints, constraint{
+			description: fmt.Sprintf("the %s bundle can't be installed because %s", b.Name, rejection.Reason),
+			clauses:     []clause{{literal(-(v + 1))}},
+		})
+	}
+	return nil
+}
+
+// deprecated returns whether the catalog deprecated the v variable's package
+// or bundle, or every channel it's in.
+func (p *problem) deprecated(v int) bool {
+	variable := p.variables[v]
+	if variable.bundle.Deprecation.Package != "" || variable.bundle.Deprecation.Bundle != "" {
+		return true
+	}
+	for _, channel := range variable.channels {
+		if variable.channelDeprecations[channel] == "" {
+			return false
+		}
+	}
+	return true
+}
+
+// explainRejections returns why the po PlatformOperator's candidates that
+// weren't accepted by its filters, or that were accepted but are excluded,
+// can't be selected. A bundle that's in several channels is only reported
+// when none of its channels were accepted.
+func (p *problem) explainRejections(ctx context.Context, po *platformv1alpha1.PlatformOperator, accepted []sourcer.Bundle, rejected []sourcer.Rejection) []sourcer.Rejection {
+	reported := make(map[string]bool, len(accepted))
+	for _, b := range accepted {
+		reported[b.Name] = true
+	}
+	var rejections []sourcer.Rejection
+	for _, r := range rejected {
+		if reported[r.Bundle.Name] {
+			continue
+		}
+		reported[r.Bundle.Name] = true
+		logr.FromContext(ctx).V(1).Info("rejected candidate", "platformoperator", po.GetName(), "bundle", r.Bundle.Name, "filter", r.Filter, "reason", r.Reason)
+		rejections = append(rejections, r)
+	}
+	seen := make(map[string]bool, len(accepted))
+	for _, b := range accepted {
+		if r, ok := p.excluded[bundleKey(b)]; ok && !seen[b.Name] {
+			seen[b.Name] = true
+			rejections = append(rejections, r)
+		}
+	}
+	return rejections
+}
+
+// preferred orders the vars variables so the ones that aren't deprecated come
+// first, and then from the most to the least preferred by sourcer.Compare.
+func (p *problem) preferred(vars []int, deprecated func(v int) bool) []int {
+	sort.SliceStable(vars, func(i, j int) bool {
+		if di, dj := deprecated(vars[i]), deprecated(vars[j]); di != dj {
+			return dj
+		}
+		return sourcer.Compare(p.variables[vars[i]].bundle, p.variables[vars[j]].bundle) > 0
+	})
+	return vars
+}
+
+// allowed returns whether the b bundle can satisfy the requirements of the
+// bundles resolved for the po PlatformOperator, which only allows prerelease
+// versions when po opted into them. Installed bundles are always allowed.
+func (p *problem) allowed(po *platformv1alpha1.PlatformOperator, b sourcer.Bundle) bool {
+	if p.installed[bundleKey(b)] {
+		return true
+	}
+	rejection, err := sourcer.NewPipeline(sourcer.VersionPolicyFilter(sourcer.AllowsPrereleases(po))).Reject(b)
+	return err == nil && rejection == nil
+}
+
+// forPackage returns a copy of the po PlatformOperator that sources the
+// packageName package from the same catalog, without po's restrictions.
+func forPackage(po *platformv1alpha1.PlatformOperator, packageName string) *platformv1alpha1.PlatformOperator {
+	dependency := &platformv1alpha1.PlatformOperator{}
+	dependency.SetName(po.GetName())
+	dependency.SetAnnotations(po.GetAnnotations())
+	dependency.Spec.Package.Name = packageName
+	return dependency
+}
+
+func bundleKey(b sourcer.Bundle) string {
+	return b.Package + "/" + b.Name
+}
+
+func containsBundle(bundles []sourcer.Bundle, name string) bool {
+	for _, b := range bundles {
+		if b.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func providedGVKs(b sourcer.Bundle) []property.GVK {
+	props, err := property.Parse(b.Properties)
+	if err != nil {
+		return nil
+	}
+	return props.GVKs
+}
+
+func describeGVK(gvk property.GVK) string {
+	return fmt.Sprintf("%s/%s, Kind=%s API", gvk.Group, gvk.Version, gvk.Kind)
+}
+
+func inRange(r semver.Range, version string) bool {
+	v, err := semver.Parse(version)
+	if err != nil {
+		return false
+	}
+	return r(v)
+}
+
+func positive(vars []int) clause {
+	c := make(clause, 0, len(vars))
+	for _, v := range vars {
+		c = append(c, literal(v+1))
+	}
+	return c
+}
+
+// requires returns a clause that's satisfied when the v variable is false, or
+// one of the vars variables is true.
+func requires(v int, vars []int) clause {
+	return append(clause{literal(-(v + 1))}, positive(vars)...)
+}
+
+// newAuxiliary allocates a variable that doesn't stand for a bundle.
+func (p *problem) newAuxiliary() int {
+	p.auxiliary++
+	return len(p.variables) + p.auxiliary - 1
+}
+
+// atMostOne returns clauses that are satisfied when at most one of the vars
+// variables is true. It uses the sequential encoding, where the auxiliary
+// variable s[i] is true when one of vars[0..i] is, so the number of clauses
+// grows linearly rather than quadratically with the number of variables.
+func (p *problem) atMostOne(vars []int) []clause {
+	if len(vars) < 2 {
+		return nil
+	}
+	s := make([]int, len(vars)-1)
+	for i := range s {
+		s[i] = p.newAuxiliary()
+	}
+	clauses := []clause{requires(vars[0], []int{s[0]})}
+	for i := 1; i < len(vars)-1; i++ {
+		clauses = append(clauses,
+			requires(vars[i], []int{s[i]}),
+			requires(s[i-1], []int{s[i]}),
+			conflicts(vars[i], s[i-1]),
+		)
+	}
+	return append(clauses, conflicts(vars[len(vars)-1], s[len(s)-1]))
+}
+
+// conflicts returns a clause that's satisfied when the v and w variables
+// aren't both true.
+func conflicts(v, w int) clause {
+	return clause{literal(-(v + 1)), literal(-(w + 1))}
+}
+
+func uniq(vars []int) []int {
+	seen := make(map[int]bool, len(vars))
+	var out []int
+	for _, v := range vars {
+		if !seen[v] {
+			seen[v] = true
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func appendUnique(values []string, value string) []string {
+	for _, v := range values {
+		if v == value {
+			return values
+		}
+	}
+	return append(values, value)
+}
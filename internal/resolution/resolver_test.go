@@ -0,0 +1,371 @@
+package resolution
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	platformv1alpha1 "github.com/openshift/api/platform/v1alpha1"
+	platformtypes "github.com/openshift/platform-operators/api/v1alpha1"
+	"github.com/openshift/platform-operators/internal/clusterfacts"
+	"github.com/openshift/platform-operators/internal/sourcer"
+)
+
+func newTestPlatformOperator(packageName string, annotations map[string]string) platformv1alpha1.PlatformOperator {
+	return platformv1alpha1.PlatformOperator{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        packageName,
+			Annotations: annotations,
+		},
+		Spec: platformv1alpha1.PlatformOperatorSpec{
+			Package: platformv1alpha1.Package{Name: packageName},
+		},
+	}
+}
+
+func TestResolve(t *testing.T) {
+	tests := []struct {
+		name      string
+		pos       []platformv1alpha1.PlatformOperator
+		installed map[string]sourcer.Bundle
+		want      map[string]string
+		wantDeps  map[string][]string
+		// wantRejected lists the rejected candidates of each
+		// PlatformOperator, as <bundle>/<filter>.
+		wantRejected map[string][]string
+		// wantDeprecated holds the deprecation of the bundle selected for
+		// each PlatformOperator.
+		wantDeprecated map[string]sourcer.Deprecation
+		// wantUnresolvable holds the constraints explaining why each of the
+		// PlatformOperators left out of the resolution is unsatisfiable,
+		// or nil when it failed for another reason.
+		wantUnresolvable map[string][]string
+	}{
+		{
+			name: "NoDependencies",
+			pos: []platformv1alpha1.PlatformOperator{
+				newTestPlatformOperator("database", nil),
+			},
+			want: map[string]string{"database": "database.v2.0.0"},
+		},
+		{
+			name: "TransitiveDependenciesInstallOrder",
+			pos: []platformv1alpha1.PlatformOperator{
+				newTestPlatformOperator("app", nil),
+			},
+			want:     map[string]string{"app": "app.v1.0.0"},
+			wantDeps: map[string][]string{"app": {"storage.v0.1.0", "database.v1.5.0"}},
+		},
+		{
+			name: "SharedDependencyVersion",
+			pos: []platformv1alpha1.PlatformOperator{
+				newTestPlatformOperator("app", nil),
+				newTestPlatformOperator("database", nil),
+			},
+			want: map[string]string{
+				"app":      "app.v1.0.0",
+				"database": "database.v1.5.0",
+			},
+			wantDeps: map[string][]string{
+				"app":      {"storage.v0.1.0", "database.v1.5.0"},
+				"database": {"storage.v0.1.0"},
+			},
+		},
+		{
+			name: "VersionRangeRejections",
+			pos: []platformv1alpha1.PlatformOperator{
+				newTestPlatformOperator("database", map[string]string{platformtypes.AnnotationVersionRange: "<2.0.0"}),
+			},
+			want:         map[string]string{"database": "database.v1.5.0"},
+			wantDeps:     map[string][]string{"database": {"storage.v0.1.0"}},
+			wantRejected: map[string][]string{"database": {"database.v2.0.0/range"}},
+		},
+		{
+			name: "AvoidsDeprecatedChannelsAndBundles",
+			pos: []platformv1alpha1.PlatformOperator{
+				newTestPlatformOperator("retired", nil),
+			},
+			want:           map[string]string{"retired": "retired.v1.0.0"},
+			wantDeprecated: map[string]sourcer.Deprecation{"retired": {}},
+		},
+		{
+			name: "DeprecatedChannelWithoutAlternative",
+			pos: []platformv1alpha1.PlatformOperator{
+				newTestPlatformOperator("retired", map[string]string{platformtypes.AnnotationChannel: "fast"}),
+			},
+			want:           map[string]string{"retired": "retired.v2.0.0"},
+			wantRejected:   map[string][]string{"retired": {"retired.v1.5.0/channel"}},
+			wantDeprecated: map[string]sourcer.Deprecation{"retired": {Channel: "use the stable channel"}},
+		},
+		{
+			name: "PrereleasesRejected",
+			pos: []platformv1alpha1.PlatformOperator{
+				newTestPlatformOperator("preview", nil),
+			},
+			want:         map[string]string{"preview": "preview.v1.0.0"},
+			wantRejected: map[string][]string{"preview": {"preview.v1.1.0-rc.1/policy"}},
+		},
+		{
+			name: "PrereleasesAllowed",
+			pos: []platformv1alpha1.PlatformOperator{
+				newTestPlatformOperator("preview", map[string]string{platformtypes.AnnotationAllowPrereleases: "true"}),
+			},
+			want: map[string]string{"preview": "preview.v1.1.0-rc.1"},
+		},
+		{
+			name: "PinnedInstalledBundle",
+			pos: []platformv1alpha1.PlatformOperator{
+				newTestPlatformOperator("database", nil),
+			},
+			installed: map[string]sourcer.Bundle{"database": {Name: "database.v1.0.0", Package: "database", Version: "1.0.0"}},
+			want:      map[string]string{"database": "database.v1.0.0"},
+		},
+		{
+			name: "CycleBackToRoot",
+			pos: []platformv1alpha1.PlatformOperator{
+				newTestPlatformOperator("cyclic", nil),
+			},
+			want:     map[string]string{"cyclic": "cyclic.v1.0.0"},
+			wantDeps: map[string][]string{"cyclic": {"loop.v1.0.0"}},
+		},
+		{
+			name: "ConflictingVersionRanges",
+			pos: []platformv1alpha1.PlatformOperator{
+				newTestPlatformOperator("app", nil),
+				newTestPlatformOperator("database", map[string]string{platformtypes.AnnotationVersionRange: ">=2.0.0"}),
+			},
+			want:     map[string]string{"app": "app.v1.0.0"},
+			wantDeps: map[string][]string{"app": {"storage.v0.1.0", "database.v1.5.0"}},
+			wantUnresolvable: map[string][]string{"database": {
+				"the app PlatformOperator requires a bundle from the app package",
+				"the database PlatformOperator requires a bundle from the database package that matches its channel, version range and version policy",
+				`the app.v1.0.0 bundle requires the database package in the ">=1.0.0 <2.0.0" range`,
+				"only one bundle from the database package can be installed",
+			}},
+		},
+		{
+			name: "ConflictingAPIProviders",
+			pos: []platformv1alpha1.PlatformOperator{
+				newTestPlatformOperator("altcache", nil),
+				newTestPlatformOperator("storage", nil),
+			},
+			want: map[string]string{"altcache": "altcache.v1.0.0"},
+			wantUnresolvable: map[string][]string{"storage": {
+				"the altcache PlatformOperator requires a bundle from the altcache package",
+				"the storage PlatformOperator requires a bundle from the storage package",
+				"only one package can provide the cache.example.com/v1, Kind=Cache API",
+			}},
+		},
+		{
+			name: "MissingDependencyVersion",
+			pos: []platformv1alpha1.PlatformOperator{
+				newTestPlatformOperator("broken", nil),
+			},
+			wantUnresolvable: map[string][]string{"broken": {
+				"the broken PlatformOperator requires a bundle from the broken package",
+				`the broken.v1.0.0 bundle requires the database package in the ">=3.0.0" range`,
+			}},
+		},
+		{
+			name: "UnknownPackageLeftOut",
+			pos: []platformv1alpha1.PlatformOperator{
+				newTestPlatformOperator("database", nil),
+				newTestPlatformOperator("unknown", nil),
+			},
+			want: map[string]string{"database": "database.v2.0.0"},
+			wantUnresolvable: map[string][]string{"unknown": {
+				"the unknown PlatformOperator requires a bundle from the unknown package",
+			}},
+		},
+		{
+			name: "InvalidAnnotationLeftOut",
+			pos: []platformv1alpha1.PlatformOperator{
+				newTestPlatformOperator("app", map[string]string{platformtypes.AnnotationVersionRange: "not-a-range"}),
+				newTestPlatformOperator("database", nil),
+			},
+			want:             map[string]string{"database": "database.v2.0.0"},
+			wantUnresolvable: map[string][]string{"app": nil},
+		},
+		{
+			name: "ConstraintsExcludeCandidates",
+			pos: []platformv1alpha1.PlatformOperator{
+				newTestPlatformOperator("modern", nil),
+			},
+			want:         map[string]string{"modern": "modern.v1.5.0"},
+			wantRejected: map[string][]string{"modern": {"modern.v2.0.0/constraints"}},
+		},
+		{
+			name: "CompoundNotConstraint",
+			pos: []platformv1alpha1.PlatformOperator{
+				newTestPlatformOperator("modern", nil),
+			},
+			installed:    map[string]sourcer.Bundle{"legacy": {Name: "legacy.v0.1.0", Package: "legacy", Version: "0.1.0"}},
+			want:         map[string]string{"modern": "modern.v1.0.0"},
+			wantRejected: map[string][]string{"modern": {"modern.v1.5.0/constraints", "modern.v2.0.0/constraints"}},
+		},
+		{
+			name: "UnsatisfiedConstraintExplained",
+			pos: []platformv1alpha1.PlatformOperator{
+				newTestPlatformOperator("edge", nil),
+			},
+			wantUnresolvable: map[string][]string{"edge": {
+				"the edge PlatformOperator requires a bundle from the edge package",
+				`the edge.v1.0.0 bundle can't be installed because the cluster doesn't satisfy any of [the "properties.exists(p, p.type == \"cluster.architecture\" && p.value == \"arm64\")" rule, the edge.example.com/v1, Kind=Gateway API being served]`,
+			}},
+		},
+	}
+	facts := clusterfacts.Static(clusterfacts.Facts{
+		OpenShiftVersion:  "4.14.0",
+		KubernetesVersion: "1.27.0",
+		Architectures:     []string{"amd64"},
+		GVKs:              []schema.GroupVersionKind{{Group: "apps", Version: "v1", Kind: "Deployment"}},
+	})
+	r := NewResolver(sourcer.NewFileBasedCatalogHandler("testdata/catalog"), facts, false)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			solution, err := r.Resolve(context.Background(), tt.pos, tt.installed)
+			if err != nil {
+				t.Fatalf("Resolve() unexpected error: %v", err)
+			}
+			for name, wantUnsat := range tt.wantUnresolvable {
+				err := solution.Unresolvable[name]
+				if err == nil {
+					t.Errorf("Resolve() resolved %s, want it left out", name)
+					continue
+				}
+				if _, ok := solution.Selections[name]; ok {
+					t.Errorf("Resolve() selected a bundle for the unresolvable %s", name)
+				}
+				var unsat *UnsatisfiableError
+				if !errors.As(err, &unsat) {
+					if wantUnsat != nil {
+						t.Errorf("Resolve() error for %s = %v, want an UnsatisfiableError", name, err)
+					}
+					continue
+				}
+				if !errors.Is(err, ErrUnsatisfiable) {
+					t.Errorf("Resolve() error for %s = %v, want it to wrap ErrUnsatisfiable", name, err)
+				}
+				got, want := append([]string(nil), unsat.Constraints...), append([]string(nil), wantUnsat...)
+				sort.Strings(got)
+				sort.Strings(want)
+				if !reflect.DeepEqual(got, want) {
+					t.Errorf("Resolve() explanation for %s = %q, want %q", name, got, want)
+				}
+			}
+			if len(solution.Unresolvable) != len(tt.wantUnresolvable) {
+				t.Errorf("Resolve() left out %d PlatformOperators, want %d", len(solution.Unresolvable), len(tt.wantUnresolvable))
+			}
+			for name, want := range tt.want {
+				selection := solution.Selections[name]
+				if selection.Bundle.Name != want {
+					t.Errorf("Resolve() selected %s for %s, want %s", selection.Bundle.Name, name, want)
+				}
+				var gotDeps []string
+				for _, dep := range selection.Dependencies {
+					gotDeps = append(gotDeps, dep.Bundle.Name)
+				}
+				if !reflect.DeepEqual(gotDeps, tt.wantDeps[name]) {
+					t.Errorf("Resolve() dependencies of %s = %v, want %v", name, gotDeps, tt.wantDeps[name])
+				}
+				var gotRejected []string
+				for _, r := range selection.Rejections {
+					gotRejected = append(gotRejected, r.Bundle.Name+"/"+r.Filter)
+				}
+				if !reflect.DeepEqual(gotRejected, tt.wantRejected[name]) {
+					t.Errorf("Resolve() rejections of %s = %v, want %v", name, gotRejected, tt.wantRejected[name])
+				}
+				if want, ok := tt.wantDeprecated[name]; ok && selection.Bundle.Deprecation != want {
+					t.Errorf("Resolve() deprecation of %s = %+v, want %+v", name, selection.Bundle.Deprecation, want)
+				}
+			}
+		})
+	}
+}
+
+func TestResolveUpgrade(t *testing.T) {
+	var (
+		database = sourcer.Bundle{Name: "database.v1.0.0", Package: "database", Version: "1.0.0"}
+		app      = sourcer.Bundle{Name: "app.v1.0.0", Package: "app", Version: "1.0.0"}
+		updates  = []sourcer.Bundle{{Name: "database.v1.5.0"}, {Name: "database.v2.0.0"}}
+	)
+	tests := []struct {
+		name      string
+		pos       []platformv1alpha1.PlatformOperator
+		installed map[string]sourcer.Bundle
+		updates   []sourcer.Bundle
+		want      string
+		wantDeps  []string
+	}{
+		{
+			name:      "PreferredUpdate",
+			pos:       []platformv1alpha1.PlatformOperator{newTestPlatformOperator("database", nil)},
+			installed: map[string]sourcer.Bundle{"database": database},
+			updates:   updates,
+			want:      "database.v2.0.0",
+		},
+		{
+			// app's installed bundle requires a database below 2.0.0.
+			name: "HeldBackByInstalledBundles",
+			pos: []platformv1alpha1.PlatformOperator{
+				newTestPlatformOperator("app", nil),
+				newTestPlatformOperator("database", nil),
+			},
+			installed: map[string]sourcer.Bundle{"app": app, "database": database},
+			updates:   updates,
+			want:      "database.v1.5.0",
+			wantDeps:  []string{"storage.v0.1.0"},
+		},
+		{
+			name: "NoResolvableUpdate",
+			pos: []platformv1alpha1.PlatformOperator{
+				newTestPlatformOperator("app", nil),
+				newTestPlatformOperator("database", nil),
+			},
+			installed: map[string]sourcer.Bundle{"app": app, "database": database},
+			updates:   updates[1:],
+		},
+	}
+	facts := clusterfacts.Static(clusterfacts.Facts{
+		OpenShiftVersion:  "4.14.0",
+		KubernetesVersion: "1.27.0",
+		Architectures:     []string{"amd64"},
+	})
+	r := NewResolver(sourcer.NewFileBasedCatalogHandler("testdata/catalog"), facts, false)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			solution, err := r.ResolveUpgrade(context.Background(), tt.pos, tt.installed, "database", tt.updates)
+			if err != nil {
+				t.Fatalf("ResolveUpgrade() unexpected error: %v", err)
+			}
+			if tt.want == "" {
+				if err := solution.Unresolvable["database"]; !errors.Is(err, ErrUnsatisfiable) {
+					t.Errorf("ResolveUpgrade() error for database = %v, want it to wrap ErrUnsatisfiable", err)
+				}
+				if got := solution.Selections["app"].Bundle.Name; got != app.Name {
+					t.Errorf("ResolveUpgrade() selected %s for app, want its installed %s", got, app.Name)
+				}
+				return
+			}
+			selection, ok := solution.Selections["database"]
+			if !ok {
+				t.Fatalf("ResolveUpgrade() left database out: %v", solution.Unresolvable["database"])
+			}
+			if selection.Bundle.Name != tt.want {
+				t.Errorf("ResolveUpgrade() selected %s, want %s", selection.Bundle.Name, tt.want)
+			}
+			var gotDeps []string
+			for _, dep := range selection.Dependencies {
+				gotDeps = append(gotDeps, dep.Bundle.Name)
+			}
+			if !reflect.DeepEqual(gotDeps, tt.wantDeps) {
+				t.Errorf("ResolveUpgrade() dependencies = %v, want %v", gotDeps, tt.wantDeps)
+			}
+		})
+	}
+}
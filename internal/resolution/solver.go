@@ -0,0 +1,243 @@
+package resolution
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// literal refers to a variable by its 1-based index, and is negative when the
+// variable has to be false for the literal to be satisfied.
+type literal int
+
+func (l literal) variable() int {
+	if l < 0 {
+		return int(-l) - 1
+	}
+	return int(l) - 1
+}
+
+// clause is satisfied when at least one of its literals is satisfied.
+type clause []literal
+
+// constraint is a set of clauses that's explained by a single sentence, and is
+// the unit that unsatisfiable problems are explained in.
+type constraint struct {
+	description string
+	clauses     []clause
+	// owner is the name of the PlatformOperator that the constraint requires
+	// a bundle for, and is only set on mandatory constraints.
+	owner string
+}
+
+const (
+	unassigned  int8 = 0
+	assignTrue  int8 = 1
+	assignFalse int8 = -1
+)
+
+const (
+	// maxDecisions and maxDuration bound the work done by the solves of a
+	// single resolution, including explaining why it's unsatisfiable, so a
+	// problem that's too large fails the resolution instead of blocking the
+	// reconciler.
+	maxDecisions = 10000
+	maxDuration  = 10 * time.Second
+)
+
+// budget is the work that the solves of a single resolution have left.
+type budget struct {
+	ctx       context.Context
+	decisions int
+	deadline  time.Time
+}
+
+func newBudget(ctx context.Context) *budget {
+	return &budget{ctx: ctx, decisions: maxDecisions, deadline: time.Now().Add(maxDuration)}
+}
+
+// spend takes a decision from the budget, and returns an error wrapping
+// ErrBudgetExceeded when there's none left, or the resolution has run out of
+// time.
+func (b *budget) spend() error {
+	if err := b.ctx.Err(); err != nil {
+		return err
+	}
+	if b.decisions == 0 {
+		return fmt.Errorf("%w: made %d decisions without finding a solution", ErrBudgetExceeded, maxDecisions)
+	}
+	if time.Now().After(b.deadline) {
+		return fmt.Errorf("%w: didn't find a solution within %s", ErrBudgetExceeded, maxDuration)
+	}
+	b.decisions--
+	return nil
+}
+
+// solver is a DPLL SAT solver whose decisions are driven by the order of the
+// clauses and of the literals within them, rather than by a heuristic. Callers
+// express preferences by ordering the positive literals of a clause from most
+// to least preferred, and a variable is only ever decided true to satisfy a
+// clause whose other obligations are already true. As a result, variables
+// that nothing requires are left false, and the solution is deterministic.
+type solver struct {
+	variables int
+	clauses   []clause
+	budget    *budget
+}
+
+func newSolver(variables int, constraints []constraint, b *budget) *solver {
+	s := &solver{variables: variables, budget: b}
+	for _, c := range constraints {
+		s.clauses = append(s.clauses, c.clauses...)
+	}
+	return s
+}
+
+// solve returns the value of every variable in a satisfying assignment, and
+// false when no such assignment exists. An error is returned when the budget
+// runs out before either is known.
+func (s *solver) solve() ([]bool, bool, error) {
+	assignment := make([]int8, s.variables)
+	ok, err := s.search(assignment)
+	if err != nil || !ok {
+		return nil, false, err
+	}
+	values := make([]bool, s.variables)
+	for i, value := range assignment {
+		values[i] = value == assignTrue
+	}
+	return values, true, nil
+}
+
+func (s *solver) search(assignment []int8) (bool, error) {
+	if !s.propagate(assignment) {
+		return false, nil
+	}
+	v, ok := s.decide(assignment)
+	if !ok {
+		// every remaining clause can be satisfied by leaving the variables
+		// that haven't been decided false.
+		for i := range assignment {
+			if assignment[i] == unassigned {
+				assignment[i] = assignFalse
+			}
+		}
+		return true, nil
+	}
+	if err := s.budget.spend(); err != nil {
+		return false, err
+	}
+	for _, value := range []int8{assignTrue, assignFalse} {
+		next := append([]int8(nil), assignment...)
+		next[v] = value
+		ok, err := s.search(next)
+		if err != nil {
+			return false, err
+		}
+		if ok {
+			copy(assignment, next)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// propagate repeatedly assigns the last unassigned literal of otherwise
+// unsatisfied clauses, and returns false when a clause can't be satisfied.
+func (s *solver) propagate(assignment []int8) bool {
+	for changed := true; changed; {
+		changed = false
+		for _, c := range s.clauses {
+			var (
+				satisfied bool
+				open      []literal
+			)
+			for _, l := range c {
+				switch value(assignment, l) {
+				case assignTrue:
+					satisfied = true
+				case unassigned:
+					open = append(open, l)
+				}
+			}
+			if satisfied {
+				continue
+			}
+			switch len(open) {
+			case 0:
+				return false
+			case 1:
+				assign(assignment, open[0])
+				changed = true
+			}
+		}
+	}
+	return true
+}
+
+// decide returns the first unassigned positive literal of the first
+// unsatisfied clause whose negative literals are all false, i.e. the most
+// preferred way of satisfying an obligation that's already in force.
+func (s *solver) decide(assignment []int8) (int, bool) {
+	for _, c := range s.clauses {
+		var (
+			satisfied bool
+			pending   bool
+			decision  = -1
+		)
+		for _, l := range c {
+			switch value(assignment, l) {
+			case assignTrue:
+				satisfied = true
+			case unassigned:
+				if l < 0 {
+					pending = true
+				} else if decision < 0 {
+					decision = l.variable()
+				}
+			}
+		}
+		if satisfied || pending || decision < 0 {
+			continue
+		}
+		return decision, true
+	}
+	return 0, false
+}
+
+// value returns whether the l literal is satisfied by the assignment.
+func value(assignment []int8, l literal) int8 {
+	v := assignment[l.variable()]
+	if l < 0 {
+		return -v
+	}
+	return v
+}
+
+func assign(assignment []int8, l literal) {
+	if l < 0 {
+		assignment[l.variable()] = assignFalse
+		return
+	}
+	assignment[l.variable()] = assignTrue
+}
+
+// explain returns a minimal subset of the unsatisfiable constraints that's
+// still unsatisfiable, by dropping every constraint that the problem remains
+// unsatisfiable without.
+func explain(variables int, constraints []constraint, b *budget) ([]constraint, error) {
+	core := append([]constraint(nil), constraints...)
+	for i := 0; i < len(core); {
+		without := append(append([]constraint(nil), core[:i]...), core[i+1:]...)
+		_, ok, err := newSolver(variables, without, b).solve()
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			core = without
+			continue
+		}
+		i++
+	}
+	return core, nil
+}
@@ -0,0 +1,139 @@
+package resolution
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSolver(t *testing.T) {
+	tests := []struct {
+		name        string
+		variables   int
+		constraints []constraint
+		want        []bool
+		wantUnsat   bool
+	}{
+		{
+			name:      "PrefersEarlierLiterals",
+			variables: 2,
+			constraints: []constraint{
+				{clauses: []clause{{1, 2}}},
+			},
+			want: []bool{true, false},
+		},
+		{
+			name:      "LeavesUnrequiredVariablesFalse",
+			variables: 3,
+			constraints: []constraint{
+				{clauses: []clause{{1}}},
+				{clauses: []clause{{-2, 3}}},
+			},
+			want: []bool{true, false, false},
+		},
+		{
+			name:      "BacktracksFromPreferredLiteral",
+			variables: 3,
+			constraints: []constraint{
+				{clauses: []clause{{1, 2}}},
+				{clauses: []clause{{-1, 3}}},
+				{clauses: []clause{{-3}}},
+			},
+			want: []bool{false, true, false},
+		},
+		{
+			name:      "Unsatisfiable",
+			variables: 2,
+			constraints: []constraint{
+				{clauses: []clause{{1}}},
+				{clauses: []clause{{2}}},
+				{clauses: []clause{{-1, -2}}},
+			},
+			wantUnsat: true,
+		},
+		{
+			name:      "EmptyClause",
+			variables: 1,
+			constraints: []constraint{
+				{clauses: []clause{{}}},
+			},
+			wantUnsat: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok, err := newSolver(tt.variables, tt.constraints, newBudget(context.Background())).solve()
+			if err != nil {
+				t.Fatalf("solve() unexpected error: %v", err)
+			}
+			if ok == tt.wantUnsat {
+				t.Fatalf("solve() satisfiable = %v, want %v", ok, !tt.wantUnsat)
+			}
+			if !reflect.DeepEqual(got, tt.want) && !tt.wantUnsat {
+				t.Errorf("solve() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExplain(t *testing.T) {
+	constraints := []constraint{
+		{description: "a", clauses: []clause{{1}}},
+		{description: "unrelated", clauses: []clause{{3, 4}}},
+		{description: "b", clauses: []clause{{2}}},
+		{description: "a conflicts with b", clauses: []clause{{-1, -2}}},
+	}
+	core, err := explain(4, constraints, newBudget(context.Background()))
+	if err != nil {
+		t.Fatalf("explain() unexpected error: %v", err)
+	}
+	var got []string
+	for _, c := range core {
+		got = append(got, c.description)
+	}
+	want := []string{"a", "b", "a conflicts with b"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("explain() = %v, want %v", got, want)
+	}
+}
+
+func TestSolverBudget(t *testing.T) {
+	// each of the first clauses takes a decision, and the first decision
+	// has to be taken back.
+	constraints := []constraint{
+		{clauses: []clause{{1, 2}, {3, 4}, {5, 6}, {7, 8}}},
+		{clauses: []clause{{-1, -7}, {-1, -8}}},
+	}
+	b := &budget{ctx: context.Background(), decisions: 2, deadline: time.Now().Add(time.Minute)}
+	if _, _, err := newSolver(8, constraints, b).solve(); !errors.Is(err, ErrBudgetExceeded) {
+		t.Errorf("solve() error = %v, want it to wrap ErrBudgetExceeded", err)
+	}
+
+	b = &budget{ctx: context.Background(), decisions: maxDecisions, deadline: time.Now()}
+	if _, _, err := newSolver(8, constraints, b).solve(); !errors.Is(err, ErrBudgetExceeded) {
+		t.Errorf("solve() past the deadline error = %v, want it to wrap ErrBudgetExceeded", err)
+	}
+}
+
+func TestAtMostOne(t *testing.T) {
+	p := &problem{variables: make([]*variable, 4)}
+	amo := constraint{clauses: p.atMostOne([]int{0, 1, 2, 3})}
+	for i := 0; i < 4; i++ {
+		for j := i + 1; j < 4; j++ {
+			both := constraint{clauses: []clause{{literal(i + 1)}, {literal(j + 1)}}}
+			_, ok, err := newSolver(4+p.auxiliary, []constraint{amo, both}, newBudget(context.Background())).solve()
+			if err != nil {
+				t.Fatalf("solve() unexpected error: %v", err)
+			}
+			if ok {
+				t.Errorf("atMostOne() allowed variables %d and %d to be selected together", i, j)
+			}
+		}
+		one := constraint{clauses: []clause{{literal(i + 1)}}}
+		if _, ok, _ := newSolver(4+p.auxiliary, []constraint{amo, one}, newBudget(context.Background())).solve(); !ok {
+			t.Errorf("atMostOne() didn't allow variable %d to be selected alone", i)
+		}
+	}
+}
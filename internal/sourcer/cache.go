@@ -0,0 +1,151 @@
+package sourcer
+
+import (
+	"context"
+	"sync"
+
+	operatorsv1alpha1 "github.com/operator-framework/api/pkg/operators/v1alpha1"
+	"github.com/operator-framework/operator-registry/alpha/property"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// catalogIndex is a snapshot of a catalog's contents, keyed by package name
+// and then by channel name.
+type catalogIndex map[string]map[string]bundles
+
+func (idx catalogIndex) add(b Bundle) {
+	channels, ok := idx[b.Package]
+	if !ok {
+		channels = make(map[string]bundles)
+		idx[b.Package] = channels
+	}
+	channels[b.Channel] = append(channels[b.Channel], b)
+}
+
+// Candidates returns every bundle in the packageName package, across all
+// of its channels.
+func (idx catalogIndex) Candidates(packageName string) bundles {
+	var candidates bundles
+	for _, channel := range idx[packageName] {
+		candidates = append(candidates, channel...)
+	}
+	return candidates
+}
+
+// Providers returns every bundle, across all packages, that provides the gvk API.
+func (idx catalogIndex) Providers(gvk property.GVK) bundles {
+	var providers bundles
+	for _, channels := range idx {
+		for _, channel := range channels {
+			for _, b := range channel {
+				if b.Provides(gvk) {
+					providers = append(providers, b)
+				}
+			}
+		}
+	}
+	return providers
+}
+
+// indexFetchFunc is responsible for building a fresh catalogIndex from the
+// contents being served by a catalog.
+type indexFetchFunc func(ctx context.Context) (catalogIndex, error)
+
+// cacheEntry tracks the catalogIndex that was built for a particular catalog
+// snapshot. The ready channel is closed once the fetch has completed, which
+// allows concurrent callers to share a single in-flight fetch.
+type cacheEntry struct {
+	key   string
+	ready chan struct{}
+	index catalogIndex
+	err   error
+}
+
+// catalogCache stores a catalogIndex per catalog, and only rebuilds that
+// index when the key identifying the catalog's current contents changes,
+// e.g. a CatalogSource reporting a new address or a new registry poll.
+type catalogCache struct {
+	mu      sync.Mutex
+	entries map[types.NamespacedName]*cacheEntry
+}
+
+func newCatalogCache() *catalogCache {
+	return &catalogCache{
+		entries: make(map[types.NamespacedName]*cacheEntry),
+	}
+}
+
+// Get returns the catalogIndex for the nn catalog, calling fetch to build the
+// catalogIndex when the cache is empty or was populated under a different key.
+func (c *catalogCache) Get(ctx context.Context, nn types.NamespacedName, key string, fetch indexFetchFunc) (catalogIndex, error) {
+	c.mu.Lock()
+	entry, ok := c.entries[nn]
+	if !ok || entry.key != key {
+		entry = &cacheEntry{
+			key:   key,
+			ready: make(chan struct{}),
+		}
+		c.entries[nn] = entry
+		go c.populate(nn, entry, fetch)
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-entry.ready:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return entry.index, entry.err
+}
+
+// Evict removes the nn catalog's index, e.g. once the catalog is deleted.
+func (c *catalogCache) Evict(nn types.NamespacedName) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, nn)
+}
+
+// Retain removes the index of every catalog that isn't in live, e.g. the
+// catalogs that still exist on the cluster.
+func (c *catalogCache) Retain(live map[types.NamespacedName]struct{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for nn := range c.entries {
+		if _, ok := live[nn]; !ok {
+			delete(c.entries, nn)
+		}
+	}
+}
+
+// populate runs the fetch for the entry outside of any caller's context so an
+// individual reconcile being cancelled doesn't fail the fetch for every other
+// caller waiting on the same entry. Failed fetches are evicted so the next
+// caller retries instead of observing a cached error.
+func (c *catalogCache) populate(nn types.NamespacedName, entry *cacheEntry, fetch indexFetchFunc) {
+	defer close(entry.ready)
+
+	entry.index, entry.err = fetch(context.Background())
+	if entry.err == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries[nn] == entry {
+		delete(c.entries, nn)
+	}
+}
+
+// catalogSourceCacheKey identifies a snapshot of the catalog contents served by cs.
+func catalogSourceCacheKey(cs operatorsv1alpha1.CatalogSource) string {
+	var (
+		address  string
+		lastPoll string
+	)
+	if cs.Status.GRPCConnectionState != nil {
+		address = cs.Status.GRPCConnectionState.Address
+	}
+	if cs.Status.LatestImageRegistryPoll != nil {
+		lastPoll = cs.Status.LatestImageRegistryPoll.UTC().String()
+	}
+	return address + "|" + lastPoll
+}
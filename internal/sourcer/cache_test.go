@@ -0,0 +1,171 @@
+package sourcer
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	operatorsv1alpha1 "github.com/operator-framework/api/pkg/operators/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func newTestCatalogSource(address string, lastPoll *metav1.Time) operatorsv1alpha1.CatalogSource {
+	return operatorsv1alpha1.CatalogSource{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      catalogName,
+			Namespace: catalogNamespace,
+		},
+		Status: operatorsv1alpha1.CatalogSourceStatus{
+			GRPCConnectionState: &operatorsv1alpha1.GRPCConnectionState{
+				Address:           address,
+				LastObservedState: "READY",
+			},
+			LatestImageRegistryPoll: lastPoll,
+		},
+	}
+}
+
+func TestCatalogCacheSharesInFlightFetch(t *testing.T) {
+	var (
+		calls   int32
+		release = make(chan struct{})
+	)
+	fetch := func(_ context.Context) (catalogIndex, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		index := make(catalogIndex)
+		index.add(Bundle{Package: "foo", Channel: "stable", Version: "1.0.0"})
+		return index, nil
+	}
+	cache := newCatalogCache()
+	cs := newTestCatalogSource("10.0.0.1:50051", nil)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			index, err := cache.Get(context.Background(), client.ObjectKeyFromObject(&cs), catalogSourceCacheKey(cs), fetch)
+			if err != nil {
+				t.Errorf("Get() unexpected error: %v", err)
+				return
+			}
+			if got := len(index.Candidates("foo")); got != 1 {
+				t.Errorf("Candidates() = %d bundles, want 1", got)
+			}
+		}()
+	}
+	// give the goroutines a chance to block on the in-flight fetch
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if calls != 1 {
+		t.Errorf("fetch was called %d times, want 1", calls)
+	}
+}
+
+func TestCatalogCacheInvalidation(t *testing.T) {
+	var calls int
+	fetch := func(_ context.Context) (catalogIndex, error) {
+		calls++
+		return make(catalogIndex), nil
+	}
+	cache := newCatalogCache()
+	poll := metav1.NewTime(time.Unix(1000, 0))
+	newPoll := metav1.NewTime(time.Unix(2000, 0))
+
+	tests := []struct {
+		name      string
+		cs        operatorsv1alpha1.CatalogSource
+		wantCalls int
+	}{
+		{
+			name:      "InitialFetch",
+			cs:        newTestCatalogSource("10.0.0.1:50051", &poll),
+			wantCalls: 1,
+		},
+		{
+			name:      "UnchangedCatalog",
+			cs:        newTestCatalogSource("10.0.0.1:50051", &poll),
+			wantCalls: 1,
+		},
+		{
+			name:      "NewRegistryPoll",
+			cs:        newTestCatalogSource("10.0.0.1:50051", &newPoll),
+			wantCalls: 2,
+		},
+		{
+			name:      "NewAddress",
+			cs:        newTestCatalogSource("10.0.0.2:50051", &newPoll),
+			wantCalls: 3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := cache.Get(context.Background(), client.ObjectKeyFromObject(&tt.cs), catalogSourceCacheKey(tt.cs), fetch); err != nil {
+				t.Fatalf("Get() unexpected error: %v", err)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("fetch was called %d times, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestCatalogCacheRetriesFailedFetch(t *testing.T) {
+	var calls int
+	fetch := func(_ context.Context) (catalogIndex, error) {
+		calls++
+		if calls == 1 {
+			return nil, errors.New("connection refused")
+		}
+		return make(catalogIndex), nil
+	}
+	cache := newCatalogCache()
+	cs := newTestCatalogSource("10.0.0.1:50051", nil)
+	nn := client.ObjectKeyFromObject(&cs)
+
+	if _, err := cache.Get(context.Background(), nn, catalogSourceCacheKey(cs), fetch); err == nil {
+		t.Fatalf("Get() expected an error on the first fetch")
+	}
+	if _, err := cache.Get(context.Background(), nn, catalogSourceCacheKey(cs), fetch); err != nil {
+		t.Fatalf("Get() unexpected error: %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("fetch was called %d times, want 2", calls)
+	}
+}
+
+func TestCatalogCacheEviction(t *testing.T) {
+	var calls int
+	fetch := func(_ context.Context) (catalogIndex, error) {
+		calls++
+		return make(catalogIndex), nil
+	}
+	cache := newCatalogCache()
+	foo := types.NamespacedName{Name: "foo"}
+	bar := types.NamespacedName{Name: "bar"}
+	for _, nn := range []types.NamespacedName{foo, bar} {
+		if _, err := cache.Get(context.Background(), nn, "key", fetch); err != nil {
+			t.Fatalf("Get() unexpected error: %v", err)
+		}
+	}
+
+	cache.Evict(foo)
+	cache.Retain(map[types.NamespacedName]struct{}{foo: {}})
+	if len(cache.entries) != 0 {
+		t.Errorf("entries = %v, want none after evicting foo and retaining only foo", cache.entries)
+	}
+	if _, err := cache.Get(context.Background(), foo, "key", fetch); err != nil {
+		t.Fatalf("Get() unexpected error: %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("fetch was called %d times, want 3", calls)
+	}
+}
@@ -0,0 +1,238 @@
+package sourcer
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/operator-framework/operator-registry/alpha/declcfg"
+	"github.com/operator-framework/operator-registry/alpha/property"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	platformv1alpha1 "github.com/openshift/api/platform/v1alpha1"
+	platformtypes "github.com/openshift/platform-operators/api/v1alpha1"
+)
+
+var (
+	// ClusterCatalogGVK and ClusterCatalogListGVK are the catalogd
+	// ClusterCatalog types. The catalogd API isn't vendored, so ClusterCatalogs
+	// are read as unstructured objects.
+	ClusterCatalogGVK = schema.GroupVersionKind{
+		Group:   "olm.operatorframework.io",
+		Version: "v1",
+		Kind:    "ClusterCatalog",
+	}
+	ClusterCatalogListGVK = schema.GroupVersionKind{
+		Group:   "olm.operatorframework.io",
+		Version: "v1",
+		Kind:    "ClusterCatalogList",
+	}
+)
+
+const (
+	clusterCatalogTypeServing = "Serving"
+	clusterCatalogContentPath = "api/v1/all"
+)
+
+// clusterCatalog sources bundles from the catalog content that catalogd
+// serves over HTTP for each ClusterCatalog.
+type clusterCatalog struct {
+	client.Client
+	httpClient *http.Client
+	cache      *catalogCache
+}
+
+// NewClusterCatalogHandler returns a Sourcer that resolves bundles from the
+// ClusterCatalogs that are currently being served by catalogd. The httpClient
+// is used to stream catalog content, and must trust catalogd's serving
+// certificate.
+func NewClusterCatalogHandler(c client.Client, httpClient *http.Client) CatalogSet {
+	return &clusterCatalog{
+		Client:     c,
+		httpClient: httpClient,
+		cache:      newCatalogCache(),
+	}
+}
+
+// HasCatalog reports whether the name ClusterCatalog exists, whether or not
+// it's being served yet.
+func (cc *clusterCatalog) HasCatalog(ctx context.Context, name string) (bool, error) {
+	catalog := &unstructured.Unstructured{}
+	catalog.SetGroupVersionKind(ClusterCatalogGVK)
+	if err := cc.Get(ctx, types.NamespacedName{Name: name}, catalog); err != nil {
+		if apierrors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to get the %s ClusterCatalog: %w", name, err)
+	}
+	return true, nil
+}
+
+func (cc *clusterCatalog) Source(ctx context.Context, po *platformv1alpha1.PlatformOperator) (*Bundle, error) {
+	candidates, err := cc.Candidates(ctx, po)
+	if err != nil {
+		return nil, err
+	}
+	return latestCandidate(po, candidates)
+}
+
+// Revision identifies the contents of the serving ClusterCatalogs that the po
+// PlatformOperator can be sourced from by the image each was unpacked from.
+func (cc *clusterCatalog) Revision(ctx context.Context, po *platformv1alpha1.PlatformOperator) (string, error) {
+	catalogs := &unstructured.UnstructuredList{}
+	catalogs.SetGroupVersionKind(ClusterCatalogListGVK)
+	if err := cc.List(ctx, catalogs); err != nil {
+		return "", fmt.Errorf("failed to list ClusterCatalogs: %w", err)
+	}
+	selected := po.GetAnnotations()[platformtypes.AnnotationCatalog]
+
+	var revisions []string
+	for _, catalog := range catalogs.Items {
+		if selected != "" && catalog.GetName() != selected {
+			continue
+		}
+		if !isClusterCatalogServing(catalog) {
+			continue
+		}
+		revisions = append(revisions, catalog.GetName()+"="+string(catalog.GetUID())+"|"+clusterCatalogRef(catalog))
+	}
+	sort.Strings(revisions)
+	return strings.Join(revisions, ";"), nil
+}
+
+// Candidates finds bundles across every serving ClusterCatalog, unless the po
+// PlatformOperator has selected a single ClusterCatalog by name.
+func (cc *clusterCatalog) Candidates(ctx context.Context, po *platformv1alpha1.PlatformOperator) ([]Bundle, error) {
+	indexes, err := cc.indexes(ctx, po)
+	if err != nil {
+		return nil, err
+	}
+	var candidates bundles
+	for _, index := range indexes {
+		candidates = append(candidates, index.Candidates(po.Spec.Package.Name)...)
+	}
+	return candidates, nil
+}
+
+// Providers finds the bundles that provide the gvk API across the same
+// ClusterCatalogs that Candidates would search.
+func (cc *clusterCatalog) Providers(ctx context.Context, po *platformv1alpha1.PlatformOperator, gvk property.GVK) ([]Bundle, error) {
+	indexes, err := cc.indexes(ctx, po)
+	if err != nil {
+		return nil, err
+	}
+	var providers bundles
+	for _, index := range indexes {
+		providers = append(providers, index.Providers(gvk)...)
+	}
+	return providers, nil
+}
+
+// indexes returns the catalogIndex of every serving ClusterCatalog that the po
+// PlatformOperator can be sourced from.
+func (cc *clusterCatalog) indexes(ctx context.Context, po *platformv1alpha1.PlatformOperator) ([]catalogIndex, error) {
+	catalogs := &unstructured.UnstructuredList{}
+	catalogs.SetGroupVersionKind(ClusterCatalogListGVK)
+	if err := cc.List(ctx, catalogs); err != nil {
+		return nil, fmt.Errorf("failed to list ClusterCatalogs: %w", err)
+	}
+	// the indexes of deleted ClusterCatalogs are evicted, so they don't
+	// linger for the lifetime of the process.
+	live := make(map[types.NamespacedName]struct{}, len(catalogs.Items))
+	for _, catalog := range catalogs.Items {
+		live[types.NamespacedName{Name: catalog.GetName()}] = struct{}{}
+	}
+	cc.cache.Retain(live)
+	selected := po.GetAnnotations()[platformtypes.AnnotationCatalog]
+
+	var indexes []catalogIndex
+	for _, catalog := range catalogs.Items {
+		catalog := catalog
+		if selected != "" && catalog.GetName() != selected {
+			continue
+		}
+		if !isClusterCatalogServing(catalog) {
+			continue
+		}
+		contentURL, err := clusterCatalogContentURL(catalog)
+		if err != nil {
+			return nil, err
+		}
+		index, err := cc.cache.Get(ctx, types.NamespacedName{Name: catalog.GetName()}, contentURL+"|"+clusterCatalogRef(catalog), func(ctx context.Context) (catalogIndex, error) {
+			return cc.fetchContent(ctx, catalog.GetName(), contentURL)
+		})
+		if err != nil {
+			return nil, err
+		}
+		indexes = append(indexes, index)
+	}
+	return indexes, nil
+}
+
+// fetchContent streams the FBC JSON served at contentURL into a catalogIndex.
+func (cc *clusterCatalog) fetchContent(ctx context.Context, name, contentURL string) (catalogIndex, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, contentURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := cc.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch the contents of the %s ClusterCatalog: %w", name, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch the contents of the %s ClusterCatalog: unexpected status %q", name, resp.Status)
+	}
+	dc, err := declcfg.LoadReader(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the contents of the %s ClusterCatalog: %w", name, err)
+	}
+	index, err := buildIndex(dc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the contents of the %s ClusterCatalog: %w", name, err)
+	}
+	return index, nil
+}
+
+func isClusterCatalogServing(catalog unstructured.Unstructured) bool {
+	conditions, _, _ := unstructured.NestedSlice(catalog.Object, "status", "conditions")
+	for _, c := range conditions {
+		condition, ok := c.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if condition["type"] == clusterCatalogTypeServing {
+			return condition["status"] == "True"
+		}
+	}
+	return false
+}
+
+// clusterCatalogContentURL returns the endpoint serving the entirety of the
+// catalog's content as a stream of FBC JSON blobs.
+func clusterCatalogContentURL(catalog unstructured.Unstructured) (string, error) {
+	base, _, _ := unstructured.NestedString(catalog.Object, "status", "urls", "base")
+	if base == "" {
+		return "", fmt.Errorf("the %s ClusterCatalog is serving but doesn't report a base URL", catalog.GetName())
+	}
+	u, err := url.Parse(strings.TrimSuffix(base, "/") + "/" + clusterCatalogContentPath)
+	if err != nil {
+		return "", fmt.Errorf("the %s ClusterCatalog reports an invalid base URL: %w", catalog.GetName(), err)
+	}
+	return u.String(), nil
+}
+
+// clusterCatalogRef returns the resolved image reference the ClusterCatalog
+// content was unpacked from, which changes whenever the content changes.
+func clusterCatalogRef(catalog unstructured.Unstructured) string {
+	ref, _, _ := unstructured.NestedString(catalog.Object, "status", "resolvedSource", "image", "ref")
+	return ref
+}
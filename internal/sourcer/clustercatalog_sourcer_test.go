@@ -0,0 +1,156 @@
+package sourcer
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	platformtypes "github.com/openshift/platform-operators/api/v1alpha1"
+)
+
+// fakeClusterCatalogClient serves a static set of ClusterCatalogs.
+type fakeClusterCatalogClient struct {
+	client.Client
+	catalogs []unstructured.Unstructured
+}
+
+func (f *fakeClusterCatalogClient) List(_ context.Context, list client.ObjectList, _ ...client.ListOption) error {
+	list.(*unstructured.UnstructuredList).Items = f.catalogs
+	return nil
+}
+
+func (f *fakeClusterCatalogClient) Get(_ context.Context, key client.ObjectKey, obj client.Object, _ ...client.GetOption) error {
+	for _, catalog := range f.catalogs {
+		if catalog.GetName() == key.Name {
+			catalog.DeepCopyInto(obj.(*unstructured.Unstructured))
+			return nil
+		}
+	}
+	return apierrors.NewNotFound(schema.GroupResource{Group: ClusterCatalogGVK.Group, Resource: "clustercatalogs"}, key.Name)
+}
+
+func newTestClusterCatalog(name, baseURL, ref string, serving bool) unstructured.Unstructured {
+	status := "False"
+	if serving {
+		status = "True"
+	}
+	catalog := unstructured.Unstructured{Object: map[string]interface{}{
+		"status": map[string]interface{}{
+			"conditions": []interface{}{
+				map[string]interface{}{"type": clusterCatalogTypeServing, "status": status},
+			},
+			"urls": map[string]interface{}{"base": baseURL},
+			"resolvedSource": map[string]interface{}{
+				"image": map[string]interface{}{"ref": ref},
+			},
+		},
+	}}
+	catalog.SetName(name)
+	return catalog
+}
+
+// newTestCatalogdServer serves the JSON content in file for every catalog.
+func newTestCatalogdServer(t *testing.T, file string, requests *int32) *httptest.Server {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(requests, 1)
+		if r.URL.Path != "/catalogs/operators/"+clusterCatalogContentPath {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/jsonl")
+		_, _ = w.Write(content)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestClusterCatalogSource(t *testing.T) {
+	var requests int32
+	srv := newTestCatalogdServer(t, "testdata/catalogd/all.json", &requests)
+	base := srv.URL + "/catalogs/operators"
+
+	tests := []struct {
+		name        string
+		catalogs    []unstructured.Unstructured
+		packageName string
+		selected    string
+		wantImage   string
+		wantErr     bool
+	}{
+		{
+			name:        "ServingCatalog",
+			catalogs:    []unstructured.Unstructured{newTestClusterCatalog("operators", base, "sha256:1", true)},
+			packageName: "foo",
+			wantImage:   "quay.io/example/foo-bundle:v1.1.0",
+		},
+		{
+			name:        "SelectedCatalog",
+			catalogs:    []unstructured.Unstructured{newTestClusterCatalog("operators", base, "sha256:1", true), newTestClusterCatalog("broken", srv.URL+"/missing", "sha256:2", true)},
+			packageName: "foo",
+			selected:    "operators",
+			wantImage:   "quay.io/example/foo-bundle:v1.1.0",
+		},
+		{
+			name:        "CatalogNotServing",
+			catalogs:    []unstructured.Unstructured{newTestClusterCatalog("operators", base, "sha256:1", false)},
+			packageName: "foo",
+			wantErr:     true,
+		},
+		{
+			name:        "CatalogContentMissing",
+			catalogs:    []unstructured.Unstructured{newTestClusterCatalog("broken", srv.URL+"/missing", "sha256:2", true)},
+			packageName: "foo",
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewClusterCatalogHandler(&fakeClusterCatalogClient{catalogs: tt.catalogs}, srv.Client())
+			po := newTestPlatformOperator(tt.packageName)
+			if tt.selected != "" {
+				po.SetAnnotations(map[string]string{platformtypes.AnnotationCatalog: tt.selected})
+			}
+
+			got, err := s.Source(context.Background(), po)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Source() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got.Image != tt.wantImage {
+				t.Errorf("Source() = %s, want %s", got.Image, tt.wantImage)
+			}
+		})
+	}
+}
+
+func TestClusterCatalogCachesContent(t *testing.T) {
+	var requests int32
+	srv := newTestCatalogdServer(t, "testdata/catalogd/all.json", &requests)
+	base := srv.URL + "/catalogs/operators"
+
+	fake := &fakeClusterCatalogClient{}
+	s := NewClusterCatalogHandler(fake, srv.Client())
+	for _, ref := range []string{"sha256:1", "sha256:1", "sha256:2"} {
+		fake.catalogs = []unstructured.Unstructured{newTestClusterCatalog("operators", base, ref, true)}
+		if _, err := s.Source(context.Background(), newTestPlatformOperator("foo")); err != nil {
+			t.Fatalf("Source() unexpected error: %v", err)
+		}
+	}
+	if requests != 2 {
+		t.Errorf("catalog content was fetched %d times, want 2", requests)
+	}
+}
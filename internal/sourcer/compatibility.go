@@ -0,0 +1,139 @@
+package sourcer
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/blang/semver/v4"
+	"github.com/operator-framework/operator-registry/alpha/property"
+
+	"github.com/openshift/platform-operators/internal/clusterfacts"
+)
+
+const (
+	// archLabelPrefix is the prefix of the CSV labels that declare the
+	// architectures an operator supports, e.g. operatorframework.io/arch.amd64.
+	archLabelPrefix = "operatorframework.io/arch."
+	// defaultArchitecture is supported by operators that don't declare any
+	// architecture labels.
+	defaultArchitecture = "amd64"
+
+	featureDisconnected = "features.operators.openshift.io/disconnected"
+	featureFIPS         = "features.operators.openshift.io/fips-compliant"
+	featureProxyAware   = "features.operators.openshift.io/proxy-aware"
+
+	// infrastructureFeatures is the annotation that declared the supported
+	// infrastructure features, as a JSON list, before the individual
+	// features.operators.openshift.io annotations replaced it.
+	infrastructureFeatures = "operators.openshift.io/infrastructure-features"
+)
+
+// CSVMetadata returns the b bundle's olm.csv.metadata property, or nil when
+// the bundle doesn't declare one.
+func (b Bundle) CSVMetadata() (*property.CSVMetadata, error) {
+	for _, p := range b.Properties {
+		if p.Type != property.TypeCSVMetadata {
+			continue
+		}
+		metadata := &property.CSVMetadata{}
+		if err := json.Unmarshal(p.Value, metadata); err != nil {
+			return nil, fmt.Errorf("failed to parse the %s property of the %s bundle: %w", property.TypeCSVMetadata, b.Name, err)
+		}
+		return metadata, nil
+	}
+	return nil, nil
+}
+
+// Incompatible returns why the b bundle can't run on the cluster described by
+// facts, based on the minimum Kubernetes version, the architectures and, when
+// requireFeatures is set, the infrastructure features its ClusterServiceVersion
+// declares, or an empty string when it's compatible. Bundles without an
+// olm.csv.metadata property are assumed to be compatible as there's nothing to
+// check.
+//
+// Most bundles don't declare their infrastructure features, and the facts
+// they're checked against are approximations, e.g. any image mirroring policy
+// is taken to mean the cluster is disconnected, so requiring them is left for
+// administrators to opt into.
+func Incompatible(b Bundle, facts *clusterfacts.Facts, requireFeatures bool) (string, error) {
+	metadata, err := b.CSVMetadata()
+	if err != nil || metadata == nil {
+		return "", err
+	}
+
+	if metadata.MinKubeVersion != "" && facts.KubernetesVersion != "" {
+		minVersion, err := semver.ParseTolerant(metadata.MinKubeVersion)
+		if err != nil {
+			return "", fmt.Errorf("failed to parse the minKubeVersion of the %s bundle: %w", b.Name, err)
+		}
+		current, err := semver.ParseTolerant(facts.KubernetesVersion)
+		if err != nil {
+			return "", fmt.Errorf("failed to parse the cluster's Kubernetes version: %w", err)
+		}
+		if current.LT(minVersion) {
+			return fmt.Sprintf("it requires Kubernetes %s or newer, and the cluster is running %s", metadata.MinKubeVersion, facts.KubernetesVersion), nil
+		}
+	}
+
+	supported := supportedArchitectures(metadata.Labels)
+	var unsupported []string
+	for _, arch := range facts.Architectures {
+		if !supported[arch] {
+			unsupported = append(unsupported, arch)
+		}
+	}
+	if len(unsupported) != 0 {
+		return fmt.Sprintf("it doesn't support the %s node architectures", strings.Join(unsupported, ", ")), nil
+	}
+	if !requireFeatures {
+		return "", nil
+	}
+
+	var missing []string
+	if facts.FIPS && !supportsFeature(metadata.Annotations, featureFIPS, "fips") {
+		missing = append(missing, "FIPS mode")
+	}
+	if facts.Proxy && !supportsFeature(metadata.Annotations, featureProxyAware, "proxy-aware") {
+		missing = append(missing, "a cluster-wide proxy")
+	}
+	if facts.Disconnected && !supportsFeature(metadata.Annotations, featureDisconnected, "disconnected") {
+		missing = append(missing, "disconnected installation")
+	}
+	if len(missing) != 0 {
+		return fmt.Sprintf("it doesn't declare support for %s, which the cluster uses", strings.Join(missing, ", ")), nil
+	}
+	return "", nil
+}
+
+func supportedArchitectures(labels map[string]string) map[string]bool {
+	supported := make(map[string]bool)
+	for key, value := range labels {
+		if strings.HasPrefix(key, archLabelPrefix) && value == "supported" {
+			supported[strings.TrimPrefix(key, archLabelPrefix)] = true
+		}
+	}
+	if len(supported) == 0 {
+		supported[defaultArchitecture] = true
+	}
+	return supported
+}
+
+// supportsFeature returns whether the annotations declare support for an
+// infrastructure feature, through either its features.operators.openshift.io
+// annotation or the legacy infrastructure features list.
+func supportsFeature(annotations map[string]string, annotation, legacyFeature string) bool {
+	if value, ok := annotations[annotation]; ok {
+		return value == "true"
+	}
+	var features []string
+	if err := json.Unmarshal([]byte(annotations[infrastructureFeatures]), &features); err != nil {
+		return false
+	}
+	for _, feature := range features {
+		if strings.EqualFold(feature, legacyFeature) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,135 @@
+package sourcer
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/operator-framework/operator-registry/alpha/property"
+
+	"github.com/openshift/platform-operators/internal/clusterfacts"
+)
+
+func newTestCSVMetadataBundle(t *testing.T, metadata property.CSVMetadata) Bundle {
+	t.Helper()
+	value, err := json.Marshal(metadata)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Bundle{
+		Name:       "foo.v1.0.0",
+		Properties: []property.Property{{Type: property.TypeCSVMetadata, Value: value}},
+	}
+}
+
+func TestIncompatible(t *testing.T) {
+	facts := clusterfacts.Facts{
+		KubernetesVersion: "1.27.4",
+		Architectures:     []string{"amd64"},
+	}
+	tests := []struct {
+		name     string
+		metadata *property.CSVMetadata
+		mutate   func(*clusterfacts.Facts)
+		// optional marks the cases whose infrastructure features aren't
+		// required.
+		optional  bool
+		wantMatch bool
+	}{
+		{
+			name:      "NoCSVMetadata",
+			wantMatch: true,
+		},
+		{
+			name:      "DefaultsToAMD64",
+			metadata:  &property.CSVMetadata{},
+			wantMatch: true,
+		},
+		{
+			name:      "MinKubeVersionSatisfied",
+			metadata:  &property.CSVMetadata{MinKubeVersion: "1.25.0"},
+			wantMatch: true,
+		},
+		{
+			name:     "MinKubeVersionTooNew",
+			metadata: &property.CSVMetadata{MinKubeVersion: "1.28.0"},
+		},
+		{
+			name:     "UnsupportedArchitecture",
+			metadata: &property.CSVMetadata{Labels: map[string]string{"operatorframework.io/arch.amd64": "supported"}},
+			mutate: func(f *clusterfacts.Facts) {
+				f.Architectures = []string{"amd64", "arm64"}
+			},
+		},
+		{
+			name: "MultiArchitecture",
+			metadata: &property.CSVMetadata{Labels: map[string]string{
+				"operatorframework.io/arch.amd64": "supported",
+				"operatorframework.io/arch.arm64": "supported",
+			}},
+			mutate: func(f *clusterfacts.Facts) {
+				f.Architectures = []string{"amd64", "arm64"}
+			},
+			wantMatch: true,
+		},
+		{
+			name:     "FIPSNotDeclared",
+			metadata: &property.CSVMetadata{},
+			mutate: func(f *clusterfacts.Facts) {
+				f.FIPS = true
+			},
+		},
+		{
+			name:     "FIPSCompliant",
+			metadata: &property.CSVMetadata{Annotations: map[string]string{"features.operators.openshift.io/fips-compliant": "true"}},
+			mutate: func(f *clusterfacts.Facts) {
+				f.FIPS = true
+			},
+			wantMatch: true,
+		},
+		{
+			name:     "DisconnectedDeclaredFalse",
+			metadata: &property.CSVMetadata{Annotations: map[string]string{"features.operators.openshift.io/disconnected": "false"}},
+			mutate: func(f *clusterfacts.Facts) {
+				f.Disconnected = true
+			},
+		},
+		{
+			name:     "FeaturesNotRequired",
+			metadata: &property.CSVMetadata{},
+			mutate: func(f *clusterfacts.Facts) {
+				f.Disconnected = true
+				f.Proxy = true
+			},
+			optional:  true,
+			wantMatch: true,
+		},
+		{
+			name:     "LegacyInfrastructureFeatures",
+			metadata: &property.CSVMetadata{Annotations: map[string]string{"operators.openshift.io/infrastructure-features": `["Disconnected", "proxy-aware"]`}},
+			mutate: func(f *clusterfacts.Facts) {
+				f.Disconnected = true
+				f.Proxy = true
+			},
+			wantMatch: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := Bundle{Name: "foo.v1.0.0"}
+			if tt.metadata != nil {
+				b = newTestCSVMetadataBundle(t, *tt.metadata)
+			}
+			f := facts
+			if tt.mutate != nil {
+				tt.mutate(&f)
+			}
+			reason, err := Incompatible(b, &f, !tt.optional)
+			if err != nil {
+				t.Fatalf("Incompatible() unexpected error: %v", err)
+			}
+			if (reason == "") != tt.wantMatch {
+				t.Errorf("Incompatible() = %q, want compatible %v", reason, tt.wantMatch)
+			}
+		})
+	}
+}
@@ -0,0 +1,252 @@
+package sourcer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	operatorsv1alpha1 "github.com/operator-framework/api/pkg/operators/v1alpha1"
+	registryClient "github.com/operator-framework/operator-registry/pkg/client"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/openshift/platform-operators/internal/metrics"
+)
+
+// ConnectionOptions configures how registry connections are dialed, and
+// how calls over those connections are bounded and retried.
+type ConnectionOptions struct {
+	// DialTimeout bounds how long establishing a new connection may take.
+	DialTimeout time.Duration
+	// CallTimeout bounds each individual call made against a connection.
+	CallTimeout time.Duration
+	// Backoff controls how failed dials and calls are retried.
+	Backoff wait.Backoff
+}
+
+// DefaultConnectionOptions returns the ConnectionOptions used when the
+// manager hasn't been configured otherwise.
+func DefaultConnectionOptions() ConnectionOptions {
+	return ConnectionOptions{
+		DialTimeout: 10 * time.Second,
+		CallTimeout: 15 * time.Second,
+		Backoff: wait.Backoff{
+			Duration: 500 * time.Millisecond,
+			Factor:   2,
+			Steps:    2,
+			Cap:      time.Second,
+		},
+	}
+}
+
+// registryConn holds the registry client for a single CatalogSource. Its
+// mutex serializes dialing that CatalogSource, so a catalog whose dials hang
+// doesn't hold up the connections to every other catalog.
+type registryConn struct {
+	mu      sync.Mutex
+	address string
+	client  *registryClient.Client
+	// closed is set once the registryConn is removed from the manager, so
+	// callers that were waiting on it look up its replacement instead.
+	closed bool
+}
+
+// connectionManager maintains a single registry client per CatalogSource,
+// and replaces that client when the CatalogSource address changes or the
+// underlying connection has failed.
+type connectionManager struct {
+	mu    sync.Mutex
+	conns map[types.NamespacedName]*registryConn
+	opts  ConnectionOptions
+}
+
+func newConnectionManager(opts ConnectionOptions) *connectionManager {
+	return &connectionManager{
+		conns: make(map[types.NamespacedName]*registryConn),
+		opts:  opts,
+	}
+}
+
+// Do runs fn against the registry client for the cs CatalogSource. Each
+// attempt is bounded by the configured call timeout, and connection-level
+// failures cause the client to be re-dialed and the call retried with an
+// exponential backoff. Calls that time out aren't retried, nor are any once
+// ctx is done, so a catalog that doesn't respond is left to the caller's
+// next attempt rather than holding it up.
+func (m *connectionManager) Do(ctx context.Context, cs operatorsv1alpha1.CatalogSource, fn func(context.Context, registryClient.Interface) error) error {
+	nn := client.ObjectKeyFromObject(&cs)
+
+	var lastErr error
+	err := wait.ExponentialBackoffWithContext(ctx, m.opts.Backoff, func(ctx context.Context) (bool, error) {
+		rc, err := m.client(ctx, cs)
+		if err != nil {
+			metrics.CatalogConnectionFailures.WithLabelValues(nn.String()).Inc()
+			if !retryable(ctx, err) {
+				return false, err
+			}
+			lastErr = err
+			return false, nil
+		}
+
+		callCtx, cancel := context.WithTimeout(ctx, m.opts.CallTimeout)
+		defer cancel()
+
+		err = fn(callCtx, rc)
+		if err == nil {
+			return true, nil
+		}
+		if !isConnectionError(err) {
+			return false, err
+		}
+		metrics.CatalogConnectionFailures.WithLabelValues(nn.String()).Inc()
+		m.evict(nn, rc)
+		if !retryable(ctx, err) {
+			return false, err
+		}
+		lastErr = err
+		return false, nil
+	})
+	if wait.Interrupted(err) && lastErr != nil {
+		return lastErr
+	}
+	return err
+}
+
+// conn returns the registryConn for the nn CatalogSource, adding it when
+// there's none.
+func (m *connectionManager) conn(nn types.NamespacedName) *registryConn {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	conn, ok := m.conns[nn]
+	if !ok {
+		conn = &registryConn{}
+		m.conns[nn] = conn
+	}
+	return conn
+}
+
+// client returns the cached registry client for the cs CatalogSource,
+// dialing a new connection when there's no usable client. Only callers of
+// the same CatalogSource wait on the dial.
+func (m *connectionManager) client(ctx context.Context, cs operatorsv1alpha1.CatalogSource) (*registryClient.Client, error) {
+	nn := client.ObjectKeyFromObject(&cs)
+	address := cs.Status.GRPCConnectionState.Address
+
+	conn := m.conn(nn)
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+	if conn.closed {
+		// the CatalogSource was closed while waiting, and is dialed afresh
+		// under its replacement, should it still be in use.
+		return nil, fmt.Errorf("the connection to the %s catalog was closed", nn)
+	}
+
+	if conn.client != nil {
+		if conn.address == address && isHealthy(conn.client) {
+			return conn.client, nil
+		}
+		conn.closeClient()
+	}
+
+	dialCtx, cancel := context.WithTimeout(ctx, m.opts.DialTimeout)
+	defer cancel()
+
+	cc, err := grpc.DialContext(dialCtx, address, grpc.WithTransportCredentials(insecure.NewCredentials()), grpc.WithBlock())
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial the %s catalog at %s: %w", nn, address, err)
+	}
+	conn.address = address
+	conn.client = registryClient.NewClientFromConn(cc)
+	metrics.CatalogConnectionsOpen.Inc()
+
+	return conn.client, nil
+}
+
+// evict closes the rc client for the nn CatalogSource, unless it has already
+// been replaced by a concurrent caller.
+func (m *connectionManager) evict(nn types.NamespacedName, rc registryClient.Interface) {
+	m.mu.Lock()
+	conn, ok := m.conns[nn]
+	m.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+	if conn.client != nil && conn.client == rc {
+		conn.closeClient()
+	}
+}
+
+// Close closes the connection to the nn CatalogSource, e.g. once it's deleted.
+func (m *connectionManager) Close(nn types.NamespacedName) {
+	m.mu.Lock()
+	conn, ok := m.conns[nn]
+	delete(m.conns, nn)
+	m.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+	conn.closed = true
+	if conn.client != nil {
+		conn.closeClient()
+	}
+}
+
+func (c *registryConn) closeClient() {
+	_ = c.client.Close()
+	c.client = nil
+	metrics.CatalogConnectionsOpen.Dec()
+}
+
+func isHealthy(rc *registryClient.Client) bool {
+	switch rc.Conn.GetState() {
+	case connectivity.TransientFailure, connectivity.Shutdown:
+		return false
+	default:
+		return true
+	}
+}
+
+// retryable returns whether a dial or call that failed with err is retried,
+// which isn't the case once ctx is done or when it timed out.
+func retryable(ctx context.Context, err error) bool {
+	return ctx.Err() == nil && !isTimeout(err)
+}
+
+// isTimeout returns whether err is a dial or call running out of time.
+func isTimeout(err error) bool {
+	return errors.Is(err, context.DeadlineExceeded) || status.Code(err) == codes.DeadlineExceeded
+}
+
+// isConnectionError determines whether err was caused by the connection to
+// the registry server rather than the request itself, e.g. a NotFound error
+// is a valid response and shouldn't force the connection to be re-dialed.
+func isConnectionError(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	s, ok := status.FromError(err)
+	if !ok {
+		return false
+	}
+	switch s.Code() {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.Aborted:
+		return true
+	default:
+		return false
+	}
+}
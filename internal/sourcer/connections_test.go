@@ -0,0 +1,212 @@
+package sourcer
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/operator-framework/operator-registry/pkg/api"
+	registryClient "github.com/operator-framework/operator-registry/pkg/client"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func newTestConnectionOptions() ConnectionOptions {
+	return ConnectionOptions{
+		DialTimeout: time.Second,
+		CallTimeout: time.Second,
+		Backoff: wait.Backoff{
+			Duration: 10 * time.Millisecond,
+			Factor:   2,
+			Steps:    3,
+		},
+	}
+}
+
+func countBundles(ctx context.Context, rc registryClient.Interface) (int, error) {
+	it, err := rc.ListBundles(ctx)
+	if err != nil {
+		return 0, err
+	}
+	var count int
+	for b := it.Next(); b != nil; b = it.Next() {
+		count++
+	}
+	return count, it.Error()
+}
+
+func TestConnectionManagerReusesConnections(t *testing.T) {
+	address, _ := startFakeRegistryServer(t, &fakeRegistryServer{
+		bundles: []*api.Bundle{{PackageName: "foo", ChannelName: "stable", Version: "1.0.0"}},
+	})
+	m := newConnectionManager(newTestConnectionOptions())
+	cs := newTestCatalogSource(address, nil)
+
+	var clients []registryClient.Interface
+	for i := 0; i < 2; i++ {
+		err := m.Do(context.Background(), cs, func(ctx context.Context, rc registryClient.Interface) error {
+			clients = append(clients, rc)
+			count, err := countBundles(ctx, rc)
+			if count != 1 {
+				t.Errorf("ListBundles() returned %d bundles, want 1", count)
+			}
+			return err
+		})
+		if err != nil {
+			t.Fatalf("Do() unexpected error: %v", err)
+		}
+	}
+	if clients[0] != clients[1] {
+		t.Errorf("expected the registry client to be reused between calls")
+	}
+	if len(m.conns) != 1 {
+		t.Errorf("connection manager is tracking %d connections, want 1", len(m.conns))
+	}
+}
+
+func TestConnectionManagerRedialsOnAddressChange(t *testing.T) {
+	first, _ := startFakeRegistryServer(t, &fakeRegistryServer{})
+	second, _ := startFakeRegistryServer(t, &fakeRegistryServer{})
+	m := newConnectionManager(newTestConnectionOptions())
+
+	var clients []registryClient.Interface
+	for _, address := range []string{first, second} {
+		err := m.Do(context.Background(), newTestCatalogSource(address, nil), func(ctx context.Context, rc registryClient.Interface) error {
+			clients = append(clients, rc)
+			_, err := countBundles(ctx, rc)
+			return err
+		})
+		if err != nil {
+			t.Fatalf("Do() unexpected error: %v", err)
+		}
+	}
+	if clients[0] == clients[1] {
+		t.Errorf("expected a new registry client after the catalog address changed")
+	}
+	if len(m.conns) != 1 {
+		t.Errorf("connection manager is tracking %d connections, want 1", len(m.conns))
+	}
+}
+
+func TestConnectionManagerRetriesConnectionFailures(t *testing.T) {
+	address, _ := startFakeRegistryServer(t, &fakeRegistryServer{})
+	m := newConnectionManager(newTestConnectionOptions())
+	cs := newTestCatalogSource(address, nil)
+
+	tests := []struct {
+		name      string
+		err       error
+		wantCalls int
+	}{
+		{
+			name:      "Unavailable",
+			err:       status.Error(codes.Unavailable, "connection reset"),
+			wantCalls: 3,
+		},
+		{
+			// a catalog that timed out is evicted, but left to the next
+			// reconcile.
+			name:      "DeadlineExceeded",
+			err:       status.Error(codes.DeadlineExceeded, "context deadline exceeded"),
+			wantCalls: 1,
+		},
+		{
+			name:      "NotFound",
+			err:       status.Error(codes.NotFound, "package not found"),
+			wantCalls: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls int
+			err := m.Do(context.Background(), cs, func(_ context.Context, _ registryClient.Interface) error {
+				calls++
+				return tt.err
+			})
+			if !errors.Is(err, tt.err) {
+				t.Errorf("Do() error = %v, want %v", err, tt.err)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("Do() made %d calls, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestConnectionManagerStopsRetryingWhenDone(t *testing.T) {
+	address, _ := startFakeRegistryServer(t, &fakeRegistryServer{})
+	m := newConnectionManager(newTestConnectionOptions())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var calls int
+	unavailable := status.Error(codes.Unavailable, "connection reset")
+	err := m.Do(ctx, newTestCatalogSource(address, nil), func(context.Context, registryClient.Interface) error {
+		calls++
+		cancel()
+		return unavailable
+	})
+	if !errors.Is(err, unavailable) {
+		t.Errorf("Do() error = %v, want %v", err, unavailable)
+	}
+	if calls != 1 {
+		t.Errorf("Do() made %d calls, want 1", calls)
+	}
+}
+
+func TestConnectionManagerDialsCatalogsIndependently(t *testing.T) {
+	// the hung listener accepts connections, but never completes the HTTP/2
+	// handshake, so dialing it blocks until the dial timeout.
+	hung, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hung.Close()
+	go func() {
+		for {
+			if _, err := hung.Accept(); err != nil {
+				return
+			}
+		}
+	}()
+	address, _ := startFakeRegistryServer(t, &fakeRegistryServer{})
+
+	opts := newTestConnectionOptions()
+	opts.DialTimeout = 2 * time.Second
+	opts.Backoff.Steps = 1
+	m := newConnectionManager(opts)
+	hungCatalog := newTestCatalogSource(hung.Addr().String(), nil)
+	hungCatalog.SetName("hung")
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = m.Do(context.Background(), hungCatalog, func(context.Context, registryClient.Interface) error { return nil })
+	}()
+	// give the hung dial a chance to start
+	time.Sleep(100 * time.Millisecond)
+
+	start := time.Now()
+	err = m.Do(context.Background(), newTestCatalogSource(address, nil), func(ctx context.Context, rc registryClient.Interface) error {
+		_, err := countBundles(ctx, rc)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Do() unexpected error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Do() took %s, want it not to wait on the hung catalog's dial", elapsed)
+	}
+
+	m.Close(client.ObjectKeyFromObject(&hungCatalog))
+	m.Close(types.NamespacedName{Name: catalogName, Namespace: catalogNamespace})
+	if len(m.conns) != 0 {
+		t.Errorf("connection manager is tracking %d connections after closing them, want 0", len(m.conns))
+	}
+	<-done
+}
@@ -0,0 +1,104 @@
+package sourcer
+
+import (
+	"context"
+	"io/fs"
+	"sort"
+	"strings"
+
+	"github.com/operator-framework/operator-registry/alpha/declcfg"
+	"github.com/operator-framework/operator-registry/alpha/model"
+)
+
+// indexIgnoreFile is the file that declcfg.LoadFS reads ignore patterns from.
+const indexIgnoreFile = ".indexignore"
+
+// loadDeclarativeConfigFS reads the file-based catalog stored in the fsys
+// filesystem into a single declarative config.
+func loadDeclarativeConfigFS(ctx context.Context, fsys fs.FS) (*declcfg.DeclarativeConfig, error) {
+	return declcfg.LoadFS(ctx, visibleFS{FS: fsys})
+}
+
+// visibleFS hides the hidden entries of an fs.FS, such as the timestamped
+// "..data" directories that back ConfigMap volumes, so their files aren't read
+// twice when the filesystem is walked.
+type visibleFS struct {
+	fs.FS
+}
+
+func (v visibleFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	entries, err := fs.ReadDir(v.FS, name)
+	if err != nil {
+		return nil, err
+	}
+	visible := entries[:0]
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".") && entry.Name() != indexIgnoreFile {
+			continue
+		}
+		visible = append(visible, entry)
+	}
+	return visible, nil
+}
+
+// skipHiddenDir skips hidden directories, such as the timestamped "..data"
+// directories that back ConfigMap volumes, so their files aren't read twice.
+func skipHiddenDir(path string, d fs.DirEntry) error {
+	if path != "." && strings.HasPrefix(d.Name(), ".") {
+		return fs.SkipDir
+	}
+	return nil
+}
+
+// buildIndex validates the dc declarative config and converts it into a
+// catalogIndex, where a Bundle is produced for every channel entry. The
+// bundles in each channel are ordered by version, so the index doesn't depend
+// on the model's map iteration order.
+func buildIndex(dc *declcfg.DeclarativeConfig) (catalogIndex, error) {
+	m, err := declcfg.ConvertToModel(*dc)
+	if err != nil {
+		return nil, err
+	}
+	index := make(catalogIndex)
+	for _, pkg := range m {
+		for _, ch := range pkg.Channels {
+			entries := make([]*model.Bundle, 0, len(ch.Bundles))
+			for _, b := range ch.Bundles {
+				entries = append(entries, b)
+			}
+			sort.Slice(entries, func(i, j int) bool {
+				if c := entries[i].Version.Compare(entries[j].Version); c != 0 {
+					return c < 0
+				}
+				return entries[i].Name < entries[j].Name
+			})
+			for _, b := range entries {
+				index.add(Bundle{
+					Name:      b.Name,
+					Package:   pkg.Name,
+					Channel:   ch.Name,
+					Version:   b.Version.String(),
+					Image:     b.Image,
+					Replaces:  b.Replaces,
+					Skips:     b.Skips,
+					SkipRange: b.SkipRange,
+
+					Properties: b.Properties,
+					Deprecation: Deprecation{
+						Package: deprecationMessage(pkg.Deprecation),
+						Channel: deprecationMessage(ch.Deprecation),
+						Bundle:  deprecationMessage(b.Deprecation),
+					},
+				})
+			}
+		}
+	}
+	return index, nil
+}
+
+func deprecationMessage(d *model.Deprecation) string {
+	if d == nil {
+		return ""
+	}
+	return d.Message
+}
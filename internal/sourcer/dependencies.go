@@ -0,0 +1,86 @@
+package sourcer
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/operator-framework/api/pkg/constraints"
+	"github.com/operator-framework/operator-registry/alpha/property"
+)
+
+// Dependency is a bundle that needs to be installed before the bundle that
+// requires it.
+type Dependency struct {
+	// Bundle is the bundle that was selected to satisfy the requirement.
+	Bundle Bundle
+	// VersionRange is the range of versions of Bundle.Package that are
+	// acceptable, or empty when any version is.
+	VersionRange string
+	// RequiredBy is the name of the bundle that declared the requirement.
+	RequiredBy string
+}
+
+// Provides returns whether the b bundle declares an olm.gvk property for gvk.
+func (b Bundle) Provides(gvk property.GVK) bool {
+	for _, p := range b.Properties {
+		if p.Type != property.TypeGVK {
+			continue
+		}
+		var provided property.GVK
+		if err := json.Unmarshal(p.Value, &provided); err != nil {
+			continue
+		}
+		if provided == gvk {
+			return true
+		}
+	}
+	return false
+}
+
+// Requirements returns the packages and APIs the b bundle depends on, from
+// its olm.package.required and olm.gvk.required properties, and from its
+// olm.constraint properties that hold a single package or gvk constraint.
+func (b Bundle) Requirements() ([]property.PackageRequired, []property.GVKRequired, error) {
+	props, err := property.Parse(b.Properties)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse the properties of the %s bundle: %w", b.Name, err)
+	}
+	packages, gvks := props.PackagesRequired, props.GVKsRequired
+
+	cs, err := b.Constraints()
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, c := range cs {
+		switch {
+		case c.Package != nil:
+			packages = append(packages, property.PackageRequired{
+				PackageName:  c.Package.PackageName,
+				VersionRange: c.Package.VersionRange,
+			})
+		case c.GVK != nil:
+			gvks = append(gvks, property.GVKRequired{
+				Group:   c.GVK.Group,
+				Version: c.GVK.Version,
+				Kind:    c.GVK.Kind,
+			})
+		}
+	}
+	return packages, gvks, nil
+}
+
+// Constraints returns the parsed olm.constraint properties of the b bundle.
+func (b Bundle) Constraints() ([]constraints.Constraint, error) {
+	var cs []constraints.Constraint
+	for _, p := range b.Properties {
+		if p.Type != constraints.OLMConstraintType {
+			continue
+		}
+		c, err := constraints.Parse(p.Value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse an %s property of the %s bundle: %w", constraints.OLMConstraintType, b.Name, err)
+		}
+		cs = append(cs, c)
+	}
+	return cs, nil
+}
@@ -0,0 +1,79 @@
+package sourcer
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/operator-framework/operator-registry/alpha/property"
+)
+
+func TestBundleRequirements(t *testing.T) {
+	tests := []struct {
+		name         string
+		properties   []property.Property
+		wantPackages []property.PackageRequired
+		wantGVKs     []property.GVKRequired
+		wantErr      bool
+	}{
+		{
+			name: "NoRequirements",
+			properties: []property.Property{
+				property.MustBuildPackage("app", "1.0.0"),
+			},
+		},
+		{
+			name: "RequiredProperties",
+			properties: []property.Property{
+				property.MustBuildPackageRequired("database", ">=1.0.0"),
+				property.MustBuildGVKRequired("storage.example.com", "v1", "Volume"),
+			},
+			wantPackages: []property.PackageRequired{{PackageName: "database", VersionRange: ">=1.0.0"}},
+			wantGVKs:     []property.GVKRequired{{Group: "storage.example.com", Version: "v1", Kind: "Volume"}},
+		},
+		{
+			name: "SingleRequirementConstraints",
+			properties: []property.Property{
+				{Type: "olm.constraint", Value: json.RawMessage(`{"package":{"packageName":"database","versionRange":"<2.0.0"}}`)},
+				{Type: "olm.constraint", Value: json.RawMessage(`{"gvk":{"group":"storage.example.com","version":"v1","kind":"Volume"}}`)},
+				{Type: "olm.constraint", Value: json.RawMessage(`{"all":{"constraints":[{"package":{"packageName":"cache"}}]}}`)},
+			},
+			wantPackages: []property.PackageRequired{{PackageName: "database", VersionRange: "<2.0.0"}},
+			wantGVKs:     []property.GVKRequired{{Group: "storage.example.com", Version: "v1", Kind: "Volume"}},
+		},
+		{
+			name: "InvalidConstraint",
+			properties: []property.Property{
+				{Type: "olm.constraint", Value: json.RawMessage(`{"unknown":{}}`)},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := Bundle{Name: "app.v1.0.0", Properties: tt.properties}
+			packages, gvks, err := b.Requirements()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Requirements() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(packages, tt.wantPackages) {
+				t.Errorf("Requirements() packages = %v, want %v", packages, tt.wantPackages)
+			}
+			if !reflect.DeepEqual(gvks, tt.wantGVKs) {
+				t.Errorf("Requirements() gvks = %v, want %v", gvks, tt.wantGVKs)
+			}
+		})
+	}
+}
+
+func TestBundleProvides(t *testing.T) {
+	b := Bundle{Properties: []property.Property{
+		property.MustBuildGVK("storage.example.com", "v1", "Volume"),
+	}}
+	if !b.Provides(property.GVK{Group: "storage.example.com", Version: "v1", Kind: "Volume"}) {
+		t.Errorf("Provides() = false for the provided API, want true")
+	}
+	if b.Provides(property.GVK{Group: "storage.example.com", Version: "v1beta1", Kind: "Volume"}) {
+		t.Errorf("Provides() = true for another version of the provided API, want false")
+	}
+}
@@ -0,0 +1,63 @@
+package sourcer
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Deprecation holds the messages a catalog uses to deprecate a bundle's
+// package, the channel the bundle was sourced from, and the bundle itself.
+// An empty message means that part isn't deprecated.
+type Deprecation struct {
+	Package string
+	Channel string
+	Bundle  string
+}
+
+// IsDeprecated returns whether any part of the deprecation is set.
+func (d Deprecation) IsDeprecated() bool {
+	return d.Package != "" || d.Channel != "" || d.Bundle != ""
+}
+
+// Describe returns a sentence for each deprecated part of the b bundle.
+func (d Deprecation) Describe(b Bundle) string {
+	var messages []string
+	if d.Package != "" {
+		messages = append(messages, fmt.Sprintf("The %s package is deprecated: %s", b.Package, d.Package))
+	}
+	if d.Channel != "" {
+		messages = append(messages, fmt.Sprintf("The %s channel is deprecated: %s", b.Channel, d.Channel))
+	}
+	if d.Bundle != "" {
+		messages = append(messages, fmt.Sprintf("The %s bundle is deprecated: %s", b.Name, d.Bundle))
+	}
+	return strings.Join(messages, " ")
+}
+
+// DeprecationFilter rejects the deprecated candidates, but only when there's
+// a candidate that isn't deprecated to select instead. Package deprecations
+// apply to every candidate, so they're ignored.
+func DeprecationFilter(candidates []Bundle) Filter {
+	var alternative bool
+	for _, b := range candidates {
+		if b.Deprecation.Channel == "" && b.Deprecation.Bundle == "" {
+			alternative = true
+			break
+		}
+	}
+	return Filter{
+		Name: FilterDeprecation,
+		Reject: func(b Bundle) (string, error) {
+			if !alternative {
+				return "", nil
+			}
+			switch {
+			case b.Deprecation.Bundle != "":
+				return fmt.Sprintf("it's deprecated: %s", b.Deprecation.Bundle), nil
+			case b.Deprecation.Channel != "":
+				return fmt.Sprintf("its %s channel is deprecated: %s", b.Channel, b.Deprecation.Channel), nil
+			}
+			return "", nil
+		},
+	}
+}
@@ -0,0 +1,121 @@
+package sourcer
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/operator-framework/operator-registry/alpha/property"
+
+	platformv1alpha1 "github.com/openshift/api/platform/v1alpha1"
+)
+
+// fileBasedCatalog sources bundles from file-based catalog content that's
+// been mounted into the controller's filesystem, e.g. a ConfigMap volume or
+// the /configs directory of an unpacked catalog image.
+type fileBasedCatalog struct {
+	fsys fs.FS
+
+	mu          sync.Mutex
+	fingerprint string
+	index       catalogIndex
+}
+
+// NewFileBasedCatalogHandler returns a Sourcer that reads the olm.package,
+// olm.channel and olm.bundle declarative config stored under the root directory.
+func NewFileBasedCatalogHandler(root string) Sourcer {
+	return newFileBasedCatalog(os.DirFS(root))
+}
+
+func newFileBasedCatalog(fsys fs.FS) *fileBasedCatalog {
+	return &fileBasedCatalog{
+		fsys: fsys,
+	}
+}
+
+func (f *fileBasedCatalog) Source(ctx context.Context, po *platformv1alpha1.PlatformOperator) (*Bundle, error) {
+	candidates, err := f.Candidates(ctx, po)
+	if err != nil {
+		return nil, err
+	}
+	return latestCandidate(po, candidates)
+}
+
+func (f *fileBasedCatalog) Candidates(ctx context.Context, po *platformv1alpha1.PlatformOperator) ([]Bundle, error) {
+	index, err := f.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return index.Candidates(po.Spec.Package.Name), nil
+}
+
+// Revision identifies the catalog content by the fingerprint of its files.
+func (f *fileBasedCatalog) Revision(context.Context, *platformv1alpha1.PlatformOperator) (string, error) {
+	fingerprint, err := fingerprintFS(f.fsys)
+	if err != nil {
+		return "", fmt.Errorf("failed to read the file-based catalog: %w", err)
+	}
+	return fingerprint, nil
+}
+
+// load returns the catalogIndex for the catalog content, and only re-parses
+// that content when the files on disk have changed since the last load.
+func (f *fileBasedCatalog) load(ctx context.Context) (catalogIndex, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	fingerprint, err := fingerprintFS(f.fsys)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the file-based catalog: %w", err)
+	}
+	if f.index != nil && fingerprint == f.fingerprint {
+		return f.index, nil
+	}
+
+	dc, err := loadDeclarativeConfigFS(ctx, f.fsys)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the file-based catalog: %w", err)
+	}
+	index, err := buildIndex(dc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the file-based catalog: %w", err)
+	}
+	f.index = index
+	f.fingerprint = fingerprint
+
+	return index, nil
+}
+
+// fingerprintFS summarizes the name, size and modification time of every
+// file in fsys so changes to the catalog content can be cheaply detected.
+func fingerprintFS(fsys fs.FS) (string, error) {
+	var fingerprint string
+	err := fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return skipHiddenDir(path, d)
+		}
+		// stat the path rather than the directory entry so symlinked files,
+		// e.g. ConfigMap keys, reflect changes to the file they point to.
+		info, err := fs.Stat(fsys, path)
+		if err != nil {
+			return err
+		}
+		fingerprint += path + ":" + strconv.FormatInt(info.Size(), 10) + ":" + strconv.FormatInt(info.ModTime().UnixNano(), 10) + ";"
+		return nil
+	})
+	return fingerprint, err
+}
+
+func (f *fileBasedCatalog) Providers(ctx context.Context, _ *platformv1alpha1.PlatformOperator, gvk property.GVK) ([]Bundle, error) {
+	index, err := f.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return index.Providers(gvk), nil
+}
@@ -0,0 +1,170 @@
+package sourcer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestFileBasedCatalogSource(t *testing.T) {
+	tests := []struct {
+		name        string
+		dir         string
+		packageName string
+		wantImage   string
+		wantErr     bool
+	}{
+		{
+			name:        "SampleCatalog",
+			dir:         "testdata/fbc/sample",
+			packageName: "combo",
+			wantImage:   "docker.io/tyslaton/combo-platform-operator-bundle:v0.0.1",
+		},
+		{
+			name:        "MultipleChannelsAndFiles",
+			dir:         "testdata/fbc/multi",
+			packageName: "foo",
+			wantImage:   "quay.io/example/foo-bundle:v2.0.0",
+		},
+		{
+			name:        "AvoidsDeprecatedChannelsAndBundles",
+			dir:         "testdata/fbc/deprecated",
+			packageName: "old",
+			wantImage:   "quay.io/example/old-bundle:v1.0.0",
+		},
+		{
+			name:        "MissingPackage",
+			dir:         "testdata/fbc/multi",
+			packageName: "missing",
+			wantErr:     true,
+		},
+		{
+			name:        "MissingBundle",
+			dir:         "testdata/fbc/invalid",
+			packageName: "bar",
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewFileBasedCatalogHandler(tt.dir).Source(context.Background(), newTestPlatformOperator(tt.packageName))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Source() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got.Image != tt.wantImage {
+				t.Errorf("Source() = %s, want %s", got.Image, tt.wantImage)
+			}
+		})
+	}
+}
+
+func TestFileBasedCatalogIndex(t *testing.T) {
+	index, err := newFileBasedCatalog(os.DirFS("testdata/fbc/multi")).load(context.Background())
+	if err != nil {
+		t.Fatalf("load() unexpected error: %v", err)
+	}
+	if got := len(index["foo"]["stable"]); got != 3 {
+		t.Errorf("stable channel has %d bundles, want 3", got)
+	}
+	if got := len(index["foo"]["candidate"]); got != 2 {
+		t.Errorf("candidate channel has %d bundles, want 2", got)
+	}
+	for _, b := range index["foo"]["stable"] {
+		if b.Name == "foo.v1.2.0" && b.Replaces != "foo.v1.1.0" {
+			t.Errorf("foo.v1.2.0 replaces %q in the stable channel, want foo.v1.1.0", b.Replaces)
+		}
+	}
+}
+
+func TestFileBasedCatalogDeprecations(t *testing.T) {
+	index, err := newFileBasedCatalog(os.DirFS("testdata/fbc/deprecated")).load(context.Background())
+	if err != nil {
+		t.Fatalf("load() unexpected error: %v", err)
+	}
+	want := map[string]Deprecation{
+		"old.v1.0.0": {Package: "the old package is replaced by the new package"},
+		"old.v1.1.0": {Package: "the old package is replaced by the new package", Bundle: "old.v1.1.0 has a critical bug"},
+		"old.v2.0.0": {Package: "the old package is replaced by the new package", Channel: "the legacy channel is no longer maintained"},
+	}
+	for _, b := range index.Candidates("old") {
+		if b.Deprecation != want[b.Name] {
+			t.Errorf("%s deprecation = %+v, want %+v", b.Name, b.Deprecation, want[b.Name])
+		}
+	}
+}
+
+func TestFileBasedCatalogReloadsChangedContent(t *testing.T) {
+	dir := t.TempDir()
+	write := func(version string) {
+		t.Helper()
+		catalog := `---
+schema: olm.package
+name: foo
+defaultChannel: stable
+---
+schema: olm.channel
+package: foo
+name: stable
+entries:
+  - name: foo.v` + version + `
+---
+schema: olm.bundle
+package: foo
+name: foo.v` + version + `
+image: quay.io/example/foo-bundle:v` + version + `
+properties:
+  - type: olm.package
+    value:
+      packageName: foo
+      version: ` + version + `
+`
+		path := filepath.Join(dir, "catalog.yaml")
+		if err := os.WriteFile(path, []byte(catalog), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		// bump the modification time so the change is observed on filesystems
+		// with a coarse timestamp resolution.
+		mtime := time.Now().Add(time.Duration(len(version)) * time.Second)
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s := NewFileBasedCatalogHandler(dir)
+	for _, version := range []string{"1.0.0", "1.10.0"} {
+		write(version)
+		got, err := s.Source(context.Background(), newTestPlatformOperator("foo"))
+		if err != nil {
+			t.Fatalf("Source() unexpected error: %v", err)
+		}
+		if got.Version != version {
+			t.Errorf("Source() = %s, want %s", got.Version, version)
+		}
+	}
+}
+
+func TestFileBasedCatalogSkipsHiddenDirectories(t *testing.T) {
+	content, err := os.ReadFile("testdata/fbc/sample/operators.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// ConfigMap volumes expose each key both at the root and within a
+	// timestamped hidden directory.
+	fsys := fstest.MapFS{
+		"operators.yaml":                         {Data: content},
+		"..2024_01_01_00_00_00.0/operators.yaml": {Data: content},
+	}
+	index, err := newFileBasedCatalog(fsys).load(context.Background())
+	if err != nil {
+		t.Fatalf("load() unexpected error: %v", err)
+	}
+	if len(index.Candidates("combo")) == 0 {
+		t.Errorf("expected candidates for the combo package")
+	}
+}
@@ -1,8 +1,12 @@
 package sourcer
 
 import (
+	"fmt"
+
 	"github.com/blang/semver/v4"
 	operatorsv1alpha1 "github.com/operator-framework/api/pkg/operators/v1alpha1"
+
+	"github.com/openshift/platform-operators/internal/clusterfacts"
 )
 
 type filterSourceFn func(cs operatorsv1alpha1.CatalogSource) bool
@@ -33,38 +37,145 @@ func byConnectionReadiness(cs operatorsv1alpha1.CatalogSource) bool {
 
 type bundles []Bundle
 
-type bundleFilterFunc func(b1, b2 *Bundle) bool
+// The names of the filters in a Pipeline, which identify the filter that
+// rejected a candidate bundle.
+const (
+	FilterChannel       = "channel"
+	FilterVersionRange  = "range"
+	FilterCompatibility = "compatibility"
+	FilterDeprecation   = "deprecation"
+	FilterPolicy        = "policy"
+	FilterConstraints   = "constraints"
+)
+
+// Filter is a named predicate that candidate bundles have to pass to be
+// selected. Reject returns why the bundle doesn't pass, or an empty string
+// when it does.
+type Filter struct {
+	Name   string
+	Reject func(Bundle) (string, error)
+}
+
+// Rejection records which filter rejected a candidate bundle, and why.
+type Rejection struct {
+	Bundle Bundle
+	Filter string
+	Reason string
+}
+
+func (r Rejection) String() string {
+	return fmt.Sprintf("%s was rejected by the %s filter because %s", r.Bundle.Name, r.Filter, r.Reason)
+}
+
+// Pipeline chains filters, which candidate bundles have to pass in order.
+type Pipeline []Filter
+
+// NewPipeline returns a Pipeline that runs the filters in the order given.
+func NewPipeline(filters ...Filter) Pipeline {
+	return Pipeline(filters)
+}
+
+// Reject returns the Rejection from the first filter that rejects the b
+// bundle, or nil when b passes every filter.
+func (p Pipeline) Reject(b Bundle) (*Rejection, error) {
+	for _, f := range p {
+		reason, err := f.Reject(b)
+		if err != nil {
+			return nil, fmt.Errorf("failed to run the %s filter on the %s bundle: %w", f.Name, b.Name, err)
+		}
+		if reason != "" {
+			return &Rejection{Bundle: b, Filter: f.Name, Reason: reason}, nil
+		}
+	}
+	return nil, nil
+}
+
+// Run returns the candidates that pass every filter, along with a Rejection
+// for each candidate that doesn't.
+func (p Pipeline) Run(candidates []Bundle) ([]Bundle, []Rejection, error) {
+	var (
+		accepted []Bundle
+		rejected []Rejection
+	)
+	for _, b := range candidates {
+		rejection, err := p.Reject(b)
+		if err != nil {
+			return nil, nil, err
+		}
+		if rejection != nil {
+			rejected = append(rejected, *rejection)
+			continue
+		}
+		accepted = append(accepted, b)
+	}
+	return accepted, rejected, nil
+}
+
+// Filter returns the bundles that pass every filter, and a Rejection for each
+// bundle that doesn't.
+func (bundles bundles) Filter(filters ...Filter) (bundles, []Rejection, error) {
+	return NewPipeline(filters...).Run(bundles)
+}
 
-// TODO(tflannag): Support variadic filtering functions
-func (bundles bundles) Filter(f bundleFilterFunc) (*Bundle, error) {
+// Latest returns the bundle that Compare prefers over every other bundle,
+// regardless of the order of the bundles.
+func (bundles bundles) Latest() (*Bundle, error) {
 	var (
 		desiredBundle *Bundle
 	)
 	for _, bundle := range bundles {
 		bundle := bundle
 
-		if desiredBundle == nil {
-			desiredBundle = &bundle
-		}
-		if f(&bundle, desiredBundle) {
+		if desiredBundle == nil || Compare(bundle, *desiredBundle) > 0 {
 			desiredBundle = &bundle
 		}
 	}
 	return desiredBundle, nil
 }
 
-func (bundles bundles) Latest() (*Bundle, error) {
-	return bundles.Filter(byHighestSemver)
+// ChannelFilter rejects bundles that aren't in the channel channel.
+func ChannelFilter(channel string) Filter {
+	return Filter{
+		Name: FilterChannel,
+		Reject: func(b Bundle) (string, error) {
+			if b.Channel == channel {
+				return "", nil
+			}
+			return fmt.Sprintf("it's in the %s channel rather than the %s channel", b.Channel, channel), nil
+		},
+	}
 }
 
-func byHighestSemver(currBundle, desiredBundle *Bundle) bool {
-	currV, err := semver.Parse(currBundle.Version)
+// VersionRangeFilter rejects bundles whose version isn't within the
+// versionRange semver range.
+func VersionRangeFilter(versionRange string) (Filter, error) {
+	r, err := semver.ParseRange(versionRange)
 	if err != nil {
-		return false
+		return Filter{}, fmt.Errorf("failed to parse the %q version range: %w", versionRange, err)
 	}
-	desiredV, err := semver.Parse(desiredBundle.Version)
-	if err != nil {
-		return false
+	return Filter{
+		Name: FilterVersionRange,
+		Reject: func(b Bundle) (string, error) {
+			v, err := semver.Parse(b.Version)
+			if err != nil {
+				return fmt.Sprintf("its %q version isn't valid semver", b.Version), nil
+			}
+			if r(v) {
+				return "", nil
+			}
+			return fmt.Sprintf("its %s version isn't within the %q range", b.Version, versionRange), nil
+		},
+	}, nil
+}
+
+// CompatibilityFilter rejects bundles that can't run on the cluster described
+// by facts, including those that don't declare support for the cluster's
+// infrastructure features when requireFeatures is set.
+func CompatibilityFilter(facts *clusterfacts.Facts, requireFeatures bool) Filter {
+	return Filter{
+		Name: FilterCompatibility,
+		Reject: func(b Bundle) (string, error) {
+			return Incompatible(b, facts, requireFeatures)
+		},
 	}
-	return currV.Compare(desiredV) == 1
 }
@@ -0,0 +1,60 @@
+package sourcer
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestPipelineRun(t *testing.T) {
+	candidates := []Bundle{
+		{Name: "foo.v1.0.0", Channel: "stable", Version: "1.0.0"},
+		{Name: "foo.v2.0.0", Channel: "stable", Version: "2.0.0"},
+		{Name: "foo.v2.1.0", Channel: "candidate", Version: "2.1.0"},
+		{Name: "foo.v3.0.0-bad", Channel: "stable", Version: "three"},
+	}
+	versionRange, err := VersionRangeFilter(">=2.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	accepted, rejected, err := NewPipeline(ChannelFilter("stable"), versionRange).Run(candidates)
+	if err != nil {
+		t.Fatalf("Run() unexpected error: %v", err)
+	}
+	var gotAccepted []string
+	for _, b := range accepted {
+		gotAccepted = append(gotAccepted, b.Name)
+	}
+	if want := []string{"foo.v2.0.0"}; !reflect.DeepEqual(gotAccepted, want) {
+		t.Errorf("Run() accepted %v, want %v", gotAccepted, want)
+	}
+
+	var gotRejected []string
+	for _, r := range rejected {
+		gotRejected = append(gotRejected, r.String())
+	}
+	wantRejected := []string{
+		`foo.v1.0.0 was rejected by the range filter because its 1.0.0 version isn't within the ">=2.0.0" range`,
+		"foo.v2.1.0 was rejected by the channel filter because it's in the candidate channel rather than the stable channel",
+		`foo.v3.0.0-bad was rejected by the range filter because its "three" version isn't valid semver`,
+	}
+	if !reflect.DeepEqual(gotRejected, wantRejected) {
+		t.Errorf("Run() rejected %q, want %q", gotRejected, wantRejected)
+	}
+}
+
+func TestPipelineFilterError(t *testing.T) {
+	failing := Filter{
+		Name: "failing",
+		Reject: func(Bundle) (string, error) {
+			return "", errors.New("boom")
+		},
+	}
+	if _, _, err := NewPipeline(ChannelFilter("stable"), failing).Run([]Bundle{{Name: "foo.v1.0.0", Channel: "stable"}}); err == nil {
+		t.Error("Run() expected an error from the failing filter")
+	}
+	if _, err := VersionRangeFilter("not a range"); err == nil {
+		t.Error("VersionRangeFilter() expected an error for an invalid range")
+	}
+}
@@ -0,0 +1,137 @@
+package sourcer
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/operator-framework/operator-registry/pkg/api"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fakeRegistryServer serves an in-memory set of bundles over the registry
+// gRPC API. The head of each channel is the bundle no other bundle in the
+// channel replaces or skips, unless heads names it by package/channel.
+type fakeRegistryServer struct {
+	api.UnimplementedRegistryServer
+	bundles []*api.Bundle
+	heads   map[string]string
+}
+
+func (s *fakeRegistryServer) ListBundles(_ *api.ListBundlesRequest, stream api.Registry_ListBundlesServer) error {
+	for _, b := range s.bundles {
+		if err := stream.Send(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *fakeRegistryServer) GetPackage(_ context.Context, req *api.GetPackageRequest) (*api.Package, error) {
+	var (
+		pkg      *api.Package
+		replaced = make(map[string]struct{})
+	)
+	for _, b := range s.bundles {
+		if b.GetPackageName() != req.GetName() {
+			continue
+		}
+		if pkg == nil {
+			pkg = &api.Package{Name: req.GetName()}
+		}
+		replaced[b.GetChannelName()+"/"+b.GetReplaces()] = struct{}{}
+		for _, skip := range b.GetSkips() {
+			replaced[b.GetChannelName()+"/"+skip] = struct{}{}
+		}
+	}
+	if pkg == nil {
+		return nil, status.Errorf(codes.NotFound, "package %s not found", req.GetName())
+	}
+	channels := make(map[string]struct{})
+	for _, b := range s.bundles {
+		if b.GetPackageName() != req.GetName() {
+			continue
+		}
+		if _, ok := channels[b.GetChannelName()]; ok {
+			continue
+		}
+		head, ok := s.heads[req.GetName()+"/"+b.GetChannelName()]
+		if !ok {
+			if _, replaced := replaced[b.GetChannelName()+"/"+b.GetCsvName()]; replaced {
+				continue
+			}
+			head = b.GetCsvName()
+		}
+		channels[b.GetChannelName()] = struct{}{}
+		pkg.Channels = append(pkg.Channels, &api.Channel{Name: b.GetChannelName(), CsvName: head})
+	}
+	return pkg, nil
+}
+
+func (s *fakeRegistryServer) GetBundleForChannel(ctx context.Context, req *api.GetBundleInChannelRequest) (*api.Bundle, error) {
+	pkg, err := s.GetPackage(ctx, &api.GetPackageRequest{Name: req.GetPkgName()})
+	if err != nil {
+		return nil, err
+	}
+	for _, channel := range pkg.GetChannels() {
+		if channel.GetName() == req.GetChannelName() {
+			return s.GetBundle(ctx, &api.GetBundleRequest{PkgName: req.GetPkgName(), ChannelName: channel.GetName(), CsvName: channel.GetCsvName()})
+		}
+	}
+	return nil, status.Errorf(codes.NotFound, "channel %s not found", req.GetChannelName())
+}
+
+func (s *fakeRegistryServer) GetBundle(_ context.Context, req *api.GetBundleRequest) (*api.Bundle, error) {
+	for _, b := range s.bundles {
+		if b.GetPackageName() == req.GetPkgName() && b.GetChannelName() == req.GetChannelName() && b.GetCsvName() == req.GetCsvName() {
+			return b, nil
+		}
+	}
+	return nil, status.Errorf(codes.NotFound, "bundle %s not found", req.GetCsvName())
+}
+
+// startFakeRegistryServer runs s on a random local port and returns the
+// address it's listening on, along with a function that stops the server.
+func startFakeRegistryServer(t testing.TB, s *fakeRegistryServer) (string, func()) {
+	t.Helper()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	srv := grpc.NewServer()
+	api.RegisterRegistryServer(srv, s)
+	go func() {
+		_ = srv.Serve(lis)
+	}()
+	t.Cleanup(srv.Stop)
+
+	return lis.Addr().String(), srv.Stop
+}
+
+// syntheticCatalog generates a catalog with a single stable channel for each
+// of the numPackages packages, where each channel is a replaces chain of
+// numBundles bundles.
+func syntheticCatalog(numPackages, numBundles int) []*api.Bundle {
+	var catalog []*api.Bundle
+	for p := 0; p < numPackages; p++ {
+		pkg := fmt.Sprintf("package-%d", p)
+		for v := 0; v < numBundles; v++ {
+			b := &api.Bundle{
+				CsvName:     fmt.Sprintf("%s.v1.0.%d", pkg, v),
+				PackageName: pkg,
+				ChannelName: "stable",
+				Version:     fmt.Sprintf("1.0.%d", v),
+				BundlePath:  fmt.Sprintf("quay.io/example/%s-bundle:v1.0.%d", pkg, v),
+			}
+			if v > 0 {
+				b.Replaces = fmt.Sprintf("%s.v1.0.%d", pkg, v-1)
+			}
+			catalog = append(catalog, b)
+		}
+	}
+	return catalog
+}
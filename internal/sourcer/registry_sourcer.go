@@ -2,10 +2,17 @@ package sourcer
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"sync"
 
 	operatorsv1alpha1 "github.com/operator-framework/api/pkg/operators/v1alpha1"
+	"github.com/operator-framework/operator-registry/alpha/property"
+	"github.com/operator-framework/operator-registry/pkg/api"
 	registryClient "github.com/operator-framework/operator-registry/pkg/client"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
@@ -17,71 +24,382 @@ const (
 	catalogNamespace = "openshift-marketplace"
 )
 
-func getDefaultCatalogNN() types.NamespacedName {
+// DefaultCatalogSourceKey returns the key of the CatalogSource that platform
+// operators are sourced from when they don't select a catalog.
+func DefaultCatalogSourceKey() types.NamespacedName {
 	return types.NamespacedName{Name: catalogName, Namespace: catalogNamespace}
 }
 
 type catalogSource struct {
 	client.Client
+	cache *catalogCache
+	conns *connectionManager
 }
 
-func NewCatalogSourceHandler(c client.Client) Sourcer {
-	return &catalogSource{
+// NewCatalogSourceHandler returns a Sourcer that resolves bundles from the
+// registry server backing the default CatalogSource. When useCache is false,
+// candidates are looked up with package-scoped queries on every call instead
+// of being served from an index of the entire catalog.
+func NewCatalogSourceHandler(c client.Client, opts ConnectionOptions, useCache bool) Sourcer {
+	cs := &catalogSource{
 		Client: c,
+		conns:  newConnectionManager(opts),
 	}
+	if useCache {
+		cs.cache = newCatalogCache()
+	}
+	return cs
 }
 
 func (cs catalogSource) Source(ctx context.Context, po *platformv1alpha1.PlatformOperator) (*Bundle, error) {
-	catalog := &operatorsv1alpha1.CatalogSource{}
-	if err := cs.Client.Get(ctx, getDefaultCatalogNN(), catalog); err != nil {
+	candidates, err := cs.Candidates(ctx, po)
+	if err != nil {
 		return nil, err
 	}
-	sources := sources([]operatorsv1alpha1.CatalogSource{*catalog})
+	return latestCandidate(po, candidates)
+}
 
-	candidates, err := sources.Filter(byConnectionReadiness).GetCandidates(ctx, po)
+func (cs catalogSource) Candidates(ctx context.Context, po *platformv1alpha1.PlatformOperator) ([]Bundle, error) {
+	catalog, err := cs.defaultCatalog(ctx)
 	if err != nil {
 		return nil, err
 	}
-	if len(candidates) == 0 {
-		return nil, fmt.Errorf("failed to find candidate olm.bundles from the %s package", po.Spec.Package.Name)
+	sources := sources([]operatorsv1alpha1.CatalogSource{*catalog})
+
+	return cs.GetCandidates(ctx, sources.Filter(byConnectionReadiness), po)
+}
+
+// Revision identifies the contents of the default CatalogSource by the same
+// key its cached index is built under.
+func (cs catalogSource) Revision(ctx context.Context, _ *platformv1alpha1.PlatformOperator) (string, error) {
+	catalog, err := cs.defaultCatalog(ctx)
+	if err != nil {
+		return "", err
 	}
-	latestBundle, err := candidates.Latest()
+	return string(catalog.GetUID()) + "|" + catalogSourceCacheKey(*catalog), nil
+}
+
+// defaultCatalog returns the default CatalogSource. The index cached for it,
+// and the connection to its registry server, are dropped once it's deleted,
+// so a recreated CatalogSource is never served from the deleted one's.
+func (cs catalogSource) defaultCatalog(ctx context.Context) (*operatorsv1alpha1.CatalogSource, error) {
+	catalog := &operatorsv1alpha1.CatalogSource{}
+	if err := cs.Client.Get(ctx, DefaultCatalogSourceKey(), catalog); err != nil {
+		if apierrors.IsNotFound(err) {
+			cs.conns.Close(DefaultCatalogSourceKey())
+			if cs.cache != nil {
+				cs.cache.Evict(DefaultCatalogSourceKey())
+			}
+		}
+		return nil, err
+	}
+	return catalog, nil
+}
+
+// Providers returns the bundles in the default CatalogSource that provide the
+// gvk API. Without a cache, only the provider from the default channel of
+// the providing package is returned.
+func (cs catalogSource) Providers(ctx context.Context, _ *platformv1alpha1.PlatformOperator, gvk property.GVK) ([]Bundle, error) {
+	catalog, err := cs.defaultCatalog(ctx)
 	if err != nil {
 		return nil, err
 	}
+	if !byConnectionReadiness(*catalog) {
+		return nil, fmt.Errorf("the %s/%s catalog isn't ready", catalog.GetName(), catalog.GetNamespace())
+	}
+	if cs.cache != nil {
+		index, err := cs.cache.Get(ctx, client.ObjectKeyFromObject(catalog), catalogSourceCacheKey(*catalog), func(ctx context.Context) (catalogIndex, error) {
+			return cs.listBundles(ctx, *catalog)
+		})
+		if err != nil {
+			return nil, err
+		}
+		return index.Providers(gvk), nil
+	}
 
-	return latestBundle, nil
+	var providers []Bundle
+	err = cs.conns.Do(ctx, *catalog, func(ctx context.Context, rc registryClient.Interface) error {
+		b, err := rc.GetBundleThatProvides(ctx, gvk.Group, gvk.Version, gvk.Kind)
+		if err != nil {
+			if status.Code(err) == codes.NotFound {
+				return nil
+			}
+			return err
+		}
+		providers = []Bundle{newBundle(b)}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to find providers of %s/%s, Kind=%s: %w", gvk.Group, gvk.Version, gvk.Kind, err)
+	}
+	return providers, nil
 }
 
-func (s sources) GetCandidates(ctx context.Context, po *platformv1alpha1.PlatformOperator) (bundles, error) {
+func (cs catalogSource) GetCandidates(ctx context.Context, s sources, po *platformv1alpha1.PlatformOperator) (bundles, error) {
 	// TODO(tflannag): This doesn't account for edge case where there are zero sources.
 	if len(s) != 1 {
 		return nil, fmt.Errorf("validation error: only a single catalog source is supported during phase 0")
 	}
-	cs := s[0]
+	if cs.cache == nil {
+		return cs.queryPackage(ctx, s[0], po.Spec.Package.Name)
+	}
+	catalog := s[0]
+	index, err := cs.cache.Get(ctx, client.ObjectKeyFromObject(&catalog), catalogSourceCacheKey(catalog), func(ctx context.Context) (catalogIndex, error) {
+		return cs.listBundles(ctx, catalog)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return index.Candidates(po.Spec.Package.Name), nil
+}
 
-	rc, err := registryClient.NewClient(cs.Status.GRPCConnectionState.Address)
+// listBundles builds a catalogIndex by streaming every bundle that's being
+// served by the cs CatalogSource's registry server. Each channel's bundles
+// are ordered as queryPackage finds them walking the channel from its head,
+// followed by the bundles that walk doesn't reach, e.g. those only covered by
+// a skipRange. Streamed bundles only carry their own deprecation, so the
+// package and channel deprecations are looked up for every package in the
+// index.
+func (cs catalogSource) listBundles(ctx context.Context, catalog operatorsv1alpha1.CatalogSource) (catalogIndex, error) {
+	var index catalogIndex
+	err := cs.conns.Do(ctx, catalog, func(ctx context.Context, rc registryClient.Interface) error {
+		it, err := rc.ListBundles(ctx)
+		if err != nil {
+			return err
+		}
+		streamed := make(catalogIndex)
+		for b := it.Next(); b != nil; b = it.Next() {
+			streamed.add(newBundle(b))
+		}
+		if err := it.Error(); err != nil {
+			return err
+		}
+		packageNames := make([]string, 0, len(streamed))
+		for packageName := range streamed {
+			packageNames = append(packageNames, packageName)
+		}
+		packages, err := getPackages(ctx, rc, packageNames)
+		if err != nil {
+			return err
+		}
+		index = make(catalogIndex, len(streamed))
+		for packageName, channels := range streamed {
+			pkg := packages[packageName]
+			heads := make(map[string]string, len(pkg.GetChannels()))
+			for _, channel := range pkg.GetChannels() {
+				heads[channel.GetName()] = channel.GetCsvName()
+			}
+			for channelName, channelBundles := range channels {
+				channelBundles = orderFromHead(channelBundles, heads[channelName])
+				deprecateBundles(channelBundles, pkg)
+				for _, b := range channelBundles {
+					index.add(b)
+				}
+			}
+		}
+		return nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to register client from the %s/%s grpc connection: %w", cs.GetName(), cs.GetNamespace(), err)
+		return nil, fmt.Errorf("failed to list bundles from the %s/%s catalog: %w", catalog.GetName(), catalog.GetNamespace(), err)
 	}
-	it, err := rc.ListBundles(ctx)
+	return index, nil
+}
+
+// maxConcurrentPackageQueries bounds the GetPackage calls that getPackages
+// keeps in flight against a single registry server.
+const maxConcurrentPackageQueries = 8
+
+// getPackages looks up each of the packageNames packages concurrently.
+func getPackages(ctx context.Context, rc registryClient.Interface, packageNames []string) (map[string]*api.Package, error) {
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		firstErr error
+		packages = make(map[string]*api.Package, len(packageNames))
+		sem      = make(chan struct{}, maxConcurrentPackageQueries)
+	)
+	for _, packageName := range packageNames {
+		packageName := packageName
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			pkg, err := rc.GetPackage(ctx, packageName)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				return
+			}
+			packages[packageName] = pkg
+		}()
+	}
+	wg.Wait()
+	return packages, firstErr
+}
+
+// queryPackage finds the bundles in the packageName package by walking the
+// upgrade graph of each channel, starting from the channel head. This only
+// requests the bundles from the package, rather than the entire catalog, but
+// can't find the bundles that are only covered by a skipRange, as they can
+// only be looked up by name.
+func (cs catalogSource) queryPackage(ctx context.Context, catalog operatorsv1alpha1.CatalogSource, packageName string) (bundles, error) {
+	var candidates bundles
+	err := cs.conns.Do(ctx, catalog, func(ctx context.Context, rc registryClient.Interface) error {
+		candidates = nil
+
+		pkg, err := rc.GetPackage(ctx, packageName)
+		if err != nil {
+			if status.Code(err) == codes.NotFound {
+				return nil
+			}
+			return err
+		}
+		for _, channel := range pkg.GetChannels() {
+			channelBundles, err := queryChannel(ctx, rc, packageName, channel.GetName())
+			if err != nil {
+				return err
+			}
+			deprecateBundles(channelBundles, pkg)
+			candidates = append(candidates, channelBundles...)
+		}
+		return nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to list bundles from the %s/%s catalog: %w", cs.GetName(), cs.GetNamespace(), err)
+		return nil, fmt.Errorf("failed to query the %s package from the %s/%s catalog: %w", packageName, catalog.GetName(), catalog.GetNamespace(), err)
 	}
+	return candidates, nil
+}
 
+// queryChannel returns the head of the channelName channel and every bundle
+// it transitively replaces or skips within the channel.
+func queryChannel(ctx context.Context, rc registryClient.Interface, packageName, channelName string) (bundles, error) {
+	head, err := rc.GetBundleInPackageChannel(ctx, packageName, channelName)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
 	var (
-		candidates bundles
+		channelBundles = bundles{newBundle(head)}
+		visited        = map[string]struct{}{head.GetCsvName(): {}}
+		queue          = predecessors(channelBundles[0])
 	)
-	for b := it.Next(); b != nil; b = it.Next() {
-		if b.PackageName != po.Spec.Package.Name {
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		if _, ok := visited[name]; ok || name == "" {
 			continue
 		}
-		candidates = append(candidates, Bundle{
-			Version:  b.GetVersion(),
-			Image:    b.GetBundlePath(),
-			Skips:    b.GetSkips(),
-			Replaces: b.GetReplaces(),
+		visited[name] = struct{}{}
+
+		b, err := rc.GetBundle(ctx, packageName, channelName, name)
+		if err != nil {
+			// skipped bundles, and the tail of a replaces chain, don't
+			// need to be present in the catalog.
+			if status.Code(err) == codes.NotFound {
+				continue
+			}
+			return nil, err
+		}
+		channelBundles = append(channelBundles, newBundle(b))
+		queue = append(queue, predecessors(channelBundles[len(channelBundles)-1])...)
+	}
+	return channelBundles, nil
+}
+
+// orderFromHead orders the channel bundles by when queryChannel would find
+// them walking the channel from the head bundle, followed by the bundles the
+// walk doesn't reach, in their original order. Every bundle is kept.
+func orderFromHead(channel bundles, head string) bundles {
+	byName := make(map[string]Bundle, len(channel))
+	for _, b := range channel {
+		byName[b.Name] = b
+	}
+	var (
+		ordered = make(bundles, 0, len(channel))
+		visited = make(map[string]struct{})
+		queue   = []string{head}
+	)
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		if _, ok := visited[name]; ok || name == "" {
+			continue
+		}
+		visited[name] = struct{}{}
+
+		b, ok := byName[name]
+		if !ok {
+			continue
+		}
+		ordered = append(ordered, b)
+		queue = append(queue, predecessors(b)...)
+	}
+	for _, b := range channel {
+		if _, ok := visited[b.Name]; !ok {
+			ordered = append(ordered, b)
+		}
+	}
+	return ordered
+}
+
+// predecessors returns the names of the bundles that b replaces or skips.
+func predecessors(b Bundle) []string {
+	return append([]string{b.Replaces}, b.Skips...)
+}
+
+func newBundle(b *api.Bundle) Bundle {
+	var hasCSVMetadata bool
+	props := make([]property.Property, 0, len(b.GetProperties()))
+	for _, p := range b.GetProperties() {
+		props = append(props, property.Property{
+			Type:  p.GetType(),
+			Value: json.RawMessage(p.GetValue()),
 		})
+		hasCSVMetadata = hasCSVMetadata || p.GetType() == property.TypeCSVMetadata
+	}
+	// registries serving SQLite catalogs don't produce an olm.csv.metadata
+	// property, so derive it from the ClusterServiceVersion when it's served.
+	if !hasCSVMetadata && b.GetCsvJson() != "" {
+		csv := operatorsv1alpha1.ClusterServiceVersion{}
+		if err := json.Unmarshal([]byte(b.GetCsvJson()), &csv); err == nil {
+			props = append(props, property.MustBuildCSVMetadata(csv))
+		}
+	}
+	return Bundle{
+		Name:      b.GetCsvName(),
+		Package:   b.GetPackageName(),
+		Channel:   b.GetChannelName(),
+		Version:   b.GetVersion(),
+		Image:     b.GetBundlePath(),
+		Skips:     b.GetSkips(),
+		Replaces:  b.GetReplaces(),
+		SkipRange: b.GetSkipRange(),
+
+		Properties: props,
+		Deprecation: Deprecation{
+			Bundle: b.GetDeprecation().GetMessage(),
+		},
+	}
+}
+
+// deprecateBundles sets the package and channel deprecations of the pkg
+// package on the bs bundles.
+func deprecateBundles(bs bundles, pkg *api.Package) {
+	channels := make(map[string]string, len(pkg.GetChannels()))
+	for _, channel := range pkg.GetChannels() {
+		channels[channel.GetName()] = channel.GetDeprecation().GetMessage()
+	}
+	for i := range bs {
+		bs[i].Deprecation.Package = pkg.GetDeprecation().GetMessage()
+		bs[i].Deprecation.Channel = channels[bs[i].Channel]
 	}
-	return candidates, nil
 }
@@ -0,0 +1,168 @@
+package sourcer
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/operator-framework/operator-registry/pkg/api"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	platformv1alpha1 "github.com/openshift/api/platform/v1alpha1"
+)
+
+func newTestPlatformOperator(packageName string) *platformv1alpha1.PlatformOperator {
+	return &platformv1alpha1.PlatformOperator{
+		ObjectMeta: metav1.ObjectMeta{Name: packageName},
+		Spec: platformv1alpha1.PlatformOperatorSpec{
+			Package: platformv1alpha1.Package{Name: packageName},
+		},
+	}
+}
+
+func candidateNames(candidates bundles) []string {
+	var names []string
+	for _, b := range candidates {
+		names = append(names, b.Channel+"/"+b.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestGetCandidatesWithoutCache(t *testing.T) {
+	catalog := append(syntheticCatalog(3, 3),
+		&api.Bundle{CsvName: "package-1.v2.0.0", PackageName: "package-1", ChannelName: "fast", Version: "2.0.0"},
+		&api.Bundle{CsvName: "package-1.v2.1.0", PackageName: "package-1", ChannelName: "fast", Version: "2.1.0", Skips: []string{"package-1.v2.0.0"}},
+		// package-2.v3.0.0 is only covered by the head's skipRange, and
+		// package-2.v2.9.0 isn't part of the upgrade graph at all.
+		&api.Bundle{CsvName: "package-2.v2.9.0", PackageName: "package-2", ChannelName: "fast", Version: "2.9.0"},
+		&api.Bundle{CsvName: "package-2.v3.0.0", PackageName: "package-2", ChannelName: "fast", Version: "3.0.0"},
+		&api.Bundle{CsvName: "package-2.v3.1.0", PackageName: "package-2", ChannelName: "fast", Version: "3.1.0", SkipRange: "<3.1.0"},
+	)
+	address, _ := startFakeRegistryServer(t, &fakeRegistryServer{
+		bundles: catalog,
+		heads:   map[string]string{"package-2/fast": "package-2.v3.1.0"},
+	})
+	cs := newTestCatalogSource(address, nil)
+
+	cached := NewCatalogSourceHandler(nil, newTestConnectionOptions(), true).(*catalogSource)
+	targeted := NewCatalogSourceHandler(nil, newTestConnectionOptions(), false).(*catalogSource)
+
+	tests := []struct {
+		name        string
+		packageName string
+		want        int
+		// wantCached is the number of bundles the cache serves, when it
+		// differs from want.
+		wantCached int
+	}{
+		{
+			name:        "ReplacesChain",
+			packageName: "package-0",
+			want:        3,
+		},
+		{
+			name:        "MultipleChannelsWithSkips",
+			packageName: "package-1",
+			want:        5,
+		},
+		{
+			// the cache keeps the bundles the upgrade graph doesn't reach,
+			// which can't be looked up without it.
+			name:        "BundlesOutsideTheUpgradeGraph",
+			packageName: "package-2",
+			want:        4,
+			wantCached:  6,
+		},
+		{
+			name:        "MissingPackage",
+			packageName: "missing",
+			want:        0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			po := newTestPlatformOperator(tt.packageName)
+
+			want, err := cached.GetCandidates(context.Background(), sources{cs}, po)
+			if err != nil {
+				t.Fatalf("GetCandidates() with cache unexpected error: %v", err)
+			}
+			got, err := targeted.GetCandidates(context.Background(), sources{cs}, po)
+			if err != nil {
+				t.Fatalf("GetCandidates() without cache unexpected error: %v", err)
+			}
+			if len(got) != tt.want {
+				t.Errorf("GetCandidates() returned %d bundles, want %d", len(got), tt.want)
+			}
+			wantCached := tt.wantCached
+			if wantCached == 0 {
+				wantCached = tt.want
+			}
+			if len(want) != wantCached {
+				t.Errorf("GetCandidates() with cache returned %d bundles, want %d", len(want), wantCached)
+			}
+			cachedNames := make(map[string]bool, len(want))
+			for _, name := range candidateNames(want) {
+				cachedNames[name] = true
+			}
+			for _, name := range candidateNames(got) {
+				if !cachedNames[name] {
+					t.Errorf("GetCandidates() returned %s, which the cache doesn't serve", name)
+				}
+			}
+		})
+	}
+}
+
+func TestOrderFromHead(t *testing.T) {
+	channel := bundles{
+		{Name: "foo.v1.0.0"},
+		{Name: "foo.v1.1.0", Replaces: "foo.v1.0.0"},
+		// outside the upgrade graph.
+		{Name: "foo.v1.2.0"},
+		{Name: "foo.v1.3.0", Replaces: "foo.v1.1.0"},
+	}
+	var got []string
+	for _, b := range orderFromHead(channel, "foo.v1.3.0") {
+		got = append(got, b.Name)
+	}
+	want := []string{"foo.v1.3.0", "foo.v1.1.0", "foo.v1.0.0", "foo.v1.2.0"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("orderFromHead() = %v, want %v", got, want)
+	}
+}
+
+// BenchmarkGetCandidates compares streaming the entire catalog against
+// querying only the requested package from a large synthetic catalog.
+func BenchmarkGetCandidates(b *testing.B) {
+	address, _ := startFakeRegistryServer(b, &fakeRegistryServer{bundles: syntheticCatalog(500, 20)})
+	cs := newTestCatalogSource(address, nil)
+	po := newTestPlatformOperator("package-250")
+
+	b.Run("ListBundles", func(b *testing.B) {
+		sourcer := NewCatalogSourceHandler(nil, newTestConnectionOptions(), false).(*catalogSource)
+		for i := 0; i < b.N; i++ {
+			index, err := sourcer.listBundles(context.Background(), cs)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if len(index.Candidates(po.Spec.Package.Name)) == 0 {
+				b.Fatal("expected candidates")
+			}
+		}
+	})
+	b.Run("TargetedQueries", func(b *testing.B) {
+		sourcer := NewCatalogSourceHandler(nil, newTestConnectionOptions(), false).(*catalogSource)
+		for i := 0; i < b.N; i++ {
+			candidates, err := sourcer.GetCandidates(context.Background(), sources{cs}, po)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if len(candidates) == 0 {
+				b.Fatal("expected candidates")
+			}
+		}
+	})
+}
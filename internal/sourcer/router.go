@@ -0,0 +1,93 @@
+package sourcer
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/operator-framework/operator-registry/alpha/property"
+
+	platformv1alpha1 "github.com/openshift/api/platform/v1alpha1"
+	platformtypes "github.com/openshift/platform-operators/api/v1alpha1"
+)
+
+// catalogRouter dispatches each PlatformOperator to the Sourcer backing the
+// catalog it has selected through the platformtypes.AnnotationCatalog annotation.
+type catalogRouter struct {
+	defaultSourcer  Sourcer
+	catalogs        map[string]Sourcer
+	clusterCatalogs CatalogSet
+}
+
+// CatalogSet is a Sourcer that serves a changing set of named catalogs.
+type CatalogSet interface {
+	Sourcer
+
+	// HasCatalog reports whether the name catalog currently exists.
+	HasCatalog(ctx context.Context, name string) (bool, error)
+}
+
+// NewCatalogRouter returns a Sourcer that sources PlatformOperators from the
+// named catalogs, falling back to defaultSourcer when no catalog was selected.
+// Catalog names that aren't configured are resolved against clusterCatalogs
+// as ClusterCatalog names, when clusterCatalogs is non-nil and serves a
+// ClusterCatalog with that name.
+func NewCatalogRouter(defaultSourcer Sourcer, catalogs map[string]Sourcer, clusterCatalogs CatalogSet) Sourcer {
+	return &catalogRouter{
+		defaultSourcer:  defaultSourcer,
+		catalogs:        catalogs,
+		clusterCatalogs: clusterCatalogs,
+	}
+}
+
+func (r *catalogRouter) Source(ctx context.Context, po *platformv1alpha1.PlatformOperator) (*Bundle, error) {
+	s, err := r.route(ctx, po)
+	if err != nil {
+		return nil, err
+	}
+	return s.Source(ctx, po)
+}
+
+func (r *catalogRouter) Candidates(ctx context.Context, po *platformv1alpha1.PlatformOperator) ([]Bundle, error) {
+	s, err := r.route(ctx, po)
+	if err != nil {
+		return nil, err
+	}
+	return s.Candidates(ctx, po)
+}
+
+func (r *catalogRouter) Providers(ctx context.Context, po *platformv1alpha1.PlatformOperator, gvk property.GVK) ([]Bundle, error) {
+	s, err := r.route(ctx, po)
+	if err != nil {
+		return nil, err
+	}
+	return s.Providers(ctx, po, gvk)
+}
+
+func (r *catalogRouter) Revision(ctx context.Context, po *platformv1alpha1.PlatformOperator) (string, error) {
+	s, err := r.route(ctx, po)
+	if err != nil {
+		return "", err
+	}
+	return Revision(ctx, s, po)
+}
+
+// route returns the Sourcer for the catalog selected by the po PlatformOperator.
+func (r *catalogRouter) route(ctx context.Context, po *platformv1alpha1.PlatformOperator) (Sourcer, error) {
+	name, ok := po.GetAnnotations()[platformtypes.AnnotationCatalog]
+	if !ok || name == "" {
+		return r.defaultSourcer, nil
+	}
+	if s, ok := r.catalogs[name]; ok {
+		return s, nil
+	}
+	if r.clusterCatalogs != nil {
+		exists, err := r.clusterCatalogs.HasCatalog(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			return r.clusterCatalogs, nil
+		}
+	}
+	return nil, fmt.Errorf("the %s platform operator references the unknown %q catalog", po.GetName(), name)
+}
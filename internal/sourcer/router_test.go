@@ -0,0 +1,96 @@
+package sourcer
+
+import (
+	"context"
+	"testing"
+
+	"github.com/operator-framework/operator-registry/alpha/property"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	platformv1alpha1 "github.com/openshift/api/platform/v1alpha1"
+	platformtypes "github.com/openshift/platform-operators/api/v1alpha1"
+)
+
+// namedSourcer is a Sourcer whose candidates identify the catalog they came from.
+type namedSourcer string
+
+func (s namedSourcer) Source(ctx context.Context, po *platformv1alpha1.PlatformOperator) (*Bundle, error) {
+	candidates, err := s.Candidates(ctx, po)
+	if err != nil {
+		return nil, err
+	}
+	return &candidates[0], nil
+}
+
+func (s namedSourcer) Candidates(context.Context, *platformv1alpha1.PlatformOperator) ([]Bundle, error) {
+	return []Bundle{{Name: string(s)}}, nil
+}
+
+func (s namedSourcer) Providers(context.Context, *platformv1alpha1.PlatformOperator, property.GVK) ([]Bundle, error) {
+	return nil, nil
+}
+
+func TestCatalogRouter(t *testing.T) {
+	clusterCatalogs := NewClusterCatalogHandler(&fakeClusterCatalogClient{
+		catalogs: []unstructured.Unstructured{newTestClusterCatalog("operators", "https://catalogd.example.com", "sha256:1", false)},
+	}, nil)
+
+	tests := []struct {
+		name            string
+		catalog         string
+		clusterCatalogs CatalogSet
+		want            string
+		wantErr         bool
+	}{
+		{
+			name: "DefaultCatalog",
+			want: "default",
+		},
+		{
+			name:    "ConfiguredCatalog",
+			catalog: "fbc",
+			want:    "fbc",
+		},
+		{
+			name:            "ConfiguredCatalogTakesPrecedence",
+			catalog:         "fbc",
+			clusterCatalogs: clusterCatalogs,
+			want:            "fbc",
+		},
+		{
+			name:    "UnknownCatalog",
+			catalog: "operators",
+			wantErr: true,
+		},
+		{
+			name:            "UnknownClusterCatalog",
+			catalog:         "missing",
+			clusterCatalogs: clusterCatalogs,
+			wantErr:         true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewCatalogRouter(namedSourcer("default"), map[string]Sourcer{"fbc": namedSourcer("fbc")}, tt.clusterCatalogs)
+			po := newTestPlatformOperator("foo")
+			if tt.catalog != "" {
+				po.SetAnnotations(map[string]string{platformtypes.AnnotationCatalog: tt.catalog})
+			}
+			got, err := r.Source(context.Background(), po)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Source() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got.Name != tt.want {
+				t.Errorf("Source() was routed to the %s catalog, want %s", got.Name, tt.want)
+			}
+		})
+	}
+
+	r := NewCatalogRouter(namedSourcer("default"), nil, clusterCatalogs)
+	po := newTestPlatformOperator("foo")
+	po.SetAnnotations(map[string]string{platformtypes.AnnotationCatalog: "operators"})
+	s, err := r.(*catalogRouter).route(context.Background(), po)
+	if err != nil || s != clusterCatalogs {
+		t.Errorf("route() = %v, %v, want the operators ClusterCatalog", s, err)
+	}
+}
@@ -2,17 +2,105 @@ package sourcer
 
 import (
 	"context"
+	"fmt"
+
+	"github.com/operator-framework/operator-registry/alpha/property"
 
 	platformv1alpha1 "github.com/openshift/api/platform/v1alpha1"
+	platformtypes "github.com/openshift/platform-operators/api/v1alpha1"
 )
 
 type Bundle struct {
+	Name     string
+	Package  string
+	Channel  string
 	Version  string
 	Image    string
 	Replaces string
 	Skips    []string
+	// SkipRange is the semver range of versions the bundle can be
+	// upgraded from directly, in addition to Replaces and Skips.
+	SkipRange string
+	// Properties are the olm.* properties declared by the bundle, e.g. the
+	// APIs it provides and the packages or APIs it depends on.
+	Properties []property.Property
+	// Deprecation holds what the catalog deprecated of the bundle.
+	Deprecation Deprecation
 }
 
 type Sourcer interface {
+	// Source returns the bundle that should be installed for the PlatformOperator.
 	Source(context.Context, *platformv1alpha1.PlatformOperator) (*Bundle, error)
+	// Candidates returns every bundle available for the PlatformOperator's package.
+	Candidates(context.Context, *platformv1alpha1.PlatformOperator) ([]Bundle, error)
+	// Providers returns the bundles, from the catalog selected by the
+	// PlatformOperator, that provide the GVK API.
+	Providers(context.Context, *platformv1alpha1.PlatformOperator, property.GVK) ([]Bundle, error)
+}
+
+// Revisioner is implemented by the Sourcers that can cheaply tell when the
+// contents of the catalog a PlatformOperator is sourced from change.
+type Revisioner interface {
+	// Revision returns a string identifying the current contents of the
+	// catalog the PlatformOperator is sourced from, or an empty string when
+	// they can't be identified.
+	Revision(context.Context, *platformv1alpha1.PlatformOperator) (string, error)
+}
+
+// Revision returns the revision of the catalog the s Sourcer sources the po
+// PlatformOperator from, or an empty string when s isn't a Revisioner.
+func Revision(ctx context.Context, s Sourcer, po *platformv1alpha1.PlatformOperator) (string, error) {
+	r, ok := s.(Revisioner)
+	if !ok {
+		return "", nil
+	}
+	return r.Revision(ctx, po)
+}
+
+// latestCandidate returns the highest versioned bundle from the candidates
+// that were sourced for the po PlatformOperator.
+func latestCandidate(po *platformv1alpha1.PlatformOperator, candidates bundles) (*Bundle, error) {
+	filters, err := PlatformOperatorFilters(po)
+	if err != nil {
+		return nil, err
+	}
+	candidates, _, err = candidates.Filter(filters...)
+	if err != nil {
+		return nil, err
+	}
+	// prefer the candidates that aren't deprecated over those that are.
+	candidates, _, err = candidates.Filter(DeprecationFilter(candidates))
+	if err != nil {
+		return nil, err
+	}
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("failed to find candidate olm.bundles from the %s package", po.Spec.Package.Name)
+	}
+	return candidates.Latest()
+}
+
+// PlatformOperatorFilters returns the filters that restrict the po
+// PlatformOperator's candidates to the channel and version range it's been
+// restricted to through annotations, and to valid versions that aren't
+// prereleases, unless po allows prereleases.
+func PlatformOperatorFilters(po *platformv1alpha1.PlatformOperator) ([]Filter, error) {
+	var filters []Filter
+	if channel, ok := po.GetAnnotations()[platformtypes.AnnotationChannel]; ok {
+		filters = append(filters, ChannelFilter(channel))
+	}
+	filters = append(filters, VersionPolicyFilter(AllowsPrereleases(po)))
+	if versionRange, ok := po.GetAnnotations()[platformtypes.AnnotationVersionRange]; ok {
+		f, err := VersionRangeFilter(versionRange)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, f)
+	}
+	return filters, nil
+}
+
+// AllowsPrereleases returns whether the po PlatformOperator opted into
+// prerelease versions.
+func AllowsPrereleases(po *platformv1alpha1.PlatformOperator) bool {
+	return po.GetAnnotations()[platformtypes.AnnotationAllowPrereleases] == "true"
 }
@@ -0,0 +1,80 @@
+package sourcer
+
+import (
+	"sort"
+
+	"github.com/blang/semver/v4"
+)
+
+// Updates returns the candidates the installed bundle can be upgraded to,
+// directly or by upgrading through other candidates, following the replaces,
+// skips and skipRange edges within each channel installed is in. Candidates
+// that are reachable through several channels are returned once, with the
+// first of those channels in name order. The updates are ordered from the
+// most to the least preferred by Compare.
+func Updates(installed Bundle, candidates []Bundle) []Bundle {
+	channels := make(map[string][]Bundle)
+	var installedChannels []string
+	for _, b := range candidates {
+		channels[b.Channel] = append(channels[b.Channel], b)
+		if b.Name == installed.Name {
+			installedChannels = append(installedChannels, b.Channel)
+		}
+	}
+	// the installed bundle may have been removed from the catalog, in
+	// which case the channel it was installed from is still followed.
+	if len(installedChannels) == 0 && installed.Channel != "" {
+		installedChannels = append(installedChannels, installed.Channel)
+	}
+	sort.Strings(installedChannels)
+
+	var (
+		updates []Bundle
+		seen    = map[string]bool{installed.Name: true}
+	)
+	for _, channel := range installedChannels {
+		var (
+			visited = map[string]bool{installed.Name: true}
+			queue   = []Bundle{installed}
+		)
+		for len(queue) > 0 {
+			from := queue[0]
+			queue = queue[1:]
+			for _, b := range channels[channel] {
+				if visited[b.Name] || !upgradesFrom(b, from) {
+					continue
+				}
+				visited[b.Name] = true
+				queue = append(queue, b)
+				if !seen[b.Name] {
+					seen[b.Name] = true
+					updates = append(updates, b)
+				}
+			}
+		}
+	}
+	sort.SliceStable(updates, func(i, j int) bool {
+		return Compare(updates[i], updates[j]) > 0
+	})
+	return updates
+}
+
+// upgradesFrom returns whether the from bundle can be upgraded directly to
+// the b bundle.
+func upgradesFrom(b, from Bundle) bool {
+	if upgrades(b, from.Name) {
+		return true
+	}
+	if b.SkipRange == "" {
+		return false
+	}
+	r, err := semver.ParseRange(b.SkipRange)
+	if err != nil {
+		return false
+	}
+	v, err := semver.Parse(from.Version)
+	if err != nil {
+		return false
+	}
+	return r(v)
+}
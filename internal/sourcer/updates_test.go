@@ -0,0 +1,58 @@
+package sourcer
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestUpdates(t *testing.T) {
+	candidates := []Bundle{
+		{Name: "foo.v1.0.0", Channel: "stable", Version: "1.0.0"},
+		{Name: "foo.v1.1.0", Channel: "stable", Version: "1.1.0", Replaces: "foo.v1.0.0"},
+		{Name: "foo.v1.2.0", Channel: "stable", Version: "1.2.0", Replaces: "foo.v1.1.0"},
+		{Name: "foo.v1.3.0", Channel: "stable", Version: "1.3.0", Replaces: "foo.v1.2.0", Skips: []string{"foo.v1.1.0"}},
+		{Name: "foo.v1.2.0", Channel: "fast", Version: "1.2.0"},
+		{Name: "foo.v2.0.0", Channel: "fast", Version: "2.0.0", SkipRange: ">=1.0.0 <2.0.0"},
+		{Name: "foo.v3.0.0", Channel: "candidate", Version: "3.0.0", SkipRange: ">=1.0.0 <3.0.0"},
+	}
+	tests := []struct {
+		name      string
+		installed Bundle
+		want      []string
+	}{
+		{
+			name:      "TransitiveReplaces",
+			installed: Bundle{Name: "foo.v1.0.0", Channel: "stable", Version: "1.0.0"},
+			want:      []string{"foo.v1.3.0", "foo.v1.2.0", "foo.v1.1.0"},
+		},
+		{
+			name:      "AcrossInstalledChannels",
+			installed: Bundle{Name: "foo.v1.2.0", Channel: "stable", Version: "1.2.0"},
+			want:      []string{"foo.v2.0.0", "foo.v1.3.0"},
+		},
+		{
+			name:      "Latest",
+			installed: Bundle{Name: "foo.v1.3.0", Channel: "stable", Version: "1.3.0"},
+		},
+		{
+			name:      "RemovedFromCatalog",
+			installed: Bundle{Name: "foo.v0.9.0", Channel: "candidate", Version: "0.9.0"},
+		},
+		{
+			name:      "RemovedFromCatalogWithSkipRange",
+			installed: Bundle{Name: "foo.v1.0.1", Channel: "candidate", Version: "1.0.1"},
+			want:      []string{"foo.v3.0.0"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, b := range Updates(tt.installed, candidates) {
+				got = append(got, b.Name)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Updates() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package sourcer
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/blang/semver/v4"
+)
+
+// VersionPolicyFilter rejects bundles whose version isn't valid semver, and
+// bundles with prerelease versions unless allowPrereleases is set.
+func VersionPolicyFilter(allowPrereleases bool) Filter {
+	return Filter{
+		Name: FilterPolicy,
+		Reject: func(b Bundle) (string, error) {
+			v, err := semver.Parse(b.Version)
+			if err != nil {
+				return fmt.Sprintf("its %q version isn't valid semver", b.Version), nil
+			}
+			if len(v.Pre) != 0 && !allowPrereleases {
+				return fmt.Sprintf("its %s version is a prerelease, and prereleases haven't been allowed", b.Version), nil
+			}
+			return "", nil
+		},
+	}
+}
+
+// Compare returns a positive number when the a bundle is preferred over the b
+// bundle, a negative number when b is preferred, and zero when they're the
+// same bundle. Bundles are ordered by version precedence, with unparsable
+// versions lowest. Equal versions are ordered by their build metadata, then
+// by the upgrade graph, where a bundle that replaces or skips the other is
+// higher, and finally by name, so the order never depends on the order the
+// catalog served the bundles in.
+func Compare(a, b Bundle) int {
+	va, erra := semver.Parse(a.Version)
+	vb, errb := semver.Parse(b.Version)
+	switch {
+	case erra == nil && errb != nil:
+		return 1
+	case erra != nil && errb == nil:
+		return -1
+	case erra == nil && errb == nil:
+		if c := va.Compare(vb); c != 0 {
+			return c
+		}
+		if c := compareBuild(va.Build, vb.Build); c != 0 {
+			return c
+		}
+	}
+	switch {
+	case upgrades(a, b.Name):
+		return 1
+	case upgrades(b, a.Name):
+		return -1
+	}
+	return strings.Compare(b.Name, a.Name)
+}
+
+// compareBuild orders build metadata identifiers like semver orders
+// prerelease identifiers: numeric identifiers numerically, others lexically,
+// and a longer list of otherwise equal identifiers higher.
+func compareBuild(a, b []string) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		na, erra := strconv.ParseUint(a[i], 10, 64)
+		nb, errb := strconv.ParseUint(b[i], 10, 64)
+		switch {
+		case erra == nil && errb == nil:
+			if na != nb {
+				if na > nb {
+					return 1
+				}
+				return -1
+			}
+		case erra == nil:
+			return -1
+		case errb == nil:
+			return 1
+		default:
+			if c := strings.Compare(a[i], b[i]); c != 0 {
+				return c
+			}
+		}
+	}
+	return len(a) - len(b)
+}
+
+// upgrades returns whether the b bundle replaces or skips the bundle named name.
+func upgrades(b Bundle, name string) bool {
+	if b.Replaces == name {
+		return true
+	}
+	for _, skip := range b.Skips {
+		if skip == name {
+			return true
+		}
+	}
+	return false
+}
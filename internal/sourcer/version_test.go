@@ -0,0 +1,127 @@
+package sourcer
+
+import (
+	"testing"
+)
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b Bundle
+		want int
+	}{
+		{
+			name: "HigherVersion",
+			a:    Bundle{Name: "foo.v1.1.0", Version: "1.1.0"},
+			b:    Bundle{Name: "foo.v1.0.0", Version: "1.0.0"},
+			want: 1,
+		},
+		{
+			name: "ReleaseOverPrerelease",
+			a:    Bundle{Name: "foo.v1.0.0-rc.1", Version: "1.0.0-rc.1"},
+			b:    Bundle{Name: "foo.v1.0.0", Version: "1.0.0"},
+			want: -1,
+		},
+		{
+			name: "ValidOverInvalid",
+			a:    Bundle{Name: "foo.latest", Version: "latest"},
+			b:    Bundle{Name: "foo.v0.0.1", Version: "0.0.1"},
+			want: -1,
+		},
+		{
+			name: "NumericBuildMetadata",
+			a:    Bundle{Name: "foo.v1.0.0-10", Version: "1.0.0+10"},
+			b:    Bundle{Name: "foo.v1.0.0-9", Version: "1.0.0+9"},
+			want: 1,
+		},
+		{
+			name: "LongerBuildMetadata",
+			a:    Bundle{Name: "foo.v1.0.0-a", Version: "1.0.0+build"},
+			b:    Bundle{Name: "foo.v1.0.0-b", Version: "1.0.0+build.1"},
+			want: -1,
+		},
+		{
+			name: "ReplacesOnEqualVersion",
+			a:    Bundle{Name: "foo.v1.0.0-b", Version: "1.0.0"},
+			b:    Bundle{Name: "foo.v1.0.0-a", Version: "1.0.0", Replaces: "foo.v1.0.0-b"},
+			want: -1,
+		},
+		{
+			name: "SkipsOnEqualVersion",
+			a:    Bundle{Name: "foo.v1.0.0-b", Version: "1.0.0", Skips: []string{"foo.v1.0.0-a"}},
+			b:    Bundle{Name: "foo.v1.0.0-a", Version: "1.0.0"},
+			want: 1,
+		},
+		{
+			name: "NameOnEqualVersion",
+			a:    Bundle{Name: "foo.v1.0.0-a", Version: "1.0.0"},
+			b:    Bundle{Name: "foo.v1.0.0-b", Version: "1.0.0"},
+			want: 1,
+		},
+	}
+	sign := func(n int) int {
+		switch {
+		case n > 0:
+			return 1
+		case n < 0:
+			return -1
+		}
+		return 0
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sign(Compare(tt.a, tt.b)); got != tt.want {
+				t.Errorf("Compare(%s, %s) = %d, want %d", tt.a.Name, tt.b.Name, got, tt.want)
+			}
+			if got := sign(Compare(tt.b, tt.a)); got != -tt.want {
+				t.Errorf("Compare(%s, %s) = %d, want %d", tt.b.Name, tt.a.Name, got, -tt.want)
+			}
+		})
+	}
+}
+
+func TestLatestIgnoresOrder(t *testing.T) {
+	candidates := bundles{
+		{Name: "foo.latest", Version: "latest"},
+		{Name: "foo.v1.0.0-b", Version: "1.0.0"},
+		{Name: "foo.v1.0.0-a", Version: "1.0.0"},
+		{Name: "foo.v0.9.0", Version: "0.9.0"},
+	}
+	for i := range candidates {
+		rotated := append(append(bundles{}, candidates[i:]...), candidates[:i]...)
+		got, err := rotated.Latest()
+		if err != nil {
+			t.Fatalf("Latest() unexpected error: %v", err)
+		}
+		if got.Name != "foo.v1.0.0-a" {
+			t.Errorf("Latest() with %s first = %s, want foo.v1.0.0-a", rotated[0].Name, got.Name)
+		}
+	}
+}
+
+func TestVersionPolicyFilter(t *testing.T) {
+	tests := []struct {
+		name             string
+		version          string
+		allowPrereleases bool
+		wantRejected     bool
+	}{
+		{name: "Release", version: "1.0.0"},
+		{name: "BuildMetadata", version: "1.0.0+build.1"},
+		{name: "Prerelease", version: "1.0.0-rc.1", wantRejected: true},
+		{name: "AllowedPrerelease", version: "1.0.0-rc.1", allowPrereleases: true},
+		{name: "Invalid", version: "v1", wantRejected: true},
+		{name: "InvalidWithPrereleases", version: "", allowPrereleases: true, wantRejected: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reason, err := VersionPolicyFilter(tt.allowPrereleases).Reject(Bundle{Name: "foo", Version: tt.version})
+			if err != nil {
+				t.Fatalf("Reject() unexpected error: %v", err)
+			}
+			if (reason != "") != tt.wantRejected {
+				t.Errorf("Reject() = %q, want rejected %v", reason, tt.wantRejected)
+			}
+		})
+	}
+}
@@ -4,9 +4,11 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"strings"
 
 	configv1 "github.com/openshift/api/config/v1"
 	rukpakv1alpha2 "github.com/operator-framework/rukpak/api/v1alpha2"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
@@ -50,10 +52,11 @@ func RequeuePlatformOperators(cl client.Client) handler.MapFunc {
 	}
 }
 
-func RequeueBundleDeployment(c client.Client) handler.MapFunc {
+// RequeueOwnerPlatformOperator requeues the PlatformOperators that own the
+// installation object, e.g. a BundleDeployment or ClusterExtension, that
+// triggered the event.
+func RequeueOwnerPlatformOperator(c client.Client) handler.MapFunc {
 	return func(ctx context.Context, obj client.Object) []reconcile.Request {
-		bi := obj.(*rukpakv1alpha2.BundleDeployment)
-
 		poList := &platformv1alpha1.PlatformOperatorList{}
 		if err := c.List(context.Background(), poList); err != nil {
 			return nil
@@ -63,7 +66,7 @@ func RequeueBundleDeployment(c client.Client) handler.MapFunc {
 		for _, po := range poList.Items {
 			po := po
 
-			for _, ref := range bi.GetOwnerReferences() {
+			for _, ref := range obj.GetOwnerReferences() {
 				if ref.Name == po.GetName() {
 					requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&po)})
 				}
@@ -73,6 +76,54 @@ func RequeueBundleDeployment(c client.Client) handler.MapFunc {
 	}
 }
 
+// RequeueInstallingPlatformOperator requeues the PlatformOperator that
+// controls the installation object which installed the object that triggered
+// the event. The installation object, of newInstallation's type, is named by
+// the object's ownerLabel label.
+func RequeueInstallingPlatformOperator(c client.Reader, newInstallation func() client.Object, ownerLabel string) handler.MapFunc {
+	return func(ctx context.Context, obj client.Object) []reconcile.Request {
+		name, ok := obj.GetLabels()[ownerLabel]
+		if !ok || name == "" {
+			return nil
+		}
+		installation := newInstallation()
+		if err := c.Get(ctx, types.NamespacedName{Name: name}, installation); err != nil {
+			return nil
+		}
+		ref := metav1.GetControllerOf(installation)
+		if ref == nil {
+			return nil
+		}
+		return []reconcile.Request{{NamespacedName: types.NamespacedName{Name: ref.Name}}}
+	}
+}
+
+// RequeueDependentPlatformOperators requeues the PlatformOperators that are
+// waiting on their dependencies to be installed, whenever another
+// PlatformOperator changes.
+func RequeueDependentPlatformOperators(c client.Client) handler.MapFunc {
+	return func(ctx context.Context, obj client.Object) []reconcile.Request {
+		poList := &platformv1alpha1.PlatformOperatorList{}
+		if err := c.List(context.Background(), poList); err != nil {
+			return nil
+		}
+
+		var requests []reconcile.Request
+		for _, po := range poList.Items {
+			po := po
+			if po.GetName() == obj.GetName() {
+				continue
+			}
+			dependencies := meta.FindStatusCondition(po.Status.Conditions, platformtypes.TypeDependencies)
+			if dependencies == nil || dependencies.Reason != platformtypes.ReasonDependenciesPending {
+				continue
+			}
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&po)})
+		}
+		return requests
+	}
+}
+
 func RequeueClusterOperator(c client.Client, name string) handler.MapFunc {
 	return func(ctx context.Context, obj client.Object) []reconcile.Request {
 		co := &configv1.ClusterOperator{}
@@ -109,9 +160,31 @@ func inspectPlatformOperator(po platformv1alpha1.PlatformOperator) error {
 	if installed.Status != metav1.ConditionTrue {
 		return buildPOFailureMessage(po.GetName(), installed.Reason)
 	}
+	// workloads that are still rolling out, and report an Unknown status,
+	// aren't failures yet.
+	available := meta.FindStatusCondition(po.Status.Conditions, platformtypes.TypeAvailable)
+	if available != nil && available.Status == metav1.ConditionFalse {
+		return buildPOFailureMessage(po.GetName(), available.Reason)
+	}
 	return nil
 }
 
+// DeprecatedPlatformOperators returns a summary of the POs in the list whose
+// package, channel or bundle was deprecated by their catalog, or an empty
+// string when there are none.
+func DeprecatedPlatformOperators(poList *platformv1alpha1.PlatformOperatorList) string {
+	var deprecated []string
+	for _, po := range poList.Items {
+		if meta.IsStatusConditionTrue(po.Status.Conditions, platformtypes.TypeDeprecated) {
+			deprecated = append(deprecated, po.GetName())
+		}
+	}
+	if len(deprecated) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("The %s platform operators use deprecated content", strings.Join(deprecated, ", "))
+}
+
 func buildPOFailureMessage(name, reason string) error {
 	return fmt.Errorf("encountered the failing %s platform operator with reason %q", name, reason)
 }
@@ -158,3 +231,86 @@ func InspectBundleDeployment(_ context.Context, conditions []metav1.Condition) *
 	}
 	return nil
 }
+
+const (
+	clusterExtensionTypeInstalled   = "Installed"
+	clusterExtensionTypeProgressing = "Progressing"
+	clusterExtensionReasonRetrying  = "Retrying"
+)
+
+// InspectClusterExtension is responsible for inspecting an individual OLM v1
+// ClusterExtension resource, and verifying whether its bundle has been
+// successfully installed. In the case that the ClusterExtension is reporting
+// a successful status, a nil metav1.Condition will be returned.
+func InspectClusterExtension(_ context.Context, conditions []metav1.Condition) *metav1.Condition {
+	installed := meta.FindStatusCondition(conditions, clusterExtensionTypeInstalled)
+	if installed != nil && installed.Status == metav1.ConditionTrue {
+		return nil
+	}
+
+	// OLM v1 reports retryable installation errors through the Progressing
+	// condition, while the Installed condition still reflects the last
+	// successful installation, or lack thereof.
+	progressing := meta.FindStatusCondition(conditions, clusterExtensionTypeProgressing)
+	if progressing != nil && progressing.Reason == clusterExtensionReasonRetrying {
+		return &metav1.Condition{
+			Type:    platformtypes.TypeInstalled,
+			Status:  metav1.ConditionFalse,
+			Reason:  platformtypes.ReasonInstallFailed,
+			Message: progressing.Message,
+		}
+	}
+	if installed == nil {
+		return &metav1.Condition{
+			Type:    platformtypes.TypeInstalled,
+			Status:  metav1.ConditionFalse,
+			Reason:  platformtypes.ReasonInstallPending,
+			Message: "Waiting for the ClusterExtension to be installed",
+		}
+	}
+	return &metav1.Condition{
+		Type:    platformtypes.TypeInstalled,
+		Status:  metav1.ConditionFalse,
+		Reason:  platformtypes.ReasonInstallPending,
+		Message: installed.Message,
+	}
+}
+
+// DiagnoseUnpackPod summarizes why the pod that unpacks a bundle image hasn't
+// succeeded, from its container statuses, e.g. image pull errors or failed
+// containers, and the tail of its logs, or returns an empty string when
+// there's nothing to report.
+func DiagnoseUnpackPod(pod *corev1.Pod, logs string) string {
+	var diagnostics []string
+	statuses := append(append([]corev1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...)
+	for _, status := range statuses {
+		switch {
+		case status.State.Waiting != nil && status.State.Waiting.Reason != "" && status.State.Waiting.Reason != "PodInitializing" && status.State.Waiting.Reason != "ContainerCreating":
+			diagnostics = append(diagnostics, containerDiagnostic(status.Name, status.State.Waiting.Reason, status.State.Waiting.Message))
+		case status.State.Terminated != nil && status.State.Terminated.ExitCode != 0:
+			reason := fmt.Sprintf("%s with exit code %d", status.State.Terminated.Reason, status.State.Terminated.ExitCode)
+			diagnostics = append(diagnostics, containerDiagnostic(status.Name, reason, status.State.Terminated.Message))
+		}
+	}
+	if len(diagnostics) == 0 {
+		for _, c := range pod.Status.Conditions {
+			if c.Type == corev1.PodScheduled && c.Status == corev1.ConditionFalse {
+				diagnostics = append(diagnostics, fmt.Sprintf("the pod can't be scheduled: %s", c.Message))
+			}
+		}
+	}
+	if logs = strings.TrimSpace(logs); logs != "" {
+		diagnostics = append(diagnostics, fmt.Sprintf("the pod logs end with %q", logs))
+	}
+	if len(diagnostics) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("the %s/%s unpack pod is %s: %s", pod.GetNamespace(), pod.GetName(), pod.Status.Phase, strings.Join(diagnostics, "; "))
+}
+
+func containerDiagnostic(name, reason, message string) string {
+	if message == "" {
+		return fmt.Sprintf("the %s container is %s", name, reason)
+	}
+	return fmt.Sprintf("the %s container is %s: %s", name, reason, message)
+}
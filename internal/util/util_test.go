@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	rukpakv1alpha2 "github.com/operator-framework/rukpak/api/v1alpha2"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	platformv1alpha1 "github.com/openshift/api/platform/v1alpha1"
@@ -99,6 +100,50 @@ func Test_inspectPlatformOperator(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "WorkloadsUnavailable",
+			args: args{
+				po: platformv1alpha1.PlatformOperator{
+					Status: platformv1alpha1.PlatformOperatorStatus{
+						Conditions: []metav1.Condition{
+							{
+								Type:   platformtypes.TypeInstalled,
+								Status: metav1.ConditionTrue,
+								Reason: platformtypes.ReasonInstallSuccessful,
+							},
+							{
+								Type:   platformtypes.TypeAvailable,
+								Status: metav1.ConditionFalse,
+								Reason: platformtypes.ReasonCrashLoopBackOff,
+							},
+						},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "WorkloadsProgressing",
+			args: args{
+				po: platformv1alpha1.PlatformOperator{
+					Status: platformv1alpha1.PlatformOperatorStatus{
+						Conditions: []metav1.Condition{
+							{
+								Type:   platformtypes.TypeInstalled,
+								Status: metav1.ConditionTrue,
+								Reason: platformtypes.ReasonInstallSuccessful,
+							},
+							{
+								Type:   platformtypes.TypeAvailable,
+								Status: metav1.ConditionUnknown,
+								Reason: platformtypes.ReasonWorkloadsProgressing,
+							},
+						},
+					},
+				},
+			},
+			wantErr: false,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -228,6 +273,82 @@ func TestInspectBundleDeployment(t *testing.T) {
 	}
 }
 
+func TestInspectClusterExtension(t *testing.T) {
+	tests := []struct {
+		name       string
+		conditions []metav1.Condition
+		want       *metav1.Condition
+	}{
+		{
+			name: "InstallSucceeded",
+			conditions: []metav1.Condition{
+				{
+					Type:   "Installed",
+					Status: metav1.ConditionTrue,
+					Reason: "Succeeded",
+				},
+				{
+					Type:   "Progressing",
+					Status: metav1.ConditionTrue,
+					Reason: "Succeeded",
+				},
+			},
+			want: nil,
+		},
+		{
+			name:       "InstalledNil",
+			conditions: []metav1.Condition{},
+			want: &metav1.Condition{
+				Type:   platformtypes.TypeInstalled,
+				Status: metav1.ConditionFalse,
+				Reason: platformtypes.ReasonInstallPending,
+			},
+		},
+		{
+			name: "InstallRetrying",
+			conditions: []metav1.Condition{
+				{
+					Type:   "Installed",
+					Status: metav1.ConditionFalse,
+					Reason: "Failed",
+				},
+				{
+					Type:   "Progressing",
+					Status: metav1.ConditionTrue,
+					Reason: "Retrying",
+				},
+			},
+			want: &metav1.Condition{
+				Type:   platformtypes.TypeInstalled,
+				Status: metav1.ConditionFalse,
+				Reason: platformtypes.ReasonInstallFailed,
+			},
+		},
+		{
+			name: "NotYetInstalled",
+			conditions: []metav1.Condition{
+				{
+					Type:   "Installed",
+					Status: metav1.ConditionFalse,
+					Reason: "Failed",
+				},
+			},
+			want: &metav1.Condition{
+				Type:   platformtypes.TypeInstalled,
+				Status: metav1.ConditionFalse,
+				Reason: platformtypes.ReasonInstallPending,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := InspectClusterExtension(context.Background(), tt.conditions); !conditionsAreEqual(got, tt.want) {
+				t.Errorf("name = %s, InspectClusterExtension() = %v, want %v", tt.name, got, tt.want)
+			}
+		})
+	}
+}
+
 func conditionsAreEqual(a, b *metav1.Condition) bool {
 	if a == nil && b == nil {
 		return true
@@ -240,3 +361,116 @@ func conditionsAreEqual(a, b *metav1.Condition) bool {
 	}
 	return a.Type == b.Type && a.Status == b.Status && a.Reason == b.Reason
 }
+
+func TestDiagnoseUnpackPod(t *testing.T) {
+	newPod := func(status corev1.PodStatus) *corev1.Pod {
+		pod := &corev1.Pod{Status: status}
+		pod.SetNamespace("openshift-rukpak")
+		pod.SetName("foo")
+		return pod
+	}
+	tests := []struct {
+		name string
+		pod  *corev1.Pod
+		logs string
+		want string
+	}{
+		{
+			name: "Starting",
+			pod: newPod(corev1.PodStatus{
+				Phase: corev1.PodPending,
+				ContainerStatuses: []corev1.ContainerStatus{{
+					Name:  "unpack",
+					State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "ContainerCreating"}},
+				}},
+			}),
+		},
+		{
+			name: "ImagePullBackOff",
+			pod: newPod(corev1.PodStatus{
+				Phase: corev1.PodPending,
+				ContainerStatuses: []corev1.ContainerStatus{{
+					Name: "unpack",
+					State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{
+						Reason:  "ImagePullBackOff",
+						Message: `Back-off pulling image "quay.io/example/foo-bundle:v1.0.0"`,
+					}},
+				}},
+			}),
+			want: `the openshift-rukpak/foo unpack pod is Pending: the unpack container is ImagePullBackOff: Back-off pulling image "quay.io/example/foo-bundle:v1.0.0"`,
+		},
+		{
+			name: "Unschedulable",
+			pod: newPod(corev1.PodStatus{
+				Phase: corev1.PodPending,
+				Conditions: []corev1.PodCondition{{
+					Type:    corev1.PodScheduled,
+					Status:  corev1.ConditionFalse,
+					Message: "0/3 nodes are available",
+				}},
+			}),
+			want: "the openshift-rukpak/foo unpack pod is Pending: the pod can't be scheduled: 0/3 nodes are available",
+		},
+		{
+			name: "ContainerFailed",
+			pod: newPod(corev1.PodStatus{
+				Phase: corev1.PodFailed,
+				ContainerStatuses: []corev1.ContainerStatus{{
+					Name:  "unpack",
+					State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Reason: "Error", ExitCode: 1}},
+				}},
+			}),
+			logs: "reading manifests\nmanifests/foo.yaml: invalid YAML\n",
+			want: `the openshift-rukpak/foo unpack pod is Failed: the unpack container is Error with exit code 1; the pod logs end with "reading manifests\nmanifests/foo.yaml: invalid YAML"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DiagnoseUnpackPod(tt.pod, tt.logs); got != tt.want {
+				t.Errorf("DiagnoseUnpackPod() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeprecatedPlatformOperators(t *testing.T) {
+	newPO := func(name string, status metav1.ConditionStatus) platformv1alpha1.PlatformOperator {
+		po := platformv1alpha1.PlatformOperator{}
+		po.SetName(name)
+		po.Status.Conditions = []metav1.Condition{{
+			Type:   platformtypes.TypeDeprecated,
+			Status: status,
+		}}
+		return po
+	}
+	tests := []struct {
+		name string
+		pos  []platformv1alpha1.PlatformOperator
+		want string
+	}{
+		{
+			name: "NoPlatformOperators",
+		},
+		{
+			name: "NoneDeprecated",
+			pos:  []platformv1alpha1.PlatformOperator{newPO("foo", metav1.ConditionFalse), {}},
+		},
+		{
+			name: "SomeDeprecated",
+			pos: []platformv1alpha1.PlatformOperator{
+				newPO("foo", metav1.ConditionTrue),
+				newPO("bar", metav1.ConditionFalse),
+				newPO("baz", metav1.ConditionTrue),
+			},
+			want: "The foo, baz platform operators use deprecated content",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DeprecatedPlatformOperators(&platformv1alpha1.PlatformOperatorList{Items: tt.pos})
+			if got != tt.want {
+				t.Errorf("DeprecatedPlatformOperators() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}